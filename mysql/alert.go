@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"time"
+)
+
+// DirtyAlert describes a migration run that left the database dirty, see WithDirtyHandler.
+type DirtyAlert struct {
+	Database  string `json:"database"`
+	Namespace string `json:"namespace,omitempty"`
+	// Version is the dirty schema version.
+	Version uint64 `json:"version"`
+	// AppID identifies the application that applied the migrations, see WithNotificationAppID.
+	AppID     string    `json:"app_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Failure contains the diagnostics of the failed migration, if available.
+	Failure *MigrationFailure `json:"failure,omitempty"`
+}
+
+// DirtyHandler is called after a migration run that left the database dirty, once the migration lock was released.
+// Errors are logged.
+type DirtyHandler func(ctx context.Context, alert DirtyAlert) error
+
+// WithDirtyHandler adds a handler that is called whenever a run leaves the database dirty, e.g. to page the
+// on-call engineer with the diagnostics of the failed migration.
+func WithDirtyHandler(handler DirtyHandler) DriverOption {
+	return func(d *driver) {
+		d.dirtyHandlers = append(d.dirtyHandlers, handler)
+	}
+}
+
+// WithDirtyWebhook posts the DirtyAlert as JSON to the URL whenever a run leaves the database dirty.
+func WithDirtyWebhook(url string) DriverOption {
+	return func(d *driver) {
+		d.cfg.DirtyWebhookURL = url
+	}
+}
+
+// alertDirty sends the alerts for a run that left the database dirty. Failed alerts are logged.
+func (d *driver) alertDirty(version uint64, failure *MigrationFailure) {
+	handlers := d.dirtyHandlers
+	if d.cfg.DirtyWebhookURL != "" {
+		handlers = append([]DirtyHandler{d.alertWebhook}, handlers...)
+	}
+	if len(handlers) == 0 {
+		return
+	}
+
+	alert := DirtyAlert{
+		Database:  d.cfg.DatabaseName,
+		Namespace: d.cfg.Namespace,
+		Version:   version,
+		AppID:     d.cfg.NotificationAppID,
+		Timestamp: time.Now(),
+		Failure:   failure,
+	}
+	timeout := d.cfg.QueryTimeout
+	if timeout <= 0 {
+		timeout = defaultNotifyTimeout
+	}
+	for _, handler := range handlers {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := handler(ctx, alert); err != nil {
+			d.logf("failed to send dirty alert for version %d: %v", alert.Version, err)
+		}
+		cancel()
+	}
+}
+
+// alertWebhook posts the alert to the dirty webhook URL.
+func (d *driver) alertWebhook(ctx context.Context, alert DirtyAlert) error {
+	return postJSON(ctx, d.cfg.DirtyWebhookURL, alert)
+}
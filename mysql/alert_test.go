@@ -0,0 +1,69 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_driver_Unlock_DirtyAlert(t *testing.T) {
+	var received []DirtyAlert
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert DirtyAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		received = append(received, alert)
+	}))
+	defer webhook.Close()
+
+	errSyntax := errors.New("syntax error")
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "ALTER TABLE") {
+			return fakeResponse{Err: errSyntax}
+		}
+		return defaultFakeHandler(call)
+	})
+	var alerted []DirtyAlert
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations", SplitStatements: true,
+		DirtyWebhookURL: webhook.URL}}
+	WithDirtyHandler(func(_ context.Context, alert DirtyAlert) error {
+		alerted = append(alerted, alert)
+		return nil
+	})(d)
+
+	// a successful run is not alerted
+	for _, dirty := range []bool{true, false} {
+		if err := d.SetVersion(4, dirty); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(alerted) != 0 {
+		t.Fatalf("unexpected alerts: %+v", alerted)
+	}
+
+	if err := d.SetVersion(5, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.RunMigration(strings.NewReader("SELECT 1;\nALTER TABLE a ADD b int;")); !errors.Is(err, errSyntax) {
+		t.Fatalf("expected error %v, got: %v", errSyntax, err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(alerted) != 1 || alerted[0].Version != 5 || alerted[0].Database != "testdb" || alerted[0].Failure == nil ||
+		alerted[0].Failure.StatementIndex != 2 || !strings.Contains(alerted[0].Failure.Message, "syntax error") {
+		t.Fatalf("unexpected alerts: %+v", alerted)
+	}
+	if len(received) != 1 || received[0].Version != 5 || received[0].Failure == nil {
+		t.Fatalf("unexpected webhook alerts: %+v", received)
+	}
+}
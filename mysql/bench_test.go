@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"os"
+	"strings"
+	"testing"
+)
+
+// benchmarkDSNVariable names the environment variable with the DSN of the MySQL database used by the benchmarks,
+// e.g. "root:secret@tcp(127.0.0.1:3306)/bench". The benchmarks are skipped if it is not set.
+const benchmarkDSNVariable = "LIGHTMIGRATE_MYSQL_DSN"
+
+// benchmarkDriver connects to the benchmark database, the benchmark tables are dropped afterwards.
+func benchmarkDriver(b *testing.B, opts ...DriverOption) *driver {
+	dsn := os.Getenv(benchmarkDSNVariable)
+	if dsn == "" {
+		b.Skipf("%s is not set", benchmarkDSNVariable)
+	}
+
+	drv, err := NewDriverFromDSN(dsn, append([]DriverOption{WithMigrationTable("bench_migrations")}, opts...)...)
+	if err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+	d := drv.(*driver)
+	b.Cleanup(func() {
+		for _, table := range []string{d.migrationsTable(), d.historyTable(), "bench"} {
+			if _, err := d.client.Exec("DROP TABLE IF EXISTS `" + table + "`"); err != nil {
+				b.Errorf("failed to drop table %s: %v", table, err)
+			}
+		}
+		_ = d.Close()
+	})
+	return d
+}
+
+// Benchmark_driver_SetVersion reports the queries of a version update, each of them is a round trip to the server.
+func Benchmark_driver_SetVersion(b *testing.B) {
+	db, srv := newFakeDB(b, versionHandler([]sqldriver.Value{int64(1), int64(0), nil, nil, nil}))
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations"}, statements: newStatementCache()}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := d.SetVersion(uint64(i+1), false); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+	b.ReportMetric(float64(len(srv.Queries()))/float64(b.N), "queries/op")
+}
+
+func Benchmark_driver_GetVersion_MySQL(b *testing.B) {
+	d := benchmarkDriver(b)
+	if err := d.SetVersion(1, false); err != nil {
+		b.Fatalf("unexpected error: %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := d.GetVersion(); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func Benchmark_driver_SetVersion_MySQL(b *testing.B) {
+	d := benchmarkDriver(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := d.SetVersion(uint64(i/2+1), i%2 == 0); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+// Benchmark_driver_RunMigration_MySQL measures a complete version step of the migrator: dirty version, migration, clean version.
+func Benchmark_driver_RunMigration_MySQL(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		opts []DriverOption
+	}{
+		{"default", nil},
+		{"history", []DriverOption{WithHistory(true)}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			d := benchmarkDriver(b, bm.opts...)
+			if _, err := d.client.Exec("CREATE TABLE IF NOT EXISTS bench (id int)"); err != nil {
+				b.Fatalf("unexpected error: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				version := uint64(i + 1)
+				if err := d.SetVersion(version, true); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				if err := d.RunMigration(strings.NewReader("INSERT INTO bench VALUES (1);")); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				if err := d.SetVersion(version, false); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
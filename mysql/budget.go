@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// MigrationTimeoutError is returned if a migration exceeded the budget of its "-- lightmigrate:max-duration"
+// directive. The version stays dirty, the statements before Statement were applied.
+type MigrationTimeoutError struct {
+	// Version is the migration that exceeded its budget.
+	Version uint64
+	// Budget is the maximum duration of the migration.
+	Budget time.Duration
+	// Statement is the index of the statement that was interrupted or not started.
+	Statement int
+	// Interrupted is set if the running statement was stopped with KILL QUERY.
+	Interrupted bool
+	// Err is the error of the interrupted statement, if any.
+	Err error
+}
+
+// Error implements error interface.
+func (e *MigrationTimeoutError) Error() string {
+	msg := fmt.Sprintf("migration %d exceeded its budget of %v", e.Version, e.Budget)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error of the interrupted statement.
+func (e *MigrationTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrMigrationBudgetExceeded.
+func (e *MigrationTimeoutError) Is(target error) bool {
+	return target == ErrMigrationBudgetExceeded
+}
+
+// maxDuration handles the max-duration directive, which sets the budget of the migration.
+func (d *driver) maxDuration(state *migrationState, index int, arg string, line int) error {
+	if index > 0 {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrMisplacedDirective,
+			Msg:     "the max-duration directive must precede the first statement",
+			Line:    uint(line),
+		}
+	}
+
+	budget, err := time.ParseDuration(arg)
+	if err != nil || budget <= 0 {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrInvalidDirective,
+			Msg:     fmt.Sprintf("invalid max-duration %q, expected a positive duration like 10m", arg),
+			Line:    uint(line),
+		}
+	}
+
+	state.Budget = budget
+	state.BudgetDeadline = time.Now().Add(budget)
+	return nil
+}
+
+// checkBudget stops a migration before the given statement, if its budget is used up.
+func (d *driver) checkBudget(state *migrationState, stmt statement) error {
+	if state.BudgetDeadline.IsZero() || time.Now().Before(state.BudgetDeadline) {
+		return nil
+	}
+	state.Failed = &stmt
+	return d.budgetError(state, stmt, false, nil)
+}
+
+// budgetError reports that the migration exceeded its budget at the given statement.
+func (d *driver) budgetError(state *migrationState, stmt statement, interrupted bool, err error) error {
+	when := "before"
+	if interrupted {
+		when = "during"
+	}
+	return stmt.error(fmt.Sprintf("max-duration of %v exceeded %s statement %d, %d statements of the migration "+
+		"were applied", state.Budget, when, stmt.Index, state.Result.result.Statements), &MigrationTimeoutError{
+		Version:     d.runningVersion,
+		Budget:      state.Budget,
+		Statement:   stmt.Index,
+		Interrupted: interrupted,
+		Err:         err,
+	})
+}
@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func Test_driver_RunMigration_MaxDuration(t *testing.T) {
+	errInterrupted := errors.New("query execution was interrupted")
+	killed := make(chan struct{})
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case call.Query == "SELECT CONNECTION_ID()":
+			return fakeResponse{Columns: []string{"id"}, Rows: [][]sqldriver.Value{{int64(call.ConnID)}}}
+		case strings.HasPrefix(call.Query, "KILL QUERY"):
+			close(killed)
+		case strings.HasPrefix(call.Query, "ALTER TABLE"):
+			<-killed
+			return fakeResponse{Err: errInterrupted}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+
+	err := d.RunMigration(strings.NewReader("-- lightmigrate:max-duration=50ms\nSELECT 1;\nALTER TABLE a ADD b int;"))
+	var timeoutErr *MigrationTimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, ErrMigrationBudgetExceeded) || !errors.Is(err, errInterrupted) {
+		t.Fatalf("expected error %v, got: %v", ErrMigrationBudgetExceeded, err)
+	}
+	if !timeoutErr.Interrupted || timeoutErr.Statement != 2 {
+		t.Fatalf("unexpected timeout error: %+v", timeoutErr)
+	}
+
+	var session, kill fakeCall
+	for _, call := range srv.Calls() {
+		switch {
+		case strings.HasPrefix(call.Query, "ALTER TABLE"):
+			session = call
+		case strings.HasPrefix(call.Query, "KILL QUERY"):
+			kill = call
+		}
+	}
+	if kill.Query != fmt.Sprintf("KILL QUERY %d", session.ConnID) || kill.ConnID == session.ConnID {
+		t.Fatalf("statement must be interrupted from a separate connection: %+v, %+v", session, kill)
+	}
+}
+
+func Test_driver_RunMigration_MaxDurationInvalid(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+
+	if err := d.RunMigration(strings.NewReader("-- lightmigrate:max-duration=soon\nSELECT 1;")); !errors.Is(err,
+		ErrInvalidDirective) {
+		t.Fatalf("expected error %v, got: %v", ErrInvalidDirective, err)
+	}
+	if err := d.RunMigration(strings.NewReader("SELECT 1;\n-- lightmigrate:max-duration 1m\nSELECT 2;")); !errors.Is(
+		err, ErrMisplacedDirective) {
+		t.Fatalf("expected error %v, got: %v", ErrMisplacedDirective, err)
+	}
+}
@@ -0,0 +1,96 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultSessionCharset is the character set of the migration sessions, see WithSessionCharset.
+const DefaultSessionCharset = "utf8mb4"
+
+// charsetNamePattern matches the names of character sets, e.g. utf8mb4 or latin1.
+var charsetNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,64}$`)
+
+// connectionCharset is the character set configuration of a connection, before it was pinned by pinCharset.
+type connectionCharset struct {
+	Client     string
+	Connection string
+	Collation  string
+}
+
+// pinnedCharsets remembers the original character sets of the sessions that were changed by pinCharset.
+type pinnedCharsets struct {
+	mux      sync.Mutex
+	sessions map[*sql.Conn]connectionCharset
+}
+
+func newPinnedCharsets() *pinnedCharsets {
+	return &pinnedCharsets{sessions: map[*sql.Conn]connectionCharset{}}
+}
+
+// WithSessionCharset sets character_set_client and character_set_connection of the sessions that execute the
+// migrations. Defaults to utf8mb4, so that 4-byte UTF-8 characters (e.g. emoji in seed data) are not mangled by a
+// utf8mb3 server or connection default. The settings of the connection are restored after each migration. An empty
+// charset keeps the settings of the connection.
+func WithSessionCharset(charset string) DriverOption {
+	return func(d *driver) {
+		d.cfg.SessionCharset = charset
+	}
+}
+
+// pinCharset sets the configured character set on the migration session, if the session uses another one. The
+// original settings are remembered and restored by restoreCharset, unless the driver was not created by NewDriver.
+func (d *driver) pinCharset(ctx context.Context, conn *sql.Conn) error {
+	charset := d.cfg.SessionCharset
+	if charset == "" {
+		return nil
+	}
+
+	query := "SELECT @@SESSION.character_set_client, @@SESSION.character_set_connection, " +
+		"@@SESSION.collation_connection"
+	var original connectionCharset
+	if err := conn.QueryRowContext(ctx, query).Scan(&original.Client, &original.Connection,
+		&original.Collation); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read session charset", Query: []byte(query)}
+	}
+	if strings.EqualFold(original.Client, charset) && strings.EqualFold(original.Connection, charset) {
+		return nil
+	}
+
+	query = "SET SESSION character_set_client = " + charset + ", character_set_connection = " + charset
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to set session charset", Query: []byte(query)}
+	}
+
+	if d.charsets != nil {
+		d.charsets.mux.Lock()
+		d.charsets.sessions[conn] = original
+		d.charsets.mux.Unlock()
+	}
+	return nil
+}
+
+// restoreCharset restores the character set of a session that was changed by pinCharset.
+func (d *driver) restoreCharset(ctx context.Context, conn *sql.Conn) {
+	if d.charsets == nil {
+		return
+	}
+	d.charsets.mux.Lock()
+	original, ok := d.charsets.sessions[conn]
+	delete(d.charsets.sessions, conn)
+	d.charsets.mux.Unlock()
+	if !ok {
+		return
+	}
+
+	query := "SET SESSION character_set_client = " + original.Client + ", character_set_connection = " +
+		original.Connection + ", collation_connection = " + original.Collation
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		d.logf("failed to restore session charset %s: %v", original.Client, err)
+	}
+}
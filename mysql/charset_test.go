@@ -0,0 +1,127 @@
+package mysql
+
+import (
+	"bytes"
+	sqldriver "database/sql/driver"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// seedWithEmoji contains 4-byte UTF-8 characters, which are mangled by utf8mb3 connections.
+const seedWithEmoji = "INSERT INTO t (name) VALUES ('party \U0001F389'), ('\U0001F600')"
+
+// charsetHandler answers the charset query with the given connection charset.
+func charsetHandler(charset, collation string) fakeHandler {
+	return func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT @@SESSION.character_set_client") {
+			return fakeResponse{Columns: []string{"client", "connection", "collation"},
+				Rows: [][]sqldriver.Value{{charset, charset, collation}}}
+		}
+		return defaultFakeHandler(call)
+	}
+}
+
+func Test_driver_RunMigration_SessionCharset(t *testing.T) {
+	db, srv := newFakeDB(t, charsetHandler("utf8mb3", "utf8mb3_general_ci"))
+	d := &driver{client: db, cfg: &config{SplitStatements: true, SessionCharset: DefaultSessionCharset},
+		charsets: newPinnedCharsets()}
+
+	if err := d.RunMigration(strings.NewReader(seedWithEmoji + ";")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"SELECT @@SESSION.character_set_client, @@SESSION.character_set_connection, @@SESSION.collation_connection",
+		"SET SESSION character_set_client = utf8mb4, character_set_connection = utf8mb4",
+		seedWithEmoji,
+		"SET SESSION character_set_client = utf8mb3, character_set_connection = utf8mb3, " +
+			"collation_connection = utf8mb3_general_ci",
+	}
+	if queries := srv.Queries(); !reflect.DeepEqual(queries, want) {
+		t.Fatalf("unexpected queries %q, got: %q", want, queries)
+	}
+	if len(d.charsets.sessions) != 0 {
+		t.Fatalf("unexpected pinned sessions, got: %v", d.charsets.sessions)
+	}
+}
+
+func Test_driver_RunMigration_SessionCharsetUnchanged(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, SessionCharset: DefaultSessionCharset},
+		charsets: newPinnedCharsets()}
+
+	if err := d.RunMigration(strings.NewReader(seedWithEmoji + ";")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 2 || queries[1] != seedWithEmoji {
+		t.Fatalf("unexpected queries, got: %q", queries)
+	}
+}
+
+func Test_driver_RunMigration_Utf16Emoji(t *testing.T) {
+	units := utf16.Encode([]rune(seedWithEmoji + ";"))
+	migration := []byte{0xFF, 0xFE} // UTF-16LE byte order mark
+	for _, unit := range units {
+		migration = append(migration, byte(unit), byte(unit>>8))
+	}
+
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+	if err := d.RunMigration(bytes.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 1 || queries[0] != seedWithEmoji {
+		t.Fatalf("unexpected queries %q, got: %q", seedWithEmoji, queries)
+	}
+}
+
+// Test_driver_RunMigration_Utf8mb4_MySQL inserts 4-byte characters through a connection that negotiated utf8mb3,
+// see benchmarkDSNVariable.
+func Test_driver_RunMigration_Utf8mb4_MySQL(t *testing.T) {
+	dsn := os.Getenv(benchmarkDSNVariable)
+	if dsn == "" {
+		t.Skipf("%s is not set", benchmarkDSNVariable)
+	}
+	cfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("invalid DSN: %v", err)
+	}
+	cfg.Collation = "utf8_general_ci"
+
+	drv, err := NewDriverFromDSN(cfg.FormatDSN(), WithMigrationTable("charset_migrations"),
+		WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d := drv.(*driver)
+	t.Cleanup(func() {
+		for _, table := range []string{d.migrationsTable(), "t"} {
+			if _, err := d.client.Exec("DROP TABLE IF EXISTS `" + table + "`"); err != nil {
+				t.Errorf("failed to drop table %s: %v", table, err)
+			}
+		}
+		_ = d.Close()
+	})
+
+	migration := "CREATE TABLE t (id int auto_increment primary key, name varchar(32)) CHARACTER SET utf8mb4;\n" +
+		seedWithEmoji + ";"
+	if err := d.RunMigration(strings.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var count int
+	if err := d.client.QueryRow("SELECT COUNT(*) FROM t WHERE HEX(name) IN (?, ?)",
+		"706172747920F09F8E89", "F09F9880").Scan(&count); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("4-byte characters were mangled, found %d of 2 rows", count)
+	}
+}
@@ -0,0 +1,198 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// ChecksumAlgorithm selects how the checksums of migrations are computed, see WithChecksumAlgorithm.
+type ChecksumAlgorithm int
+
+const (
+	// ChecksumCRC32 is the IEEE CRC-32 of the migration, formatted as unsigned decimal number.
+	ChecksumCRC32 ChecksumAlgorithm = iota
+	// ChecksumSHA256 is the SHA-256 hash of the migration, formatted as hex string.
+	ChecksumSHA256
+	// ChecksumFlyway is the checksum of Flyway: the CRC-32 of all lines without line terminators and without a
+	// leading byte order mark, formatted as signed decimal number.
+	ChecksumFlyway
+)
+
+// String returns the name of the checksum algorithm.
+func (a ChecksumAlgorithm) String() string {
+	switch a {
+	case ChecksumSHA256:
+		return "sha256"
+	case ChecksumFlyway:
+		return "flyway"
+	default:
+		return "crc32"
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (a ChecksumAlgorithm) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (a *ChecksumAlgorithm) UnmarshalText(text []byte) error {
+	for _, algorithm := range []ChecksumAlgorithm{ChecksumCRC32, ChecksumSHA256, ChecksumFlyway} {
+		if strings.EqualFold(string(text), algorithm.String()) {
+			*a = algorithm
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown checksum algorithm %q", text)
+}
+
+// WithChecksumAlgorithm sets the algorithm of the migration checksums that are stored in the migration history,
+// see WithHistory. Defaults to ChecksumCRC32.
+func WithChecksumAlgorithm(algorithm ChecksumAlgorithm) DriverOption {
+	return func(d *driver) {
+		d.cfg.ChecksumAlgorithm = algorithm
+	}
+}
+
+// checksum computes the checksum of the data written to it.
+type checksum interface {
+	io.Writer
+	Sum() string
+}
+
+// newChecksum creates a new checksum of the given algorithm, see WithChecksumNormalization for normalize.
+func newChecksum(algorithm ChecksumAlgorithm, normalize bool) checksum {
+	var sum checksum
+	switch algorithm {
+	case ChecksumSHA256:
+		sum = &hashChecksum{Hash: sha256.New()}
+	case ChecksumFlyway:
+		sum = &flywayChecksum{crc: crc32.NewIEEE()}
+	default:
+		sum = &hashChecksum{Hash: crc32.NewIEEE()}
+	}
+
+	if normalize {
+		return &normalizedChecksum{checksum: sum}
+	}
+	return sum
+}
+
+// hashChecksum formats 32-bit hashes as decimal number and all other hashes as hex string.
+type hashChecksum struct {
+	hash.Hash
+}
+
+func (c *hashChecksum) Sum() string {
+	if h, ok := c.Hash.(hash.Hash32); ok {
+		return strconv.FormatUint(uint64(h.Sum32()), 10)
+	}
+	return hex.EncodeToString(c.Hash.Sum(nil))
+}
+
+// utf8BOM is the byte order mark that is ignored by the Flyway checksum.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// flywayChecksum skips line terminators and a leading byte order mark, like Flyway does.
+type flywayChecksum struct {
+	crc  hash.Hash32
+	head []byte // the first bytes, until it is known whether they are a byte order mark
+	done bool   // the byte order mark was checked
+}
+
+func (c *flywayChecksum) Write(p []byte) (int, error) {
+	n := len(p)
+	if !c.done {
+		missing := len(utf8BOM) - len(c.head)
+		if len(p) < missing {
+			c.head = append(c.head, p...)
+			return n, nil
+		}
+		c.head, p = append(c.head, p[:missing]...), p[missing:]
+		c.done = true
+		if !bytes.Equal(c.head, utf8BOM) {
+			c.writeLines(c.head)
+		}
+	}
+	c.writeLines(p)
+	return n, nil
+}
+
+func (c *flywayChecksum) writeLines(p []byte) {
+	for len(p) > 0 {
+		end := bytes.IndexAny(p, "\r\n")
+		if end < 0 {
+			_, _ = c.crc.Write(p)
+			return
+		}
+		_, _ = c.crc.Write(p[:end])
+		p = p[end+1:]
+	}
+}
+
+func (c *flywayChecksum) Sum() string {
+	if !c.done {
+		c.done = true
+		c.writeLines(c.head) // shorter than a byte order mark
+	}
+	return strconv.FormatInt(int64(int32(c.crc.Sum32())), 10)
+}
+
+// computeChecksum computes the checksum of the whole migration.
+func computeChecksum(algorithm ChecksumAlgorithm, normalize bool, migration io.Reader) (string, error) {
+	sum := newChecksum(algorithm, normalize)
+	if _, err := io.Copy(sum, migration); err != nil {
+		return "", err
+	}
+	return sum.Sum(), nil
+}
+
+// VerifyChecksum compares the checksum of the migration with the checksum of the last successful up migration to
+// the given version, that was recorded in the migration history. The recorded algorithm and normalization are
+// used for the comparison. If the checksums differ, ErrChecksumMismatch is returned.
+func (d *driver) VerifyChecksum(ctx context.Context, version uint64, migration io.Reader) error {
+	if !d.cfg.History {
+		return ErrNoHistory
+	}
+
+	query := "SELECT checksum, checksum_algorithm, checksum_normalized FROM `" + d.historyTable() + "` " +
+		"WHERE version = ? AND success AND checksum IS NOT NULL AND (direction IS NULL OR direction = 'up') " +
+		"ORDER BY id DESC LIMIT 1"
+	var recorded string
+	var algorithm ChecksumAlgorithm
+	var algorithmName sql.NullString
+	var normalized sql.NullBool
+	if err := d.client.QueryRowContext(ctx, query, version).Scan(&recorded, &algorithmName, &normalized); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no checksum recorded for version %d", version)
+		}
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read recorded checksum", Query: []byte(query)}
+	}
+	if algorithmName.Valid {
+		if err := algorithm.UnmarshalText([]byte(algorithmName.String)); err != nil {
+			return err
+		}
+	}
+
+	actual, err := computeChecksum(algorithm, normalized.Bool, migration)
+	if err != nil {
+		return fmt.Errorf("failed to read migration: %w", err)
+	}
+	if actual != recorded {
+		return fmt.Errorf("%w: version %d was applied with %s checksum %s, the migration has %s",
+			ErrChecksumMismatch, version, algorithm, recorded, actual)
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package mysql
+
+import "bytes"
+
+// WithChecksumNormalization enables the normalization of migrations before their checksum is computed: comments,
+// line terminators, trailing whitespace and empty lines are ignored, so that trivially reformatted migrations
+// (e.g. converted line endings or an added trailing newline) keep their checksum. Executable comments and
+// optimizer hints are kept, as they change the statements.
+func WithChecksumNormalization(normalize bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.ChecksumNormalization = normalize
+	}
+}
+
+// normalizedChecksum normalizes the data line by line before it is passed to the checksum.
+type normalizedChecksum struct {
+	checksum
+	line  []byte
+	out   []byte
+	state scanState // the quote or comment state at the end of the last line
+}
+
+func (c *normalizedChecksum) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		end := bytes.IndexByte(p, '\n')
+		if end < 0 {
+			c.line = append(c.line, p...)
+			break
+		}
+		c.line = append(c.line, p[:end]...)
+		c.flushLine()
+		p = p[end+1:]
+	}
+	return n, nil
+}
+
+func (c *normalizedChecksum) Sum() string {
+	c.flushLine()
+	return c.checksum.Sum()
+}
+
+// flushLine passes the normalized line to the checksum. Lines that are empty after the normalization are skipped.
+func (c *normalizedChecksum) flushLine() {
+	out := bytes.TrimRight(c.stripComments(c.line), " \t\r\f\v")
+	if len(out) > 0 {
+		_, _ = c.checksum.Write(out)
+		_, _ = c.checksum.Write([]byte{'\n'})
+	}
+	c.line = c.line[:0]
+}
+
+// stripComments removes the comments of the line, quoted strings and identifiers are respected.
+func (c *normalizedChecksum) stripComments(line []byte) []byte {
+	out := c.out[:0]
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		next := byte(0)
+		if i+1 < len(line) {
+			next = line[i+1]
+		}
+
+		switch c.state {
+		case stateNormal:
+			switch {
+			case ch == '\'':
+				c.state = stateSingleQuote
+			case ch == '"':
+				c.state = stateDoubleQuote
+			case ch == '`':
+				c.state = stateBacktick
+			case ch == '#', ch == '-' && next == '-' && (i+2 == len(line) || isSpace(line[i+2])):
+				c.out = out
+				return out // line comment
+			case ch == '/' && next == '*':
+				if i+2 < len(line) && (line[i+2] == '!' || line[i+2] == '+') {
+					c.state = stateKeptBlockComment
+					out = append(out, ch, next)
+				} else {
+					c.state = stateBlockComment
+				}
+				i++
+				continue
+			}
+			out = append(out, ch)
+		case stateSingleQuote, stateDoubleQuote:
+			out = append(out, ch)
+			if ch == '\\' && i+1 < len(line) {
+				out = append(out, next)
+				i++
+			} else if (ch == '\'' && c.state == stateSingleQuote) || (ch == '"' && c.state == stateDoubleQuote) {
+				c.state = stateNormal
+			}
+		case stateBacktick:
+			out = append(out, ch)
+			if ch == '`' {
+				c.state = stateNormal
+			}
+		case stateBlockComment:
+			if ch == '*' && next == '/' {
+				c.state = stateNormal
+				i++
+			}
+		case stateKeptBlockComment:
+			out = append(out, ch)
+			if ch == '*' && next == '/' {
+				c.state = stateNormal
+				out = append(out, next)
+				i++
+			}
+		}
+	}
+
+	c.out = out
+	return out
+}
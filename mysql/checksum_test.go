@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_computeChecksum(t *testing.T) {
+	tests := []struct {
+		algorithm ChecksumAlgorithm
+		migration string
+		want      string
+	}{
+		{ChecksumCRC32, "SELECT 1;", "78787420"},
+		{ChecksumSHA256, "", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{ChecksumFlyway, "SELECT 1;\r\nSELECT 2;\n", "-1665099012"},
+		{ChecksumFlyway, "\xEF\xBB\xBFSELECT 1;\nSELECT 2;", "-1665099012"},
+		{ChecksumFlyway, "SE", "-688281225"},
+	}
+	for _, tt := range tests {
+		got, err := computeChecksum(tt.algorithm, false, strings.NewReader(tt.migration))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Fatalf("unexpected %s checksum %s of %q, got: %s", tt.algorithm, tt.want, tt.migration, got)
+		}
+	}
+}
+
+func Test_flywayChecksum_Write(t *testing.T) {
+	want, _ := computeChecksum(ChecksumFlyway, false, strings.NewReader("\xEF\xBB\xBFSELECT 1;\nSELECT 2;"))
+
+	sum := newChecksum(ChecksumFlyway, false)
+	for _, part := range []string{"\xEF", "\xBB", "\xBFSEL", "ECT 1;\r", "\nSELECT 2;"} {
+		_, _ = sum.Write([]byte(part))
+	}
+	if got := sum.Sum(); got != want {
+		t.Fatalf("unexpected checksum %s for partial writes, got: %s", want, got)
+	}
+}
+
+func Test_driver_VerifyChecksum(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT checksum") && strings.Contains(call.Query, "direction = 'up'") &&
+			call.Args[0] == int64(1) {
+			return fakeResponse{
+				Columns: []string{"checksum", "checksum_algorithm", "checksum_normalized"},
+				Rows:    [][]sqldriver.Value{{"78787420", "crc32", nil}},
+			}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{History: true, ChecksumAlgorithm: ChecksumSHA256}}
+
+	if err := d.VerifyChecksum(context.Background(), 1, strings.NewReader("SELECT 1;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.VerifyChecksum(context.Background(), 1, strings.NewReader("SELECT 2;")); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("unexpected error %v, got: %v", ErrChecksumMismatch, err)
+	}
+	if err := d.VerifyChecksum(context.Background(), 2, strings.NewReader("SELECT 1;")); err == nil {
+		t.Fatalf("expected error for unrecorded version")
+	}
+}
+
+func Test_normalizedChecksum(t *testing.T) {
+	original := "-- add users\nCREATE TABLE users (\n  id int, -- the id\n  name varchar(10) DEFAULT '-- no comment'\n);\n" +
+		"/* block\ncomment */ SELECT /*+ BKA(users) */ 1;"
+	variants := []string{
+		strings.ReplaceAll(original, "\n", "\r\n"),
+		original + "\n\n",
+		strings.ReplaceAll(original, "\n", "   \n"),
+		strings.Replace(original, "-- the id", "# another comment", 1),
+	}
+
+	want, _ := computeChecksum(ChecksumSHA256, true, strings.NewReader(original))
+	for _, variant := range variants {
+		if got, _ := computeChecksum(ChecksumSHA256, true, strings.NewReader(variant)); got != want {
+			t.Fatalf("unexpected checksum change for %q", variant)
+		}
+	}
+
+	changed := []string{
+		strings.Replace(original, "'-- no comment'", "'-- other'", 1),
+		strings.Replace(original, "BKA(users)", "NO_BKA(users)", 1),
+		strings.Replace(original, "  id int", "  id bigint", 1),
+	}
+	for _, variant := range changed {
+		if got, _ := computeChecksum(ChecksumSHA256, true, strings.NewReader(variant)); got == want {
+			t.Fatalf("expected checksum change for %q", variant)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+var (
+	myISAMPattern      = regexp.MustCompile(`(?i)\bENGINE\s*=?\s*MyISAM\b`)
+	createTablePattern = regexp.MustCompile(`(?i)^CREATE\s+(TEMPORARY\s+)?TABLE\s`)
+	primaryKeyPattern  = regexp.MustCompile(`(?i)\bPRIMARY\s+KEY\b|\bLIKE\b|\bSELECT\b`)
+)
+
+// detectCluster checks if the server is a node of a Galera cluster (e.g. Percona XtraDB Cluster or MariaDB
+// Galera Cluster) and enables the cluster-safe defaults.
+func (d *driver) detectCluster(ctx context.Context) {
+	rows, err := d.client.QueryContext(ctx, "SHOW VARIABLES WHERE Variable_name IN ('wsrep_on', 'wsrep_OSU_method')")
+	if err != nil {
+		d.logf("failed to detect Galera cluster: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	variables := make(map[string]string)
+	for rows.Next() {
+		var name, value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			d.logf("failed to detect Galera cluster: %v", err)
+			return
+		}
+		variables[strings.ToLower(name.String)] = value.String
+	}
+
+	if !strings.EqualFold(variables["wsrep_on"], "ON") {
+		return
+	}
+	d.server.Cluster = true
+
+	if d.cfg.LockStrategy == LockStrategyAuto {
+		d.cfg.LockStrategy = LockStrategyTable // advisory locks are not replicated to the other nodes
+	}
+	method := variables["wsrep_osu_method"]
+	if method == "" {
+		method = "TOI"
+	}
+	d.logf("Galera cluster detected, using the %s lock strategy. DDL statements are replicated using %s, "+
+		"with TOI they block the whole cluster while they are executed", d.cfg.LockStrategy, method)
+}
+
+// tableOptions returns the options for the internal tables. On Galera clusters, only InnoDB tables are replicated.
+func (d *driver) tableOptions() string {
+	if d.server.Cluster {
+		return " ENGINE=InnoDB"
+	}
+	return ""
+}
+
+// checkClusterSafety warns about statements that are not replicated correctly within a Galera cluster.
+// In strict mode, such statements fail the migration before they are executed.
+func (d *driver) checkClusterSafety(state *migrationState, stmt statement) error {
+	if !d.server.Cluster {
+		return nil
+	}
+
+	var msg string
+	switch {
+	case (createTablePattern.MatchString(stmt.Query) || strings.EqualFold(firstKeyword(stmt.Query), "ALTER")) &&
+		myISAMPattern.MatchString(stmt.Query):
+		msg = "MyISAM tables are not replicated within a Galera cluster"
+	case createTablePattern.MatchString(stmt.Query) && !primaryKeyPattern.MatchString(stmt.Query):
+		msg = "tables without primary key are not supported well within a Galera cluster"
+	default:
+		return nil
+	}
+
+	if d.cfg.Strict {
+		return stmt.error(msg, ErrUnsafeStatement)
+	}
+
+	d.logf("statement %d (line %d): %s", stmt.Index, stmt.Line, msg)
+	state.Result.warn(Warning{StatementIndex: stmt.Index, Line: stmt.Line, Level: "Warning", Message: msg})
+	return nil
+}
+
+// isDeadlock checks if the error is a deadlock or a Galera certification conflict.
+func isDeadlock(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1213 // ER_LOCK_DEADLOCK
+}
+
+// String implements the fmt.Stringer interface.
+func (s LockStrategy) String() string {
+	switch s {
+	case LockStrategyAdvisory:
+		return "advisory"
+	case LockStrategyTable:
+		return "table"
+	default:
+		return "auto"
+	}
+}
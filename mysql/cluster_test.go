@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func galeraHandler(call fakeCall) fakeResponse {
+	if strings.HasPrefix(call.Query, "SHOW VARIABLES") {
+		return fakeResponse{
+			Columns: []string{"Variable_name", "Value"},
+			Rows:    [][]sqldriver.Value{{"wsrep_on", "ON"}, {"wsrep_OSU_method", "TOI"}},
+		}
+	}
+	return defaultFakeHandler(call)
+}
+
+func Test_driver_detectCluster(t *testing.T) {
+	db, _ := newFakeDB(t, galeraHandler)
+	d := &driver{client: db, cfg: &config{}}
+
+	d.detectCluster(context.Background())
+	if !d.server.Cluster || d.cfg.LockStrategy != LockStrategyTable {
+		t.Fatalf("unexpected cluster detection: %+v, %s", d.server, d.cfg.LockStrategy)
+	}
+	if d.tableOptions() != " ENGINE=InnoDB" {
+		t.Fatalf("unexpected table options: %s", d.tableOptions())
+	}
+
+	d = &driver{client: db, cfg: &config{LockStrategy: LockStrategyAdvisory}}
+	d.detectCluster(context.Background())
+	if d.cfg.LockStrategy != LockStrategyAdvisory {
+		t.Fatalf("explicit lock strategy was overridden")
+	}
+}
+
+func Test_driver_detectCluster_NoCluster(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{}}
+
+	d.detectCluster(context.Background())
+	if d.server.Cluster || d.cfg.LockStrategy != LockStrategyAuto {
+		t.Fatalf("unexpected cluster detection: %+v", d.server)
+	}
+}
+
+func Test_driver_checkClusterSafety(t *testing.T) {
+	d := &driver{cfg: &config{}, server: serverInfo{Cluster: true}}
+
+	tests := map[string]bool{
+		"CREATE TABLE a (id int primary key) ENGINE=MyISAM": true,
+		"ALTER TABLE a ENGINE MyISAM":                       true,
+		"CREATE TABLE a (id int)":                           true,
+		"CREATE TABLE a (id int, PRIMARY KEY (id))":         false,
+		"CREATE TABLE b LIKE a":                             false,
+		"INSERT INTO a VALUES ('ENGINE=MyISAM')":            false,
+	}
+	for query, unsafe := range tests {
+		state := &migrationState{}
+		if err := d.checkClusterSafety(state, statement{Query: query, Index: 1}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := len(state.Result.result.Warnings) > 0; got != unsafe {
+			t.Fatalf("unexpected safety check result %t for %q", unsafe, query)
+		}
+	}
+
+	d.cfg.Strict = true
+	err := d.checkClusterSafety(&migrationState{}, statement{Query: "CREATE TABLE a (id int)", Index: 1})
+	if !errors.Is(err, ErrUnsafeStatement) {
+		t.Fatalf("unexpected error %v, got: %v", ErrUnsafeStatement, err)
+	}
+}
@@ -1,10 +1,336 @@
 package mysql
 
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
 // DefaultMigrationsTable is the table to use for migration state by default.
 const DefaultMigrationsTable = "schema_migrations"
 
+// DefaultLockTimeout is the time the driver waits for the migration lock by default.
+const DefaultLockTimeout = 5 * time.Second
+
+// DefaultMaxParallelStatements is the number of statements of a parallel block that are executed concurrently
+// by default.
+const DefaultMaxParallelStatements = 4
+
 type config struct {
-	DatabaseName    string
-	MigrationsTable string
-	Locking         bool
+	DatabaseName      string
+	MigrationsTable   string
+	TablePrefix       string
+	Namespace         string
+	Locking           bool
+	LockStrategy      LockStrategy
+	LockPolicy        LockPolicy
+	LockTargetVersion uint64
+	LockTTL           time.Duration
+	LockTable         string
+	LockTableEngine   string
+	ShardLockKey      bool
+	PrimaryGuard      bool
+	SplitStatements   bool
+	SessionCharset    string
+
+	TableScopedLocking bool
+	ConnectionWarmup   bool
+	AllowReplica       bool
+
+	MaxParallelStatements int
+
+	DirtyRetry DirtyRetryPolicy
+	Reconnect  ReconnectPolicy
+
+	ExpectedVersion uint64
+
+	QueryTimeout     time.Duration
+	LockTimeout      time.Duration
+	StatementTimeout time.Duration
+	RunDeadline      time.Duration
+
+	StatementWatchdog time.Duration
+	ProgressInterval  time.Duration
+
+	MaintenanceWindows  []MaintenanceWindow
+	MaintenanceWait     time.Duration
+	MaintenanceOverride string
+
+	MaxAffectedRows int64
+	Strict          bool
+	CaptureWarnings bool
+	Explain         bool
+
+	EventSchedulerCheck bool
+
+	ImpactEstimation ImpactEstimation
+
+	AtomicDDLRecovery  bool
+	VersionUpsert      bool
+	VersionTablePolicy VersionTablePolicy
+
+	InnoDBLockWaitTimeout   time.Duration
+	MetadataLockWaitTimeout time.Duration
+	Role                    string
+
+	PreRunSQL  []string
+	PostRunSQL []string
+
+	History           bool
+	AppliedBy         string
+	ChecksumAlgorithm ChecksumAlgorithm
+	Quarantine        bool
+	ObjectTracking    bool
+
+	TableCheck *TableExpectation
+
+	NotificationTable string
+	WebhookURL        string
+	DirtyWebhookURL   string
+	NotificationAppID string
+	ReportFile        string
+
+	ChecksumNormalization bool
+
+	MaxInMemoryMigrationSize int64
+
+	RollbackFloor     uint64
+	FullRollbackToken string
+
+	ExtraVersionColumns map[string]func() interface{}
+}
+
+// DirtyRetryPolicy configures the automatic retry of dirty migrations.
+// Only migrations that are marked with the "-- lightmigrate:idempotent" directive are retried.
+type DirtyRetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts for a single migration, including the first one.
+	// Values below 2 disable the automatic retry.
+	MaxAttempts int
+}
+
+// Config is the declarative driver configuration, e.g. loaded from a JSON or YAML configuration file.
+// Zero values keep the driver defaults. Settings that cannot be serialized (e.g. the logger or the decryptor)
+// can still be passed as options to NewDriverWithConfig.
+type Config struct {
+	Database        string `json:"database" yaml:"database"`
+	MigrationsTable string `json:"migrations_table,omitempty" yaml:"migrations_table,omitempty"`
+	TablePrefix     string `json:"table_prefix,omitempty" yaml:"table_prefix,omitempty"`
+	Namespace       string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+
+	// Locking defaults to true.
+	Locking      *bool        `json:"locking,omitempty" yaml:"locking,omitempty"`
+	LockStrategy LockStrategy `json:"lock_strategy,omitempty" yaml:"lock_strategy,omitempty"`
+	LockPolicy   LockPolicy   `json:"lock_policy,omitempty" yaml:"lock_policy,omitempty"`
+	// LockTargetVersion is required by the skip lock policy.
+	LockTargetVersion uint64 `json:"lock_target_version,omitempty" yaml:"lock_target_version,omitempty"`
+	// LockTimeout defaults to DefaultLockTimeout.
+	LockTimeout *Duration `json:"lock_timeout,omitempty" yaml:"lock_timeout,omitempty"`
+	LockTTL     Duration  `json:"lock_ttl,omitempty" yaml:"lock_ttl,omitempty"`
+	// LockTable and LockTableEngine configure the lock table of the table lock strategy.
+	LockTable       string `json:"lock_table,omitempty" yaml:"lock_table,omitempty"`
+	LockTableEngine string `json:"lock_table_engine,omitempty" yaml:"lock_table_engine,omitempty"`
+	// ShardLockKey derives the lock key from the server identity, the database and the namespace.
+	ShardLockKey bool `json:"shard_lock_key,omitempty" yaml:"shard_lock_key,omitempty"`
+	// PrimaryGuard refuses concurrent runs on different primaries, it requires the table lock strategy.
+	PrimaryGuard bool `json:"primary_guard,omitempty" yaml:"primary_guard,omitempty"`
+	// TableScopedLocking enables the tables directive.
+	TableScopedLocking bool `json:"table_scoped_locking,omitempty" yaml:"table_scoped_locking,omitempty"`
+
+	// ConnectionWarmup pings the pooled connections before a run.
+	ConnectionWarmup bool `json:"connection_warmup,omitempty" yaml:"connection_warmup,omitempty"`
+
+	// AllowReplica allows migrations on read-only servers.
+	AllowReplica bool `json:"allow_replica,omitempty" yaml:"allow_replica,omitempty"`
+
+	// StatementSplitting defaults to true.
+	StatementSplitting *bool `json:"statement_splitting,omitempty" yaml:"statement_splitting,omitempty"`
+	// SessionCharset defaults to utf8mb4, an empty string keeps the charset of the connection.
+	SessionCharset *string `json:"session_charset,omitempty" yaml:"session_charset,omitempty"`
+
+	MaxParallelStatements int    `json:"max_parallel_statements,omitempty" yaml:"max_parallel_statements,omitempty"`
+	DirtyRetryAttempts    int    `json:"dirty_retry_attempts,omitempty" yaml:"dirty_retry_attempts,omitempty"`
+	ReconnectAttempts     int    `json:"reconnect_attempts,omitempty" yaml:"reconnect_attempts,omitempty"`
+	ExpectedVersion       uint64 `json:"expected_version,omitempty" yaml:"expected_version,omitempty"`
+
+	QueryTimeout            Duration `json:"query_timeout,omitempty" yaml:"query_timeout,omitempty"`
+	StatementTimeout        Duration `json:"statement_timeout,omitempty" yaml:"statement_timeout,omitempty"`
+	RunDeadline             Duration `json:"run_deadline,omitempty" yaml:"run_deadline,omitempty"`
+	StatementWatchdog       Duration `json:"statement_watchdog,omitempty" yaml:"statement_watchdog,omitempty"`
+	ProgressInterval        Duration `json:"progress_interval,omitempty" yaml:"progress_interval,omitempty"`
+	ReconnectBackoff        Duration `json:"reconnect_backoff,omitempty" yaml:"reconnect_backoff,omitempty"`
+	InnoDBLockWaitTimeout   Duration `json:"innodb_lock_wait_timeout,omitempty" yaml:"innodb_lock_wait_timeout,omitempty"`
+	MetadataLockWaitTimeout Duration `json:"metadata_lock_wait_timeout,omitempty" yaml:"metadata_lock_wait_timeout,omitempty"`
+	// MaintenanceWindows restrict heavy migrations, e.g. "Sat,Sun 01:00-05:00 Europe/Berlin".
+	MaintenanceWindows  []MaintenanceWindow `json:"maintenance_windows,omitempty" yaml:"maintenance_windows,omitempty"`
+	MaintenanceWait     Duration            `json:"maintenance_wait,omitempty" yaml:"maintenance_wait,omitempty"`
+	MaintenanceOverride string              `json:"maintenance_override,omitempty" yaml:"maintenance_override,omitempty"`
+	// Role is activated on the migration sessions, e.g. "ddl_admin".
+	Role string `json:"role,omitempty" yaml:"role,omitempty"`
+
+	MaxAffectedRows   int64 `json:"max_affected_rows,omitempty" yaml:"max_affected_rows,omitempty"`
+	StrictMode        bool  `json:"strict_mode,omitempty" yaml:"strict_mode,omitempty"`
+	WarningsCapture   bool  `json:"warnings_capture,omitempty" yaml:"warnings_capture,omitempty"`
+	AtomicDDLRecovery bool  `json:"atomic_ddl_recovery,omitempty" yaml:"atomic_ddl_recovery,omitempty"`
+	VersionUpsert     bool  `json:"version_upsert,omitempty" yaml:"version_upsert,omitempty"`
+	VerboseLogging    bool  `json:"verbose_logging,omitempty" yaml:"verbose_logging,omitempty"`
+	Explain           bool  `json:"explain,omitempty" yaml:"explain,omitempty"`
+	// EventSchedulerCheck warns about event definitions while event_scheduler is OFF.
+	EventSchedulerCheck bool `json:"event_scheduler_check,omitempty" yaml:"event_scheduler_check,omitempty"`
+	// ImpactEstimation is "disabled" (default), "explain" or "count".
+	ImpactEstimation ImpactEstimation `json:"impact_estimation,omitempty" yaml:"impact_estimation,omitempty"`
+	// VersionTablePolicy is "fail" (default) or "repair".
+	VersionTablePolicy VersionTablePolicy `json:"version_table_policy,omitempty" yaml:"version_table_policy,omitempty"`
+
+	PreRunSQL  []string `json:"pre_run_sql,omitempty" yaml:"pre_run_sql,omitempty"`
+	PostRunSQL []string `json:"post_run_sql,omitempty" yaml:"post_run_sql,omitempty"`
+
+	History   bool   `json:"history,omitempty" yaml:"history,omitempty"`
+	AppliedBy string `json:"applied_by,omitempty" yaml:"applied_by,omitempty"`
+	// Quarantine enables the best-effort mode, see WithQuarantine.
+	Quarantine bool `json:"quarantine,omitempty" yaml:"quarantine,omitempty"`
+	// ObjectTracking records the objects changed by each migration, see WithObjectTracking.
+	ObjectTracking bool `json:"object_tracking,omitempty" yaml:"object_tracking,omitempty"`
+	// TableCheck enables the check of the migrations and history table definitions, see WithTableCheck.
+	TableCheck *TableExpectation `json:"table_check,omitempty" yaml:"table_check,omitempty"`
+
+	NotificationTable string `json:"notification_table,omitempty" yaml:"notification_table,omitempty"`
+	WebhookURL        string `json:"webhook_url,omitempty" yaml:"webhook_url,omitempty"`
+	DirtyWebhookURL   string `json:"dirty_webhook_url,omitempty" yaml:"dirty_webhook_url,omitempty"`
+	NotificationAppID string `json:"notification_app_id,omitempty" yaml:"notification_app_id,omitempty"`
+	ReportFile        string `json:"report_file,omitempty" yaml:"report_file,omitempty"`
+	// ChecksumAlgorithm is "crc32" (default), "sha256" or "flyway".
+	ChecksumAlgorithm     ChecksumAlgorithm `json:"checksum_algorithm,omitempty" yaml:"checksum_algorithm,omitempty"`
+	ChecksumNormalization bool              `json:"checksum_normalization,omitempty" yaml:"checksum_normalization,omitempty"`
+
+	// MaxInMemoryMigrationSize is the size in bytes, larger migrations are buffered in a temporary file.
+	MaxInMemoryMigrationSize int64 `json:"max_in_memory_migration_size,omitempty" yaml:"max_in_memory_migration_size,omitempty"`
+
+	// RollbackFloor defaults to DefaultRollbackFloor.
+	RollbackFloor *uint64 `json:"rollback_floor,omitempty" yaml:"rollback_floor,omitempty"`
+	// AllowFullRollback confirms a rollback below the floor, it must be created by RollbackToken.
+	AllowFullRollback string `json:"allow_full_rollback,omitempty" yaml:"allow_full_rollback,omitempty"`
+}
+
+// NewDriverWithConfig instantiates a new MySQL driver from the declarative configuration. The options are applied
+// after the configuration, so they take precedence.
+func NewDriverWithConfig(client *sql.DB, cfg Config, opts ...DriverOption) (Driver, error) {
+	return NewDriver(client, cfg.Database, append(cfg.options(), opts...)...)
+}
+
+// options translates the configuration into driver options.
+func (c Config) options() []DriverOption {
+	opts := []DriverOption{
+		WithTablePrefix(c.TablePrefix),
+		WithNamespace(c.Namespace),
+		WithLockStrategy(c.LockStrategy),
+		WithLockPolicy(c.LockPolicy, c.LockTargetVersion),
+		WithLockTTL(time.Duration(c.LockTTL)),
+		WithLockTable(c.LockTable),
+		WithLockTableEngine(c.LockTableEngine),
+		WithShardLockKey(c.ShardLockKey),
+		WithPrimaryGuard(c.PrimaryGuard),
+		WithTableScopedLocking(c.TableScopedLocking),
+		WithConnectionWarmup(c.ConnectionWarmup),
+		WithAllowReplica(c.AllowReplica),
+		WithDirtyRetry(DirtyRetryPolicy{MaxAttempts: c.DirtyRetryAttempts}),
+		WithReconnect(ReconnectPolicy{MaxAttempts: c.ReconnectAttempts, Backoff: time.Duration(c.ReconnectBackoff)}),
+		WithExpectedVersion(c.ExpectedVersion),
+		WithDefaultQueryTimeout(time.Duration(c.QueryTimeout)),
+		WithStatementTimeout(time.Duration(c.StatementTimeout)),
+		WithRunDeadline(time.Duration(c.RunDeadline)),
+		WithStatementWatchdog(time.Duration(c.StatementWatchdog)),
+		WithProgressInterval(time.Duration(c.ProgressInterval)),
+		WithMaintenanceWindow(c.MaintenanceWindows...),
+		WithMaintenanceWait(time.Duration(c.MaintenanceWait)),
+		WithMaintenanceOverride(c.MaintenanceOverride),
+		WithLockWaitTimeouts(time.Duration(c.InnoDBLockWaitTimeout), time.Duration(c.MetadataLockWaitTimeout)),
+		WithRole(c.Role),
+		WithMaxAffectedRows(c.MaxAffectedRows),
+		WithStrictMode(c.StrictMode),
+		WithWarningsCapture(c.WarningsCapture),
+		WithAtomicDDLRecovery(c.AtomicDDLRecovery),
+		WithVersionUpsert(c.VersionUpsert),
+		WithVersionTablePolicy(c.VersionTablePolicy),
+		WithEventSchedulerCheck(c.EventSchedulerCheck),
+		WithVerboseLogging(c.VerboseLogging),
+		WithExplain(c.Explain),
+		WithImpactEstimation(c.ImpactEstimation),
+		WithPreRunSQL(c.PreRunSQL),
+		WithPostRunSQL(c.PostRunSQL),
+		WithHistory(c.History),
+		WithAppliedBy(c.AppliedBy),
+		WithQuarantine(c.Quarantine),
+		WithObjectTracking(c.ObjectTracking),
+		WithNotificationTable(c.NotificationTable),
+		WithWebhook(c.WebhookURL),
+		WithDirtyWebhook(c.DirtyWebhookURL),
+		WithNotificationAppID(c.NotificationAppID),
+		WithReportFile(c.ReportFile),
+		WithChecksumAlgorithm(c.ChecksumAlgorithm),
+		WithChecksumNormalization(c.ChecksumNormalization),
+		WithMaxInMemoryMigrationSize(c.MaxInMemoryMigrationSize),
+		WithAllowFullRollback(c.AllowFullRollback),
+	}
+
+	if c.MigrationsTable != "" {
+		opts = append(opts, WithMigrationTable(c.MigrationsTable))
+	}
+	if c.Locking != nil {
+		opts = append(opts, WithLocking(*c.Locking))
+	}
+	if c.LockTimeout != nil {
+		opts = append(opts, WithLockTimeout(time.Duration(*c.LockTimeout)))
+	}
+	if c.StatementSplitting != nil {
+		opts = append(opts, WithStatementSplitting(*c.StatementSplitting))
+	}
+	if c.SessionCharset != nil {
+		opts = append(opts, WithSessionCharset(*c.SessionCharset))
+	}
+	if c.MaxParallelStatements != 0 {
+		opts = append(opts, WithMaxParallelStatements(c.MaxParallelStatements))
+	}
+	if c.RollbackFloor != nil {
+		opts = append(opts, WithRollbackFloor(*c.RollbackFloor))
+	}
+	if c.TableCheck != nil {
+		opts = append(opts, WithTableCheck(*c.TableCheck))
+	}
+
+	return opts
+}
+
+// Duration is a time.Duration that is serialized in the time.ParseDuration format, e.g. "1m30s".
+type Duration time.Duration
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (s LockStrategy) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (s *LockStrategy) UnmarshalText(text []byte) error {
+	for _, strategy := range []LockStrategy{LockStrategyAuto, LockStrategyAdvisory, LockStrategyTable} {
+		if strings.EqualFold(string(text), strategy.String()) {
+			*s = strategy
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown lock strategy %q", text)
 }
@@ -0,0 +1,86 @@
+package mysql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfig_JSON(t *testing.T) {
+	input := `{"database": "app", "locking": false, "lock_strategy": "table", "lock_timeout": "0s",
+		"statement_timeout": "1m30s", "max_parallel_statements": 2, "dirty_retry_attempts": 3}`
+
+	var cfg Config
+	if err := json.Unmarshal([]byte(input), &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Database != "app" || cfg.Locking == nil || *cfg.Locking || cfg.LockStrategy != LockStrategyTable ||
+		cfg.LockTimeout == nil || *cfg.LockTimeout != 0 || time.Duration(cfg.StatementTimeout) != 90*time.Second {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	output, err := json.Marshal(Config{Database: "app", QueryTimeout: Duration(time.Second)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"database":"app","query_timeout":"1s"}`; string(output) != want {
+		t.Fatalf("unexpected json %s, got: %s", want, output)
+	}
+}
+
+func TestConfig_InvalidLockStrategy(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"lock_strategy": "optimistic"}`), &cfg); err == nil {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
+
+func TestConfig_options(t *testing.T) {
+	locking, splitting, lockTimeout := false, false, Duration(0)
+	cfg := Config{
+		Database:              "app",
+		MigrationsTable:       "migrations",
+		Locking:               &locking,
+		LockTimeout:           &lockTimeout,
+		StatementSplitting:    &splitting,
+		MaxParallelStatements: 2,
+		StrictMode:            true,
+	}
+
+	d := &driver{cfg: &config{MigrationsTable: DefaultMigrationsTable, Locking: true, SplitStatements: true,
+		MaxParallelStatements: DefaultMaxParallelStatements, LockTimeout: DefaultLockTimeout}}
+	for _, opt := range cfg.options() {
+		opt(d)
+	}
+
+	if d.cfg.MigrationsTable != "migrations" || d.cfg.Locking || d.cfg.SplitStatements || d.cfg.LockTimeout != 0 ||
+		d.cfg.MaxParallelStatements != 2 || !d.cfg.Strict {
+		t.Fatalf("unexpected driver config: %+v", d.cfg)
+	}
+
+	d = &driver{cfg: &config{MigrationsTable: DefaultMigrationsTable, Locking: true, LockTimeout: DefaultLockTimeout}}
+	for _, opt := range (Config{}).options() {
+		opt(d)
+	}
+	if d.cfg.MigrationsTable != DefaultMigrationsTable || !d.cfg.Locking || d.cfg.LockTimeout != DefaultLockTimeout {
+		t.Fatalf("defaults were overridden: %+v", d.cfg)
+	}
+}
+
+func TestNewDriverWithConfig(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+
+	if _, err := NewDriverWithConfig(db, Config{}); !errors.Is(err, ErrNoDatabaseName) {
+		t.Fatalf("unexpected error %v, got: %v", ErrNoDatabaseName, err)
+	}
+
+	d, err := NewDriverWithConfig(db, Config{Database: "app", TablePrefix: "a_"}, WithTablePrefix("b_"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.(*driver).cfg.TablePrefix != "b_" {
+		t.Fatalf("options must take precedence over the configuration")
+	}
+}
@@ -0,0 +1,302 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultParallelism is the number of databases that are migrated concurrently by the Coordinator by default.
+const DefaultParallelism = 4
+
+// Target describes a single, independent database that should be migrated by the Coordinator.
+type Target struct {
+	// Name identifies the target within the error report. Defaults to the database name.
+	Name string
+	// Client is the database client used for this target.
+	Client *sql.DB
+	// DSN is used to open a database client, if no client is set. The database of the DSN is migrated and the
+	// client is closed after the migration.
+	DSN string
+	// Database is the name of the database.
+	Database string
+	// Source provides the migrations for this target.
+	Source lightmigrate.MigrationSource
+	// Version is the schema version that should be reached.
+	Version uint64
+	// DriverOptions are passed to the driver of this target.
+	DriverOptions []DriverOption
+	// MigratorOptions are passed to the migrator of this target.
+	MigratorOptions []lightmigrate.MigratorOption
+}
+
+func (t Target) name() string {
+	switch {
+	case t.Name != "":
+		return t.Name
+	case t.Database != "" || t.DSN == "":
+		return t.Database
+	}
+
+	if cfg, err := gomysql.ParseDSN(t.DSN); err == nil {
+		return cfg.Addr + "/" + cfg.DBName
+	}
+	return t.DSN
+}
+
+// TargetError is the error of a single failed target.
+type TargetError struct {
+	Target string
+	Err    error
+}
+
+// Error implements error interface.
+func (e TargetError) Error() string {
+	return e.Target + ": " + e.Err.Error()
+}
+
+// Unwrap returns the original error of the target.
+func (e TargetError) Unwrap() error {
+	return e.Err
+}
+
+// TargetResult describes the migration of a single target, see Coordinator.MigrateWithReport.
+type TargetResult struct {
+	Target string `json:"target"`
+	// FromVersion is the schema version before the migration.
+	FromVersion uint64 `json:"from_version"`
+	// ToVersion is the schema version after the migration.
+	ToVersion uint64 `json:"to_version"`
+	// Dirty is true, if the database was left dirty.
+	Dirty    bool          `json:"dirty"`
+	Duration time.Duration `json:"duration_ns"`
+	// Skipped is true, if the migration of the target was not started.
+	Skipped bool  `json:"skipped"`
+	Err     error `json:"-"`
+
+	versionKnown bool   // ToVersion and Dirty were read after the migration
+	server       string // the server identity of the target, only detected for the version registry
+	database     string // the database of the target, only set for the version registry
+}
+
+// Report is the consolidated result of a coordinated migration.
+type Report struct {
+	// Results contains the result of each target, sorted by target name.
+	Results []TargetResult `json:"results"`
+}
+
+// Failed returns the results of all failed (or skipped) targets.
+func (r *Report) Failed() []TargetResult {
+	var failed []TargetResult
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// CoordinatorError aggregates the errors of all failed targets of a coordinated migration.
+type CoordinatorError struct {
+	// Failed contains the errors of the failed targets, sorted by target name.
+	Failed []TargetError
+	// Total is the number of targets of the run.
+	Total int
+}
+
+// Error implements error interface.
+func (e *CoordinatorError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, failed := range e.Failed {
+		msgs[i] = failed.Error()
+	}
+	return fmt.Sprintf("migration failed for %d of %d databases: %s", len(e.Failed), e.Total, strings.Join(msgs, "; "))
+}
+
+// Coordinator migrates many independent databases concurrently. Each database uses its own driver instance,
+// and therefore its own lock and version table.
+type Coordinator struct {
+	parallelism   int
+	stopOnFailure bool
+	registry      *versionRegistry
+	logger        lightmigrate.Logger
+}
+
+// CoordinatorOption is a function that can be used within the coordinator constructor to
+// modify the coordinator object.
+type CoordinatorOption func(c *Coordinator)
+
+// NewCoordinator instantiates a new Coordinator.
+func NewCoordinator(opts ...CoordinatorOption) *Coordinator {
+	c := &Coordinator{
+		parallelism: DefaultParallelism,
+		logger:      log.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithParallelism sets the maximum number of databases that are migrated at the same time.
+func WithParallelism(parallelism int) CoordinatorOption {
+	return func(c *Coordinator) {
+		if parallelism < 1 {
+			parallelism = 1
+		}
+		c.parallelism = parallelism
+	}
+}
+
+// WithStopOnFailure stops the run after the first failed target. Running migrations are completed, targets that
+// were not started yet are reported as failed with ErrTargetSkipped.
+func WithStopOnFailure(stop bool) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.stopOnFailure = stop
+	}
+}
+
+// WithCoordinatorLogger sets the logger of the coordinator, e.g. for failed registry updates. The drivers of the
+// targets use their own logger, see WithLogger. Defaults to log.Default().
+func WithCoordinatorLogger(logger lightmigrate.Logger) CoordinatorOption {
+	return func(c *Coordinator) {
+		c.logger = logger
+	}
+}
+
+// Migrate migrates all targets to their configured version. Unless WithStopOnFailure is set, all targets are
+// migrated, even if some of them fail. Targets that were not started before the context was cancelled are reported
+// as failed. If at least one target failed, a *CoordinatorError is returned.
+func (c *Coordinator) Migrate(ctx context.Context, targets []Target) error {
+	_, err := c.MigrateWithReport(ctx, targets)
+	return err
+}
+
+// MigrateWithReport migrates all targets, like Migrate, and reports the result of each target.
+// The report is also returned if targets failed.
+func (c *Coordinator) MigrateWithReport(ctx context.Context, targets []Target) (*Report, error) {
+	names := make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		if _, ok := names[target.name()]; ok {
+			return nil, fmt.Errorf("duplicate migration target %s", target.name())
+		}
+		names[target.name()] = struct{}{}
+	}
+
+	if c.registry != nil {
+		if err := c.registry.prepare(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	runCtx, stop := context.WithCancel(ctx) // stops the run after the first failure, see WithStopOnFailure
+	defer stop()
+
+	// targets are started in the given order
+	jobs := make(chan Target)
+	go func() {
+		defer close(jobs)
+		for _, target := range targets {
+			jobs <- target
+		}
+	}()
+
+	var mux sync.Mutex
+	report := &Report{}
+	var wg sync.WaitGroup
+	for i := 0; i < c.parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for target := range jobs {
+				var result TargetResult
+				if runCtx.Err() == nil { // do not start new migrations after cancellation
+					result = c.migrate(target)
+					if c.registry != nil {
+						if err := c.registry.record(ctx, result); err != nil {
+							c.logger.Printf("target %s: %v", result.Target, err)
+						}
+					}
+				} else {
+					result = TargetResult{Target: target.name(), Skipped: true, Err: ctx.Err()}
+					if result.Err == nil {
+						result.Err = ErrTargetSkipped
+					}
+				}
+				if result.Err != nil && c.stopOnFailure {
+					stop()
+				}
+
+				mux.Lock()
+				report.Results = append(report.Results, result)
+				mux.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(report.Results, func(i, j int) bool { return report.Results[i].Target < report.Results[j].Target })
+
+	var failed []TargetError
+	for _, result := range report.Results {
+		if result.Err != nil {
+			failed = append(failed, TargetError{Target: result.Target, Err: result.Err})
+		}
+	}
+	if len(failed) == 0 {
+		return report, nil
+	}
+
+	return report, &CoordinatorError{Failed: failed, Total: len(targets)}
+}
+
+// migrate runs the migration for a single target.
+func (c *Coordinator) migrate(target Target) (result TargetResult) {
+	result.Target = target.name()
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	var driver Driver
+	if target.Client == nil && target.DSN != "" {
+		driver, result.Err = NewDriverFromDSN(target.DSN, target.DriverOptions...)
+	} else {
+		driver, result.Err = NewDriver(target.Client, target.Database, target.DriverOptions...)
+	}
+	if result.Err != nil {
+		return result
+	}
+	defer driver.Close()
+	if c.registry != nil {
+		result.server, result.database = registryKey(driver)
+	}
+
+	if result.FromVersion, result.Dirty, result.Err = driver.GetVersion(); result.Err != nil {
+		return result
+	}
+
+	migrator, err := lightmigrate.NewMigrator(target.Source, driver, target.MigratorOptions...)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.Err = migrator.Migrate(target.Version)
+	if version, dirty, err := driver.GetVersion(); err == nil {
+		result.ToVersion, result.Dirty, result.versionKnown = version, dirty, true
+	} else if result.Err == nil {
+		result.Err = err
+	}
+
+	return result
+}
@@ -0,0 +1,93 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/h44z/lightmigrate"
+)
+
+func testSource(t *testing.T) lightmigrate.MigrationSource {
+	fsys := fstest.MapFS{
+		"migrations/1_init.up.sql":   {Data: []byte("CREATE TABLE a (id int);")},
+		"migrations/1_init.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+	source, err := lightmigrate.NewFsSource(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	return source
+}
+
+func TestWithParallelism(t *testing.T) {
+	c := NewCoordinator(WithParallelism(0))
+	if c.parallelism != 1 {
+		t.Fatalf("unexpected parallelism 1, got: %d", c.parallelism)
+	}
+
+	c = NewCoordinator(WithParallelism(8))
+	if c.parallelism != 8 {
+		t.Fatalf("unexpected parallelism 8, got: %d", c.parallelism)
+	}
+}
+
+func TestCoordinator_Migrate(t *testing.T) {
+	errCreate := errors.New("access denied")
+	okDB, okSrv := newFakeDB(t, nil)
+	failDB, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "CREATE TABLE IF NOT EXISTS") {
+			return fakeResponse{Err: errCreate}
+		}
+		return defaultFakeHandler(call)
+	})
+
+	source := testSource(t)
+	targets := []Target{
+		{Client: okDB, Database: "db1", Source: source, Version: 1},
+		{Name: "broken", Client: failDB, Database: "db2", Source: source, Version: 1},
+	}
+
+	err := NewCoordinator(WithParallelism(2)).Migrate(context.Background(), targets)
+	var coordErr *CoordinatorError
+	if !errors.As(err, &coordErr) {
+		t.Fatalf("expected coordinator error, got: %v", err)
+	}
+	if coordErr.Total != 2 || len(coordErr.Failed) != 1 || coordErr.Failed[0].Target != "broken" {
+		t.Fatalf("unexpected coordinator error: %v", coordErr)
+	}
+	if !errors.Is(coordErr.Failed[0], errCreate) {
+		t.Fatalf("unexpected target error %v, got: %v", errCreate, coordErr.Failed[0].Err)
+	}
+
+	applied := false
+	for _, query := range okSrv.Queries() {
+		if query == "CREATE TABLE a (id int)" {
+			applied = true
+		}
+	}
+	if !applied {
+		t.Fatalf("migration was not applied to db1")
+	}
+}
+
+func TestCoordinator_Migrate_Duplicate(t *testing.T) {
+	targets := []Target{{Database: "db"}, {Database: "db"}}
+
+	if err := NewCoordinator().Migrate(context.Background(), targets); err == nil {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
+
+func TestCoordinator_Migrate_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewCoordinator().Migrate(ctx, []Target{{Database: "db"}})
+	var coordErr *CoordinatorError
+	if !errors.As(err, &coordErr) || !errors.Is(coordErr.Failed[0], context.Canceled) {
+		t.Fatalf("unexpected error %v, got: %v", context.Canceled, err)
+	}
+}
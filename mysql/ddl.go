@@ -0,0 +1,89 @@
+package mysql
+
+import "strings"
+
+// ddlKeywords are the first keywords of data definition statements.
+var ddlKeywords = []string{"CREATE", "ALTER", "DROP", "RENAME", "TRUNCATE"}
+
+// isDDL checks if the statement is a data definition statement.
+func isDDL(query string) bool {
+	keyword := strings.ToUpper(firstKeyword(query))
+	for _, ddl := range ddlKeywords {
+		if keyword == ddl {
+			return true
+		}
+	}
+	return false
+}
+
+// firstKeyword returns the first word of the statement. Statements are already stripped of comments by the
+// statement scanner, only executable comments might precede the keyword.
+func firstKeyword(query string) string {
+	query = strings.TrimSpace(query)
+	for strings.HasPrefix(query, "/*") {
+		end := strings.Index(query, "*/")
+		if end < 0 {
+			return ""
+		}
+		query = strings.TrimSpace(query[end+2:])
+	}
+
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	word := fields[0]
+	if idx := strings.IndexAny(word, "(;"); idx >= 0 {
+		word = word[:idx]
+	}
+	return word
+}
+
+// atomicDDLRollback checks if the failed statement was a DDL statement that was rolled back atomically by the
+// server, so that no partially created or changed objects were left behind.
+func (d *driver) atomicDDLRollback(stmt statement) bool {
+	return d.server.supportsAtomicDDL() && isDDL(stmt.Query)
+}
+
+// recoverAtomicDDL restores the last clean version, if the migration failed with its first statement and this
+// statement was an atomically rolled back DDL statement. The database is then unchanged and must not stay dirty.
+func (d *driver) recoverAtomicDDL(state *migrationState) bool {
+	if !d.cfg.AtomicDDLRecovery || state.Failed == nil || state.Result.result.Statements > 0 ||
+		!d.atomicDDLRollback(*state.Failed) {
+		return false
+	}
+
+	return d.restorePreviousVersion("migration failed with an atomically rolled back DDL statement")
+}
+
+// recoverRefusal restores the clean version of a migration that was refused before its first statement.
+func (d *driver) recoverRefusal(state *migrationState) bool {
+	if !state.Refused || state.Result.result.Statements > 0 {
+		return false
+	}
+	return d.restorePreviousVersion("migration was refused")
+}
+
+// restorePreviousVersion replaces the dirty version in the migrations table with the previous clean version.
+func (d *driver) restorePreviousVersion(reason string) bool {
+	if d.store != nil {
+		return false // the previous version is only kept in the migrations table
+	}
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	row, err := d.readVersionRow(ctx, d.client, false)
+	if err != nil || row == nil || !row.Dirty || !row.PreviousVersion.Valid {
+		return false
+	}
+
+	if err := d.SetVersion(uint64(row.PreviousVersion.Int64), false); err != nil {
+		d.logf("failed to restore version %d (%s): %v", row.PreviousVersion.Int64, reason, err)
+		return false
+	}
+
+	d.logf("%s, migration %d is not applied, restored clean version %d", reason, row.Version,
+		row.PreviousVersion.Int64)
+	return true
+}
@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_isDDL(t *testing.T) {
+	tests := map[string]bool{
+		"CREATE TABLE a (id int)":              true,
+		"alter table a add column b int":       true,
+		"/*!40101 DROP TABLE a */":             false,
+		"/*!40101 SET NAMES utf8 */ DROP VIEW": true,
+		"RENAME TABLE a TO b":                  true,
+		"INSERT INTO a VALUES (1)":             false,
+		"CREATE(":                              true,
+		"":                                     false,
+	}
+	for query, want := range tests {
+		if got := isDDL(query); got != want {
+			t.Fatalf("unexpected DDL detection %t for %q", want, query)
+		}
+	}
+}
+
+func Test_driver_RunMigration_AtomicDDLRecovery(t *testing.T) {
+	errFailed := errors.New("duplicate column name")
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case strings.HasPrefix(call.Query, "ALTER TABLE"):
+			return fakeResponse{Err: errFailed}
+		case strings.HasPrefix(call.Query, "SELECT version"):
+			return versionHandler([]sqldriver.Value{int64(5), int64(1), int64(4), nil, int64(1)})(call)
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true, AtomicDDLRecovery: true}}
+	d.server = parseServerVersion("8.0.35")
+
+	err := d.RunMigration(strings.NewReader("ALTER TABLE a ADD COLUMN b int;\nUPDATE a SET b = 1;"))
+	if !errors.Is(err, errFailed) || !strings.Contains(err.Error(), "rolled back atomically") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var restored bool
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `migrations`") && call.Args[0] == int64(4) && call.Args[1] == false {
+			restored = true
+		}
+		if strings.HasPrefix(call.Query, "UPDATE `migrations` SET error_statement") {
+			t.Fatalf("failure must not be recorded for recovered migration")
+		}
+	}
+	if !restored {
+		t.Fatalf("clean version was not restored: %q", srv.Queries())
+	}
+}
+
+func Test_driver_RunMigration_AtomicDDLRecoveryPartial(t *testing.T) {
+	errFailed := errors.New("duplicate column name")
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "ALTER TABLE") {
+			return fakeResponse{Err: errFailed}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true, AtomicDDLRecovery: true}}
+	d.server = parseServerVersion("8.0.35")
+
+	if err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int);\nALTER TABLE a ADD COLUMN b int;")); !errors.Is(err, errFailed) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "INSERT INTO") {
+			t.Fatalf("version must stay dirty if statements were applied before the failure")
+		}
+	}
+}
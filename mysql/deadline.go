@@ -0,0 +1,56 @@
+package mysql
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// WithRunDeadline bounds the total time of a single migration run, which starts when the migrator acquires the
+// migration lock. Once the deadline has passed, the driver does not start further migrations or statements.
+// Running statements are not interrupted, see WithStatementTimeout. A value of 0 disables the deadline.
+func WithRunDeadline(deadline time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.RunDeadline = deadline
+	}
+}
+
+// startRun starts the clock of the run deadline.
+func (d *driver) startRun() {
+	atomic.StoreInt64(&d.runStarted, time.Now().UnixNano())
+}
+
+// runDeadlineExceeded checks if the run deadline has passed.
+func (d *driver) runDeadlineExceeded() bool {
+	started := atomic.LoadInt64(&d.runStarted)
+	if d.cfg.RunDeadline <= 0 || started == 0 {
+		return false
+	}
+	return time.Since(time.Unix(0, started)) > d.cfg.RunDeadline
+}
+
+// checkRunDeadlineBeforeMigration prevents that a new migration is marked dirty after the run deadline has passed,
+// so the database stays at the last clean version.
+func (d *driver) checkRunDeadlineBeforeMigration(version uint64) error {
+	if !d.runDeadlineExceeded() {
+		return nil
+	}
+	return &lightmigrate.DriverError{
+		OrigErr: ErrRunDeadlineExceeded,
+		Msg:     fmt.Sprintf("run deadline of %v exceeded, migration %d was not started", d.cfg.RunDeadline, version),
+	}
+}
+
+// checkRunDeadline stops a migration before the given statement, if the run deadline has passed.
+// The error reports how many statements of the migration were applied before.
+func (d *driver) checkRunDeadline(state *migrationState, stmt statement) error {
+	if !d.runDeadlineExceeded() {
+		return nil
+	}
+	state.Failed = &stmt
+	return stmt.error(fmt.Sprintf("run deadline of %v exceeded before statement %d, %d statements of the "+
+		"migration were applied", d.cfg.RunDeadline, stmt.Index, state.Result.result.Statements),
+		ErrRunDeadlineExceeded)
+}
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+func TestWithRunDeadline(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithRunDeadline(time.Minute)(d)
+	if d.cfg.RunDeadline != time.Minute {
+		t.Fatalf("failed to set run deadline")
+	}
+}
+
+func Test_driver_RunMigration_RunDeadline(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, RunDeadline: time.Hour}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.RunMigration(strings.NewReader("SELECT 1; SELECT 2;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d.runStarted = time.Now().Add(-2 * time.Hour).UnixNano()
+	srv.Reset()
+
+	err := d.RunMigration(strings.NewReader("SELECT 1;\nSELECT 2;"))
+	if !errors.Is(err, ErrRunDeadlineExceeded) {
+		t.Fatalf("unexpected error %v, got: %v", ErrRunDeadlineExceeded, err)
+	}
+	var driverErr *lightmigrate.DriverError
+	if !errors.As(err, &driverErr) || driverErr.Line != 1 || !strings.Contains(driverErr.Msg, "before statement 1") {
+		t.Fatalf("unexpected error details, got: %v", err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "SELECT 1") {
+			t.Fatalf("unexpected statement after the deadline: %q", srv.Queries())
+		}
+	}
+
+	if err := d.SetVersion(3, true); !errors.Is(err, ErrRunDeadlineExceeded) {
+		t.Fatalf("unexpected error %v, got: %v", ErrRunDeadlineExceeded, err)
+	}
+}
+
+func Test_driver_runDeadlineExceeded_KeptOnResume(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", Locking: true, RunDeadline: time.Hour}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.runStarted = time.Now().Add(-2 * time.Hour).UnixNano()
+
+	resume, err := d.suspendLock("no-lock directive")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := resume(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !d.runDeadlineExceeded() {
+		t.Fatalf("expected the run deadline to survive the lock resume")
+	}
+}
@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"errors"
+	"sync"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// Optional features of the driver. If the database user lacks the privileges that are required for one of these
+// features, the feature is disabled instead of failing the migration.
+const (
+	featureColumnCheck = "migration table upgrade check"
+	featureWarnings    = "warnings capture"
+	featureLockStatus  = "lock status"
+)
+
+// privilegeErrorCodes are the MySQL error codes that signal missing privileges.
+var privilegeErrorCodes = map[uint16]struct{}{
+	1044: {}, // ER_DBACCESS_DENIED_ERROR
+	1142: {}, // ER_TABLEACCESS_DENIED_ERROR
+	1143: {}, // ER_COLUMNACCESS_DENIED_ERROR
+	1227: {}, // ER_SPECIFIC_ACCESS_DENIED_ERROR
+	1370: {}, // ER_PROCACCESS_DENIED_ERROR
+}
+
+// isPrivilegeError checks if the error was caused by missing privileges of the database user.
+func isPrivilegeError(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	_, ok := privilegeErrorCodes[mysqlErr.Number]
+	return ok
+}
+
+// featureSet keeps track of the optional features that were disabled at runtime.
+type featureSet struct {
+	mux      sync.Mutex
+	disabled map[string]struct{}
+}
+
+func newFeatureSet() *featureSet {
+	return &featureSet{disabled: make(map[string]struct{})}
+}
+
+// featureDisabled checks if the optional feature was disabled due to missing privileges.
+func (d *driver) featureDisabled(feature string) bool {
+	if d.features == nil {
+		return false
+	}
+
+	d.features.mux.Lock()
+	defer d.features.mux.Unlock()
+
+	_, ok := d.features.disabled[feature]
+	return ok
+}
+
+// degrade disables the optional feature if err was caused by missing privileges, the reason is logged once.
+// For all other errors, false is returned and the caller has to handle the error.
+func (d *driver) degrade(feature string, err error) bool {
+	if !isPrivilegeError(err) {
+		return false
+	}
+
+	if d.features != nil {
+		d.features.mux.Lock()
+		_, known := d.features.disabled[feature]
+		d.features.disabled[feature] = struct{}{}
+		d.features.mux.Unlock()
+		if known {
+			return true
+		}
+	}
+
+	d.logf("%s disabled, the database user lacks the required privileges: %v", feature, err)
+	return true
+}
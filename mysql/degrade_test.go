@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+func Test_isPrivilegeError(t *testing.T) {
+	denied := &lightmigrate.DriverError{OrigErr: &gomysql.MySQLError{Number: 1142, Message: "SELECT command denied"}}
+	if !isPrivilegeError(denied) {
+		t.Fatalf("expected privilege error")
+	}
+	if isPrivilegeError(&gomysql.MySQLError{Number: 1064}) || isPrivilegeError(errors.New("denied")) {
+		t.Fatalf("unexpected privilege error")
+	}
+}
+
+func Test_driver_degrade(t *testing.T) {
+	logs := &bytes.Buffer{}
+	d := &driver{logger: log.New(logs, "", 0), features: newFeatureSet()}
+	denied := &gomysql.MySQLError{Number: 1227, Message: "access denied"}
+
+	if d.degrade(featureWarnings, errors.New("connection lost")) || d.featureDisabled(featureWarnings) {
+		t.Fatalf("unexpected degradation for non-privilege error")
+	}
+	if !d.degrade(featureWarnings, denied) || !d.degrade(featureWarnings, denied) {
+		t.Fatalf("expected degradation for privilege error")
+	}
+	if !d.featureDisabled(featureWarnings) || d.featureDisabled(featureLockStatus) {
+		t.Fatalf("unexpected disabled features: %v", d.features.disabled)
+	}
+	if strings.Count(logs.String(), "warnings capture disabled") != 1 {
+		t.Fatalf("unexpected log output: %s", logs.String())
+	}
+}
+
+func Test_driver_ensureColumns_Restricted(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SHOW COLUMNS") {
+			return fakeResponse{Err: &gomysql.MySQLError{Number: 1142, Message: "SELECT command denied"}}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{}, features: newFeatureSet()}
+
+	if err := d.ensureColumns(context.Background(), "migrations", versionTableColumns); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.ensureColumns(context.Background(), "migrations", versionTableColumns); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 1 {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_Status_Restricted(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case strings.HasPrefix(call.Query, "SELECT version"):
+			return versionHandler([]sqldriver.Value{int64(4), int64(0), nil, nil, nil})(call)
+		case strings.HasPrefix(call.Query, "SELECT IS_USED_LOCK"):
+			return fakeResponse{Err: &gomysql.MySQLError{Number: 1370, Message: "execute command denied"}}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", Locking: true}, features: newFeatureSet()}
+
+	status, err := d.Status(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Version != 4 || status.LockHeld {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
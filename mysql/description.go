@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"io"
+	"strings"
+)
+
+// DescriptionPrefix starts the description comment of a migration, e.g. "-- description: add the users table".
+// The comment must precede the first statement.
+const DescriptionPrefix = "description:"
+
+const (
+	maxDescriptionLength = 255
+	descriptionPeekSize  = 4096
+)
+
+// parseDescription extracts the description comment from the beginning of the migration. Only the leading
+// comments within the first few kilobytes are searched. The returned reader must be used instead of the migration.
+func parseDescription(migration io.Reader) (string, io.Reader) {
+	r := bufio.NewReaderSize(migration, descriptionPeekSize)
+	head, _ := r.Peek(descriptionPeekSize) // shorter migrations are returned with an error
+
+	for _, line := range strings.Split(string(head), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "#") {
+			break // the first statement
+		}
+
+		comment := strings.TrimSpace(strings.TrimLeft(line, "-#"))
+		if len(comment) >= len(DescriptionPrefix) && strings.EqualFold(comment[:len(DescriptionPrefix)], DescriptionPrefix) {
+			return truncateMessage(strings.TrimSpace(comment[len(DescriptionPrefix):]), maxDescriptionLength), r
+		}
+	}
+
+	return "", r
+}
+
+// versionDescription returns the description of the last successful up migration to the given version. Down
+// migrations are recorded under the version they revert to, their description belongs to the reverted version.
+// Without history, or if the migration had no description, an empty string is returned.
+func (d *driver) versionDescription(ctx context.Context, version uint64) (string, error) {
+	if !d.cfg.History {
+		return "", nil
+	}
+
+	query := "SELECT description FROM `" + d.historyTable() + "` WHERE version = ? AND success AND " +
+		"(direction IS NULL OR direction = 'up') ORDER BY id DESC LIMIT 1"
+	var description sql.NullString
+	err := d.client.QueryRowContext(ctx, query, version).Scan(&description)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+
+	return description.String, nil
+}
@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_parseDescription(t *testing.T) {
+	tests := []struct {
+		name      string
+		migration string
+		want      string
+	}{
+		{"none", "CREATE TABLE users (id int);", ""},
+		{"leading", "-- Description: add the users table\nCREATE TABLE users (id int);", "add the users table"},
+		{"after other comments", "\n-- ticket 1234\n#description:  hash comment \nSELECT 1;", "hash comment"},
+		{"after statement", "SELECT 1;\n-- description: too late", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			description, migration := parseDescription(strings.NewReader(tt.migration))
+			if description != tt.want {
+				t.Fatalf("unexpected description %q, got: %q", tt.want, description)
+			}
+			if content, _ := io.ReadAll(migration); string(content) != tt.migration {
+				t.Fatalf("unexpected migration content, got: %q", content)
+			}
+		})
+	}
+}
+
+func Test_driver_RunMigration_Description(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, History: true}}
+
+	if err := d.RunMigration(strings.NewReader("-- description: add users\nSELECT 1;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_history`") {
+			if description := call.Args[9]; description != "add users" {
+				t.Fatalf("unexpected description add users, got: %v", description)
+			}
+			return
+		}
+	}
+	t.Fatalf("expected history row, got queries: %q", srv.Queries())
+}
+
+func Test_driver_versionDescription(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT description") && strings.Contains(call.Query, "direction = 'up'") {
+			return fakeResponse{Columns: []string{"description"}, Rows: [][]sqldriver.Value{{"add users"}}}
+		}
+		return fakeResponse{Columns: []string{"description"}, Rows: [][]sqldriver.Value{{"drop orders"}}}
+	})
+	d := &driver{client: db, cfg: &config{History: true}}
+
+	description, err := d.versionDescription(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if description != "add users" {
+		t.Fatalf("unexpected description add users, got: %q", description)
+	}
+}
@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"unicode/utf8"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+// maxErrorMessageLength is the maximum length (in bytes) of an error message that is stored in the migration table.
+const maxErrorMessageLength = 16 * 1024
+
+// MigrationFailure describes why a migration failed. It is stored alongside the dirty flag.
+type MigrationFailure struct {
+	// StatementIndex is the 1-based index of the failed statement within the migration, 0 if the failure
+	// happened before the first statement (e.g. while reading the migration).
+	StatementIndex int `json:"statement_index"`
+	// ErrorCode is the MySQL error number, 0 if the error was not reported by the server.
+	ErrorCode uint16 `json:"error_code,omitempty"`
+	// Message is the error message.
+	Message string `json:"message"`
+	// Idempotent is true if the migration may be re-applied automatically.
+	Idempotent bool `json:"idempotent"`
+}
+
+// newMigrationFailure collects the diagnostics for the given migration error.
+func newMigrationFailure(state *migrationState, err error) MigrationFailure {
+	failure := MigrationFailure{
+		Message:    truncateMessage(err.Error(), maxErrorMessageLength),
+		Idempotent: state.Idempotent,
+	}
+	if state.Failed != nil {
+		failure.StatementIndex = state.Failed.Index
+	}
+
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		failure.ErrorCode = mysqlErr.Number
+	}
+
+	return failure
+}
+
+// recordRunError keeps the error of a failed SetVersion call as failure of the current run, e.g. if the run
+// deadline passed before the next migration. The database stays clean, but the run did not reach its target.
+func (d *driver) recordRunError(err *error) {
+	if *err == nil || !d.runActive || d.runFailure != nil {
+		return
+	}
+	d.runFailure = &MigrationFailure{Message: truncateMessage((*err).Error(), maxErrorMessageLength)}
+}
+
+// recordFailure stores the failure diagnostics in the (dirty) version row of the migration table.
+// Errors are only logged, as the original migration error is more important for the caller.
+func (d *driver) recordFailure(failure MigrationFailure) {
+	d.runFailure = &failure
+	if d.store != nil {
+		return // the diagnostics are only kept in the migrations table
+	}
+
+	var errorCode interface{}
+	if failure.ErrorCode != 0 {
+		errorCode = failure.ErrorCode
+	}
+
+	query := "UPDATE `" + d.migrationsTable() + "` SET error_statement = ?, error_code = ?, error_message = ?, " +
+		"idempotent = ? WHERE dirty"
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	_, err := d.client.ExecContext(ctx, query, failure.StatementIndex, errorCode, failure.Message, failure.Idempotent)
+	if err != nil {
+		d.logf("failed to record migration failure diagnostics: %v", err)
+	}
+}
+
+// readFailure reads the failure diagnostics of the dirty version. If no diagnostics are available, nil is returned.
+func (d *driver) readFailure(ctx context.Context) (*MigrationFailure, error) {
+	var statementIndex, errorCode sql.NullInt64
+	var message sql.NullString
+	var idempotent sql.NullBool
+
+	query := "SELECT error_statement, error_code, error_message, idempotent FROM `" + d.migrationsTable() +
+		"` WHERE dirty LIMIT 1"
+	err := d.client.QueryRowContext(ctx, query).Scan(&statementIndex, &errorCode, &message, &idempotent)
+	switch {
+	case err == sql.ErrNoRows || (err == nil && !message.Valid):
+		return nil, nil
+	case err != nil:
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to select failure diagnostics", Query: []byte(query)}
+	}
+
+	return &MigrationFailure{
+		StatementIndex: int(statementIndex.Int64),
+		ErrorCode:      uint16(errorCode.Int64),
+		Message:        message.String,
+		Idempotent:     idempotent.Bool,
+	}, nil
+}
+
+// truncateMessage shortens msg to at most maxLen bytes without splitting multi-byte characters.
+func truncateMessage(msg string, maxLen int) string {
+	if len(msg) <= maxLen {
+		return msg
+	}
+
+	msg = msg[:maxLen]
+	for len(msg) > 0 && !utf8.ValidString(msg) {
+		msg = msg[:len(msg)-1]
+	}
+	return msg
+}
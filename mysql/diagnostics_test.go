@@ -0,0 +1,56 @@
+package mysql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+func Test_newMigrationFailure(t *testing.T) {
+	err := &lightmigrate.DriverError{OrigErr: &gomysql.MySQLError{Number: 1064, Message: "syntax error"}, Msg: "migration failed"}
+
+	failure := newMigrationFailure(&migrationState{Failed: &statement{Index: 3}, Idempotent: true}, err)
+	if failure.StatementIndex != 3 || failure.ErrorCode != 1064 || failure.Message != err.Error() || !failure.Idempotent {
+		t.Fatalf("unexpected failure: %+v", failure)
+	}
+
+	failure = newMigrationFailure(&migrationState{}, errors.New("read failed"))
+	if failure.StatementIndex != 0 || failure.ErrorCode != 0 || failure.Message != "read failed" {
+		t.Fatalf("unexpected failure: %+v", failure)
+	}
+}
+
+func Test_truncateMessage(t *testing.T) {
+	if msg := truncateMessage("short", 10); msg != "short" {
+		t.Fatalf("unexpected message short, got: %s", msg)
+	}
+	if msg := truncateMessage("abcäöü", 5); msg != "abcä" {
+		t.Fatalf("unexpected message abcä, got: %s", msg)
+	}
+}
+
+func Test_driver_RunMigration_RecordFailure(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "BROKEN") {
+			return fakeResponse{Err: &gomysql.MySQLError{Number: 1064, Message: "syntax error"}}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true}}
+
+	if err := d.RunMigration(strings.NewReader("SELECT 1;\nBROKEN;")); err == nil {
+		t.Fatalf("expected error, got: %v", err)
+	}
+
+	calls := srv.Calls()
+	last := calls[len(calls)-1]
+	if !strings.HasPrefix(last.Query, "UPDATE `migrations` SET error_statement") {
+		t.Fatalf("failure was not recorded, got: %s", last.Query)
+	}
+	if last.Args[0] != int64(2) || last.Args[1] != int64(1064) || !strings.Contains(last.Args[2].(string), "syntax error") {
+		t.Fatalf("unexpected failure arguments: %v", last.Args)
+	}
+}
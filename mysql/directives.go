@@ -0,0 +1,26 @@
+package mysql
+
+// Directives are special comments ("-- lightmigrate:<directive> [argument]") that are placed between the
+// statements of a migration and change how the driver executes the migration.
+const (
+	// directiveInclude includes the statements of another file, see WithIncludeFS.
+	directiveInclude = "include"
+	// directiveParallel starts a block of statements that are executed concurrently.
+	directiveParallel = "parallel"
+	// directiveParallelEnd ends a block that was started by directiveParallel.
+	directiveParallelEnd = "parallel-end"
+	// directiveIdempotent marks a migration as safe to be re-applied, see WithDirtyRetry.
+	directiveIdempotent = "idempotent"
+	// directiveNoLock releases the migration lock while the statements of the migration are executed.
+	directiveNoLock = "no-lock"
+	// directiveSkip records the migration as applied without executing it, e.g. if it was applied manually.
+	directiveSkip = "skip"
+	// directiveTables declares the tables of a migration, see WithTableScopedLocking.
+	directiveTables = "tables"
+	// directiveInfile provides a data file for the following LOAD DATA LOCAL INFILE statement, see WithInfileFS.
+	directiveInfile = "infile"
+	// directiveHeavy restricts a migration to the maintenance windows, see WithMaintenanceWindow.
+	directiveHeavy = "heavy"
+	// directiveMaxDuration sets the budget of a migration, e.g. "-- lightmigrate:max-duration=10m".
+	directiveMaxDuration = "max-duration"
+)
@@ -0,0 +1,165 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// DownVerification is the result of the down migration of a single version, see VerifyDownMigrations.
+type DownVerification struct {
+	Version uint64 `json:"version"`
+	// Identifier is the identifier of the down migration within the source.
+	Identifier string `json:"identifier,omitempty"`
+	// Missing is true, if the source has no down migration for the version.
+	Missing bool `json:"missing,omitempty"`
+	// Diff lists the schema objects that differ after the up and the down migration, e.g. "added table users".
+	Diff []string `json:"diff,omitempty"`
+	// Err is the error of a failed up or down migration.
+	Err error `json:"-"`
+}
+
+// Failed returns true, if the down migration is missing, failed or did not restore the schema.
+func (v DownVerification) Failed() bool {
+	return v.Missing || v.Err != nil || len(v.Diff) > 0
+}
+
+// DownVerificationReport is the result of VerifyDownMigrations.
+type DownVerificationReport struct {
+	// Results contains the result of each verified version, in migration order.
+	Results []DownVerification `json:"results"`
+}
+
+// Failed returns the results of all versions whose down migration failed the verification.
+func (r *DownVerificationReport) Failed() []DownVerification {
+	var failed []DownVerification
+	for _, result := range r.Results {
+		if result.Failed() {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Error describes all failed versions, it returns an empty string if all down migrations are valid.
+func (r *DownVerificationReport) Error() string {
+	var msgs []string
+	for _, result := range r.Failed() {
+		switch {
+		case result.Missing:
+			msgs = append(msgs, fmt.Sprintf("version %d: no down migration", result.Version))
+		case result.Err != nil:
+			msgs = append(msgs, fmt.Sprintf("version %d: %v", result.Version, result.Err))
+		default:
+			msgs = append(msgs, fmt.Sprintf("version %d: %s", result.Version, strings.Join(result.Diff, ", ")))
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// VerifyDownMigrations applies each up migration of the source to the given scratch database, followed by its down
+// migration, and compares the schema before and after both migrations. The up migration is applied again before
+// the next version is verified. The database must be a disposable database, e.g. of a CI pipeline, as all
+// migrations are executed without recording the versions.
+//
+// If a down migration is missing, fails or does not restore the schema, ErrDownMigrationInvalid is returned and
+// the report describes all failed versions.
+func VerifyDownMigrations(ctx context.Context, client *sql.DB, database string, source lightmigrate.MigrationSource,
+	opts ...DriverOption) (*DownVerificationReport, error) {
+	drv, err := NewDriver(client, database, append(opts, withoutSideEffects())...)
+	if err != nil {
+		return nil, err
+	}
+	defer drv.Close()
+	d := drv.(*driver)
+
+	report := &DownVerificationReport{}
+	exclude := d.internalTables()
+
+	version, sourceErr := source.First()
+	for sourceErr == nil {
+		result := DownVerification{Version: version}
+		before, err := snapshotSchema(ctx, client, database, exclude)
+		if err != nil {
+			return report, err
+		}
+
+		if _, _, err := d.runSourceMigration(source, version, true); err != nil {
+			return report, fmt.Errorf("up migration %d failed: %w", version, err)
+		}
+
+		var found bool
+		result.Identifier, found, err = d.runSourceMigration(source, version, false)
+		switch {
+		case !found:
+			result.Missing = true
+		case err != nil:
+			result.Err = err
+			report.Results = append(report.Results, result)
+			return report, fmt.Errorf("%w: %s", ErrDownMigrationInvalid, report.Error())
+		default:
+			after, err := snapshotSchema(ctx, client, database, exclude)
+			if err != nil {
+				return report, err
+			}
+			result.Diff = before.diff(after)
+
+			// the next version requires the schema of this version
+			if _, _, err := d.runSourceMigration(source, version, true); err != nil {
+				result.Err = fmt.Errorf("up migration failed after the down migration: %w", err)
+				report.Results = append(report.Results, result)
+				return report, fmt.Errorf("%w: %s", ErrDownMigrationInvalid, report.Error())
+			}
+		}
+		report.Results = append(report.Results, result)
+
+		version, sourceErr = source.Next(version)
+	}
+	if !errors.Is(sourceErr, os.ErrNotExist) {
+		return report, fmt.Errorf("failed to read migration source: %w", sourceErr)
+	}
+
+	if len(report.Failed()) > 0 {
+		return report, fmt.Errorf("%w: %s", ErrDownMigrationInvalid, report.Error())
+	}
+	return report, nil
+}
+
+// VerifyDownMigrationsFromDSN verifies the down migrations, like VerifyDownMigrations, within a throwaway database
+// that is created on the server of the DSN and dropped afterwards.
+func VerifyDownMigrationsFromDSN(ctx context.Context, dsn string, source lightmigrate.MigrationSource,
+	opts ...DriverOption) (*DownVerificationReport, error) {
+	scratch, err := createScratchDatabase(ctx, dsn, "verify")
+	if err != nil {
+		return nil, err
+	}
+	defer scratch.Drop(ctx)
+
+	return VerifyDownMigrations(ctx, scratch.Client, scratch.Name, source, opts...)
+}
+
+// runSourceMigration runs the up or down migration of the version and returns its identifier. If the source has
+// no such migration, found is false.
+func (d *driver) runSourceMigration(source lightmigrate.MigrationSource, version uint64,
+	up bool) (identifier string, found bool, err error) {
+	read := source.ReadDown
+	if up {
+		read = source.ReadUp
+	}
+
+	migration, identifier, err := read(version)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", false, err
+	}
+	if err != nil {
+		return "", true, fmt.Errorf("failed to read migration: %w", err)
+	}
+	defer migration.Close()
+
+	return identifier, true, d.RunMigration(migration)
+}
@@ -0,0 +1,125 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/h44z/lightmigrate"
+)
+
+var (
+	fakeCreatePattern = regexp.MustCompile("^CREATE TABLE (\\w+) \\(")
+	fakeDropPattern   = regexp.MustCompile("^DROP TABLE (\\w+)")
+	fakeAlterPattern  = regexp.MustCompile("^ALTER TABLE (\\w+) ADD COLUMN (\\w+)")
+	fakeShowPattern   = regexp.MustCompile("^SHOW CREATE TABLE `[^`]+`\\.`([^`]+)`")
+)
+
+// schemaHandler simulates the tables of a database, it supports simple CREATE, DROP and ALTER TABLE statements.
+func schemaHandler() fakeHandler {
+	var mux sync.Mutex
+	tables := map[string]string{"schema_migrations": "CREATE TABLE schema_migrations (version bigint)"}
+
+	return func(call fakeCall) fakeResponse {
+		mux.Lock()
+		defer mux.Unlock()
+
+		if match := fakeCreatePattern.FindStringSubmatch(call.Query); match != nil {
+			tables[match[1]] = "CREATE TABLE `" + match[1] + "` (id int)"
+		} else if match := fakeDropPattern.FindStringSubmatch(call.Query); match != nil {
+			delete(tables, match[1])
+		} else if match := fakeAlterPattern.FindStringSubmatch(call.Query); match != nil {
+			tables[match[1]] += " " + match[2]
+		} else if match := fakeShowPattern.FindStringSubmatch(call.Query); match != nil {
+			return fakeResponse{Columns: []string{"Table", "Create Table"},
+				Rows: [][]sqldriver.Value{{match[1], tables[match[1]]}}}
+		}
+
+		switch {
+		case strings.HasPrefix(call.Query, "SELECT TABLE_NAME, TABLE_TYPE"):
+			names := make([]string, 0, len(tables))
+			for name := range tables {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			response := fakeResponse{Columns: []string{"TABLE_NAME", "TABLE_TYPE"}}
+			for _, name := range names {
+				response.Rows = append(response.Rows, []sqldriver.Value{name, "BASE TABLE"})
+			}
+			return response
+		case strings.HasPrefix(call.Query, "SELECT TRIGGER_NAME"), strings.HasPrefix(call.Query, "SELECT CONCAT"):
+			return fakeResponse{Columns: []string{"name", "definition"}}
+		}
+		return defaultFakeHandler(call)
+	}
+}
+
+func Test_schemaSnapshot_diff(t *testing.T) {
+	before := schemaSnapshot{"table a": "CREATE TABLE a (id int)", "table b": "CREATE TABLE b (id int)"}
+	after := schemaSnapshot{"table a": "CREATE TABLE a (id int, name text)", "view c": "CREATE VIEW c"}
+
+	want := []string{"added view c", "changed table a", "removed table b"}
+	if got := before.diff(after); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected diff %q, got: %q", want, got)
+	}
+}
+
+func TestVerifyDownMigrations(t *testing.T) {
+	db, _ := newFakeDB(t, schemaHandler())
+	source, err := lightmigrate.NewFsSource(fstest.MapFS{
+		"migrations/1_init.up.sql":      {Data: []byte("CREATE TABLE a (id int);")},
+		"migrations/1_init.down.sql":    {Data: []byte("DROP TABLE a;")},
+		"migrations/2_name.up.sql":      {Data: []byte("ALTER TABLE a ADD COLUMN name;")},
+		"migrations/2_name.down.sql":    {Data: []byte("SELECT 1;")},
+		"migrations/3_other.up.sql":     {Data: []byte("CREATE TABLE c (id int);")},
+		"migrations/4_cleanup.up.sql":   {Data: []byte("CREATE TABLE d (id int);")},
+		"migrations/4_cleanup.down.sql": {Data: []byte("DROP TABLE d;")},
+	}, "migrations")
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	report, err := VerifyDownMigrations(context.Background(), db, "app", source)
+	if !errors.Is(err, ErrDownMigrationInvalid) {
+		t.Fatalf("expected error %v, got: %v", ErrDownMigrationInvalid, err)
+	}
+
+	if len(report.Results) != 4 {
+		t.Fatalf("unexpected results: %+v", report.Results)
+	}
+	failed := report.Failed()
+	if len(failed) != 2 || failed[0].Version != 2 || !reflect.DeepEqual(failed[0].Diff, []string{"changed table a"}) ||
+		failed[1].Version != 3 || !failed[1].Missing {
+		t.Fatalf("unexpected failed versions: %+v", failed)
+	}
+	if report.Results[0].Failed() || report.Results[3].Failed() {
+		t.Fatalf("unexpected failure of valid down migrations: %+v", report.Results)
+	}
+}
+
+func TestVerifyDownMigrations_BrokenDown(t *testing.T) {
+	errDrop := errors.New("unknown table")
+	handler := schemaHandler()
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "DROP TABLE") {
+			return fakeResponse{Err: errDrop}
+		}
+		return handler(call)
+	})
+
+	report, err := VerifyDownMigrations(context.Background(), db, "app", testSource(t))
+	if !errors.Is(err, ErrDownMigrationInvalid) {
+		t.Fatalf("expected error %v, got: %v", ErrDownMigrationInvalid, err)
+	}
+	if len(report.Results) != 1 || !errors.Is(report.Results[0].Err, errDrop) {
+		t.Fatalf("unexpected results: %+v", report.Results)
+	}
+}
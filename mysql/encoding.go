@@ -0,0 +1,137 @@
+package mysql
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Byte order marks of the supported migration file encodings.
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// normalizeEncoding converts the migration to UTF-8 without byte order mark and with LF line endings, as files
+// saved by Windows editors would otherwise fail with confusing syntax errors. UTF-16 migrations are detected by
+// their byte order mark.
+func normalizeEncoding(migration io.Reader) io.Reader {
+	r := bufio.NewReader(migration)
+	bom, _ := r.Peek(len(bomUTF8))
+
+	switch {
+	case bytes.HasPrefix(bom, bomUTF8):
+		_, _ = r.Discard(len(bomUTF8))
+	case bytes.HasPrefix(bom, bomUTF16LE):
+		_, _ = r.Discard(len(bomUTF16LE))
+		r = bufio.NewReader(&utf16Reader{r: r, order: binary.LittleEndian})
+	case bytes.HasPrefix(bom, bomUTF16BE):
+		_, _ = r.Discard(len(bomUTF16BE))
+		r = bufio.NewReader(&utf16Reader{r: r, order: binary.BigEndian})
+	}
+
+	return &crlfReader{r: r}
+}
+
+// crlfReader replaces CRLF line endings by LF.
+type crlfReader struct {
+	r *bufio.Reader
+}
+
+func (c *crlfReader) Read(p []byte) (int, error) {
+	for {
+		n, err := c.r.Read(p)
+
+		w := 0
+		for i := 0; i < n; i++ {
+			if p[i] == '\r' {
+				if i+1 < n && p[i+1] == '\n' {
+					continue
+				}
+				if next, peekErr := c.r.Peek(1); i+1 == n && peekErr == nil && next[0] == '\n' {
+					continue
+				}
+			}
+			p[w] = p[i]
+			w++
+		}
+
+		if w > 0 || err != nil || n == 0 {
+			return w, err
+		}
+		// only a dropped carriage return was read, read again instead of returning an empty result
+	}
+}
+
+// utf16Reader decodes UTF-16 (without byte order mark) to UTF-8. Unpaired surrogates are replaced by U+FFFD.
+type utf16Reader struct {
+	r       *bufio.Reader
+	order   binary.ByteOrder
+	pending []byte // encoded bytes that did not fit into the last read buffer
+}
+
+func (u *utf16Reader) Read(p []byte) (int, error) {
+	n := copy(p, u.pending)
+	u.pending = u.pending[n:]
+
+	var err error
+	for n < len(p) {
+		var r rune
+		if r, err = u.readRune(); err != nil {
+			break
+		}
+
+		var encoded [utf8.UTFMax]byte
+		size := utf8.EncodeRune(encoded[:], r)
+		copied := copy(p[n:], encoded[:size])
+		u.pending = append(u.pending, encoded[copied:size]...)
+		n += copied
+
+		if u.r.Buffered() < 2 {
+			break // do not block for more input, if some output is available
+		}
+	}
+
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// readRune decodes the next character.
+func (u *utf16Reader) readRune() (rune, error) {
+	unit, err := u.readUnit()
+	if err != nil {
+		return 0, err
+	}
+	if !utf16.IsSurrogate(rune(unit)) {
+		return rune(unit), nil
+	}
+
+	next, err := u.r.Peek(2)
+	if err != nil || len(next) < 2 {
+		return utf8.RuneError, nil
+	}
+	if r := utf16.DecodeRune(rune(unit), rune(u.order.Uint16(next))); r != utf8.RuneError {
+		_, _ = u.r.Discard(2)
+		return r, nil
+	}
+	return utf8.RuneError, nil // the next unit is decoded on its own
+}
+
+// readUnit reads the next 16-bit code unit.
+func (u *utf16Reader) readUnit() (uint16, error) {
+	var unit [2]byte
+	if n, err := io.ReadFull(u.r, unit[:]); err != nil {
+		if n == 1 {
+			return 0, fmt.Errorf("invalid UTF-16 migration: odd number of bytes")
+		}
+		return 0, err
+	}
+	return u.order.Uint16(unit[:]), nil
+}
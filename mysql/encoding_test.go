@@ -0,0 +1,69 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+func encodeUTF16(s string, order binary.ByteOrder, bom []byte) []byte {
+	buf := bytes.NewBuffer(append([]byte(nil), bom...))
+	for _, unit := range utf16.Encode([]rune(s)) {
+		_ = binary.Write(buf, order, unit)
+	}
+	return buf.Bytes()
+}
+
+func Test_normalizeEncoding(t *testing.T) {
+	const want = "INSERT INTO t VALUES ('grüße 🎉');\nSELECT 1;\n"
+	crlf := strings.ReplaceAll(want, "\n", "\r\n")
+
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"utf-8", []byte(want)},
+		{"utf-8 bom", append(append([]byte(nil), bomUTF8...), want...)},
+		{"crlf", []byte(crlf)},
+		{"utf-8 bom crlf", append(append([]byte(nil), bomUTF8...), crlf...)},
+		{"utf-16le", encodeUTF16(crlf, binary.LittleEndian, bomUTF16LE)},
+		{"utf-16be", encodeUTF16(want, binary.BigEndian, bomUTF16BE)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, input := range []io.Reader{bytes.NewReader(tt.input), iotest.OneByteReader(bytes.NewReader(tt.input))} {
+				got, err := io.ReadAll(normalizeEncoding(input))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(got) != want {
+					t.Fatalf("unexpected migration %q, got: %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func Test_normalizeEncoding_InvalidUTF16(t *testing.T) {
+	input := append(encodeUTF16("SELECT 1;", binary.LittleEndian, bomUTF16LE), 'x')
+	if _, err := io.ReadAll(normalizeEncoding(bytes.NewReader(input))); err == nil {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
+
+func Test_driver_RunMigration_BOM(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true}}
+
+	migration := encodeUTF16("CREATE TABLE a (id int);\r\n", binary.LittleEndian, bomUTF16LE)
+	if err := d.RunMigration(bytes.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 1 || queries[0] != "CREATE TABLE a (id int)" {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
@@ -9,4 +9,81 @@ var (
 	ErrNoDatabaseClient = fmt.Errorf("no database client")
 	// ErrDatabaseLocked signals that the database is already locked by another migration process.
 	ErrDatabaseLocked = fmt.Errorf("database is locked")
+	// ErrLockNotHeld signals that the migration lock was released, but the server reported that it was not held,
+	// e.g. because the session of the lock was lost.
+	ErrLockNotHeld = fmt.Errorf("lock not held")
+	// ErrVersionMismatch signals that the schema version of the database differs from the expected version.
+	ErrVersionMismatch = fmt.Errorf("schema version mismatch")
+	// ErrSchemaBehind signals that the schema version is older than the expected version, see AssertVersion.
+	ErrSchemaBehind = fmt.Errorf("schema is behind")
+	// ErrSchemaAhead signals that the schema version is newer than the expected version, see AssertVersion.
+	ErrSchemaAhead = fmt.Errorf("schema is ahead")
+	// ErrNoIncludeFS signals that a migration uses include directives, but no include filesystem was configured.
+	ErrNoIncludeFS = fmt.Errorf("no include filesystem configured")
+	// ErrMisplacedDirective signals that a directive was used at a position where it has no effect.
+	ErrMisplacedDirective = fmt.Errorf("misplaced directive")
+	// ErrInvalidDirective signals that a directive is missing a required argument.
+	ErrInvalidDirective = fmt.Errorf("invalid directive")
+	// ErrInvalidConfig signals that the driver options contain invalid or conflicting settings, see ConfigError.
+	ErrInvalidConfig = fmt.Errorf("invalid configuration")
+	// ErrInvalidNamespace signals that the migration namespace contains unsupported characters.
+	ErrInvalidNamespace = fmt.Errorf("invalid namespace")
+	// ErrUnsafeStatement signals that a migration statement was rejected by a safety check in strict mode.
+	ErrUnsafeStatement = fmt.Errorf("unsafe statement")
+	// ErrInjectedFault is the default error of failures that were injected by a FaultInjector.
+	ErrInjectedFault = fmt.Errorf("injected fault")
+	// ErrTooManyAffectedRows signals that a migration statement changed more rows than allowed, see WithMaxAffectedRows.
+	ErrTooManyAffectedRows = fmt.Errorf("too many affected rows")
+	// ErrRunDeadlineExceeded signals that a migration run was stopped because its deadline passed, see WithRunDeadline.
+	ErrRunDeadlineExceeded = fmt.Errorf("run deadline exceeded")
+	// ErrOutsideMaintenanceWindow signals that a heavy migration was refused outside of the maintenance windows, see
+	// WithMaintenanceWindow.
+	ErrOutsideMaintenanceWindow = fmt.Errorf("outside of maintenance window")
+	// ErrMigrationBudgetExceeded signals that a migration ran longer than its "-- lightmigrate:max-duration"
+	// directive allows, see MigrationTimeoutError.
+	ErrMigrationBudgetExceeded = fmt.Errorf("migration budget exceeded")
+	// ErrUnexpectedTableDefinition signals that a driver table differs from its expected definition, see
+	// WithTableCheck.
+	ErrUnexpectedTableDefinition = fmt.Errorf("unexpected table definition")
+	// ErrConnectionLost signals that a migration was aborted because the connection was lost, see WithReconnect.
+	ErrConnectionLost = fmt.Errorf("connection lost")
+	// ErrNoHistory signals that the migration history was requested, but it is not enabled, see WithHistory.
+	ErrNoHistory = fmt.Errorf("migration history is not enabled")
+	// ErrNoQuarantine signals that quarantined migrations were requested, but the best-effort mode is not enabled,
+	// see WithQuarantine.
+	ErrNoQuarantine = fmt.Errorf("quarantine is not enabled")
+	// ErrDownMigrationInvalid signals that a down migration is missing, failed or did not restore the schema, see
+	// VerifyDownMigrations.
+	ErrDownMigrationInvalid = fmt.Errorf("invalid down migration")
+	// ErrRehearsalFailed signals that the migrations failed within the scratch database, see Rehearse.
+	ErrRehearsalFailed = fmt.Errorf("rehearsal failed")
+	// ErrChecksumMismatch signals that a migration differs from the migration that was applied, see VerifyChecksum.
+	ErrChecksumMismatch = fmt.Errorf("checksum mismatch")
+	// ErrTargetSkipped signals that a target was not migrated, as the run stopped after a failure, see WithStopOnFailure.
+	ErrTargetSkipped = fmt.Errorf("target skipped")
+	// ErrPanic signals that a panic was recovered while a migration was executed, e.g. of a custom callback.
+	ErrPanic = fmt.Errorf("panic")
+	// ErrRoleNotActive signals that the configured role could not be activated, see WithRole.
+	ErrRoleNotActive = fmt.Errorf("role is not active")
+	// ErrReadOnlyServer signals that the server has read_only enabled, see WithAllowReplica.
+	ErrReadOnlyServer = fmt.Errorf("server is read-only")
+	// ErrMigrationTooLarge signals that a migration exceeds the maximum in-memory size, see WithMaxInMemoryMigrationSize.
+	ErrMigrationTooLarge = fmt.Errorf("migration too large")
+	// ErrRollbackNotAllowed signals that a down migration below the rollback floor was not confirmed, see
+	// WithRollbackFloor.
+	ErrRollbackNotAllowed = fmt.Errorf("rollback not allowed")
+	// ErrPrimaryConflict signals that the migration lock is held by a run on another primary, see WithPrimaryGuard.
+	ErrPrimaryConflict = fmt.Errorf("migration running on another primary")
+	// ErrUnknownServerIdentity signals that the server identity required by WithPrimaryGuard or WithShardLockKey was
+	// not detected.
+	ErrUnknownServerIdentity = fmt.Errorf("unknown server identity")
+	// ErrScriptStateMismatch signals that the state of a migration script does not match the database, see
+	// NewScriptDriver.
+	ErrScriptStateMismatch = fmt.Errorf("script state mismatch")
+	// ErrIncompatibleSource signals that another instance migrated the database with different migrations, see
+	// WithSourceGuard.
+	ErrIncompatibleSource = fmt.Errorf("incompatible migration source")
+	// ErrCorruptVersionTable signals that the migration table contains more than one row, see
+	// CorruptVersionTableError.
+	ErrCorruptVersionTable = fmt.Errorf("corrupt migration table")
 )
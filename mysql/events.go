@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+)
+
+// eventDefinitionPattern matches CREATE EVENT and ALTER EVENT statements, including an optional DEFINER clause.
+var eventDefinitionPattern = regexp.MustCompile(`(?i)^(CREATE|ALTER)\s+(DEFINER\s*=\s*\S+\s+)?EVENT\s`)
+
+// compoundEndKeywords follow END in the closing clause of compound statements that do not start with BEGIN.
+var compoundEndKeywords = map[string]bool{"IF": true, "LOOP": true, "WHILE": true, "REPEAT": true}
+
+// isEventDefinition checks if the statement creates or alters a scheduled event.
+func isEventDefinition(query string) bool {
+	return eventDefinitionPattern.MatchString(query)
+}
+
+// openEventBody checks if the statement is an event definition whose BEGIN ... END body is not closed yet. Event
+// bodies contain semicolons, so the statement scanner does not split them, even without a DELIMITER command.
+func openEventBody(stmt []byte) bool {
+	if !eventDefinitionPattern.Match(stmt) {
+		return false
+	}
+
+	depth := 0
+	body := false
+	previous := ""
+	for _, token := range tokenizeDDL(string(stmt)) {
+		if token.quoted {
+			previous = ""
+			continue
+		}
+		word := strings.ToUpper(token.text)
+		switch {
+		case !body:
+			body = word == "DO"
+		case previous == "END" && (compoundEndKeywords[word] || word == "CASE"):
+			// END IF, END LOOP, ... close statements that were not counted, END CASE closes a counted CASE
+			if compoundEndKeywords[word] {
+				depth++
+			}
+		case word == "BEGIN" || word == "CASE":
+			depth++
+		case word == "END":
+			depth--
+		}
+		previous = word
+	}
+	return depth > 0
+}
+
+// WithEventSchedulerCheck warns about migrations that create or alter events while the event scheduler of the server
+// is disabled (event_scheduler=OFF). Such events are created successfully, but never run.
+func WithEventSchedulerCheck(check bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.EventSchedulerCheck = check
+	}
+}
+
+// checkEventScheduler reports event definitions while the event scheduler is not running, see
+// WithEventSchedulerCheck.
+func (d *driver) checkEventScheduler(ctx context.Context, session *sql.Conn, state *migrationState, stmt statement) {
+	if !d.cfg.EventSchedulerCheck || !isEventDefinition(stmt.Query) {
+		return
+	}
+
+	var scheduler string
+	if err := session.QueryRowContext(ctx, "SELECT @@GLOBAL.event_scheduler").Scan(&scheduler); err != nil {
+		d.logf("failed to check the event scheduler for statement %d (line %d): %v", stmt.Index, stmt.Line, err)
+		return
+	}
+	if strings.EqualFold(scheduler, "ON") {
+		return
+	}
+
+	msg := "the event scheduler is " + strings.ToUpper(scheduler) + ", the event will not run until it is enabled"
+	d.logf("statement %d (line %d): %s", stmt.Index, stmt.Line, msg)
+	state.Result.warn(Warning{StatementIndex: stmt.Index, Line: stmt.Line, Level: "Warning", Message: msg})
+}
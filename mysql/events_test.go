@@ -0,0 +1,63 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func Test_openEventBody(t *testing.T) {
+	tests := map[string]bool{
+		"SELECT 1;": false,
+		"CREATE EVENT e ON SCHEDULE EVERY 1 DAY DO BEGIN DELETE FROM t;":                                   true,
+		"CREATE EVENT e ON SCHEDULE EVERY 1 DAY DO BEGIN DELETE FROM t; END;":                              false,
+		"CREATE EVENT e ON SCHEDULE EVERY 1 DAY DO BEGIN CASE x WHEN 1 THEN DELETE FROM t;":                true,
+		"CREATE EVENT e ON SCHEDULE EVERY 1 DAY DO BEGIN CASE x WHEN 1 THEN DELETE FROM t; END CASE; END;": false,
+		"CREATE EVENT e ON SCHEDULE EVERY 1 DAY DO BEGIN WHILE 1 DO SELECT 1; END WHILE;":                  true,
+		"CREATE EVENT `begin` ON SCHEDULE EVERY 1 DAY DO DELETE FROM t;":                                   false,
+		"CREATE PROCEDURE p() BEGIN SELECT 1;":                                                             false,
+	}
+	for stmt, want := range tests {
+		if got := openEventBody([]byte(stmt)); got != want {
+			t.Fatalf("unexpected result %t for %q, got: %t", want, stmt, got)
+		}
+	}
+}
+
+func Test_driver_checkEventScheduler(t *testing.T) {
+	tests := []struct {
+		name      string
+		scheduler string
+		query     string
+		wantWarn  bool
+	}{
+		{"off", "OFF", "CREATE EVENT e ON SCHEDULE EVERY 1 DAY DO DELETE FROM t", true},
+		{"disabled", "DISABLED", "ALTER EVENT e ENABLE", true},
+		{"on", "ON", "CREATE EVENT e ON SCHEDULE EVERY 1 DAY DO DELETE FROM t", false},
+		{"no event", "OFF", "CREATE TABLE t (id int primary key)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+				if call.Query == "SELECT @@GLOBAL.event_scheduler" {
+					return fakeResponse{Columns: []string{"@@GLOBAL.event_scheduler"},
+						Rows: [][]sqldriver.Value{{tt.scheduler}}}
+				}
+				return defaultFakeHandler(call)
+			})
+			d := &driver{client: db, logger: log.New(io.Discard, "", 0),
+				cfg: &config{SplitStatements: true, EventSchedulerCheck: true}}
+
+			result, err := d.RunMigrationWithResult(strings.NewReader(tt.query + ";"))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			warned := len(result.Warnings) == 1 && strings.Contains(result.Warnings[0].Message, tt.scheduler)
+			if warned != tt.wantWarn {
+				t.Fatalf("unexpected warning %t, got: %+v", tt.wantWarn, result.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,426 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// statement is a single, already split statement of a migration.
+type statement struct {
+	Query string
+	Index int    // 1-based index of the statement within the migration
+	File  string // the included file that contains the statement, empty for the migration itself
+	Line  int
+	// Infile is the data file of a LOAD DATA LOCAL INFILE statement, see the infile directive.
+	Infile string
+}
+
+// migrationState collects the state of the migration that is currently executed by RunMigration.
+type migrationState struct {
+	// Idempotent is set by the "-- lightmigrate:idempotent" directive.
+	Idempotent bool
+	// Failed is the statement that caused the migration to fail.
+	Failed *statement
+	// ResumeLock re-acquires the migration lock, if it was released by the "-- lightmigrate:no-lock" directive.
+	ResumeLock func() error
+	// Result collects the executed statements.
+	Result resultRecorder
+	// Session is the connection that executes the statements, it is replaced if the connection is lost.
+	Session *sql.Conn
+	// Reconnects is the number of reconnect attempts, see WithReconnect.
+	Reconnects int
+	// SessionStatements are the executed SET statements of the migration, they are replayed after a reconnect.
+	SessionStatements []string
+	// Transaction is set while a transaction of the migration is open in the session, see WithReconnect.
+	Transaction bool
+	// Description is the description comment of the migration, only parsed if the history is enabled.
+	Description string
+	// Checksum is computed while the migration is read, if the history is enabled.
+	Checksum checksum
+	// Skipped is set by the "-- lightmigrate:skip reason=..." directive, Note contains the reason.
+	Skipped bool
+	Note    string
+	// TableLocks are the tables of the "-- lightmigrate:tables" directive, whose locks are held by the session.
+	TableLocks []string
+	// Executing is the statement that is currently executed, it is reported if the migration panics.
+	Executing *statement
+	// Refused is set if the migration was refused before its first statement, e.g. outside of a maintenance window.
+	// The previous clean version is restored.
+	Refused bool
+	// Budget is set by the "-- lightmigrate:max-duration" directive, statements are interrupted after the deadline.
+	Budget         time.Duration
+	BudgetDeadline time.Time
+	// SessionID is the server thread id of the session, it is read once a statement may have to be interrupted.
+	SessionID int64
+	// Quarantined is the error of a migration that was quarantined in best-effort mode, see WithQuarantine.
+	Quarantined error
+}
+
+// runStatements executes all statements of the stream within the session of the state. If a statement fails,
+// it is stored in the state.
+func (d *driver) runStatements(stream *statementStream, state *migrationState) error {
+	var parallel []statement // statements of the currently open parallel block
+	inParallel := false
+	index := 0
+	infile := "" // the data file of the following statement
+
+	for {
+		kind, text, ok := stream.Next()
+		if !ok {
+			break
+		}
+		if state.Skipped {
+			continue // the migration is read completely for the checksum, but not executed
+		}
+
+		if kind == tokenDirective {
+			switch name, arg := splitDirective(text); name {
+			case directiveSkip:
+				if err := d.skipMigration(state, index, arg, stream.Line()); err != nil {
+					return err
+				}
+			case directiveTables:
+				if err := d.lockTables(state, index, arg, stream.Line()); err != nil {
+					return err
+				}
+			case directiveInfile:
+				var err error
+				if infile, err = d.infileDirective(arg, infile, stream.Line()); err != nil {
+					return err
+				}
+			case directiveHeavy:
+				if err := d.heavyMigration(state, index, stream.Line()); err != nil {
+					return err
+				}
+			case directiveMaxDuration:
+				if err := d.maxDuration(state, index, arg, stream.Line()); err != nil {
+					return err
+				}
+			case directiveIdempotent:
+				state.Idempotent = true
+			case directiveNoLock:
+				if index > 0 || state.ResumeLock != nil {
+					return &lightmigrate.DriverError{
+						OrigErr: ErrMisplacedDirective,
+						Msg:     "the no-lock directive must precede the first statement",
+						Line:    uint(stream.Line()),
+					}
+				}
+				resume, err := d.suspendLock("no-lock directive")
+				if err != nil {
+					return err
+				}
+				state.ResumeLock = resume
+			case directiveParallel:
+				inParallel = true
+			case directiveParallelEnd:
+				if len(parallel) > 0 {
+					if err := d.checkRunDeadline(state, parallel[0]); err != nil {
+						return err
+					}
+					if err := d.checkBudget(state, parallel[0]); err != nil {
+						return err
+					}
+				}
+				if failed, err := d.execParallel(state.Session, state, parallel); err != nil {
+					state.Failed = failed
+					return err
+				}
+				parallel, inParallel = nil, false
+			}
+			continue
+		}
+
+		index++
+		stmt := statement{Query: string(text), Index: index, File: stream.File(), Line: stream.Line(), Infile: infile}
+		if infile != "" {
+			if err := checkInfileStatement(stmt); err != nil {
+				return err
+			}
+			infile = ""
+		}
+		if inParallel {
+			parallel = append(parallel, stmt)
+			continue
+		}
+		if err := d.checkRunDeadline(state, stmt); err != nil {
+			return err
+		}
+		if err := d.checkBudget(state, stmt); err != nil {
+			return err
+		}
+		state.Executing = &stmt
+		if err := d.execStatementWithReconnect(state, stmt); err != nil {
+			state.Failed = &stmt
+			return err
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read migration"}
+	}
+	if infile != "" {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrMisplacedDirective,
+			Msg:     fmt.Sprintf("the infile directive for %s is not followed by a LOAD DATA statement", infile),
+		}
+	}
+
+	// a parallel block without an end directive lasts until the end of the migration
+	if len(parallel) > 0 {
+		if err := d.checkRunDeadline(state, parallel[0]); err != nil {
+			return err
+		}
+		if err := d.checkBudget(state, parallel[0]); err != nil {
+			return err
+		}
+	}
+	failed, err := d.execParallel(state.Session, state, parallel)
+	state.Failed = failed
+	return err
+}
+
+// skipMigration handles the skip directive. The migration is recorded as applied without executing it, the reason
+// is stored in the history.
+func (d *driver) skipMigration(state *migrationState, index int, arg string, line int) error {
+	if index > 0 || state.ResumeLock != nil {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrMisplacedDirective,
+			Msg:     "the skip directive must precede the first statement",
+			Line:    uint(line),
+		}
+	}
+
+	reason := strings.Trim(strings.TrimSpace(strings.TrimPrefix(arg, "reason=")), "'\"")
+	if reason == "" {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrInvalidDirective,
+			Msg:     "the skip directive requires a reason, e.g. -- lightmigrate:skip reason=applied manually",
+			Line:    uint(line),
+		}
+	}
+
+	state.Skipped, state.Note = true, reason
+	state.Result.skip()
+	d.logf("migration %d is recorded as applied without executing it: %s", d.runningVersion, reason)
+	return nil
+}
+
+// unsplitSkipDirective searches the skip directive in a migration that is not split into statements. leading is
+// false if a statement precedes the directive.
+func unsplitSkipDirective(migration string) (arg string, line int, leading bool, found bool) {
+	leading = true
+	for i, text := range strings.Split(migration, "\n") {
+		text = strings.TrimSpace(text)
+		if len(text) > len("-- ") && text[:2] == "--" && isSpace(text[2]) &&
+			strings.HasPrefix(text[3:], DirectivePrefix) {
+			if name, arg := splitDirective([]byte(text[3+len(DirectivePrefix):])); name == directiveSkip {
+				return arg, i + 1, leading, true
+			}
+			continue
+		}
+		if text != "" && !strings.HasPrefix(text, "--") && !strings.HasPrefix(text, "#") {
+			leading = false
+		}
+	}
+	return "", 0, false, false
+}
+
+// execStatement executes a single statement of a migration and records it in the state.
+func (d *driver) execStatement(ctx context.Context, session *sql.Conn, state *migrationState, stmt statement) error {
+	if err := d.checkLockLost(); err != nil {
+		return err
+	}
+	if err := d.checkClusterSafety(state, stmt); err != nil {
+		return err
+	}
+
+	cancellable := ctx.Done() != nil || d.cfg.StatementTimeout > 0
+	ctx, cancel := d.statementContext(ctx)
+	defer cancel()
+
+	d.explainStatement(ctx, session, stmt)
+	d.estimateImpact(ctx, session, state, stmt)
+	d.checkEventScheduler(ctx, session, state, stmt)
+
+	stopWatchdog := d.watchStatement(stmt)
+	stopInterrupt := d.interruptStatement(ctx, cancellable, session, state)
+	stopProgress := d.reportProgress(session, state, stmt)
+	result, err := d.execSession(ctx, session, stmt)
+	stopProgress()
+	interrupted := stopInterrupt()
+	stopWatchdog()
+	if err != nil {
+		if interrupted == interruptedByBudget {
+			return d.budgetError(state, stmt, true, err)
+		}
+		driverErr := stmt.error("migration failed", err)
+		if interrupted == interruptedByContext {
+			driverErr.Msg += " (the statement was stopped on the server with KILL QUERY)"
+		}
+		if d.atomicDDLRollback(stmt) {
+			driverErr.Msg += " (the DDL statement was rolled back atomically)"
+		}
+		return driverErr
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		affected = 0 // the number of affected rows is not supported by all statements
+	}
+
+	var warnings []Warning
+	if d.cfg.CaptureWarnings {
+		warnings = d.captureWarnings(ctx, session, stmt)
+		if d.verbose {
+			for _, warning := range warnings {
+				d.logf("statement %d (line %d): %s %d: %s", stmt.Index, stmt.Line, warning.Level, warning.Code,
+					warning.Message)
+			}
+		}
+	}
+	state.Result.record(affected, warnings)
+	if d.cfg.ObjectTracking && isDDL(stmt.Query) {
+		state.Result.track(parseSchemaObjects(stmt.Index, stmt.Query))
+	}
+
+	return d.checkAffectedRows(state, stmt, affected)
+}
+
+// execSession sends the statement to the server, unless a fault was injected for the statement.
+func (d *driver) execSession(ctx context.Context, session *sql.Conn, stmt statement) (sql.Result, error) {
+	if err := d.faults.beforeStatement(); err != nil {
+		return nil, err
+	}
+	if stmt.Infile != "" {
+		return d.execInfile(ctx, session, stmt)
+	}
+	return session.ExecContext(ctx, stmt.Query)
+}
+
+// checkAffectedRows verifies the number of changed rows against the configured limit. Only DML statements are
+// checked, the row count reported for DDL statements (e.g. a copying ALTER TABLE) is not a number of changed rows.
+func (d *driver) checkAffectedRows(state *migrationState, stmt statement, affected int64) error {
+	if d.cfg.MaxAffectedRows <= 0 || affected <= d.cfg.MaxAffectedRows || !isDML(stmt.Query) {
+		return nil
+	}
+
+	msg := fmt.Sprintf("statement changed %d rows, limit is %d", affected, d.cfg.MaxAffectedRows)
+	if d.cfg.Strict {
+		return stmt.error(msg, ErrTooManyAffectedRows)
+	}
+
+	d.logf("statement %d (line %d) changed %d rows, limit is %d", stmt.Index, stmt.Line, affected,
+		d.cfg.MaxAffectedRows)
+	state.Result.warn(Warning{StatementIndex: stmt.Index, Line: stmt.Line, Level: "Warning", Message: msg})
+	return nil
+}
+
+// error wraps err into a DriverError describing the statement.
+func (s statement) error(msg string, err error) *lightmigrate.DriverError {
+	if s.File != "" {
+		msg += " in included file " + s.File
+	}
+	return &lightmigrate.DriverError{OrigErr: err, Msg: msg, Query: []byte(s.Query), Line: uint(s.Line)}
+}
+
+// execParallel executes the statements of a parallel block concurrently. The first worker uses the given session,
+// all other workers open their own session. After the first failure, no further statements are started.
+// The first failed statement is returned once all running statements finished.
+func (d *driver) execParallel(session *sql.Conn, state *migrationState, stmts []statement) (*statement, error) {
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+
+	workers := d.cfg.MaxParallelStatements
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(stmts) {
+		workers = len(stmts)
+	}
+	if limit := d.client.Stats().MaxOpenConnections; limit > 0 {
+		// the first session and the lock connection are already open and one connection is kept for the side
+		// queries (e.g. KILL QUERY), more sessions would block forever
+		available := limit - 1
+		if d.lockConn != nil {
+			available--
+		}
+		if available < 1 {
+			available = 1
+		}
+		if workers > available {
+			workers = available
+		}
+	}
+
+	var once sync.Once
+	var firstErr error
+	var firstFailed statement
+	failed := make(chan struct{})
+	queue := make(chan statement)
+	var wg sync.WaitGroup
+
+	sessions := []*sql.Conn{session}
+	defer func() {
+		for _, session := range sessions[1:] {
+			d.closeSession(session)
+		}
+	}()
+	for i := 1; i < workers; i++ {
+		session, err := d.openSession(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	for _, session := range sessions {
+		wg.Add(1)
+		go func(session *sql.Conn) {
+			defer wg.Done()
+			for stmt := range queue {
+				if err := d.execParallelStatement(session, state, stmt); err != nil {
+					once.Do(func() {
+						firstErr, firstFailed = err, stmt
+						close(failed)
+					})
+				}
+			}
+		}(session)
+	}
+
+dispatch:
+	for _, stmt := range stmts {
+		select {
+		case <-failed:
+			break dispatch
+		case queue <- stmt:
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return &firstFailed, firstErr
+	}
+	return nil, nil
+}
+
+// execParallelStatement executes a statement of a parallel block. Panics of the worker are converted into errors,
+// as they can not be recovered by the caller.
+func (d *driver) execParallelStatement(session *sql.Conn, state *migrationState, stmt statement) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = d.panicError(recovered, d.runningVersion, &stmt)
+		}
+	}()
+
+	return d.execStatement(context.Background(), session, state, stmt)
+}
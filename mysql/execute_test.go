@@ -0,0 +1,236 @@
+package mysql
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+func TestWithMaxParallelStatements(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithMaxParallelStatements(8)(d)
+	if d.cfg.MaxParallelStatements != 8 {
+		t.Fatalf("failed to set max parallel statements")
+	}
+}
+
+func Test_driver_RunMigration_Parallel(t *testing.T) {
+	var mux sync.Mutex
+	running, maxRunning := 0, 0
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "CREATE INDEX") {
+			mux.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mux.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mux.Lock()
+			running--
+			mux.Unlock()
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true, MaxParallelStatements: 2}}
+
+	migration := "SELECT 1;\n-- lightmigrate:parallel\nCREATE INDEX a ON a (x);\nCREATE INDEX b ON b (x);\n" +
+		"CREATE INDEX c ON c (x);\n-- lightmigrate:parallel-end\nSELECT 2;"
+	if err := d.RunMigration(strings.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if maxRunning != 2 {
+		t.Fatalf("unexpected concurrency 2, got: %d", maxRunning)
+	}
+
+	queries := srv.Queries()
+	if queries[0] != "SELECT 1" || queries[len(queries)-1] != "SELECT 2" {
+		t.Fatalf("unexpected statement order: %q", queries)
+	}
+	block := append([]string(nil), queries[1:4]...)
+	sort.Strings(block)
+	if block[0] != "CREATE INDEX a ON a (x)" || block[2] != "CREATE INDEX c ON c (x)" {
+		t.Fatalf("unexpected parallel statements: %q", block)
+	}
+}
+
+func Test_driver_RunMigration_ParallelPoolLimit(t *testing.T) {
+	var mux sync.Mutex
+	conns := make(map[int]bool)
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "CREATE INDEX") {
+			mux.Lock()
+			conns[call.ConnID] = true
+			mux.Unlock()
+			time.Sleep(20 * time.Millisecond) // all sessions take part in the block
+		}
+		return defaultFakeHandler(call)
+	})
+	db.SetMaxOpenConns(4)
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", Locking: true, SplitStatements: true,
+		MaxParallelStatements: 4}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	migration := "-- lightmigrate:parallel\nCREATE INDEX a ON a (x);\nCREATE INDEX b ON b (x);\n" +
+		"CREATE INDEX c ON c (x);\nCREATE INDEX d ON d (x);\n-- lightmigrate:parallel-end\n"
+	if err := d.RunMigration(strings.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the lock connection is held and one connection is kept for KILL QUERY
+	if len(conns) != 2 {
+		t.Fatalf("unexpected number of parallel sessions 2, got: %d", len(conns))
+	}
+}
+
+func Test_driver_RunMigration_ParallelError(t *testing.T) {
+	errFailed := errors.New("duplicate key name")
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "CREATE INDEX b") {
+			return fakeResponse{Err: errFailed}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true, MaxParallelStatements: 1}}
+
+	migration := "-- lightmigrate:parallel\nCREATE INDEX a ON a (x);\nCREATE INDEX b ON b (x);\nCREATE INDEX c ON c (x);\nSELECT 2;"
+	err := d.RunMigration(strings.NewReader(migration))
+	var driverErr *lightmigrate.DriverError
+	if !errors.As(err, &driverErr) || !errors.Is(err, errFailed) || driverErr.Line != 3 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, query := range srv.Queries() {
+		if query == "SELECT 2" {
+			t.Fatalf("statement after failed parallel block was executed")
+		}
+	}
+}
+
+func TestWithMaxAffectedRows(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithMaxAffectedRows(100)(d)
+	WithStrictMode(true)(d)
+	if d.cfg.MaxAffectedRows != 100 || !d.cfg.Strict {
+		t.Fatalf("failed to set affected rows limit")
+	}
+}
+
+func Test_driver_RunMigration_MaxAffectedRows(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "DELETE") {
+			return fakeResponse{RowsAffected: 500}
+		}
+		return fakeResponse{RowsAffected: 1}
+	})
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0), cfg: &config{SplitStatements: true, MaxAffectedRows: 100}}
+
+	migration := "UPDATE users SET active = 1 WHERE id = 1;\nDELETE FROM users;"
+	if err := d.RunMigration(strings.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(logs.String(), "statement 2 (line 2) changed 500 rows") {
+		t.Fatalf("unexpected log output: %s", logs.String())
+	}
+
+	d.cfg.Strict = true
+	d.cfg.MigrationsTable = "migrations"
+	err := d.RunMigration(strings.NewReader(migration))
+	var driverErr *lightmigrate.DriverError
+	if !errors.As(err, &driverErr) || !errors.Is(err, ErrTooManyAffectedRows) || driverErr.Line != 2 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_driver_RunMigration_MaxAffectedRowsDDL(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "ALTER") {
+			return fakeResponse{RowsAffected: 500}
+		}
+		return fakeResponse{RowsAffected: 1}
+	})
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0),
+		cfg: &config{SplitStatements: true, MaxAffectedRows: 100, Strict: true}}
+
+	if err := d.RunMigration(strings.NewReader("ALTER TABLE users ADD COLUMN age int;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(logs.String(), "changed 500 rows") {
+		t.Fatalf("unexpected log output: %s", logs.String())
+	}
+}
+
+func Test_driver_RunMigration_Skip(t *testing.T) {
+	for _, split := range []bool{true, false} {
+		db, srv := newFakeDB(t, nil)
+		d := &driver{client: db, runningVersion: 3, logger: log.New(io.Discard, "", 0),
+			cfg: &config{SplitStatements: split, History: true}}
+
+		result, err := d.RunMigrationWithResult(strings.NewReader("-- lightmigrate:skip reason=applied during incident 42\n" +
+			"CREATE TABLE a (id int);"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !result.Skipped || result.Statements != 0 {
+			t.Fatalf("unexpected result (split %t): %+v", split, result)
+		}
+
+		for _, call := range srv.Calls() {
+			if strings.HasPrefix(call.Query, "CREATE TABLE a") {
+				t.Fatalf("skipped migration was executed (split %t)", split)
+			}
+			if strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_history`") &&
+				(call.Args[13] != true || call.Args[14] != "applied during incident 42" || call.Args[10] == nil) {
+				t.Fatalf("unexpected history arguments: %v", call.Args)
+			}
+		}
+	}
+}
+
+func Test_driver_RunMigration_SkipInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		migration string
+		wantErr   error
+	}{
+		{"misplaced", "SELECT 1;\n-- lightmigrate:skip reason=manual\nSELECT 2;", ErrMisplacedDirective},
+		{"no reason", "-- lightmigrate:skip\nSELECT 1;", ErrInvalidDirective},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, split := range []bool{true, false} {
+				db, srv := newFakeDB(t, nil)
+				d := &driver{client: db, cfg: &config{SplitStatements: split}}
+
+				if err := d.RunMigration(strings.NewReader(tt.migration)); !errors.Is(err, tt.wantErr) {
+					t.Fatalf("unexpected error %v (split %t), got: %v", tt.wantErr, split, err)
+				}
+				for _, query := range srv.Queries() {
+					if !split && strings.HasPrefix(query, "SELECT") {
+						t.Fatalf("unexpected query (split %t), got: %q", split, query)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// dmlKeywords are the first keywords of data manipulation statements that can be explained.
+var dmlKeywords = []string{"INSERT", "UPDATE", "DELETE", "REPLACE"}
+
+// isDML checks if the statement is a data manipulation statement.
+func isDML(query string) bool {
+	keyword := strings.ToUpper(firstKeyword(query))
+	for _, dml := range dmlKeywords {
+		if keyword == dml {
+			return true
+		}
+	}
+	return false
+}
+
+// WithExplain enables EXPLAIN for DML statements (INSERT, UPDATE, DELETE, REPLACE) before they are executed.
+// The query plan is logged, so it only takes effect if verbose logging is enabled.
+func WithExplain(explain bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.Explain = explain
+	}
+}
+
+// explainStatement logs the query plan of a DML statement. Failures are logged, they never fail the migration.
+func (d *driver) explainStatement(ctx context.Context, session *sql.Conn, stmt statement) {
+	if !d.cfg.Explain || !d.verbose || !isDML(stmt.Query) {
+		return
+	}
+
+	rows, err := session.QueryContext(ctx, "EXPLAIN "+stmt.Query)
+	if err != nil {
+		d.logf("failed to explain statement %d (line %d): %v", stmt.Index, stmt.Line, err)
+		return
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		d.logf("failed to explain statement %d (line %d): %v", stmt.Index, stmt.Line, err)
+		return
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			d.logf("failed to read plan of statement %d (line %d): %v", stmt.Index, stmt.Line, err)
+			return
+		}
+		d.logf("statement %d (line %d) plan: %s", stmt.Index, stmt.Line, formatPlanRow(columns, values))
+	}
+	if err := rows.Err(); err != nil {
+		d.logf("failed to read plan of statement %d (line %d): %v", stmt.Index, stmt.Line, err)
+	}
+}
+
+// formatPlanRow formats a single row of the EXPLAIN output as "column=value" pairs. NULL values are skipped.
+func formatPlanRow(columns []string, values []sql.RawBytes) string {
+	pairs := make([]string, 0, len(columns))
+	for i, column := range columns {
+		if values[i] == nil {
+			continue
+		}
+		pairs = append(pairs, column+"="+string(values[i]))
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"bytes"
+	sqldriver "database/sql/driver"
+	"log"
+	"strings"
+	"testing"
+)
+
+func Test_isDML(t *testing.T) {
+	tests := map[string]bool{
+		"UPDATE users SET active = 1":      true,
+		"insert into users values (1)":     true,
+		"  delete FROM users":              true,
+		"REPLACE INTO users VALUES (1)":    true,
+		"CREATE TABLE users (id int)":      false,
+		"SELECT * FROM users":              false,
+		"UPDATES_TABLE_NAME_IS_NO_KEYWORD": false,
+	}
+	for query, want := range tests {
+		if got := isDML(query); got != want {
+			t.Fatalf("unexpected result %t for %q, got: %t", want, query, got)
+		}
+	}
+}
+
+func Test_driver_RunMigration_Explain(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "EXPLAIN") {
+			return fakeResponse{
+				Columns: []string{"id", "table", "type", "key", "rows"},
+				Rows:    [][]sqldriver.Value{{int64(1), "users", "ALL", nil, int64(1000)}},
+			}
+		}
+		return fakeResponse{}
+	})
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0), verbose: true,
+		cfg: &config{SplitStatements: true, Explain: true}}
+
+	err := d.RunMigration(strings.NewReader("CREATE TABLE users (id int);\nUPDATE users SET id = id + 1;"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queries := srv.Queries()
+	if len(queries) != 3 || queries[1] != "EXPLAIN UPDATE users SET id = id + 1" {
+		t.Fatalf("unexpected queries, got: %q", queries)
+	}
+	if !strings.Contains(logs.String(), "statement 2 (line 2) plan: id=1 table=users type=ALL rows=1000") {
+		t.Fatalf("unexpected logs, got: %s", logs.String())
+	}
+}
+
+func Test_driver_RunMigration_ExplainRequiresVerbose(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, Explain: true}}
+
+	if err := d.RunMigration(strings.NewReader("UPDATE users SET id = id + 1;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "EXPLAIN") {
+			t.Fatalf("unexpected EXPLAIN without verbose logging: %q", srv.Queries())
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package mysql
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// extraColumnDefinition is the column type of user-defined extra columns. The columns are only added if they do
+// not exist, so they can be created with another type up front.
+const extraColumnDefinition = "varchar(255) null"
+
+var extraColumnPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// WithExtraVersionColumns adds user-defined columns to the migrations table (and the history table, see
+// WithHistory), e.g. to persist the git SHA, the release tag or a ticket ID alongside each recorded version.
+// The value functions are called on each SetVersion. Missing columns are created as nullable varchar(255) columns.
+func WithExtraVersionColumns(columns map[string]func() interface{}) DriverOption {
+	return func(d *driver) {
+		d.cfg.ExtraVersionColumns = columns
+	}
+}
+
+// extraColumnNames returns the sorted names of the extra columns.
+func (d *driver) extraColumnNames() []string {
+	names := make([]string, 0, len(d.cfg.ExtraVersionColumns))
+	for name := range d.cfg.ExtraVersionColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withExtraColumns appends the extra columns to the columns of an internal table.
+func (d *driver) withExtraColumns(columns []columnDefinition) []columnDefinition {
+	if len(d.cfg.ExtraVersionColumns) == 0 {
+		return columns
+	}
+
+	all := append([]columnDefinition(nil), columns...)
+	for _, name := range d.extraColumnNames() {
+		all = append(all, columnDefinition{Name: name, Definition: extraColumnDefinition})
+	}
+	return all
+}
+
+// extraColumnValues returns the column list (with a leading comma), the placeholders and the current values of
+// the extra columns for an INSERT query.
+func (d *driver) extraColumnValues() (columns, placeholders string, values []interface{}) {
+	names := d.extraColumnNames()
+	for _, name := range names {
+		values = append(values, d.cfg.ExtraVersionColumns[name]())
+	}
+	if len(names) == 0 {
+		return "", "", nil
+	}
+
+	return ", `" + strings.Join(names, "`, `") + "`", strings.Repeat(", ?", len(names)), values
+}
+
+// validateExtraColumns reports extra columns with invalid names or names of built-in columns.
+func validateExtraColumns(columns map[string]func() interface{}) []string {
+	builtin := make(map[string]struct{})
+	for _, column := range append(append([]columnDefinition(nil), versionTableColumns...), historyTableColumns...) {
+		builtin[column.Name] = struct{}{}
+	}
+
+	var problems []string
+	for name, value := range columns {
+		switch _, exists := builtin[strings.ToLower(name)]; {
+		case !extraColumnPattern.MatchString(name):
+			problems = append(problems, "invalid extra column name "+name)
+		case exists:
+			problems = append(problems, "extra column "+name+" conflicts with a built-in column")
+		case value == nil:
+			problems = append(problems, "extra column "+name+" has no value function")
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
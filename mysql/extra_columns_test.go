@@ -0,0 +1,55 @@
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_driver_SetVersion_ExtraColumns(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", ExtraVersionColumns: map[string]func() interface{}{
+		"release": func() interface{} { return "v1.2.0" }, // a reserved word
+		"git_sha": func() interface{} { return "abc123" },
+	}}}
+
+	if err := d.SetVersion(3, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `migrations`") {
+			want := "INSERT INTO `migrations` (version, dirty, previous_version, attempts, `git_sha`, `release`) " +
+				"VALUES (?, ?, ?, ?, ?, ?)"
+			if call.Query != want || call.Args[4] != "abc123" || call.Args[5] != "v1.2.0" {
+				t.Fatalf("unexpected insert: %s %v", call.Query, call.Args)
+			}
+			return
+		}
+	}
+	t.Fatalf("version was not inserted: %q", srv.Queries())
+}
+
+func Test_driver_withExtraColumns(t *testing.T) {
+	d := &driver{cfg: &config{ExtraVersionColumns: map[string]func() interface{}{"ticket": func() interface{} { return nil }}}}
+
+	columns := d.withExtraColumns(versionTableColumns)
+	if len(columns) != len(versionTableColumns)+1 || columns[len(columns)-1].Name != "ticket" {
+		t.Fatalf("unexpected columns: %+v", columns)
+	}
+}
+
+func Test_validateExtraColumns(t *testing.T) {
+	problems := validateExtraColumns(map[string]func() interface{}{
+		"git_sha":  func() interface{} { return nil },
+		"bad name": func() interface{} { return nil },
+		"VERSION":  func() interface{} { return nil },
+		"no_value": nil,
+	})
+
+	want := []string{"extra column VERSION conflicts with a built-in column", "extra column no_value has no value function",
+		"invalid extra column name bad name"}
+	if !reflect.DeepEqual(problems, want) {
+		t.Fatalf("unexpected problems %q, got: %q", want, problems)
+	}
+}
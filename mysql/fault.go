@@ -0,0 +1,67 @@
+package mysql
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// FaultInjector injects failures into the driver, so that deployment pipelines can be tested against realistic
+// failure modes. It must not be used in production. All faults are optional, the zero value injects nothing.
+type FaultInjector struct {
+	// FailStatement fails the Nth (1-based) migration statement that is executed by the driver, counted across
+	// all migrations. The statement is not sent to the server.
+	FailStatement int
+	// StatementError is the error of the failed statement. Defaults to ErrInjectedFault.
+	StatementError error
+	// DropLock releases the migration lock right after it was acquired, as if the lock connection was lost.
+	// The driver still assumes that it holds the lock.
+	DropLock bool
+	// SetVersionDelay delays each SetVersion call, e.g. to widen race windows between concurrent processes.
+	SetVersionDelay time.Duration
+
+	statements int64 // number of executed statements, must be accessed by atomic.XXX functions!
+}
+
+// WithFaultInjector enables the fault injection for resilience tests, see FaultInjector.
+func WithFaultInjector(injector *FaultInjector) DriverOption {
+	return func(d *driver) {
+		d.faults = injector
+	}
+}
+
+// beforeStatement returns the injected error, if the statement should fail.
+func (f *FaultInjector) beforeStatement() error {
+	if f == nil || f.FailStatement <= 0 {
+		return nil
+	}
+	if atomic.AddInt64(&f.statements, 1) != int64(f.FailStatement) {
+		return nil
+	}
+	if f.StatementError != nil {
+		return f.StatementError
+	}
+	return ErrInjectedFault
+}
+
+// afterLock drops the lock that was just acquired, if requested.
+func (f *FaultInjector) afterLock(d *driver) {
+	if f == nil || !f.DropLock {
+		return
+	}
+
+	var err error
+	if d.cfg.LockStrategy == LockStrategyTable {
+		err = d.releaseTableLock()
+	} else {
+		err = d.releaseLock()
+	}
+	d.logf("fault injection: dropped migration lock (%v)", err)
+}
+
+// beforeSetVersion delays the version update, if requested.
+func (f *FaultInjector) beforeSetVersion() {
+	if f == nil || f.SetVersionDelay <= 0 {
+		return
+	}
+	time.Sleep(f.SetVersionDelay)
+}
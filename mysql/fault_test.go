@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithFaultInjector(t *testing.T) {
+	d := &driver{cfg: &config{}}
+	injector := &FaultInjector{FailStatement: 2}
+
+	WithFaultInjector(injector)(d)
+	if d.faults != injector {
+		t.Fatalf("failed to set fault injector")
+	}
+}
+
+func Test_driver_RunMigration_FailStatement(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, MigrationsTable: "migrations"}}
+	d.faults = &FaultInjector{FailStatement: 3}
+
+	if err := d.RunMigration(strings.NewReader("SELECT 1;\nSELECT 2;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := d.RunMigration(strings.NewReader("SELECT 3;\nSELECT 4;"))
+	if !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("unexpected error %v, got: %v", ErrInjectedFault, err)
+	}
+
+	for _, query := range srv.Queries() {
+		if query == "SELECT 3" || query == "SELECT 4" {
+			t.Fatalf("unexpected query after injected fault: %s", query)
+		}
+	}
+}
+
+func Test_driver_Lock_DropLock(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true}}
+	d.faults = &FaultInjector{DropLock: true}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 2 || queries[1] != "SELECT RELEASE_LOCK(?)" {
+		t.Fatalf("lock was not dropped: %q", queries)
+	}
+	if d.reentrantLockFlag != 1 {
+		t.Fatalf("driver must still assume that it holds the lock")
+	}
+}
+
+func Test_driver_SetVersion_Delay(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations"}}
+	d.faults = &FaultInjector{SetVersionDelay: 20 * time.Millisecond}
+
+	start := time.Now()
+	if err := d.SetVersion(1, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("SetVersion was not delayed")
+	}
+}
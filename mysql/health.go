@@ -0,0 +1,160 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// Status describes the migration state of the database.
+type Status struct {
+	// Version is the current schema version.
+	Version uint64 `json:"version"`
+	// Dirty is true, if a migration failed and user interaction is required.
+	Dirty bool `json:"dirty"`
+	// LockHeld is true, if the migration lock is currently held (by any process).
+	LockHeld bool `json:"lock_held"`
+	// LastLockWait is the time this driver instance waited for the migration lock during its last attempt.
+	LastLockWait time.Duration `json:"last_lock_wait_ns"`
+	// Failure contains the diagnostics of the failed migration, if the database is dirty.
+	Failure *MigrationFailure `json:"failure,omitempty"`
+	// Description is the description of the current version, if the migration history is enabled.
+	Description string `json:"description,omitempty"`
+}
+
+func (d *driver) Healthy(ctx context.Context) error {
+	if err := d.client.PingContext(ctx); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "database is not reachable"}
+	}
+
+	row, err := d.currentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	version := lightmigrate.NoMigrationVersion
+	if row != nil {
+		if row.Dirty {
+			return lightmigrate.ErrDatabaseDirty
+		}
+		version = row.Version
+	}
+
+	if d.cfg.ExpectedVersion != 0 && version != d.cfg.ExpectedVersion {
+		return fmt.Errorf("%w: expected version %d, got %d", ErrVersionMismatch, d.cfg.ExpectedVersion, version)
+	}
+
+	return nil
+}
+
+// VersionAssertionError is returned by AssertVersion if the schema does not match the expected version.
+// It matches ErrSchemaBehind, ErrSchemaAhead or lightmigrate.ErrDatabaseDirty, and ErrVersionMismatch.
+type VersionAssertionError struct {
+	Expected uint64
+	Actual   uint64
+	Dirty    bool
+}
+
+// Error implements error interface.
+func (e *VersionAssertionError) Error() string {
+	switch {
+	case e.Dirty:
+		return fmt.Sprintf("schema version mismatch: expected version %d, got dirty version %d", e.Expected, e.Actual)
+	case e.Actual < e.Expected:
+		return fmt.Sprintf("schema is behind: expected version %d, got %d", e.Expected, e.Actual)
+	default:
+		return fmt.Sprintf("schema is ahead: expected version %d, got %d", e.Expected, e.Actual)
+	}
+}
+
+// Is reports whether target is ErrVersionMismatch or the sentinel error of the mismatch.
+func (e *VersionAssertionError) Is(target error) bool {
+	switch target {
+	case ErrVersionMismatch:
+		return true
+	case lightmigrate.ErrDatabaseDirty:
+		return e.Dirty
+	case ErrSchemaBehind:
+		return !e.Dirty && e.Actual < e.Expected
+	case ErrSchemaAhead:
+		return !e.Dirty && e.Actual > e.Expected
+	}
+	return false
+}
+
+func (d *driver) AssertVersion(expected uint64) error {
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	row, err := d.currentVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	actual := &VersionAssertionError{Expected: expected, Actual: lightmigrate.NoMigrationVersion}
+	if row != nil {
+		actual.Actual, actual.Dirty = row.Version, row.Dirty
+	}
+	if actual.Dirty || actual.Actual != expected {
+		return actual
+	}
+
+	return nil
+}
+
+func (d *driver) Status(ctx context.Context) (*Status, error) {
+	row, err := d.currentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{
+		Version:      lightmigrate.NoMigrationVersion,
+		LastLockWait: time.Duration(atomic.LoadInt64(&d.lastLockWait)),
+	}
+	if row != nil {
+		status.Version, status.Dirty = row.Version, row.Dirty
+	}
+	if !status.Dirty && status.Version != lightmigrate.NoMigrationVersion {
+		if status.Description, err = d.versionDescription(ctx, status.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	if status.Dirty && d.store == nil {
+		if status.Failure, err = d.readFailure(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if status.LockHeld, err = d.isLockHeld(ctx); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// isLockHeld checks if the migration lock is currently held by any session.
+func (d *driver) isLockHeld(ctx context.Context) (bool, error) {
+	if !d.cfg.Locking || d.featureDisabled(featureLockStatus) {
+		return false, nil
+	}
+	if d.cfg.LockStrategy == LockStrategyTable {
+		return d.isTableLockHeld(ctx)
+	}
+
+	var owner sql.NullInt64
+	query := "SELECT IS_USED_LOCK(?)"
+	if err := d.client.QueryRowContext(ctx, query, d.getLockingKey()).Scan(&owner); err != nil {
+		if d.degrade(featureLockStatus, err) {
+			return false, nil
+		}
+		return false, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to check lock", Query: []byte(query)}
+	}
+
+	return owner.Valid, nil
+}
@@ -0,0 +1,143 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+func TestWithExpectedVersion(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithExpectedVersion(5)(d)
+	if d.cfg.ExpectedVersion != 5 {
+		t.Fatalf("failed to set expected version")
+	}
+}
+
+func Test_driver_Healthy(t *testing.T) {
+	errPing := errors.New("connection refused")
+	tests := []struct {
+		name     string
+		handler  fakeHandler
+		expected uint64
+		wantErr  error
+	}{
+		{"clean", versionHandler([]sqldriver.Value{int64(2), int64(0), nil, nil, nil}), 2, nil},
+		{"no expectation", versionHandler([]sqldriver.Value{int64(2), int64(0), nil, nil, nil}), 0, nil},
+		{"empty", versionHandler(nil), 0, nil},
+		{"mismatch", versionHandler([]sqldriver.Value{int64(1), int64(0), nil, nil, nil}), 2, ErrVersionMismatch},
+		{"dirty", versionHandler([]sqldriver.Value{int64(2), int64(1), nil, nil, nil}), 2, lightmigrate.ErrDatabaseDirty},
+		{"unreachable", func(call fakeCall) fakeResponse {
+			if call.Query == "PING" {
+				return fakeResponse{Err: errPing}
+			}
+			return fakeResponse{}
+		}, 0, errPing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, tt.handler)
+			d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", ExpectedVersion: tt.expected}}
+
+			err := d.Healthy(context.Background())
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func Test_driver_Healthy_BrokenTable(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT version") {
+			return fakeResponse{Err: errors.New("table does not exist")}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations"}}
+
+	if err := d.Healthy(context.Background()); err == nil {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
+
+func Test_driver_Status(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case strings.HasPrefix(call.Query, "SELECT version"):
+			return versionHandler([]sqldriver.Value{int64(4), int64(1), int64(3), int64(0), int64(1)})(call)
+		case strings.HasPrefix(call.Query, "SELECT error_statement"):
+			return fakeResponse{
+				Columns: []string{"error_statement", "error_code", "error_message", "idempotent"},
+				Rows:    [][]sqldriver.Value{{int64(2), int64(1064), "syntax error", int64(0)}},
+			}
+		case strings.HasPrefix(call.Query, "SELECT IS_USED_LOCK"):
+			return fakeResponse{Columns: []string{"owner"}, Rows: [][]sqldriver.Value{{int64(12)}}}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", MigrationsTable: "migrations", Locking: true}}
+	d.lastLockWait = int64(3 * time.Second)
+
+	status, err := d.Status(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status.Version != 4 || !status.Dirty || !status.LockHeld || status.LastLockWait != 3*time.Second {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if status.Failure == nil || status.Failure.StatementIndex != 2 || status.Failure.ErrorCode != 1064 {
+		t.Fatalf("unexpected failure: %+v", status.Failure)
+	}
+}
+
+func Test_driver_AssertVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler fakeHandler
+		wantErr error
+	}{
+		{"match", versionHandler([]sqldriver.Value{int64(2), int64(0), nil, nil, nil}), nil},
+		{"behind", versionHandler([]sqldriver.Value{int64(1), int64(0), nil, nil, nil}), ErrSchemaBehind},
+		{"empty", versionHandler(nil), ErrSchemaBehind},
+		{"ahead", versionHandler([]sqldriver.Value{int64(3), int64(0), nil, nil, nil}), ErrSchemaAhead},
+		{"dirty", versionHandler([]sqldriver.Value{int64(2), int64(1), nil, nil, nil}), lightmigrate.ErrDatabaseDirty},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, tt.handler)
+			d := &driver{client: db, cfg: &config{MigrationsTable: "migrations"}}
+
+			err := d.AssertVersion(2)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) || !errors.Is(err, ErrVersionMismatch) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+			for _, other := range []error{ErrSchemaBehind, ErrSchemaAhead, lightmigrate.ErrDatabaseDirty} {
+				if other != tt.wantErr && errors.Is(err, other) {
+					t.Fatalf("unexpected match of %v, got: %v", other, err)
+				}
+			}
+
+			var assertErr *VersionAssertionError
+			if !errors.As(err, &assertErr) || assertErr.Expected != 2 {
+				t.Fatalf("unexpected error type, got: %#v", err)
+			}
+		})
+	}
+}
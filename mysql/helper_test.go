@@ -0,0 +1,261 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeCall describes a single query that was received by the fake database server.
+type fakeCall struct {
+	ConnID int
+	Query  string
+	Args   []sqldriver.Value
+}
+
+// fakeResponse is the answer of the fake database server for a single query.
+type fakeResponse struct {
+	Columns      []string
+	Rows         [][]sqldriver.Value
+	RowsAffected int64
+	Err          error
+}
+
+// fakeHandler is used by tests to script the behaviour of the fake database server.
+type fakeHandler func(call fakeCall) fakeResponse
+
+// fakeServer is a minimal in-memory database/sql driver that records all queries.
+// It allows to test the driver logic without a running MySQL server.
+type fakeServer struct {
+	mu      sync.Mutex
+	calls   []fakeCall
+	handler fakeHandler
+	nextID  int
+}
+
+var (
+	fakeServersMu sync.Mutex
+	fakeServers   = map[string]*fakeServer{}
+	fakeServerIdx int
+)
+
+func init() {
+	sql.Register("lightmigrate-fake", fakeDriver{})
+}
+
+// newFakeDB opens a new sql.DB that is backed by a fake server. If handler is nil, defaultFakeHandler is used.
+func newFakeDB(t testing.TB, handler fakeHandler) (*sql.DB, *fakeServer) {
+	if handler == nil {
+		handler = defaultFakeHandler
+	}
+
+	fakeServersMu.Lock()
+	fakeServerIdx++
+	dsn := fmt.Sprintf("fake-%d", fakeServerIdx)
+	srv := &fakeServer{handler: handler}
+	fakeServers[dsn] = srv
+	fakeServersMu.Unlock()
+
+	db, err := sql.Open("lightmigrate-fake", dsn)
+	if err != nil {
+		t.Fatalf("failed to open fake database: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+		fakeServersMu.Lock()
+		delete(fakeServers, dsn)
+		fakeServersMu.Unlock()
+	})
+
+	return db, srv
+}
+
+// defaultFakeHandler grants all locks and returns empty results for all other queries.
+func defaultFakeHandler(call fakeCall) fakeResponse {
+	if strings.HasPrefix(call.Query, "SELECT GET_LOCK") || strings.HasPrefix(call.Query, "SELECT RELEASE_LOCK") {
+		return fakeResponse{Columns: []string{"result"}, Rows: [][]sqldriver.Value{{int64(1)}}}
+	}
+	if strings.HasPrefix(call.Query, "SELECT @@SESSION.character_set_client") {
+		// like the go-sql-driver default, the connections of the fake server use utf8mb4
+		return fakeResponse{Columns: []string{"client", "connection", "collation"},
+			Rows: [][]sqldriver.Value{{"utf8mb4", "utf8mb4", "utf8mb4_general_ci"}}}
+	}
+	if call.Query == "SELECT @@server_uuid" {
+		return fakeResponse{Columns: []string{"uuid"}, Rows: [][]sqldriver.Value{{"3e11fa47-71ca-11e1-9e33-c80aa9429562"}}}
+	}
+	return fakeResponse{}
+}
+
+// Queries returns all queries received by the server.
+func (s *fakeServer) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	queries := make([]string, len(s.calls))
+	for i, call := range s.calls {
+		queries[i] = call.Query
+	}
+	return queries
+}
+
+// Calls returns all calls received by the server.
+func (s *fakeServer) Calls() []fakeCall {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]fakeCall(nil), s.calls...)
+}
+
+// Reset forgets all recorded calls.
+func (s *fakeServer) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls = nil
+}
+
+func (s *fakeServer) handle(connID int, query string, args []sqldriver.NamedValue) fakeResponse {
+	values := make([]sqldriver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	call := fakeCall{ConnID: connID, Query: query, Args: values}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	handler := s.handler
+	s.mu.Unlock()
+
+	return handler(call)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (sqldriver.Conn, error) {
+	fakeServersMu.Lock()
+	srv, ok := fakeServers[dsn]
+	fakeServersMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown fake server %s", dsn)
+	}
+
+	srv.mu.Lock()
+	srv.nextID++
+	id := srv.nextID
+	srv.mu.Unlock()
+
+	return &fakeConn{srv: srv, id: id}, nil
+}
+
+type fakeConn struct {
+	srv *fakeServer
+	id  int
+}
+
+func (c *fakeConn) Prepare(query string) (sqldriver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (sqldriver.Tx, error) {
+	return c.BeginTx(context.Background(), sqldriver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(_ context.Context, _ sqldriver.TxOptions) (sqldriver.Tx, error) {
+	if resp := c.srv.handle(c.id, "BEGIN", nil); resp.Err != nil {
+		return nil, resp.Err
+	}
+	return &fakeTx{conn: c}, nil
+}
+
+func (c *fakeConn) Ping(_ context.Context) error {
+	return c.srv.handle(c.id, "PING", nil).Err
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Result, error) {
+	resp := c.srv.handle(c.id, query, args)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return sqldriver.RowsAffected(resp.RowsAffected), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []sqldriver.NamedValue) (sqldriver.Rows, error) {
+	resp := c.srv.handle(c.id, query, args)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return &fakeRows{columns: resp.Columns, rows: resp.Rows}, nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []sqldriver.Value) (sqldriver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *fakeStmt) Query(args []sqldriver.Value) (sqldriver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []sqldriver.Value) []sqldriver.NamedValue {
+	named := make([]sqldriver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = sqldriver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type fakeTx struct {
+	conn *fakeConn
+}
+
+func (t *fakeTx) Commit() error {
+	return t.conn.srv.handle(t.conn.id, "COMMIT", nil).Err
+}
+
+func (t *fakeTx) Rollback() error {
+	return t.conn.srv.handle(t.conn.id, "ROLLBACK", nil).Err
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]sqldriver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []sqldriver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
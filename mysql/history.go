@@ -0,0 +1,228 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultHistoryTable is the name of the migration history table, see WithHistory.
+const DefaultHistoryTable = "schema_migrations_history"
+
+// historyTableColumns are the columns of the history table. Columns that are added in later releases
+// must be nullable, so that they can be added to existing tables.
+var historyTableColumns = []columnDefinition{
+	{Name: "id", Definition: "bigint not null auto_increment primary key"},
+	{Name: "version", Definition: "bigint not null"},
+	{Name: "success", Definition: "boolean not null"},
+	{Name: "started_at", Definition: "datetime not null"},
+	{Name: "finished_at", Definition: "datetime not null"},
+	{Name: "statements", Definition: "int not null"},
+	{Name: "rows_affected", Definition: "bigint not null"},
+	{Name: "error_message", Definition: "text null"},
+	{Name: "db_user", Definition: "varchar(320) null"},
+	{Name: "client_host", Definition: "varchar(255) null"},
+	{Name: "applied_by", Definition: "varchar(255) null"},
+	{Name: "description", Definition: "varchar(255) null"},
+	{Name: "checksum", Definition: "varchar(64) null"},
+	{Name: "checksum_algorithm", Definition: "varchar(16) null"},
+	{Name: "checksum_normalized", Definition: "boolean null"},
+	{Name: "skipped", Definition: "boolean null"},
+	{Name: "note", Definition: "text null"},
+	{Name: "server_uuid", Definition: "varchar(255) null"},
+	{Name: "direction", Definition: "varchar(4) null"},
+}
+
+// AppliedVersion is a single entry of the migration history.
+type AppliedVersion struct {
+	// Version is the schema version after the migration, for down migrations the version below the reverted one.
+	Version      uint64    `json:"version"`
+	Success      bool      `json:"success"`
+	StartedAt    time.Time `json:"started_at"`
+	FinishedAt   time.Time `json:"finished_at"`
+	Statements   int       `json:"statements"`
+	RowsAffected int64     `json:"rows_affected"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	// DatabaseUser is the account of the migration connection (CURRENT_USER()).
+	DatabaseUser string `json:"db_user,omitempty"`
+	// ClientHost is the hostname of the machine that applied the migration.
+	ClientHost string `json:"client_host,omitempty"`
+	// AppliedBy is the identifier that was configured by WithAppliedBy.
+	AppliedBy string `json:"applied_by,omitempty"`
+	// Description is taken from the description comment of the migration, see DescriptionPrefix.
+	Description string `json:"description,omitempty"`
+	// Checksum of the successfully applied migration, see WithChecksumAlgorithm.
+	Checksum          string `json:"checksum,omitempty"`
+	ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"`
+	// Skipped is true, if the migration was recorded as applied without executing it.
+	Skipped bool `json:"skipped,omitempty"`
+	// Note is the reason of a skipped migration.
+	Note string `json:"note,omitempty"`
+	// Direction is either lightmigrate.Up or lightmigrate.Down, it is empty for entries that were recorded before
+	// the direction was stored.
+	Direction lightmigrate.Direction `json:"direction,omitempty"`
+}
+
+// WithHistory enables the migration history table, which keeps a row for each applied (or failed) migration.
+func WithHistory(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.History = enabled
+	}
+}
+
+// WithAppliedBy sets an identifier of the application, pipeline or person that applies the migrations, e.g.
+// "ci-pipeline-1234". It is stored in the migration history, see WithHistory.
+func WithAppliedBy(identifier string) DriverOption {
+	return func(d *driver) {
+		d.cfg.AppliedBy = identifier
+	}
+}
+
+// historyTable returns the name of the history table of the migration namespace.
+func (d *driver) historyTable() string {
+	if d.cfg.Namespace != "" {
+		return d.tableName(DefaultHistoryTable + "_" + d.cfg.Namespace)
+	}
+	return d.tableName(DefaultHistoryTable)
+}
+
+// prepareHistoryTable creates the history table, if the history is enabled.
+func (d *driver) prepareHistoryTable(ctx context.Context) error {
+	if !d.cfg.History {
+		return nil
+	}
+
+	columns := d.withExtraColumns(historyTableColumns)
+	if err := d.createTable(ctx, d.historyTable(), columns, "failed create history table"); err != nil {
+		return err
+	}
+
+	return d.ensureColumns(ctx, d.historyTable(), columns)
+}
+
+// recordHistory appends the migration to the history table. Errors are only logged, as the migration itself
+// was already applied (or failed).
+func (d *driver) recordHistory(state *migrationState, started time.Time, migrationErr error) {
+	if !d.cfg.History {
+		return
+	}
+
+	if err := d.insertHistory(d.runningVersion, d.runningDirection, state, started, migrationErr); err != nil {
+		d.logf("failed to record migration history: %v", err)
+	}
+}
+
+// insertHistory inserts a row for the migration into the history table.
+func (d *driver) insertHistory(version uint64, direction lightmigrate.Direction, state *migrationState, started time.Time,
+	migrationErr error) error {
+	var errorMessage, checksum, algorithm, normalized interface{}
+	if migrationErr != nil {
+		errorMessage = truncateMessage(migrationErr.Error(), maxErrorMessageLength)
+	} else if state.Checksum != nil {
+		checksum, algorithm = state.Checksum.Sum(), d.cfg.ChecksumAlgorithm.String()
+		normalized = d.cfg.ChecksumNormalization
+	}
+	host, _ := os.Hostname()
+	extraColumns, extraPlaceholders, extraValues := d.extraColumnValues()
+
+	query := "INSERT INTO `" + d.historyTable() + "` (version, success, started_at, finished_at, statements, " +
+		"rows_affected, error_message, db_user, client_host, applied_by, description, checksum, checksum_algorithm, " +
+		"checksum_normalized, skipped, note, server_uuid, direction" + extraColumns + ") VALUES (?, ?, FROM_UNIXTIME(?), " +
+		"FROM_UNIXTIME(?), ?, ?, ?, CURRENT_USER(), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?" + extraPlaceholders + ")"
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	args := append([]interface{}{version, migrationErr == nil, started.Unix(), time.Now().Unix(),
+		state.Result.result.Statements, state.Result.result.RowsAffected, errorMessage, host, d.cfg.AppliedBy,
+		nullString(state.Description), checksum, algorithm, normalized, state.Skipped, nullString(state.Note),
+		nullString(d.server.Identity), string(direction)},
+		extraValues...)
+	_, err := d.execPrepared(ctx, nil, query, args...)
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to record migration history", Query: []byte(query)}
+	}
+	return nil
+}
+
+// AppliedVersions returns the migration history of the version store, oldest entries first. The history table
+// must be enabled, see WithHistory.
+func (d *driver) AppliedVersions(ctx context.Context) ([]AppliedVersion, error) {
+	return d.versionStore().History(ctx)
+}
+
+// VersionAt returns the schema version that was live at the given time, according to the migration history: the
+// version of the last migration that succeeded before t. Failed migrations are ignored, if no migration was applied
+// before t, lightmigrate.NoMigrationVersion is returned. The history must be enabled, see WithHistory.
+func (d *driver) VersionAt(t time.Time) (uint64, error) {
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	history, err := d.versionStore().History(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	version := lightmigrate.NoMigrationVersion
+	for _, entry := range history {
+		if entry.FinishedAt.After(t) {
+			break // the history is ordered, all following entries are newer
+		}
+		if entry.Success {
+			version = entry.Version
+		}
+	}
+	return version, nil
+}
+
+// readHistory reads the history table, oldest entries first.
+func (d *driver) readHistory(ctx context.Context) ([]AppliedVersion, error) {
+	if !d.cfg.History {
+		return nil, ErrNoHistory
+	}
+
+	query := "SELECT version, success, UNIX_TIMESTAMP(started_at), UNIX_TIMESTAMP(finished_at), statements, " +
+		"rows_affected, error_message, db_user, client_host, applied_by, description, checksum, " +
+		"checksum_algorithm, skipped, note, direction FROM `" + d.historyTable() + "` ORDER BY id"
+	rows, err := d.client.QueryContext(ctx, query)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read migration history", Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	var history []AppliedVersion
+	for rows.Next() {
+		var entry AppliedVersion
+		var started, finished int64
+		var errorMessage, user, host, appliedBy, description, checksum, algorithm, note, direction sql.NullString
+		var skipped sql.NullBool
+		if err := rows.Scan(&entry.Version, &entry.Success, &started, &finished, &entry.Statements,
+			&entry.RowsAffected, &errorMessage, &user, &host, &appliedBy, &description, &checksum,
+			&algorithm, &skipped, &note, &direction); err != nil {
+			return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read migration history", Query: []byte(query)}
+		}
+		entry.StartedAt, entry.FinishedAt = time.Unix(started, 0), time.Unix(finished, 0)
+		entry.ErrorMessage, entry.DatabaseUser = errorMessage.String, user.String
+		entry.ClientHost, entry.AppliedBy = host.String, appliedBy.String
+		entry.Description = description.String
+		entry.Checksum, entry.ChecksumAlgorithm = checksum.String, algorithm.String
+		entry.Skipped, entry.Note = skipped.Bool, note.String
+		entry.Direction = lightmigrate.Direction(direction.String)
+		history = append(history, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read migration history", Query: []byte(query)}
+	}
+
+	return history, nil
+}
+
+// nullString converts empty strings to NULL.
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
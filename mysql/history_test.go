@@ -0,0 +1,125 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+func Test_driver_RunMigration_History(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, runningVersion: 7,
+		cfg: &config{SplitStatements: true, History: true, AppliedBy: "ci-pipeline-1234"}}
+
+	if err := d.RunMigration(strings.NewReader("SELECT 1; SELECT 2;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var insert *fakeCall
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_history`") {
+			call := call
+			insert = &call
+		}
+	}
+	if insert == nil {
+		t.Fatalf("expected history row, got queries: %q", srv.Queries())
+	}
+	if !strings.Contains(insert.Query, "CURRENT_USER()") {
+		t.Fatalf("expected database user in history row, got: %s", insert.Query)
+	}
+	args := insert.Args
+	if args[0] != int64(7) || args[1] != true || args[4] != int64(2) || args[6] != nil || args[8] != "ci-pipeline-1234" ||
+		args[11] != "crc32" {
+		t.Fatalf("unexpected history arguments, got: %v", args)
+	}
+}
+
+func Test_driver_RunMigration_HistoryDirection(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true, History: true}}
+
+	// up from 3 to 4, then down from 4 to 3
+	for _, version := range []uint64{3, 4, 3} {
+		if d.versionKnown && version != d.knownVersion {
+			if err := d.SetVersion(version, true); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := d.RunMigration(strings.NewReader("SELECT 1;")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if err := d.SetVersion(version, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var directions []interface{}
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_history`") {
+			directions = append(directions, call.Args[16])
+		}
+	}
+	if len(directions) != 2 || directions[0] != "up" || directions[1] != "down" {
+		t.Fatalf("unexpected history directions, got: %v", directions)
+	}
+}
+
+func Test_driver_AppliedVersions(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT version, success") {
+			return fakeResponse{
+				Columns: []string{"version", "success", "started_at", "finished_at", "statements", "rows_affected",
+					"error_message", "db_user", "client_host", "applied_by", "description", "checksum",
+					"checksum_algorithm", "skipped", "note", "direction"},
+				Rows: [][]sqldriver.Value{
+					{int64(1), int64(1), int64(100), int64(160), int64(3), int64(10), nil, "app@%", "ci-1", "ci-pipeline-1", "add users", "123", "crc32", nil, nil, "up"},
+					{int64(2), int64(0), int64(200), int64(201), int64(0), int64(0), "syntax error", "app@%", "ci-1", nil, nil, nil, nil, int64(1), "applied manually", nil},
+				},
+			}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{History: true}}
+
+	history, err := d.AppliedVersions(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(history) != 2 || !history[0].Success || history[0].FinishedAt.Sub(history[0].StartedAt).Seconds() != 60 ||
+		history[0].AppliedBy != "ci-pipeline-1" || history[0].Description != "add users" ||
+		history[0].Checksum != "123" || history[0].Direction != lightmigrate.Up || history[1].Direction != "" ||
+		history[1].ErrorMessage != "syntax error" || history[1].AppliedBy != "" || !history[1].Skipped || history[1].Note != "applied manually" {
+		t.Fatalf("unexpected history, got: %+v", history)
+	}
+
+	d.cfg.History = false
+	if _, err := d.AppliedVersions(context.Background()); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("unexpected error %v, got: %v", ErrNoHistory, err)
+	}
+}
+
+func Test_driver_VersionAt(t *testing.T) {
+	d := &driver{cfg: &config{}, store: &memoryVersionStore{history: []AppliedVersion{
+		{Version: 1, Success: true, FinishedAt: time.Unix(100, 0)},
+		{Version: 2, Success: true, FinishedAt: time.Unix(200, 0)},
+		{Version: 3, Success: false, FinishedAt: time.Unix(300, 0)},
+		{Version: 1, Success: true, FinishedAt: time.Unix(400, 0)}, // rollback
+	}}}
+
+	tests := map[int64]uint64{50: lightmigrate.NoMigrationVersion, 100: 1, 250: 2, 350: 2, 500: 1}
+	for at, want := range tests {
+		version, err := d.VersionAt(time.Unix(at, 0))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if version != want {
+			t.Fatalf("unexpected version %d at %d, got: %d", want, at, version)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// WithPreRunSQL sets statements that are executed once per migration run, before the first migration is applied,
+// e.g. to insert a deployment marker or to enable a maintenance flag of the application.
+// If no migration needs to be applied, the statements are not executed.
+func WithPreRunSQL(statements []string) DriverOption {
+	return func(d *driver) {
+		d.cfg.PreRunSQL = statements
+	}
+}
+
+// WithPostRunSQL sets statements that are executed once per migration run, after the last migration was applied
+// (or failed) and before the migration lock is released. If no migration was applied, the statements are not
+// executed. Failed statements are logged and returned by Unlock, they do not change the migration version.
+func WithPostRunSQL(statements []string) DriverOption {
+	return func(d *driver) {
+		d.cfg.PostRunSQL = statements
+	}
+}
+
+// startRunHooks executes the pre-run statements, if the first migration of the run is about to be applied.
+func (d *driver) startRunHooks() error {
+	if d.runActive {
+		return nil
+	}
+	d.runActive = true
+	return d.execRunHooks("pre-run", d.cfg.PreRunSQL)
+}
+
+// finishRunHooks executes the post-run statements, if a migration was applied within the run.
+func (d *driver) finishRunHooks() error {
+	if !d.runActive {
+		return nil
+	}
+	d.runActive = false
+	return d.execRunHooks("post-run", d.cfg.PostRunSQL)
+}
+
+// execRunHooks executes the hook statements within a migration session, like the statements of a migration.
+func (d *driver) execRunHooks(kind string, statements []string) error {
+	if len(statements) == 0 {
+		return nil
+	}
+
+	session, err := d.openSession(context.Background())
+	if err != nil {
+		return err
+	}
+	defer d.closeSession(session)
+
+	state := &migrationState{}
+	for i, query := range statements {
+		stmt := statement{Query: query, Index: i + 1}
+		if d.verbose {
+			d.logf("executing %s statement %d: %s", kind, stmt.Index, stmt.Query)
+		}
+
+		if err := d.execStatement(context.Background(), session, state, stmt); err != nil {
+			var driverErr *lightmigrate.DriverError
+			if errors.As(err, &driverErr) {
+				driverErr.Msg = fmt.Sprintf("%s statement %d failed", kind, stmt.Index)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package mysql
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func Test_driver_RunHooks(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", Locking: true, SplitStatements: true,
+		PreRunSQL:  []string{"INSERT INTO deployments VALUES (NOW())"},
+		PostRunSQL: []string{"UPDATE flags SET maintenance = 0"}}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, version := range []uint64{1, 2} {
+		if err := d.SetVersion(version, true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := d.RunMigration(strings.NewReader("SELECT 1;")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var hooks []string
+	for _, query := range srv.Queries() {
+		switch {
+		case strings.HasPrefix(query, "INSERT INTO deployments"):
+			hooks = append(hooks, "pre")
+		case strings.HasPrefix(query, "UPDATE flags"):
+			hooks = append(hooks, "post")
+		case strings.HasPrefix(query, "SELECT RELEASE_LOCK"):
+			hooks = append(hooks, "unlock")
+		}
+	}
+	if strings.Join(hooks, ",") != "pre,post,unlock" {
+		t.Fatalf("unexpected hook order, got: %v", hooks)
+	}
+}
+
+func Test_driver_RunHooks_NoMigration(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", Locking: true, PostRunSQL: []string{"SELECT 1"}}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, query := range srv.Queries() {
+		if query == "SELECT 1" {
+			t.Fatalf("unexpected post-run statement without migration")
+		}
+	}
+}
+
+func Test_driver_RunHooks_PostRunFailure(t *testing.T) {
+	errHook := errors.New("table does not exist")
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "UPDATE flags") {
+			return fakeResponse{Err: errHook}
+		}
+		return defaultFakeHandler(call)
+	})
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0), cfg: &config{DatabaseName: "db", Locking: true,
+		SplitStatements: true, PostRunSQL: []string{"UPDATE flags SET maintenance = 0"}}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.SetVersion(1, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Unlock(); !errors.Is(err, errHook) {
+		t.Fatalf("expected error %v, got: %v", errHook, err)
+	}
+	if !strings.Contains(logs.String(), "failed to finish the migration run: post-run statement 1 failed") {
+		t.Fatalf("post-run failure was not logged, got: %s", logs.String())
+	}
+}
+
+func Test_driver_RunHooks_Failure(t *testing.T) {
+	errHook := errors.New("table does not exist")
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "INSERT INTO deployments") {
+			return fakeResponse{Err: errHook}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{PreRunSQL: []string{"INSERT INTO deployments VALUES (NOW())"}}}
+
+	err := d.SetVersion(1, true)
+	if !errors.Is(err, errHook) || !strings.Contains(err.Error(), "pre-run statement 1 failed") {
+		t.Fatalf("unexpected error, got: %v", err)
+	}
+}
@@ -0,0 +1,352 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ImpactEstimation selects how the impact of UPDATE and DELETE statements is estimated, see WithImpactEstimation.
+type ImpactEstimation int
+
+const (
+	// ImpactEstimationDisabled disables the estimation.
+	ImpactEstimationDisabled ImpactEstimation = iota
+	// ImpactEstimationExplain uses the row estimate of the query plan (EXPLAIN). It is cheap, but only as accurate
+	// as the index statistics of the server.
+	ImpactEstimationExplain
+	// ImpactEstimationCount counts the affected rows (SELECT COUNT(*)). The count is exact, but scans the rows
+	// selected by the statement. Multi-table statements fall back to ImpactEstimationExplain.
+	ImpactEstimationCount
+)
+
+// String returns the name of the estimation method.
+func (e ImpactEstimation) String() string {
+	switch e {
+	case ImpactEstimationExplain:
+		return "explain"
+	case ImpactEstimationCount:
+		return "count"
+	default:
+		return "disabled"
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (e ImpactEstimation) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (e *ImpactEstimation) UnmarshalText(text []byte) error {
+	for _, method := range []ImpactEstimation{ImpactEstimationDisabled, ImpactEstimationExplain, ImpactEstimationCount} {
+		if strings.EqualFold(string(text), method.String()) {
+			*e = method
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown impact estimation %q", text)
+}
+
+// WithImpactEstimation estimates the number of rows affected by each UPDATE and DELETE statement, and the size of
+// the target table, before the statement is executed. The estimates are logged and reported by
+// RunMigrationWithResult. Failed estimates are logged, they never fail the migration.
+func WithImpactEstimation(method ImpactEstimation) DriverOption {
+	return func(d *driver) {
+		d.cfg.ImpactEstimation = method
+	}
+}
+
+// ImpactEstimate is the estimated impact of a single UPDATE or DELETE statement.
+type ImpactEstimate struct {
+	StatementIndex int              `json:"statement_index"`
+	Line           int              `json:"line"`
+	Method         ImpactEstimation `json:"method"`
+	// Table is the target table, empty if it could not be determined.
+	Table string `json:"table,omitempty"`
+	// EstimatedRows is the number of rows the statement is expected to change.
+	EstimatedRows int64 `json:"estimated_rows"`
+	// TableRows and TableBytes are the approximate size of the target table (data and indexes) according to
+	// information_schema.
+	TableRows  int64 `json:"table_rows"`
+	TableBytes int64 `json:"table_bytes"`
+}
+
+// impactTarget is the table reference of an UPDATE or DELETE statement.
+type impactTarget struct {
+	Schema string
+	Table  string
+	// Ref is the table reference including an alias, e.g. "users u".
+	Ref string
+	// Filter contains the clauses that select the affected rows (WHERE, ORDER BY, LIMIT).
+	Filter string
+	// MultiTable is true for statements that change or join several tables. Ref and Filter are not set.
+	MultiTable bool
+}
+
+// countQuery returns the query that counts the rows affected by the statement.
+func (t impactTarget) countQuery() string {
+	return strings.TrimSpace("SELECT COUNT(*) FROM (SELECT 1 FROM "+t.Ref+" "+t.Filter) + ") AS impact"
+}
+
+// estimateImpact estimates and records the impact of UPDATE and DELETE statements, see WithImpactEstimation.
+func (d *driver) estimateImpact(ctx context.Context, session *sql.Conn, state *migrationState, stmt statement) {
+	if d.cfg.ImpactEstimation == ImpactEstimationDisabled {
+		return
+	}
+	keyword := strings.ToUpper(firstKeyword(stmt.Query))
+	if keyword != "UPDATE" && keyword != "DELETE" {
+		return
+	}
+
+	target, ok := parseImpactTarget(stmt.Query)
+	estimate := ImpactEstimate{StatementIndex: stmt.Index, Line: stmt.Line, Method: d.cfg.ImpactEstimation,
+		Table: target.Table}
+
+	var err error
+	if d.cfg.ImpactEstimation == ImpactEstimationCount && ok && !target.MultiTable {
+		err = session.QueryRowContext(ctx, target.countQuery()).Scan(&estimate.EstimatedRows)
+	} else {
+		estimate.Method = ImpactEstimationExplain
+		estimate.EstimatedRows, err = explainRows(ctx, session, stmt.Query)
+	}
+	if err != nil {
+		d.logf("failed to estimate impact of statement %d (line %d): %v", stmt.Index, stmt.Line, err)
+		return
+	}
+
+	if estimate.Table != "" {
+		var tableRows, tableBytes sql.NullInt64
+		query := "SELECT TABLE_ROWS, DATA_LENGTH + INDEX_LENGTH FROM information_schema.tables " +
+			"WHERE TABLE_SCHEMA = IFNULL(NULLIF(?, ''), DATABASE()) AND TABLE_NAME = ?"
+		err := session.QueryRowContext(ctx, query, target.Schema, target.Table).Scan(&tableRows, &tableBytes)
+		if err != nil && err != sql.ErrNoRows {
+			d.logf("failed to read size of table %s for statement %d (line %d): %v", estimate.Table, stmt.Index,
+				stmt.Line, err)
+		}
+		estimate.TableRows, estimate.TableBytes = tableRows.Int64, tableBytes.Int64
+	}
+
+	d.logf("statement %d (line %d) affects an estimated %d rows of table %s (%d rows, %d bytes, %s)", stmt.Index,
+		stmt.Line, estimate.EstimatedRows, estimate.Table, estimate.TableRows, estimate.TableBytes, estimate.Method)
+	state.Result.estimate(estimate)
+}
+
+// explainRows returns the row estimate of the first plan row of the statement.
+func explainRows(ctx context.Context, session *sql.Conn, query string) (int64, error) {
+	rows, err := session.QueryContext(ctx, "EXPLAIN "+query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	rowsColumn := -1
+	for i, column := range columns {
+		if strings.EqualFold(column, "rows") {
+			rowsColumn = i
+		}
+	}
+	if rowsColumn < 0 {
+		return 0, fmt.Errorf("query plan has no rows column")
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, err
+		}
+		if values[rowsColumn] != nil {
+			return strconv.ParseInt(string(values[rowsColumn]), 10, 64)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("query plan has no row estimate")
+}
+
+// parseImpactTarget extracts the target table of an UPDATE or DELETE statement. Quoted strings, identifiers and
+// parenthesized expressions are respected. If ok is false, the statement could not be parsed.
+func parseImpactTarget(query string) (target impactTarget, ok bool) {
+	words := topLevelWords(query)
+	if len(words) == 0 {
+		return target, false
+	}
+
+	i := 1
+	var refEnd map[string]bool
+	switch strings.ToUpper(words[0].text) {
+	case "UPDATE":
+		i = skipWords(words, i, "LOW_PRIORITY", "IGNORE")
+		refEnd = map[string]bool{"SET": true}
+	case "DELETE":
+		i = skipWords(words, i, "LOW_PRIORITY", "QUICK", "IGNORE")
+		if i >= len(words) || !strings.EqualFold(words[i].text, "FROM") {
+			// multi-table syntax: DELETE t1, t2 FROM t1 JOIN t2 ...
+			return impactTarget{MultiTable: true}, false
+		}
+		i++
+		refEnd = map[string]bool{"WHERE": true, "ORDER": true, "LIMIT": true, "USING": true}
+	default:
+		return target, false
+	}
+
+	start := i
+	for i < len(words) && !refEnd[strings.ToUpper(words[i].text)] {
+		switch strings.ToUpper(words[i].text) {
+		case ",", "JOIN", "STRAIGHT_JOIN":
+			target.MultiTable = true
+		}
+		i++
+	}
+	if i == start {
+		return target, false
+	}
+	target.Schema, target.Table = splitQualifiedName(words[start].text)
+	if target.MultiTable || (i < len(words) && strings.EqualFold(words[i].text, "USING")) {
+		return impactTarget{Schema: target.Schema, Table: target.Table, MultiTable: true}, true
+	}
+
+	ref := query[words[start].pos:]
+	if i < len(words) {
+		ref = query[words[start].pos:words[i].pos]
+	}
+	target.Ref = strings.TrimSpace(ref)
+
+	for ; i < len(words); i++ {
+		switch strings.ToUpper(words[i].text) {
+		case "WHERE", "ORDER", "LIMIT":
+			target.Filter = strings.TrimSpace(query[words[i].pos:])
+			return target, true
+		}
+	}
+	return target, true
+}
+
+// skipWords skips the optional modifiers starting at index i.
+func skipWords(words []queryWord, i int, modifiers ...string) int {
+	for i < len(words) {
+		found := false
+		for _, modifier := range modifiers {
+			if strings.EqualFold(words[i].text, modifier) {
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+// queryWord is a single word of a statement and its byte offset.
+type queryWord struct {
+	text string
+	pos  int
+}
+
+// topLevelWords splits the statement into words. Quoted strings, comments and parenthesized expressions are
+// skipped, backticked identifiers are kept within their word. Commas are returned as separate words.
+func topLevelWords(query string) []queryWord {
+	var words []queryWord
+	depth, start := 0, -1
+	flush := func(end int) {
+		if start >= 0 && depth == 0 {
+			words = append(words, queryWord{text: query[start:end], pos: start})
+		}
+		start = -1
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			flush(i)
+			i = skipQuoted(query, i, c)
+		case c == '`':
+			if start < 0 && depth == 0 {
+				start = i
+			}
+			i = skipQuoted(query, i, c)
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			flush(i)
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(query)
+			}
+		case c == '(':
+			flush(i)
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case c == ',':
+			flush(i)
+			if depth == 0 {
+				words = append(words, queryWord{text: ",", pos: i})
+			}
+		case isSpace(c):
+			flush(i)
+		default:
+			if start < 0 && depth == 0 {
+				start = i
+			}
+		}
+	}
+	flush(len(query))
+
+	return words
+}
+
+// skipQuoted returns the index of the closing quote of the quoted string starting at i. Backslash escapes and
+// doubled quotes are supported.
+func skipQuoted(query string, i int, quote byte) int {
+	for i++; i < len(query); i++ {
+		switch {
+		case query[i] == '\\' && quote != '`':
+			i++
+		case query[i] == quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(query)
+}
+
+// splitQualifiedName splits an optionally schema-qualified and backticked table name.
+func splitQualifiedName(name string) (schema, table string) {
+	inQuote := false
+	for i := 0; i < len(name); i++ {
+		switch {
+		case name[i] == '`':
+			inQuote = !inQuote
+		case name[i] == '.' && !inQuote:
+			return unquoteIdentifier(name[:i]), unquoteIdentifier(name[i+1:])
+		}
+	}
+	return "", unquoteIdentifier(name)
+}
+
+// unquoteIdentifier removes the backticks of a quoted identifier.
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 && name[0] == '`' && name[len(name)-1] == '`' {
+		return strings.ReplaceAll(name[1:len(name)-1], "``", "`")
+	}
+	return name
+}
@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"bytes"
+	sqldriver "database/sql/driver"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+)
+
+func Test_parseImpactTarget(t *testing.T) {
+	tests := []struct {
+		query string
+		ok    bool
+		want  impactTarget
+	}{
+		{"UPDATE users SET active = 1", true, impactTarget{Table: "users", Ref: "users"}},
+		{
+			"UPDATE LOW_PRIORITY `app`.`user``s` u SET u.name = 'a where b' WHERE u.id IN (SELECT id FROM x) LIMIT 10",
+			true,
+			impactTarget{Schema: "app", Table: "user`s", Ref: "`app`.`user``s` u",
+				Filter: "WHERE u.id IN (SELECT id FROM x) LIMIT 10"},
+		},
+		{"delete from users where id > 5", true, impactTarget{Table: "users", Ref: "users", Filter: "where id > 5"}},
+		{"DELETE FROM users ORDER BY id LIMIT 3", true, impactTarget{Table: "users", Ref: "users", Filter: "ORDER BY id LIMIT 3"}},
+		{"UPDATE users u JOIN roles r ON r.id = u.role SET u.active = 1", true, impactTarget{Table: "users", MultiTable: true}},
+		{"UPDATE a, b SET a.x = b.x", true, impactTarget{Table: "a", MultiTable: true}},
+		{"DELETE FROM a USING a JOIN b", true, impactTarget{Table: "a", MultiTable: true}},
+		{"DELETE a FROM a JOIN b ON a.id = b.id", false, impactTarget{MultiTable: true}},
+		{"INSERT INTO users VALUES (1)", false, impactTarget{}},
+	}
+	for _, tt := range tests {
+		got, ok := parseImpactTarget(tt.query)
+		if ok != tt.ok || got != tt.want {
+			t.Fatalf("unexpected target %+v (%t) for %q, got: %+v (%t)", tt.want, tt.ok, tt.query, got, ok)
+		}
+	}
+}
+
+func Test_impactTarget_countQuery(t *testing.T) {
+	target, _ := parseImpactTarget("DELETE FROM users WHERE id > 5 LIMIT 10")
+	if want := "SELECT COUNT(*) FROM (SELECT 1 FROM users WHERE id > 5 LIMIT 10) AS impact"; target.countQuery() != want {
+		t.Fatalf("unexpected query %q, got: %q", want, target.countQuery())
+	}
+}
+
+func impactHandler(call fakeCall) fakeResponse {
+	switch {
+	case strings.HasPrefix(call.Query, "EXPLAIN"):
+		return fakeResponse{
+			Columns: []string{"id", "table", "rows"},
+			Rows:    [][]sqldriver.Value{{int64(1), "users", int64(120)}},
+		}
+	case strings.HasPrefix(call.Query, "SELECT COUNT(*)"):
+		return fakeResponse{Columns: []string{"count"}, Rows: [][]sqldriver.Value{{int64(42)}}}
+	case strings.HasPrefix(call.Query, "SELECT TABLE_ROWS"):
+		return fakeResponse{Columns: []string{"rows", "bytes"}, Rows: [][]sqldriver.Value{{int64(1000), int64(65536)}}}
+	}
+	return fakeResponse{}
+}
+
+func Test_driver_RunMigrationWithResult_ImpactEstimation(t *testing.T) {
+	tests := []struct {
+		method ImpactEstimation
+		rows   int64
+	}{
+		{ImpactEstimationExplain, 120},
+		{ImpactEstimationCount, 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method.String(), func(t *testing.T) {
+			db, srv := newFakeDB(t, impactHandler)
+			logs := &bytes.Buffer{}
+			d := &driver{client: db, logger: log.New(logs, "", 0),
+				cfg: &config{SplitStatements: true, ImpactEstimation: tt.method}}
+
+			migration := "CREATE TABLE users (id int);\nINSERT INTO users VALUES (1);\nDELETE FROM users WHERE id > 5;"
+			result, err := d.RunMigrationWithResult(strings.NewReader(migration))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := ImpactEstimate{StatementIndex: 3, Line: 3, Method: tt.method, Table: "users", EstimatedRows: tt.rows,
+				TableRows: 1000, TableBytes: 65536}
+			if len(result.Estimates) != 1 || result.Estimates[0] != want {
+				t.Fatalf("unexpected estimates %+v, got: %+v (queries: %q)", want, result.Estimates, srv.Queries())
+			}
+			if !strings.Contains(logs.String(), "statement 3 (line 3) affects an estimated") {
+				t.Fatalf("unexpected logs, got: %s", logs.String())
+			}
+		})
+	}
+}
+
+func Test_driver_RunMigration_ImpactEstimationFailure(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "EXPLAIN") {
+			return fakeResponse{Err: errors.New("access denied")}
+		}
+		return fakeResponse{}
+	})
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0),
+		cfg: &config{SplitStatements: true, ImpactEstimation: ImpactEstimationExplain}}
+
+	result, err := d.RunMigrationWithResult(strings.NewReader("UPDATE users SET active = 1;"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Statements != 1 || len(result.Estimates) != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if !strings.Contains(logs.String(), "failed to estimate impact of statement 1 (line 1)") {
+		t.Fatalf("unexpected logs, got: %s", logs.String())
+	}
+}
@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// maxIncludeDepth limits the nesting of included migration files.
+const maxIncludeDepth = 16
+
+// includeFrame is a single (possibly included) migration file that is currently scanned.
+type includeFrame struct {
+	name    string
+	scanner *statementScanner
+	file    io.Closer
+}
+
+// statementStream yields the statements and directives of a migration. Include directives (and the
+// mysql-CLI source command) are resolved against fsys, the statements of the included file are returned
+// in place of the directive.
+type statementStream struct {
+	fsys   fs.FS
+	frames []*includeFrame
+	err    error
+}
+
+// newStatementStream creates a new stream for the given migration. The stream must be closed after use.
+func newStatementStream(migration io.Reader, fsys fs.FS) *statementStream {
+	return &statementStream{
+		fsys:   fsys,
+		frames: []*includeFrame{{scanner: newStatementScanner(migration)}},
+	}
+}
+
+// Next advances the stream to the next statement or directive, see statementScanner.Next.
+func (s *statementStream) Next() (kind tokenKind, text []byte, ok bool) {
+	for s.err == nil && len(s.frames) > 0 {
+		frame := s.frames[len(s.frames)-1]
+
+		kind, text, ok = frame.scanner.Next()
+		if !ok {
+			if err := frame.scanner.Err(); err != nil {
+				s.err = fmt.Errorf("failed to read %s: %w", frame.displayName(), err)
+				break
+			}
+			if len(s.frames) == 1 {
+				break // keep the outermost frame, so that Line and File stay valid
+			}
+			s.pop()
+			continue
+		}
+
+		if kind == tokenDirective {
+			if name, arg := splitDirective(text); name == directiveInclude {
+				s.err = s.push(arg)
+				continue
+			}
+		}
+
+		return kind, text, true
+	}
+
+	return tokenStatement, nil, false
+}
+
+// Err returns the first error that was encountered by the stream.
+func (s *statementStream) Err() error {
+	return s.err
+}
+
+// File returns the name of the included file that is currently scanned, or an empty string for the migration
+// itself.
+func (s *statementStream) File() string {
+	return s.frames[len(s.frames)-1].name
+}
+
+// Line returns the line number (within File) where the last statement returned by Next started.
+func (s *statementStream) Line() int {
+	return s.frames[len(s.frames)-1].scanner.Line()
+}
+
+// Close releases all open files and buffers of the stream.
+func (s *statementStream) Close() {
+	for len(s.frames) > 0 {
+		s.pop()
+	}
+}
+
+// push opens an included file and continues scanning within it.
+func (s *statementStream) push(name string) error {
+	if s.fsys == nil {
+		return fmt.Errorf("failed to include %s: %w", name, ErrNoIncludeFS)
+	}
+	if len(s.frames) > maxIncludeDepth {
+		return fmt.Errorf("failed to include %s: maximum include depth of %d exceeded", name, maxIncludeDepth)
+	}
+
+	name = path.Clean(strings.Trim(name, "'\""))
+	if !fs.ValidPath(name) {
+		return fmt.Errorf("failed to include %s: invalid path", name)
+	}
+	for _, frame := range s.frames {
+		if frame.name == name {
+			return fmt.Errorf("failed to include %s: include cycle detected", name)
+		}
+	}
+
+	file, err := s.fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to include %s: %w", name, err)
+	}
+
+	s.frames = append(s.frames, &includeFrame{name: name, scanner: newStatementScanner(file), file: file})
+	return nil
+}
+
+// pop closes the innermost file.
+func (s *statementStream) pop() {
+	frame := s.frames[len(s.frames)-1]
+	s.frames = s.frames[:len(s.frames)-1]
+
+	frame.scanner.Close()
+	if frame.file != nil {
+		_ = frame.file.Close()
+	}
+}
+
+func (f *includeFrame) displayName() string {
+	if f.name == "" {
+		return "migration"
+	}
+	return f.name
+}
@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func streamAll(t *testing.T, stream *statementStream) []string {
+	var stmts []string
+	for {
+		kind, text, ok := stream.Next()
+		if !ok {
+			break
+		}
+		if kind == tokenStatement {
+			stmts = append(stmts, string(text))
+		}
+	}
+	return stmts
+}
+
+func Test_statementStream_Include(t *testing.T) {
+	fsys := fstest.MapFS{
+		"parts/a.sql": {Data: []byte("SELECT 'a';\nsource parts/b.sql\n")},
+		"parts/b.sql": {Data: []byte("SELECT 'b';")},
+	}
+
+	stream := newStatementStream(strings.NewReader("SELECT 1;\n-- lightmigrate:include parts/a.sql\nSELECT 2;"), fsys)
+	defer stream.Close()
+
+	got := streamAll(t, stream)
+	if err := stream.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"SELECT 1", "SELECT 'a'", "SELECT 'b'", "SELECT 2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected statements %q, got: %q", want, got)
+	}
+}
+
+func Test_statementStream_IncludeErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"loop.sql": {Data: []byte("source loop.sql\n")},
+	}
+
+	tests := []struct {
+		name      string
+		migration string
+		fsys      fstest.MapFS
+		wantErr   string
+	}{
+		{"no fs", "source a.sql", nil, ErrNoIncludeFS.Error()},
+		{"missing", "source a.sql", fsys, "file does not exist"},
+		{"invalid path", "source ../a.sql", fsys, "invalid path"},
+		{"cycle", "source loop.sql", fsys, "include cycle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stream *statementStream
+			if tt.fsys == nil {
+				stream = newStatementStream(strings.NewReader(tt.migration), nil)
+			} else {
+				stream = newStatementStream(strings.NewReader(tt.migration), tt.fsys)
+			}
+			defer stream.Close()
+
+			streamAll(t, stream)
+			if err := stream.Err(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	stream := newStatementStream(strings.NewReader("source a.sql"), nil)
+	defer stream.Close()
+	streamAll(t, stream)
+	if !errors.Is(stream.Err(), ErrNoIncludeFS) {
+		t.Fatalf("unexpected error %v, got: %v", ErrNoIncludeFS, stream.Err())
+	}
+}
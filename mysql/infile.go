@@ -0,0 +1,144 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+// infileReaderPrefix is the file name prefix of registered readers, see gomysql.RegisterReaderHandler.
+const infileReaderPrefix = "Reader::"
+
+// infileMux serializes all LOAD DATA statements of the process, as the reader handlers of go-sql-driver are
+// registered globally.
+var infileMux sync.Mutex
+
+// WithInfileFS sets the filesystem of the data files that are referenced by infile directives. Defaults to the
+// include filesystem, see WithIncludeFS.
+func WithInfileFS(fsys fs.FS) DriverOption {
+	return func(d *driver) {
+		d.infileFS = fsys
+	}
+}
+
+// WithInfileReader registers a named data source for infile directives, e.g. generated seed data. Named readers
+// take precedence over the files of the infile filesystem. The function is called for each execution of a
+// statement that references the name.
+func WithInfileReader(name string, reader func() io.Reader) DriverOption {
+	return func(d *driver) {
+		if d.infileReaders == nil {
+			d.infileReaders = make(map[string]func() io.Reader)
+		}
+		d.infileReaders[name] = reader
+	}
+}
+
+// infileDirective handles the infile directive, which provides a data file for the LOAD DATA LOCAL INFILE
+// statement that follows the directive. The statement must reference the file as 'Reader::<name>'.
+func (d *driver) infileDirective(arg string, pending string, line int) (string, error) {
+	if pending != "" {
+		return "", &lightmigrate.DriverError{
+			OrigErr: ErrMisplacedDirective,
+			Msg:     fmt.Sprintf("the infile directive for %s is not followed by a LOAD DATA statement", pending),
+			Line:    uint(line),
+		}
+	}
+
+	name := strings.Trim(arg, "'\"")
+	if _, ok := d.infileReaders[name]; ok {
+		return name, nil
+	}
+	if name == "" || !fs.ValidPath(path.Clean(name)) {
+		return "", &lightmigrate.DriverError{
+			OrigErr: ErrInvalidDirective,
+			Msg:     fmt.Sprintf("the infile directive requires a relative data file name, got %q", arg),
+			Line:    uint(line),
+		}
+	}
+	if d.dataFS() == nil {
+		return "", &lightmigrate.DriverError{
+			OrigErr: ErrNoIncludeFS,
+			Msg:     "failed to provide data file " + name,
+			Line:    uint(line),
+		}
+	}
+
+	return name, nil
+}
+
+// checkInfileStatement verifies that the statement loads the data file of the preceding infile directive.
+func checkInfileStatement(stmt statement) error {
+	keywords := make(map[string]bool)
+	for _, field := range strings.Fields(strings.ToUpper(stmt.Query)) {
+		keywords[field] = true
+	}
+	reference := infileReaderPrefix + stmt.Infile
+	if strings.HasPrefix(strings.ToUpper(stmt.Query), "LOAD") && keywords["LOCAL"] && keywords["INFILE"] &&
+		(strings.Contains(stmt.Query, "'"+reference+"'") || strings.Contains(stmt.Query, "\""+reference+"\"")) {
+		return nil
+	}
+
+	return stmt.error(fmt.Sprintf("the infile directive must be followed by LOAD DATA LOCAL INFILE '%s'", reference),
+		ErrMisplacedDirective)
+}
+
+// dataFS returns the filesystem of the data files.
+func (d *driver) dataFS() fs.FS {
+	if d.infileFS != nil {
+		return d.infileFS
+	}
+	return d.includeFS
+}
+
+// openInfile opens the data source of the infile directive.
+func (d *driver) openInfile(name string) (io.Reader, error) {
+	if reader, ok := d.infileReaders[name]; ok {
+		if r := reader(); r != nil {
+			return r, nil
+		}
+		return nil, fmt.Errorf("reader of data file %s is nil", name)
+	}
+
+	file, err := d.dataFS().Open(path.Clean(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+	return file, nil
+}
+
+// execInfile executes a LOAD DATA LOCAL INFILE statement. The data source is registered as reader handler
+// of go-sql-driver for the duration of the statement only.
+func (d *driver) execInfile(ctx context.Context, session *sql.Conn, stmt statement) (sql.Result, error) {
+	reader, err := d.openInfile(stmt.Infile)
+	if err != nil {
+		return nil, err
+	}
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	infileMux.Lock()
+	defer infileMux.Unlock()
+
+	// the handler is called once by go-sql-driver, which closes the reader afterwards
+	used := false
+	gomysql.RegisterReaderHandler(stmt.Infile, func() io.Reader {
+		if used {
+			return bytes.NewReader(nil)
+		}
+		used = true
+		return reader
+	})
+	defer gomysql.DeregisterReaderHandler(stmt.Infile)
+
+	return session.ExecContext(ctx, stmt.Query)
+}
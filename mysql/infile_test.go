@@ -0,0 +1,71 @@
+package mysql
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_driver_openInfile(t *testing.T) {
+	d := &driver{cfg: &config{}, includeFS: fstest.MapFS{"seed/users.csv": {Data: []byte("1,alice\n")}}}
+	WithInfileReader("generated", func() io.Reader { return strings.NewReader("2,bob\n") })(d)
+
+	for name, want := range map[string]string{"seed/users.csv": "1,alice\n", "generated": "2,bob\n"} {
+		r, err := d.openInfile(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if data, _ := io.ReadAll(r); string(data) != want {
+			t.Fatalf("unexpected data %q, got: %q", want, data)
+		}
+	}
+
+	if _, err := d.openInfile("missing.csv"); err == nil {
+		t.Fatalf("expected error, got: %v", err)
+	}
+}
+
+func Test_driver_RunMigration_Infile(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true},
+		infileFS: fstest.MapFS{"users.csv": {Data: []byte("1,alice\n")}}}
+
+	migration := "-- lightmigrate:infile users.csv\nLOAD DATA LOCAL INFILE 'Reader::users.csv' INTO TABLE users;"
+	if err := d.RunMigration(strings.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 1 || !strings.HasPrefix(queries[0], "LOAD DATA LOCAL INFILE") {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_RunMigration_InfileMisplaced(t *testing.T) {
+	tests := []struct {
+		name      string
+		migration string
+		wantErr   error
+	}{
+		{"no load statement", "-- lightmigrate:infile users.csv\nINSERT INTO users VALUES (1);", ErrMisplacedDirective},
+		{"other file", "-- lightmigrate:infile users.csv\nLOAD DATA LOCAL INFILE 'Reader::other.csv' INTO TABLE users;", ErrMisplacedDirective},
+		{"end of migration", "SELECT 1;\n-- lightmigrate:infile users.csv\n", ErrMisplacedDirective},
+		{"invalid path", "-- lightmigrate:infile ../users.csv\nLOAD DATA LOCAL INFILE 'Reader::../users.csv' INTO TABLE users;", ErrInvalidDirective},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, srv := newFakeDB(t, nil)
+			d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true},
+				infileFS: fstest.MapFS{"users.csv": {Data: []byte("1,alice\n")}}}
+
+			if err := d.RunMigration(strings.NewReader(tt.migration)); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+			for _, query := range srv.Queries() {
+				if strings.HasPrefix(query, "LOAD") || strings.HasPrefix(query, "INSERT INTO users") {
+					t.Fatalf("unexpected query: %s", query)
+				}
+			}
+		})
+	}
+}
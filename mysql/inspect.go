@@ -0,0 +1,157 @@
+package mysql
+
+import (
+	"sort"
+)
+
+// serverFeatureNames are the names of the server features, as reported by EffectiveConfig.
+var serverFeatureNames = map[serverFeature]string{
+	featureAtomicDDL:        "atomic_ddl",
+	featureInstantAddColumn: "instant_add_column",
+	featureLargeIndexPrefix: "large_index_prefix",
+	featureMultipleLocks:    "multiple_locks",
+}
+
+// EffectiveConfig describes the configuration of a driver after all options were applied and the server was
+// inspected, see Driver.Config. It can be used to log the migration configuration at startup.
+type EffectiveConfig struct {
+	// Config contains the declarative settings. Defaults are filled in, the lock strategy is resolved.
+	Config `yaml:",inline"`
+
+	// MigrationsTableName is the name of the migration table, including the table prefix and the namespace.
+	MigrationsTableName string `json:"migrations_table_name" yaml:"migrations_table_name"`
+	// HistoryTableName is only set if the history is enabled.
+	HistoryTableName string `json:"history_table_name,omitempty" yaml:"history_table_name,omitempty"`
+	// LockTableName is only set if the table lock strategy is used.
+	LockTableName string `json:"lock_table_name,omitempty" yaml:"lock_table_name,omitempty"`
+
+	// ServerVersion is empty if the version could not be detected.
+	ServerVersion string `json:"server_version,omitempty" yaml:"server_version,omitempty"`
+	MariaDB       bool   `json:"mariadb" yaml:"mariadb"`
+	Cluster       bool   `json:"cluster" yaml:"cluster"`
+	ReadOnly      bool   `json:"read_only" yaml:"read_only"`
+	// ServerFeatures lists the features that are supported by the server, e.g. "atomic_ddl".
+	ServerFeatures []string `json:"server_features,omitempty" yaml:"server_features,omitempty"`
+	// DisabledFeatures lists the optional features that were disabled due to missing privileges.
+	DisabledFeatures []string `json:"disabled_features,omitempty" yaml:"disabled_features,omitempty"`
+
+	// CustomVersionStore is true if the version is persisted by a custom VersionStore.
+	CustomVersionStore bool `json:"custom_version_store" yaml:"custom_version_store"`
+}
+
+// Config returns the effective configuration of the driver. The returned value is a copy, changing it does not
+// affect the driver.
+func (d *driver) Config() EffectiveConfig {
+	cfg := d.cfg
+
+	locking := cfg.Locking
+	lockTimeout := Duration(cfg.LockTimeout)
+	splitting := cfg.SplitStatements
+	charset := cfg.SessionCharset
+	rollbackFloor := cfg.RollbackFloor
+	strategy := cfg.LockStrategy
+	if strategy == LockStrategyAuto {
+		strategy = LockStrategyAdvisory // auto only selects the table strategy on clusters
+	}
+
+	effective := EffectiveConfig{
+		Config: Config{
+			Database:                 cfg.DatabaseName,
+			MigrationsTable:          cfg.MigrationsTable,
+			TablePrefix:              cfg.TablePrefix,
+			Namespace:                cfg.Namespace,
+			Locking:                  &locking,
+			LockStrategy:             strategy,
+			LockPolicy:               cfg.LockPolicy,
+			LockTargetVersion:        cfg.LockTargetVersion,
+			LockTimeout:              &lockTimeout,
+			LockTTL:                  Duration(cfg.LockTTL),
+			LockTable:                cfg.LockTable,
+			LockTableEngine:          cfg.LockTableEngine,
+			ShardLockKey:             cfg.ShardLockKey,
+			PrimaryGuard:             cfg.PrimaryGuard,
+			TableScopedLocking:       cfg.TableScopedLocking,
+			ConnectionWarmup:         cfg.ConnectionWarmup,
+			AllowReplica:             cfg.AllowReplica,
+			StatementSplitting:       &splitting,
+			SessionCharset:           &charset,
+			MaxParallelStatements:    cfg.MaxParallelStatements,
+			DirtyRetryAttempts:       cfg.DirtyRetry.MaxAttempts,
+			ReconnectAttempts:        cfg.Reconnect.MaxAttempts,
+			ExpectedVersion:          cfg.ExpectedVersion,
+			QueryTimeout:             Duration(cfg.QueryTimeout),
+			StatementTimeout:         Duration(cfg.StatementTimeout),
+			RunDeadline:              Duration(cfg.RunDeadline),
+			StatementWatchdog:        Duration(cfg.StatementWatchdog),
+			ProgressInterval:         Duration(cfg.ProgressInterval),
+			MaintenanceWindows:       append([]MaintenanceWindow(nil), cfg.MaintenanceWindows...),
+			MaintenanceWait:          Duration(cfg.MaintenanceWait),
+			MaintenanceOverride:      cfg.MaintenanceOverride,
+			ReconnectBackoff:         Duration(cfg.Reconnect.Backoff),
+			InnoDBLockWaitTimeout:    Duration(cfg.InnoDBLockWaitTimeout),
+			MetadataLockWaitTimeout:  Duration(cfg.MetadataLockWaitTimeout),
+			Role:                     cfg.Role,
+			MaxAffectedRows:          cfg.MaxAffectedRows,
+			StrictMode:               cfg.Strict,
+			WarningsCapture:          cfg.CaptureWarnings,
+			AtomicDDLRecovery:        cfg.AtomicDDLRecovery,
+			VersionUpsert:            cfg.VersionUpsert,
+			VersionTablePolicy:       cfg.VersionTablePolicy,
+			EventSchedulerCheck:      cfg.EventSchedulerCheck,
+			VerboseLogging:           d.verbose,
+			Explain:                  cfg.Explain,
+			ImpactEstimation:         cfg.ImpactEstimation,
+			PreRunSQL:                append([]string(nil), cfg.PreRunSQL...),
+			PostRunSQL:               append([]string(nil), cfg.PostRunSQL...),
+			History:                  cfg.History,
+			AppliedBy:                cfg.AppliedBy,
+			Quarantine:               cfg.Quarantine,
+			ObjectTracking:           cfg.ObjectTracking,
+			NotificationTable:        cfg.NotificationTable,
+			WebhookURL:               cfg.WebhookURL,
+			DirtyWebhookURL:          cfg.DirtyWebhookURL,
+			NotificationAppID:        cfg.NotificationAppID,
+			ReportFile:               cfg.ReportFile,
+			ChecksumAlgorithm:        cfg.ChecksumAlgorithm,
+			ChecksumNormalization:    cfg.ChecksumNormalization,
+			MaxInMemoryMigrationSize: cfg.MaxInMemoryMigrationSize,
+			RollbackFloor:            &rollbackFloor,
+			AllowFullRollback:        cfg.FullRollbackToken,
+		},
+		MigrationsTableName: d.migrationsTable(),
+		ServerVersion:       d.server.Version,
+		MariaDB:             d.server.MariaDB,
+		Cluster:             d.server.Cluster,
+		ReadOnly:            d.server.ReadOnly,
+		CustomVersionStore:  d.store != nil,
+	}
+
+	if cfg.History {
+		effective.HistoryTableName = d.historyTable()
+	}
+	if cfg.TableCheck != nil {
+		expect := *cfg.TableCheck
+		effective.TableCheck = &expect
+	}
+	if d.usesLockTable() {
+		effective.LockTableName = d.lockTable()
+	}
+
+	for feature, name := range serverFeatureNames {
+		if d.server.supports(feature) {
+			effective.ServerFeatures = append(effective.ServerFeatures, name)
+		}
+	}
+	sort.Strings(effective.ServerFeatures)
+
+	if d.features != nil {
+		d.features.mux.Lock()
+		for feature := range d.features.disabled {
+			effective.DisabledFeatures = append(effective.DisabledFeatures, feature)
+		}
+		d.features.mux.Unlock()
+		sort.Strings(effective.DisabledFeatures)
+	}
+
+	return effective
+}
@@ -0,0 +1,38 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_driver_Config(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+
+	d, err := NewDriver(db, "testdb", WithTablePrefix("app_"), WithNamespace("billing"), WithHistory(true),
+		WithStatementTimeout(time.Minute), WithPreRunSQL([]string{"SET @a = 1"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg := d.Config()
+	if cfg.Database != "testdb" || cfg.MigrationsTableName != "app_schema_migrations_billing" ||
+		cfg.HistoryTableName == "" || cfg.LockTableName != "" || cfg.LockStrategy != LockStrategyAdvisory ||
+		*cfg.LockTimeout != Duration(DefaultLockTimeout) || time.Duration(cfg.StatementTimeout) != time.Minute {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	cfg.PreRunSQL[0] = "SET @a = 2"
+	if d.Config().PreRunSQL[0] != "SET @a = 1" {
+		t.Fatalf("config must be a copy")
+	}
+
+	// the effective configuration can be used to create an equally configured driver
+	clone, err := NewDriverWithConfig(db, d.Config().Config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(clone.Config(), d.Config()) {
+		t.Fatalf("unexpected config %+v, got: %+v", d.Config(), clone.Config())
+	}
+}
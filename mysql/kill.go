@@ -0,0 +1,107 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// sideQueryTimeout bounds the queries next to a running statement, if no query timeout is configured, see
+// driver.sideContext.
+const sideQueryTimeout = 10 * time.Second
+
+// interruption is the reason why a running statement was stopped with KILL QUERY.
+type interruption int
+
+const (
+	notInterrupted interruption = iota
+	// interruptedByContext is set if the statement context was cancelled or timed out, see WithStatementTimeout.
+	interruptedByContext
+	// interruptedByBudget is set if the budget of the migration was used up, see the max-duration directive.
+	interruptedByBudget
+)
+
+// interruptStatement stops the statement of the session on the server, once the context is done or the budget of
+// the migration is used up. A cancelled context only closes the client side of the connection, the server would
+// continue the work, e.g. of an ALTER TABLE. The returned function stops the watch and reports the interruption.
+//
+// The connection id of the session is only read if the statement can be interrupted: cancellable is set if the
+// context can be cancelled.
+func (d *driver) interruptStatement(ctx context.Context, cancellable bool, session *sql.Conn,
+	state *migrationState) (stop func() interruption) {
+	if !cancellable && state.BudgetDeadline.IsZero() {
+		return func() interruption { return notInterrupted }
+	}
+
+	id, err := d.sessionID(session, state)
+	if err != nil {
+		d.logf("failed to read the connection id, statements of migration %d cannot be interrupted: %v",
+			d.runningVersion, err)
+		return func() interruption { return notInterrupted }
+	}
+
+	done := make(chan struct{})
+	result := make(chan interruption, 1)
+	go func() {
+		var budget <-chan time.Time // never fires without a budget
+		if !state.BudgetDeadline.IsZero() {
+			timer := time.NewTimer(time.Until(state.BudgetDeadline))
+			defer timer.Stop()
+			budget = timer.C
+		}
+
+		reason := notInterrupted
+		select {
+		case <-done:
+		case <-ctx.Done():
+			reason = interruptedByContext
+			d.logf("statement of migration %d was cancelled (%v), stopping it on the server", d.runningVersion,
+				ctx.Err())
+		case <-budget:
+			reason = interruptedByBudget
+			d.logf("migration %d exceeded its budget of %v, interrupting the running statement", d.runningVersion,
+				state.Budget)
+		}
+		if reason != notInterrupted {
+			if err := d.killQuery(id); err != nil {
+				d.logf("failed to interrupt the statement of migration %d: %v", d.runningVersion, err)
+			}
+		}
+		result <- reason
+	}()
+
+	return func() interruption {
+		close(done)
+		return <-result
+	}
+}
+
+// sessionID returns the server thread id of the session. The id of the migration session is cached in the state.
+func (d *driver) sessionID(session *sql.Conn, state *migrationState) (int64, error) {
+	if session == state.Session && state.SessionID != 0 {
+		return state.SessionID, nil
+	}
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	var id int64
+	if err := session.QueryRowContext(ctx, "SELECT CONNECTION_ID()").Scan(&id); err != nil {
+		return 0, err
+	}
+	if session == state.Session {
+		state.SessionID = id
+	}
+	return id, nil
+}
+
+// killQuery stops the statement that runs on the server thread. A separate connection of the pool is used, as the
+// connection of the statement is busy. The kill is not bound to the context of the statement, which may be done.
+func (d *driver) killQuery(id int64) error {
+	ctx, cancel := d.sideContext()
+	defer cancel()
+
+	_, err := d.client.ExecContext(ctx, "KILL QUERY "+strconv.FormatInt(id, 10))
+	return err
+}
@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_driver_RunMigration_StatementTimeoutKill(t *testing.T) {
+	errInterrupted := errors.New("query execution was interrupted")
+	killed := make(chan struct{})
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case call.Query == "SELECT CONNECTION_ID()":
+			return fakeResponse{Columns: []string{"id"}, Rows: [][]sqldriver.Value{{int64(call.ConnID)}}}
+		case strings.HasPrefix(call.Query, "KILL QUERY"):
+			close(killed)
+		case strings.HasPrefix(call.Query, "ALTER TABLE"):
+			<-killed // the fake server ignores the context, like a server-side ALTER
+			return fakeResponse{Err: errInterrupted}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true, StatementTimeout: 50 * time.Millisecond}}
+
+	err := d.RunMigration(strings.NewReader("ALTER TABLE a ADD b int;"))
+	if !errors.Is(err, errInterrupted) || !strings.Contains(err.Error(), "stopped on the server with KILL QUERY") {
+		t.Fatalf("expected error %v, got: %v", errInterrupted, err)
+	}
+
+	var session, kill fakeCall
+	for _, call := range srv.Calls() {
+		switch {
+		case strings.HasPrefix(call.Query, "ALTER TABLE"):
+			session = call
+		case strings.HasPrefix(call.Query, "KILL QUERY"):
+			kill = call
+		}
+	}
+	if kill.Query != fmt.Sprintf("KILL QUERY %d", session.ConnID) || kill.ConnID == session.ConnID {
+		t.Fatalf("statement must be interrupted from a separate connection: %+v, %+v", session, kill)
+	}
+}
+
+func Test_driver_RunMigration_NoKillWithoutTimeout(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+
+	if err := d.RunMigration(strings.NewReader("ALTER TABLE a ADD b int;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 1 {
+		t.Fatalf("connection id must only be read for interruptible statements, got: %q", queries)
+	}
+}
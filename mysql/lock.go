@@ -0,0 +1,331 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync/atomic"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+const advisoryLockIDSalt uint = 1486364155
+
+// LockStrategy selects how concurrent migration processes are coordinated.
+type LockStrategy int
+
+const (
+	// LockStrategyAuto uses LockStrategyTable on Galera clusters (e.g. Percona XtraDB Cluster),
+	// LockStrategyAdvisory otherwise.
+	LockStrategyAuto LockStrategy = iota
+	// LockStrategyAdvisory uses the advisory locks of the server (GET_LOCK). Advisory locks are not replicated,
+	// so they only coordinate processes that are connected to the same server.
+	LockStrategyAdvisory
+	// LockStrategyTable uses a row of a lock table that is kept alive by a heartbeat. Expired locks of crashed
+	// processes are taken over after the lock TTL. A run whose lock row was taken over is aborted with ErrLockNotHeld.
+	LockStrategyTable
+)
+
+// LockWaitObserver is called after each attempt to acquire the migration lock with the time that was spent
+// waiting for the lock. err is nil if the lock was acquired.
+type LockWaitObserver func(wait time.Duration, err error)
+
+func (d *driver) Lock() error {
+	if atomic.LoadInt32(&d.reentrantLockFlag) == 0 {
+		d.startRun() // a new migration run starts with the acquisition of the lock
+		if err := d.warmUp(); err != nil {
+			return err
+		}
+		d.runReport = true
+	}
+	if !d.cfg.Locking {
+		return nil
+	}
+
+	// check if already locked, if not, lock
+	if !atomic.CompareAndSwapInt32(&d.reentrantLockFlag, 0, 1) {
+		return nil // no swap happened, already locked
+	}
+
+	start := time.Now()
+	var err error
+	skipped := false
+	switch d.cfg.LockPolicy {
+	case LockPolicyFail:
+		err = d.acquire(0)
+	case LockPolicySkip:
+		skipped, err = d.acquireOrSkip()
+	default:
+		err = d.acquire(d.cfg.LockTimeout)
+	}
+	d.observeLockWait(time.Since(start), err)
+	if err != nil {
+		atomic.StoreInt32(&d.reentrantLockFlag, 0) // restore unlock flag
+		d.runReport = false
+		return err
+	}
+	if skipped {
+		atomic.StoreInt32(&d.reentrantLockFlag, lockSkipped)
+		return nil
+	}
+	d.faults.afterLock(d)
+
+	return nil
+}
+
+// acquire acquires the migration lock using the configured lock strategy, waiting at most for the given timeout.
+func (d *driver) acquire(timeout time.Duration) error {
+	if d.cfg.LockStrategy == LockStrategyTable {
+		return d.acquireTableLock(timeout)
+	}
+	return d.acquireLock(timeout)
+}
+
+// acquireLock tries to acquire the advisory lock of the database. Advisory locks belong to the server session, so
+// the lock is acquired on a dedicated connection that is kept until the lock is released, see releaseLock.
+func (d *driver) acquireLock(timeout time.Duration) error {
+	lockKey := d.getLockingKey()
+	query := "SELECT GET_LOCK(?, ?)"
+	var success bool
+	ctx, cancel := d.lockContext(timeout)
+	defer cancel()
+
+	conn, err := d.lockConnection(ctx)
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "try lock failed", Query: []byte(query)}
+	}
+	var q rowQueryer = d.client
+	if conn != nil {
+		q = conn
+	}
+	if err := q.QueryRowContext(ctx, query, lockKey, lockTimeoutSeconds(timeout)).Scan(&success); err != nil {
+		closeLockConnection(conn)
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "try lock failed", Query: []byte(query)}
+	}
+
+	if !success {
+		closeLockConnection(conn)
+		return ErrDatabaseLocked
+	}
+
+	d.lockConn = conn
+	return nil
+}
+
+// lockConnection reserves a connection of the pool for the advisory lock. If the pool is limited to a single
+// connection, nil is returned: the lock is held by the only connection, reserving it would block all other queries.
+// Pools of two connections are rejected, the lock and the migration session would leave no connection for the side
+// queries of the migration (e.g. KILL QUERY).
+func (d *driver) lockConnection(ctx context.Context) (*sql.Conn, error) {
+	switch d.client.Stats().MaxOpenConnections {
+	case 1:
+		return nil, nil
+	case 2:
+		return nil, fmt.Errorf("%w: the advisory lock requires a connection pool of one or at least three connections",
+			ErrInvalidConfig)
+	}
+	return d.client.Conn(ctx)
+}
+
+// closeLockConnection returns the connection of the advisory lock to the pool, if any.
+func closeLockConnection(conn *sql.Conn) {
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+// lockContext returns the context for the lock query. The bookkeeping timeout is extended by the lock timeout,
+// as the server waits for the lock before it answers.
+func (d *driver) lockContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if d.cfg.QueryTimeout <= 0 || timeout < 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), d.cfg.QueryTimeout+timeout)
+}
+
+// lockTimeoutSeconds converts the lock timeout to the GET_LOCK timeout argument, negative values wait forever.
+func lockTimeoutSeconds(timeout time.Duration) int64 {
+	if timeout < 0 {
+		return -1
+	}
+	return timeoutSeconds(timeout)
+}
+
+// observeLockWait reports the time that was spent waiting for the lock to the logger and the lock wait observer.
+func (d *driver) observeLockWait(wait time.Duration, err error) {
+	atomic.StoreInt64(&d.lastLockWait, int64(wait))
+	d.stats.recordLockWait(wait, err)
+
+	if d.verbose {
+		if err != nil {
+			d.logf("failed to acquire migration lock after %s: %v", wait, err)
+		} else {
+			d.logf("acquired migration lock after %s", wait)
+		}
+	}
+
+	if d.lockWaitObserver != nil {
+		d.lockWaitObserver(wait, err)
+	}
+}
+
+// lockOperation acquires the migration lock for a driver operation outside of a migration run (e.g. MarkApplied),
+// unless it is already held by this driver. The returned function releases a lock that was acquired by
+// lockOperation. No run report is written for the operation.
+func (d *driver) lockOperation() (unlock func() error, err error) {
+	if atomic.LoadInt32(&d.reentrantLockFlag) != 0 {
+		return func() error { return nil }, nil
+	}
+	if err := d.Lock(); err != nil {
+		return nil, err
+	}
+	d.runReport = false
+	return d.Unlock, nil
+}
+
+func (d *driver) Unlock() error {
+	report := d.runReport
+	d.runReport = false
+	applied := d.runActive
+	dirty, failure := d.runActive && d.runDirty, d.runFailure
+	succeeded := d.runActive && !d.runDirty && failure == nil
+	d.runFailure = nil
+	hookErr := d.finishRunHooks()
+	if hookErr != nil {
+		// lightmigrate ignores the error of Unlock, the failed post-run statements would go unnoticed
+		d.logf("failed to finish the migration run: %v", hookErr)
+	}
+	if err := d.unlock(); err != nil {
+		return err
+	}
+	// after the lock was released, so that slow receivers do not delay other migrations
+	if report {
+		d.writeReport(applied, dirty, hookErr, failure)
+	}
+	if succeeded && hookErr == nil {
+		d.notify()
+	}
+	if dirty {
+		d.alertDirty(d.runVersion, failure)
+	}
+	return hookErr
+}
+
+// unlock releases the migration lock.
+func (d *driver) unlock() error {
+	if !d.cfg.Locking {
+		return nil
+	}
+
+	if atomic.CompareAndSwapInt32(&d.reentrantLockFlag, lockSkipped, 0) {
+		return nil // the lock was never acquired
+	}
+
+	// check if already unlocked, if not, unlock
+	if !atomic.CompareAndSwapInt32(&d.reentrantLockFlag, 1, 0) {
+		return nil // no swap happened, already unlocked
+	}
+
+	var err error
+	if d.cfg.LockStrategy == LockStrategyTable {
+		err = d.releaseTableLock()
+	} else {
+		err = d.releaseLock()
+	}
+	if err != nil && !errors.Is(err, ErrLockNotHeld) {
+		atomic.StoreInt32(&d.reentrantLockFlag, 1) // restore lock flag, the release can be retried
+	}
+	return err
+}
+
+// releaseLock releases the advisory lock on the connection that acquired it. RELEASE_LOCK returns 0 if the lock is
+// held by another session and NULL if it does not exist: the lock was lost, ErrLockNotHeld is reported.
+// If the release fails otherwise, the connection is kept, so that the release can be retried.
+func (d *driver) releaseLock() error {
+	lockKey := d.getLockingKey()
+	query := "SELECT RELEASE_LOCK(?)"
+	var q rowQueryer = d.client
+	if d.lockConn != nil {
+		q = d.lockConn
+	}
+
+	var released sql.NullInt64
+	ctx, cancel := d.internalContext()
+	err := q.QueryRowContext(ctx, query, lockKey).Scan(&released)
+	cancel()
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "release lock failed", Query: []byte(query)}
+	}
+
+	closeLockConnection(d.lockConn)
+	d.lockConn = nil
+
+	switch {
+	case !released.Valid:
+		return &lightmigrate.DriverError{OrigErr: ErrLockNotHeld, Msg: "release lock failed, the lock does not exist",
+			Query: []byte(query)}
+	case released.Int64 != 1:
+		return &lightmigrate.DriverError{OrigErr: ErrLockNotHeld,
+			Msg: "release lock failed, the lock is held by another session", Query: []byte(query)}
+	}
+	return nil
+}
+
+// relock acquires the migration lock again within a running migration, e.g. after the connection was lost.
+// The run continues, so the start of the run deadline is kept.
+func (d *driver) relock() error {
+	if d.lockConn != nil {
+		// the advisory lock is kept by the server as long as the session of the lock is alive
+		ctx, cancel := d.internalContext()
+		err := d.lockConn.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		closeLockConnection(d.lockConn)
+		d.lockConn = nil
+	}
+
+	d.stopHeartbeat()
+	atomic.StoreInt32(&d.reentrantLockFlag, 0)
+
+	started := atomic.LoadInt64(&d.runStarted)
+	err := d.Lock()
+	atomic.StoreInt64(&d.runStarted, started)
+	return err
+}
+
+// suspendLock releases the migration lock until the returned resume function is called.
+// If the lock is not held by this driver, nothing is released and resume does nothing.
+func (d *driver) suspendLock(reason string) (resume func() error, err error) {
+	if !d.cfg.Locking || atomic.LoadInt32(&d.reentrantLockFlag) == 0 {
+		return func() error { return nil }, nil
+	}
+
+	if err := d.unlock(); err != nil {
+		return nil, err
+	}
+	d.logf("migration lock released by %s", reason)
+
+	return d.relock, nil
+}
+
+// Generate a unique locking key for the given database.
+// The key will be derived from the database name, the table prefix and the namespace, see also shardLockKey.
+func (d *driver) getLockingKey() string {
+	if d.cfg.ShardLockKey {
+		return d.shardLockKey()
+	}
+
+	name := d.cfg.DatabaseName
+	if d.cfg.TablePrefix != "" || d.cfg.Namespace != "" {
+		name += "/" + d.cfg.TablePrefix + "/" + d.cfg.Namespace
+	}
+	sum := crc32.ChecksumIEEE([]byte(name))
+	sum = sum * uint32(advisoryLockIDSalt)
+
+	return fmt.Sprint(sum)
+}
@@ -0,0 +1,124 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// lockSkipped is the value of the reentrant lock flag, if the lock was skipped by LockPolicySkip.
+const lockSkipped int32 = 2
+
+// lockSkipPollInterval is the interval in which the version is checked while waiting for the lock.
+const lockSkipPollInterval = time.Second
+
+// LockPolicy selects the behavior if the migration lock is held by another migration process.
+type LockPolicy int
+
+const (
+	// LockPolicyWait waits up to the lock timeout for the lock, see WithLockTimeout.
+	LockPolicyWait LockPolicy = iota
+	// LockPolicyFail fails immediately with ErrDatabaseLocked.
+	LockPolicyFail
+	// LockPolicySkip waits up to the lock timeout for the lock, but stops waiting as soon as the other migration
+	// process reached the target version. The migrator then finds nothing to do and the lock is not acquired.
+	// This avoids pointless waiting of many replicas that race at startup.
+	LockPolicySkip
+)
+
+// String returns the name of the lock policy.
+func (p LockPolicy) String() string {
+	switch p {
+	case LockPolicyFail:
+		return "fail"
+	case LockPolicySkip:
+		return "skip"
+	default:
+		return "wait"
+	}
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (p LockPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (p *LockPolicy) UnmarshalText(text []byte) error {
+	for _, policy := range []LockPolicy{LockPolicyWait, LockPolicyFail, LockPolicySkip} {
+		if strings.EqualFold(string(text), policy.String()) {
+			*p = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown lock policy %q", text)
+}
+
+// WithLockPolicy sets the behavior if the migration lock is held by another migration process.
+// The target version is the version the migrator is going to migrate to, it is required by LockPolicySkip.
+func WithLockPolicy(policy LockPolicy, targetVersion uint64) DriverOption {
+	return func(d *driver) {
+		d.cfg.LockPolicy = policy
+		d.cfg.LockTargetVersion = targetVersion
+	}
+}
+
+// acquireOrSkip waits for the migration lock and checks the version in between. If the target version was
+// reached by another migration process, the lock is skipped.
+func (d *driver) acquireOrSkip() (skipped bool, err error) {
+	deadline := time.Now().Add(d.cfg.LockTimeout)
+	for {
+		wait := lockSkipPollInterval
+		if remaining := time.Until(deadline); d.cfg.LockTimeout >= 0 && remaining < wait {
+			wait = remaining
+			if wait < 0 {
+				wait = 0
+			}
+		}
+
+		err := d.acquire(wait)
+		if !errors.Is(err, ErrDatabaseLocked) {
+			return false, err
+		}
+
+		reached, err := d.targetVersionReached()
+		if err != nil {
+			return false, err
+		}
+		if reached {
+			d.logf("migration lock skipped, another migration process reached version %d", d.cfg.LockTargetVersion)
+			return true, nil
+		}
+
+		if d.cfg.LockTimeout >= 0 && !time.Now().Before(deadline) {
+			return false, ErrDatabaseLocked
+		}
+	}
+}
+
+// targetVersionReached checks if the database is clean at or above the target version.
+func (d *driver) targetVersionReached() (bool, error) {
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	row, err := d.currentVersion(ctx)
+	if err != nil {
+		return false, err
+	}
+	return row != nil && !row.Dirty && row.Version >= d.cfg.LockTargetVersion, nil
+}
+
+// checkLockSkipped rejects changes of the database, if the migration lock was skipped.
+func (d *driver) checkLockSkipped() error {
+	if atomic.LoadInt32(&d.reentrantLockFlag) != lockSkipped {
+		return nil
+	}
+	return &lightmigrate.DriverError{
+		OrigErr: ErrDatabaseLocked,
+		Msg:     "the migration lock was skipped, as another migration process reached the target version",
+	}
+}
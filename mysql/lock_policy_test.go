@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// heldLockHandler reports the advisory lock as held by another process and the given version row.
+func heldLockHandler(row []sqldriver.Value) fakeHandler {
+	return func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT GET_LOCK") {
+			return fakeResponse{Columns: []string{"result"}, Rows: [][]sqldriver.Value{{int64(0)}}}
+		}
+		return versionHandler(row)(call)
+	}
+}
+
+func Test_driver_Lock_PolicyFail(t *testing.T) {
+	db, srv := newFakeDB(t, heldLockHandler(nil))
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", Locking: true, LockPolicy: LockPolicyFail,
+		LockTimeout: DefaultLockTimeout}}
+
+	if err := d.Lock(); !errors.Is(err, ErrDatabaseLocked) {
+		t.Fatalf("unexpected error %v, got: %v", ErrDatabaseLocked, err)
+	}
+	calls := srv.Calls()
+	if len(calls) != 1 || calls[0].Args[1] != int64(0) {
+		t.Fatalf("expected a single lock attempt without waiting, got: %+v", calls)
+	}
+}
+
+func Test_driver_Lock_PolicySkip(t *testing.T) {
+	db, srv := newFakeDB(t, heldLockHandler([]sqldriver.Value{int64(5), int64(0), nil, nil, nil}))
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", Locking: true, LockPolicy: LockPolicySkip,
+		LockTargetVersion: 5, LockTimeout: DefaultLockTimeout}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version, dirty, err := d.GetVersion(); err != nil || version != 5 || dirty {
+		t.Fatalf("unexpected version 5, got: %d %t %v", version, dirty, err)
+	}
+	if err := d.SetVersion(6, true); !errors.Is(err, ErrDatabaseLocked) {
+		t.Fatalf("unexpected error %v, got: %v", ErrDatabaseLocked, err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "SELECT RELEASE_LOCK") {
+			t.Fatalf("unexpected release of a skipped lock: %q", srv.Queries())
+		}
+	}
+}
+
+func Test_driver_Lock_PolicySkip_NotReached(t *testing.T) {
+	db, _ := newFakeDB(t, heldLockHandler([]sqldriver.Value{int64(4), int64(0), nil, nil, nil}))
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", Locking: true, LockPolicy: LockPolicySkip,
+		LockTargetVersion: 5}}
+
+	if err := d.Lock(); !errors.Is(err, ErrDatabaseLocked) {
+		t.Fatalf("unexpected error %v, got: %v", ErrDatabaseLocked, err)
+	}
+}
+
+func TestLockPolicy_UnmarshalText(t *testing.T) {
+	var policy LockPolicy
+	if err := policy.UnmarshalText([]byte("Skip")); err != nil || policy != LockPolicySkip {
+		t.Fatalf("unexpected policy skip, got: %v %v", policy, err)
+	}
+	if err := policy.UnmarshalText([]byte("sometimes")); err == nil {
+		t.Fatalf("expected error for unknown policy")
+	}
+}
@@ -0,0 +1,288 @@
+package mysql
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultLockTable is the table that is used by LockStrategyTable by default.
+const DefaultLockTable = "schema_migrations_lock"
+
+// DefaultLockTTL is the time after which a lock of LockStrategyTable expires, if its heartbeat stopped.
+const DefaultLockTTL = 30 * time.Second
+
+// lockPollInterval is the delay between two attempts to acquire a table lock.
+const lockPollInterval = 500 * time.Millisecond
+
+// lockTableColumns are the columns of the lock table.
+func (d *driver) lockTableColumns() []columnDefinition {
+	return []columnDefinition{
+		{Name: "lock_key", Definition: fmt.Sprintf("varchar(%d) not null primary key", d.server.indexedVarcharLength())},
+		{Name: "owner", Definition: "varchar(255) not null"},
+		{Name: "expires_at", Definition: "datetime not null"},
+		{Name: "server_uuid", Definition: "varchar(255) null"},
+	}
+}
+
+// WithLockTable sets the name of the lock table of LockStrategyTable, defaults to DefaultLockTable. The table
+// prefix is applied like for the migrations table.
+func WithLockTable(table string) DriverOption {
+	return func(d *driver) {
+		d.cfg.LockTable = table
+	}
+}
+
+// WithLockTableEngine sets the storage engine of the lock table, e.g. "NDBCLUSTER". By default, the lock table is
+// created like the other internal tables (InnoDB within Galera clusters, the server default otherwise).
+func WithLockTableEngine(engine string) DriverOption {
+	return func(d *driver) {
+		d.cfg.LockTableEngine = engine
+	}
+}
+
+// lockTable returns the name of the lock table, including the configured table prefix.
+func (d *driver) lockTable() string {
+	if d.cfg.LockTable == "" {
+		return d.tableName(DefaultLockTable)
+	}
+	return d.tableName(d.cfg.LockTable)
+}
+
+// lockTableOptions returns the table options of the lock table.
+func (d *driver) lockTableOptions() string {
+	if d.cfg.LockTableEngine == "" {
+		return d.tableOptions()
+	}
+	return " ENGINE=" + d.cfg.LockTableEngine
+}
+
+// usesLockTable checks if the table lock strategy is used.
+func (d *driver) usesLockTable() bool {
+	return d.cfg.Locking && d.cfg.LockStrategy == LockStrategyTable
+}
+
+// prepareLockTable creates the lock table, if the table lock strategy is used.
+func (d *driver) prepareLockTable() error {
+	if !d.usesLockTable() {
+		return nil
+	}
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	err := d.createTableWithOptions(ctx, d.lockTable(), d.lockTableColumns(), d.lockTableOptions(),
+		"failed create lock table")
+	if err != nil {
+		return err
+	}
+	if !d.cfg.PrimaryGuard {
+		return nil
+	}
+	return d.ensureColumns(ctx, d.lockTable(), d.lockTableColumns())
+}
+
+// acquireTableLock tries to insert or take over the lock row until the timeout expires. Negative timeouts wait
+// forever.
+func (d *driver) acquireTableLock(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		acquired, err := d.tryTableLock()
+		if err != nil {
+			return err
+		}
+		if acquired {
+			d.startHeartbeat()
+			return nil
+		}
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			return ErrDatabaseLocked
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// tryTableLock inserts the lock row, or takes it over if it expired. The assignments of the ON DUPLICATE KEY
+// UPDATE clause are evaluated from left to right, so the expiry (and the server of the primary guard) is only
+// changed if the row is owned afterwards.
+func (d *driver) tryTableLock() (bool, error) {
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	query := "INSERT INTO `" + d.lockTable() + "` (lock_key, owner, expires_at) VALUES (?, ?, NOW() + INTERVAL ? SECOND) " +
+		"ON DUPLICATE KEY UPDATE owner = IF(expires_at < NOW(), VALUES(owner), owner), " +
+		"expires_at = IF(owner = VALUES(owner), VALUES(expires_at), expires_at)"
+	args := []interface{}{d.getLockingKey(), d.lockOwner(), d.lockTTLSeconds()}
+	if d.cfg.PrimaryGuard {
+		query = "INSERT INTO `" + d.lockTable() + "` (lock_key, owner, expires_at, server_uuid) " +
+			"VALUES (?, ?, NOW() + INTERVAL ? SECOND, ?) " +
+			"ON DUPLICATE KEY UPDATE owner = IF(expires_at < NOW(), VALUES(owner), owner), " +
+			"expires_at = IF(owner = VALUES(owner), VALUES(expires_at), expires_at), " +
+			"server_uuid = IF(owner = VALUES(owner), VALUES(server_uuid), server_uuid)"
+		args = append(args, d.server.Identity)
+	}
+	if _, err := d.client.ExecContext(ctx, query, args...); err != nil {
+		if isDeadlock(err) {
+			return false, nil // concurrent attempt on another cluster node, retry
+		}
+		return false, &lightmigrate.DriverError{OrigErr: err, Msg: "try lock failed", Query: []byte(query)}
+	}
+
+	owner, server, err := d.lockServer(ctx)
+	if err != nil {
+		return false, err
+	}
+	if owner == d.lockOwner() {
+		return true, nil
+	}
+	return false, d.primaryConflict(server)
+}
+
+// releaseTableLock stops the heartbeat and deletes the lock row. If the row is not owned anymore (e.g. it expired
+// and was taken over by another process), ErrLockNotHeld is returned.
+func (d *driver) releaseTableLock() error {
+	d.stopHeartbeat()
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	query := "DELETE FROM `" + d.lockTable() + "` WHERE lock_key = ? AND owner = ?"
+	result, err := d.client.ExecContext(ctx, query, d.getLockingKey(), d.lockOwner())
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "release lock failed", Query: []byte(query)}
+	}
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return &lightmigrate.DriverError{OrigErr: ErrLockNotHeld,
+			Msg: "release lock failed, the lock row is owned by another process", Query: []byte(query)}
+	}
+
+	return nil
+}
+
+// startHeartbeat extends the expiry of the lock row until stopHeartbeat is called. If the row is not owned anymore,
+// the heartbeat stops and the run is aborted, see checkLockLost.
+func (d *driver) startHeartbeat() {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	hb := &heartbeat{stop: stop, done: done}
+	d.heartbeat = hb
+
+	interval := d.lockTTL() / 3
+	query := "UPDATE `" + d.lockTable() + "` SET expires_at = NOW() + INTERVAL ? SECOND WHERE lock_key = ? AND owner = ?"
+	key, owner, ttl := d.getLockingKey(), d.lockOwner(), d.lockTTLSeconds()
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				ctx, cancel := d.internalContext()
+				owned, err := d.extendTableLock(ctx, query, ttl, key, owner)
+				cancel()
+				if err != nil {
+					d.logf("failed to extend migration lock: %v", err)
+					continue
+				}
+				if !owned {
+					d.logf("migration lock %s expired and was taken over by another process, aborting the run", key)
+					atomic.StoreInt32(&hb.lost, 1)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// extendTableLock executes the heartbeat update. If no row was changed, the owner of the row is checked, as the
+// server does not count rows whose expiry did not change.
+func (d *driver) extendTableLock(ctx context.Context, query string, ttl int64, key, owner string) (bool, error) {
+	result, err := d.client.ExecContext(ctx, query, ttl, key, owner)
+	if err != nil {
+		return false, err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated > 0 {
+		return true, nil
+	}
+
+	var count int
+	query = "SELECT COUNT(*) FROM `" + d.lockTable() + "` WHERE lock_key = ? AND owner = ?"
+	if err := d.client.QueryRowContext(ctx, query, key, owner).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// checkLockLost aborts the run, if the heartbeat found the lock row owned by another process.
+func (d *driver) checkLockLost() error {
+	if d.heartbeat == nil || atomic.LoadInt32(&d.heartbeat.lost) == 0 {
+		return nil
+	}
+	return &lightmigrate.DriverError{OrigErr: ErrLockNotHeld,
+		Msg: "the migration lock expired and was taken over by another process"}
+}
+
+// stopHeartbeat stops the heartbeat of the lock row and waits until it finished.
+func (d *driver) stopHeartbeat() {
+	if d.heartbeat == nil {
+		return
+	}
+
+	close(d.heartbeat.stop)
+	<-d.heartbeat.done
+	d.heartbeat = nil
+}
+
+// heartbeat is the state of the background routine that keeps the lock row alive.
+type heartbeat struct {
+	stop chan struct{}
+	done chan struct{}
+	lost int32 // set if the lock row is owned by another process, must be accessed by atomic.XXX functions!
+}
+
+// isTableLockHeld checks if the lock row exists and has not expired yet.
+func (d *driver) isTableLockHeld(ctx context.Context) (bool, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM `" + d.lockTable() + "` WHERE lock_key = ? AND expires_at > NOW()"
+	if err := d.client.QueryRowContext(ctx, query, d.getLockingKey()).Scan(&count); err != nil {
+		return false, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to check lock", Query: []byte(query)}
+	}
+	return count > 0, nil
+}
+
+func (d *driver) lockTTL() time.Duration {
+	if d.cfg.LockTTL <= 0 {
+		return DefaultLockTTL
+	}
+	return d.cfg.LockTTL
+}
+
+func (d *driver) lockTTLSeconds() int64 {
+	return timeoutSeconds(d.lockTTL())
+}
+
+// lockOwner returns the identifier that is stored in the lock row, it is unique for each driver instance.
+func (d *driver) lockOwner() string {
+	if d.owner == "" {
+		d.owner = newLockOwner()
+	}
+	return d.owner
+}
+
+// newLockOwner generates an identifier for the lock row that includes the host name and the process id.
+func newLockOwner() string {
+	host, _ := os.Hostname()
+	random := make([]byte, 8)
+	_, _ = rand.Read(random)
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(random))
+}
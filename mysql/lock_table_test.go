@@ -0,0 +1,149 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lockTableHandler simulates a lock row that is owned by owner. The owner argument is always the last argument.
+func lockTableHandler(owner string) fakeHandler {
+	return func(call fakeCall) fakeResponse {
+		var owned int64
+		if len(call.Args) > 0 && call.Args[len(call.Args)-1] == owner {
+			owned = 1
+		}
+		switch {
+		case strings.HasPrefix(call.Query, "SELECT owner"):
+			return fakeResponse{Columns: []string{"owner"}, Rows: [][]sqldriver.Value{{owner}}}
+		case strings.HasPrefix(call.Query, "SELECT COUNT(*)") && strings.HasSuffix(call.Query, "owner = ?"):
+			return fakeResponse{Columns: []string{"count"}, Rows: [][]sqldriver.Value{{owned}}}
+		case strings.HasPrefix(call.Query, "SELECT COUNT(*)"):
+			return fakeResponse{Columns: []string{"count"}, Rows: [][]sqldriver.Value{{int64(1)}}}
+		case strings.HasPrefix(call.Query, "UPDATE"), strings.HasPrefix(call.Query, "DELETE"):
+			return fakeResponse{RowsAffected: owned}
+		}
+		return fakeResponse{}
+	}
+}
+
+func TestWithLockStrategy(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithLockStrategy(LockStrategyTable)(d)
+	WithLockTTL(time.Minute)(d)
+	if d.cfg.LockStrategy != LockStrategyTable || d.cfg.LockTTL != time.Minute {
+		t.Fatalf("failed to set lock strategy")
+	}
+}
+
+func Test_driver_Lock_Table(t *testing.T) {
+	db, srv := newFakeDB(t, lockTableHandler("me"))
+	d := &driver{client: db, owner: "me", cfg: &config{DatabaseName: "testdb", Locking: true, LockStrategy: LockStrategyTable}}
+
+	if err := d.prepareLockTable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.heartbeat == nil {
+		t.Fatalf("heartbeat was not started")
+	}
+	held, err := d.isLockHeld(context.Background())
+	if err != nil || !held {
+		t.Fatalf("unexpected lock state %t: %v", held, err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.heartbeat != nil {
+		t.Fatalf("heartbeat was not stopped")
+	}
+
+	queries := srv.Queries()
+	if !strings.HasPrefix(queries[0], "CREATE TABLE IF NOT EXISTS `schema_migrations_lock`") ||
+		!strings.HasPrefix(queries[1], "INSERT INTO `schema_migrations_lock`") ||
+		!strings.HasPrefix(queries[len(queries)-1], "DELETE FROM `schema_migrations_lock`") {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_prepareLockTable_Options(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations", Locking: true,
+		LockStrategy: LockStrategyTable, TablePrefix: "app_", MaxParallelStatements: 1}}
+	WithLockTable("deploy_lock")(d)
+	WithLockTableEngine("NDBCLUSTER")(d)
+
+	if err := d.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.prepareLockTable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query := srv.Queries()[0]; !strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS `app_deploy_lock` (") ||
+		!strings.HasSuffix(query, ") ENGINE=NDBCLUSTER") {
+		t.Fatalf("unexpected query, got: %s", query)
+	}
+
+	WithLockTableEngine("InnoDB; DROP TABLE users")(d)
+	if err := d.validate(); err == nil {
+		t.Fatalf("expected error for invalid engine")
+	}
+}
+
+func Test_driver_Lock_TableLocked(t *testing.T) {
+	db, _ := newFakeDB(t, lockTableHandler("other"))
+	d := &driver{client: db, owner: "me", cfg: &config{DatabaseName: "testdb", Locking: true, LockStrategy: LockStrategyTable}}
+
+	if err := d.Lock(); !errors.Is(err, ErrDatabaseLocked) {
+		t.Fatalf("unexpected error %v, got: %v", ErrDatabaseLocked, err)
+	}
+	if d.heartbeat != nil || d.reentrantLockFlag != 0 {
+		t.Fatalf("unexpected lock state")
+	}
+}
+
+func Test_driver_heartbeat_LockLost(t *testing.T) {
+	db, _ := newFakeDB(t, lockTableHandler("other"))
+	d := &driver{client: db, owner: "me", logger: log.New(io.Discard, "", 0),
+		cfg: &config{DatabaseName: "testdb", Locking: true, LockStrategy: LockStrategyTable,
+			LockTTL: 30 * time.Millisecond}}
+
+	d.reentrantLockFlag = 1
+	d.startHeartbeat()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := d.SetVersion(2, true); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("expected error %v, got: %v", ErrLockNotHeld, err)
+	}
+	if err := d.Unlock(); !errors.Is(err, ErrLockNotHeld) {
+		t.Fatalf("expected error %v, got: %v", ErrLockNotHeld, err)
+	}
+}
+
+func Test_driver_heartbeat(t *testing.T) {
+	db, srv := newFakeDB(t, lockTableHandler("me"))
+	d := &driver{client: db, owner: "me", cfg: &config{DatabaseName: "testdb", LockTTL: 30 * time.Millisecond}}
+
+	d.startHeartbeat()
+	time.Sleep(50 * time.Millisecond)
+	d.stopHeartbeat()
+
+	calls := srv.Calls()
+	if len(calls) == 0 || !strings.HasPrefix(calls[0].Query, "UPDATE `schema_migrations_lock` SET expires_at") {
+		t.Fatalf("unexpected queries: %q", srv.Queries())
+	}
+}
+
+func Test_newLockOwner(t *testing.T) {
+	if a, b := newLockOwner(), newLockOwner(); a == b || a == "" {
+		t.Fatalf("lock owners must be unique: %s, %s", a, b)
+	}
+}
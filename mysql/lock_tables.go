@@ -0,0 +1,134 @@
+package mysql
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// WithTableScopedLocking enables the "-- lightmigrate:tables <table>, ..." directive. A migration that declares the
+// tables it touches holds a lock for each of these tables while its statements are executed, in addition to the
+// migration lock. The table locks do not depend on the namespace, so migrations of different namespaces (e.g. of
+// different applications sharing a database, see WithNamespace) only serialize if they touch the same tables. The
+// migration lock is kept, as other processes of the same namespace would fail on the dirty version otherwise.
+// The table locks are advisory locks of the migration connection, they require MySQL 5.7.5 or MariaDB 10.0.2 and
+// the advisory lock strategy. Otherwise, the directive only keeps the migration lock.
+func WithTableScopedLocking(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.TableScopedLocking = enabled
+	}
+}
+
+// parseTableList parses the argument of the tables directive, e.g. "users, `orders`". The lower-cased table names
+// are returned sorted, so that all processes acquire the table locks in the same order.
+func parseTableList(arg string) []string {
+	fields := strings.FieldsFunc(arg, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+
+	seen := make(map[string]struct{}, len(fields))
+	var tables []string
+	for _, field := range fields {
+		table := strings.ToLower(strings.Trim(field, "`"))
+		if _, ok := seen[table]; ok || table == "" {
+			continue
+		}
+		seen[table] = struct{}{}
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	return tables
+}
+
+// tableLockKey derives the lock key of a single table. It fits the maximum length of advisory lock names.
+func (d *driver) tableLockKey(table string) string {
+	sum := sha256.Sum256([]byte(d.cfg.DatabaseName + "/table/" + d.cfg.TablePrefix + "/" + table))
+	return hex.EncodeToString(sum[:])
+}
+
+// lockTables handles the tables directive. The table locks are held by the migration session until the migration
+// finished, the migration lock stays held, as the version is dirty while the statements are executed.
+func (d *driver) lockTables(state *migrationState, index int, arg string, line int) error {
+	if index > 0 || state.TableLocks != nil {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrMisplacedDirective,
+			Msg:     "the tables directive must precede the first statement",
+			Line:    uint(line),
+		}
+	}
+
+	tables := parseTableList(arg)
+	if len(tables) == 0 {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrInvalidDirective,
+			Msg:     "the tables directive requires at least one table",
+			Line:    uint(line),
+		}
+	}
+
+	if !d.cfg.TableScopedLocking || !d.cfg.Locking {
+		return nil
+	}
+	if d.cfg.LockStrategy == LockStrategyTable || !d.server.supports(featureMultipleLocks) {
+		d.logf("table-scoped locks require advisory locks and MySQL 5.7.5 or MariaDB 10.0.2, " +
+			"the migration only holds the migration lock")
+		return nil
+	}
+
+	if err := d.acquireTableLocks(state.Session, tables); err != nil {
+		return err
+	}
+	state.TableLocks = tables
+	d.logf("table locks acquired for %s", strings.Join(tables, ", "))
+
+	return nil
+}
+
+// acquireTableLocks acquires the advisory locks of the tables within the session. If a lock cannot be acquired,
+// all locks that were already acquired are released.
+func (d *driver) acquireTableLocks(session *sql.Conn, tables []string) error {
+	ctx, cancel := d.lockContext(d.cfg.LockTimeout)
+	defer cancel()
+
+	query := "SELECT GET_LOCK(?, ?)"
+	for i, table := range tables {
+		var success bool
+		err := session.QueryRowContext(ctx, query, d.tableLockKey(table), lockTimeoutSeconds(d.cfg.LockTimeout)).
+			Scan(&success)
+		if err == nil && !success {
+			err = ErrDatabaseLocked
+		}
+		if err != nil {
+			d.releaseLocks(session, tables[:i])
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to lock table " + table, Query: []byte(query)}
+		}
+	}
+
+	return nil
+}
+
+// releaseTableLocks releases the table locks of the migration. It must be called before the session is closed,
+// as advisory locks are held by the connection.
+func (d *driver) releaseTableLocks(state *migrationState) {
+	if len(state.TableLocks) == 0 || state.Session == nil {
+		return
+	}
+	d.releaseLocks(state.Session, state.TableLocks)
+	state.TableLocks = nil
+}
+
+// releaseLocks releases the table locks of the session. Errors are only logged, the locks are released by the
+// server at the latest when the connection is closed.
+func (d *driver) releaseLocks(session *sql.Conn, tables []string) {
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	for _, table := range tables {
+		if _, err := session.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", d.tableLockKey(table)); err != nil {
+			d.logf("failed to release table lock: %v", err)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseTableList(t *testing.T) {
+	tables := parseTableList(" users, `Orders` users\tpayments,")
+	if want := []string{"orders", "payments", "users"}; !reflect.DeepEqual(tables, want) {
+		t.Fatalf("unexpected tables %q, got: %q", want, tables)
+	}
+}
+
+func Test_driver_RunMigration_TableLocks(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, server: parseServerVersion("8.0.36"), logger: log.New(io.Discard, "", 0),
+		cfg: &config{DatabaseName: "db", Locking: true, SplitStatements: true, TableScopedLocking: true}}
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.Reset()
+
+	migration := "-- lightmigrate:tables users, orders\nALTER TABLE users ADD COLUMN a int;"
+	if err := d.RunMigration(strings.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := srv.Calls()
+	// the migration lock is not released, other processes would see the dirty version
+	want := []string{"SELECT GET_LOCK(?, ?)", "SELECT GET_LOCK(?, ?)", "ALTER TABLE users ADD COLUMN a int",
+		"SELECT RELEASE_LOCK(?)", "SELECT RELEASE_LOCK(?)"}
+	if queries := srv.Queries(); !reflect.DeepEqual(queries, want) {
+		t.Fatalf("unexpected queries %q, got: %q", want, queries)
+	}
+	if calls[0].Args[0] != d.tableLockKey("orders") || calls[1].Args[0] != d.tableLockKey("users") ||
+		calls[3].Args[0] != d.tableLockKey("orders") || calls[4].Args[0] != d.tableLockKey("users") {
+		t.Fatalf("unexpected lock keys: %v", calls)
+	}
+	for _, i := range []int{0, 1, 3, 4} {
+		if calls[i].ConnID != calls[2].ConnID {
+			t.Fatalf("table lock %d was not held by the migration connection", i)
+		}
+	}
+}
+
+func Test_driver_RunMigration_TableLocksUnsupported(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, server: parseServerVersion("5.6.51"), logger: log.New(io.Discard, "", 0),
+		cfg: &config{DatabaseName: "db", Locking: true, SplitStatements: true, TableScopedLocking: true}}
+
+	if err := d.RunMigration(strings.NewReader("-- lightmigrate:tables users\nSELECT 1;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); !reflect.DeepEqual(queries, []string{"SELECT 1"}) {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
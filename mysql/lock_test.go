@@ -0,0 +1,229 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLockWaitObserver(t *testing.T) {
+	d := &driver{}
+
+	WithLockWaitObserver(func(wait time.Duration, err error) {})(d)
+	if d.lockWaitObserver == nil {
+		t.Fatalf("failed to set lock wait observer")
+	}
+}
+
+func Test_driver_Lock(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	var observed []error
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true}}
+	d.lockWaitObserver = func(wait time.Duration, err error) { observed = append(observed, err) }
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Lock(); err != nil { // reentrant
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if queries := srv.Queries(); len(queries) != 1 || queries[0] != "SELECT GET_LOCK(?, ?)" {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+	if len(observed) != 1 || observed[0] != nil {
+		t.Fatalf("unexpected observed lock waits: %v", observed)
+	}
+}
+
+func Test_driver_Lock_Timeout(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true, LockTimeout: 1500 * time.Millisecond}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls := srv.Calls(); len(calls) != 1 || calls[0].Args[1] != int64(2) {
+		t.Fatalf("unexpected lock timeout argument: %+v", calls)
+	}
+
+	if got := lockTimeoutSeconds(-time.Second); got != -1 {
+		t.Fatalf("unexpected infinite lock timeout -1, got: %d", got)
+	}
+}
+
+func Test_driver_lockContext(t *testing.T) {
+	d := &driver{cfg: &config{QueryTimeout: time.Second, LockTimeout: time.Hour}}
+	ctx, cancel := d.lockContext(d.cfg.LockTimeout)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) < time.Minute {
+		t.Fatalf("unexpected deadline: %v", deadline)
+	}
+}
+
+func Test_driver_Lock_Locked(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT GET_LOCK") {
+			return fakeResponse{Columns: []string{"result"}, Rows: [][]sqldriver.Value{{int64(0)}}}
+		}
+		return fakeResponse{}
+	})
+	var observed []error
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true}}
+	d.lockWaitObserver = func(wait time.Duration, err error) { observed = append(observed, err) }
+
+	if err := d.Lock(); !errors.Is(err, ErrDatabaseLocked) {
+		t.Fatalf("unexpected error %v, got: %v", ErrDatabaseLocked, err)
+	}
+	if d.reentrantLockFlag != 0 {
+		t.Fatalf("lock flag was not restored")
+	}
+	if len(observed) != 1 || !errors.Is(observed[0], ErrDatabaseLocked) {
+		t.Fatalf("unexpected observed lock waits: %v", observed)
+	}
+}
+
+func Test_driver_Unlock(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true}}
+
+	if err := d.Unlock(); err != nil { // not locked
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if queries := srv.Queries(); len(queries) != 2 || queries[1] != "SELECT RELEASE_LOCK(?)" {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_Unlock_OwningConnection(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	db.SetMaxIdleConns(5)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := db.Exec("SELECT 1"); err != nil { // uses another connection of the pool
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	calls := srv.Calls()
+	if len(calls) != 3 || calls[0].ConnID != calls[2].ConnID || calls[0].ConnID == calls[1].ConnID {
+		t.Fatalf("lock must be released on the owning connection: %+v", calls)
+	}
+}
+
+func Test_driver_Lock_PoolOfTwo(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	db.SetMaxOpenConns(2)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true}}
+
+	if err := d.Lock(); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("expected error %v, got: %v", ErrInvalidConfig, err)
+	}
+}
+
+func Test_driver_Unlock_NotHeld(t *testing.T) {
+	tests := []struct {
+		name   string
+		result sqldriver.Value
+	}{
+		{"held by another session", int64(0)},
+		{"does not exist", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+				if strings.HasPrefix(call.Query, "SELECT RELEASE_LOCK") {
+					return fakeResponse{Columns: []string{"result"}, Rows: [][]sqldriver.Value{{tt.result}}}
+				}
+				return defaultFakeHandler(call)
+			})
+			d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true}}
+
+			if err := d.Lock(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := d.Unlock(); !errors.Is(err, ErrLockNotHeld) {
+				t.Fatalf("expected error %v, got: %v", ErrLockNotHeld, err)
+			}
+			if queries := srv.Queries(); len(queries) != 2 {
+				t.Fatalf("expected a single release, got: %q", queries)
+			}
+
+			// the lock is not held, a second unlock does nothing
+			if err := d.Unlock(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func Test_driver_getLockingKey(t *testing.T) {
+	d := &driver{cfg: &config{DatabaseName: "testdb"}}
+	key := d.getLockingKey()
+	if key != "2584668960" {
+		t.Fatalf("unexpected key 2584668960, got: %s", key)
+	}
+
+	d = &driver{cfg: &config{DatabaseName: "testdb2"}}
+	key = d.getLockingKey()
+	if key != "2083671126" {
+		t.Fatalf("unexpected key 2083671126, got: %s", key)
+	}
+
+	d = &driver{cfg: &config{DatabaseName: "testdb", TablePrefix: "app_"}}
+	if key = d.getLockingKey(); key == "2584668960" {
+		t.Fatalf("table prefix is not part of the locking key")
+	}
+}
+
+func Test_driver_RunMigration_NoLock(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true, SplitStatements: true}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.Reset()
+
+	if err := d.RunMigration(strings.NewReader("-- lightmigrate:no-lock\nCREATE TABLE a (id int);")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"SELECT RELEASE_LOCK(?)", "CREATE TABLE a (id int)", "SELECT GET_LOCK(?, ?)"}
+	if got := srv.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected queries %q, got: %q", want, got)
+	}
+	if d.reentrantLockFlag != 1 {
+		t.Fatalf("lock was not re-acquired")
+	}
+}
+
+func Test_driver_RunMigration_NoLockMisplaced(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations", Locking: true, SplitStatements: true}}
+
+	err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int);\n-- lightmigrate:no-lock\nCREATE TABLE b (id int);"))
+	if !errors.Is(err, ErrMisplacedDirective) {
+		t.Fatalf("unexpected error %v, got: %v", ErrMisplacedDirective, err)
+	}
+	for _, query := range srv.Queries() {
+		if query == "CREATE TABLE b (id int)" {
+			t.Fatalf("unexpected query after misplaced directive")
+		}
+	}
+}
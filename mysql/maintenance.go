@@ -0,0 +1,308 @@
+package mysql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// MaintenanceWindow is a recurring time range, e.g. "Sat,Sun 01:00-05:00 Europe/Berlin", see WithMaintenanceWindow.
+type MaintenanceWindow struct {
+	// Days are the weekdays on which the window opens, every day if empty.
+	Days []time.Weekday
+	// Start and End are the offsets since midnight. If End is not after Start, the window ends on the next day.
+	Start time.Duration
+	End   time.Duration
+	// Location is the time zone of the window, UTC if nil.
+	Location *time.Location
+}
+
+// ParseMaintenanceWindow parses a window of the form "[days] HH:MM-HH:MM [time zone]". Days are a comma separated
+// list of weekdays or ranges, e.g. "Mon-Fri" or "Sat,Sun", and can be omitted for daily windows.
+func ParseMaintenanceWindow(spec string) (MaintenanceWindow, error) {
+	var window MaintenanceWindow
+	fields := strings.Fields(spec)
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return window, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+		}
+		window.Days, fields = days, fields[1:]
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return window, fmt.Errorf("invalid maintenance window %q: expected [days] HH:MM-HH:MM [time zone]", spec)
+	}
+
+	bounds := strings.Split(fields[0], "-")
+	if len(bounds) != 2 {
+		return window, fmt.Errorf("invalid maintenance window %q: invalid time range %s", spec, fields[0])
+	}
+	var err error
+	if window.Start, err = parseTimeOfDay(bounds[0]); err != nil {
+		return window, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+	}
+	if window.End, err = parseTimeOfDay(bounds[1]); err != nil {
+		return window, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+	}
+
+	if len(fields) == 2 {
+		if window.Location, err = time.LoadLocation(fields[1]); err != nil {
+			return window, fmt.Errorf("invalid maintenance window %q: %w", spec, err)
+		}
+	}
+
+	return window, nil
+}
+
+// parseWeekdays parses a comma separated list of weekdays and weekday ranges.
+func parseWeekdays(spec string) ([]time.Weekday, error) {
+	if spec == "*" || strings.EqualFold(spec, "daily") {
+		return nil, nil
+	}
+
+	var days []time.Weekday
+	for _, part := range strings.Split(spec, ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return nil, fmt.Errorf("invalid weekday range %s", part)
+		}
+		first, err := parseWeekday(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = parseWeekday(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for day, weekday := range weekdayNames {
+		if len(name) >= 3 && strings.HasPrefix(strings.ToLower(name), weekday) {
+			return time.Weekday(day), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %s", name)
+}
+
+// parseTimeOfDay parses HH:MM as offset since midnight, 24:00 is the end of the day.
+func parseTimeOfDay(spec string) (time.Duration, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %s", spec)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s", spec)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time %s", spec)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// String returns the window in the format of ParseMaintenanceWindow.
+func (w MaintenanceWindow) String() string {
+	var parts []string
+	if len(w.Days) > 0 {
+		days := make([]string, len(w.Days))
+		for i, day := range w.Days {
+			days[i] = day.String()[:3]
+		}
+		parts = append(parts, strings.Join(days, ","))
+	}
+	parts = append(parts, formatTimeOfDay(w.Start)+"-"+formatTimeOfDay(w.End))
+	if w.Location != nil && w.Location != time.UTC {
+		parts = append(parts, w.Location.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func formatTimeOfDay(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset/time.Hour), int(offset%time.Hour/time.Minute))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (w MaintenanceWindow) MarshalText() ([]byte, error) {
+	return []byte(w.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (w *MaintenanceWindow) UnmarshalText(text []byte) error {
+	window, err := ParseMaintenanceWindow(string(text))
+	if err != nil {
+		return err
+	}
+	*w = window
+	return nil
+}
+
+// opensOn checks if the window opens on the weekday.
+func (w MaintenanceWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// location returns the time zone of the window.
+func (w MaintenanceWindow) location() *time.Location {
+	if w.Location == nil {
+		return time.UTC
+	}
+	return w.Location
+}
+
+// at returns the wall clock time of the offset on the day of t, shifted by days. The time is built from the wall
+// clock, so that windows keep their local times on days with a daylight saving time change.
+func (w MaintenanceWindow) at(t time.Time, days int, offset time.Duration) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0,
+		t.Location())
+}
+
+// Contains checks if the time is within the window.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	t = t.In(w.location())
+	for _, days := range []int{0, -1} { // the window of yesterday might end today
+		if !w.opensOn(w.at(t, days, 0).Weekday()) {
+			continue
+		}
+		start, end := w.at(t, days, w.Start), w.at(t, days, w.End)
+		if w.End <= w.Start {
+			end = w.at(t, days+1, w.End) // the window ends on the next day
+		}
+		if !t.Before(start) && t.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the next time the window opens after t.
+func (w MaintenanceWindow) Next(t time.Time) time.Time {
+	t = t.In(w.location())
+	for i := 0; i <= 7; i++ {
+		if start := w.at(t, i, w.Start); start.After(t) && w.opensOn(w.at(t, i, 0).Weekday()) {
+			return start
+		}
+	}
+	return time.Time{} // unreachable, the window opens at least once a week
+}
+
+// WithMaintenanceWindow restricts migrations marked with the "-- lightmigrate:heavy" directive to the given windows.
+// Outside of the windows, heavy migrations are refused with ErrOutsideMaintenanceWindow and the version stays clean,
+// unless WithMaintenanceWait or WithMaintenanceOverride is used. With a custom version store (see WithVersionStore),
+// the previous version is not known and the refused version stays dirty. Other migrations are not restricted.
+func WithMaintenanceWindow(windows ...MaintenanceWindow) DriverOption {
+	return func(d *driver) {
+		d.cfg.MaintenanceWindows = windows
+	}
+}
+
+// WithMaintenanceWait delays heavy migrations until the next maintenance window opens, instead of refusing them,
+// if the window opens within maxWait. The migration lock is held while waiting and verified afterwards, a lost
+// advisory lock is acquired again. If the window opens later or after the run deadline (see WithRunDeadline), the
+// migration is refused. A value of 0 disables waiting.
+func WithMaintenanceWait(maxWait time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.MaintenanceWait = maxWait
+	}
+}
+
+// WithMaintenanceOverride allows heavy migrations outside of the maintenance windows, e.g. for emergency changes.
+// The reason is logged for each overridden migration. An empty reason disables the override.
+func WithMaintenanceOverride(reason string) DriverOption {
+	return func(d *driver) {
+		d.cfg.MaintenanceOverride = reason
+	}
+}
+
+// withinMaintenanceWindow checks if the time is within any of the configured windows.
+func (d *driver) withinMaintenanceWindow(t time.Time) bool {
+	for _, window := range d.cfg.MaintenanceWindows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextMaintenanceWindow returns the next time any of the configured windows opens.
+func (d *driver) nextMaintenanceWindow(t time.Time) time.Time {
+	var next time.Time
+	for _, window := range d.cfg.MaintenanceWindows {
+		if start := window.Next(t); next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	return next
+}
+
+// heavyMigration handles the heavy directive. Outside of the maintenance windows, it waits for the next window or
+// refuses the migration, see WithMaintenanceWindow.
+func (d *driver) heavyMigration(state *migrationState, index, line int) error {
+	if index > 0 {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrMisplacedDirective,
+			Msg:     "the heavy directive must precede the first statement",
+			Line:    uint(line),
+		}
+	}
+
+	now := time.Now()
+	switch {
+	case len(d.cfg.MaintenanceWindows) == 0 || d.withinMaintenanceWindow(now):
+		return nil
+	case d.cfg.MaintenanceOverride != "":
+		d.logf("heavy migration %d runs outside of the maintenance windows: %s", d.runningVersion,
+			d.cfg.MaintenanceOverride)
+		return nil
+	}
+
+	next := d.nextMaintenanceWindow(now)
+	started := atomic.LoadInt64(&d.runStarted)
+	deadline := d.cfg.RunDeadline > 0 && started != 0 && next.After(time.Unix(0, started).Add(d.cfg.RunDeadline))
+	if next.Sub(now) > d.cfg.MaintenanceWait || deadline {
+		state.Refused = true
+		return &lightmigrate.DriverError{
+			OrigErr: ErrOutsideMaintenanceWindow,
+			Msg: fmt.Sprintf("heavy migration %d was not started, the next maintenance window opens at %s",
+				d.runningVersion, next.Format(time.RFC3339)),
+			Line: uint(line),
+		}
+	}
+
+	d.logf("heavy migration %d waits for the maintenance window at %s", d.runningVersion, next.Format(time.RFC3339))
+	time.Sleep(time.Until(next))
+
+	// the idle connection of the advisory lock might have been closed by the server (wait_timeout)
+	if atomic.LoadInt32(&d.reentrantLockFlag) == 1 {
+		if err := d.relock(); err != nil {
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to verify the migration lock after waiting for " +
+				"the maintenance window", Line: uint(line)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package mysql
+
+import (
+	"bytes"
+	sqldriver "database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	tests := []struct {
+		spec string
+		want MaintenanceWindow
+	}{
+		{"01:00-05:00", MaintenanceWindow{Start: time.Hour, End: 5 * time.Hour}},
+		{"daily 22:30-24:00", MaintenanceWindow{Start: 22*time.Hour + 30*time.Minute, End: 24 * time.Hour}},
+		{"Sat,Sun 01:00-05:00 Europe/Berlin", MaintenanceWindow{Days: []time.Weekday{time.Saturday, time.Sunday},
+			Start: time.Hour, End: 5 * time.Hour, Location: berlin}},
+		{"Fri-Mon 22:00-04:00", MaintenanceWindow{
+			Days:  []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday},
+			Start: 22 * time.Hour, End: 4 * time.Hour}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMaintenanceWindow(tt.spec)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", tt.spec, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("unexpected window %+v for %q, got: %+v", tt.want, tt.spec, got)
+		}
+	}
+
+	for _, spec := range []string{"", "01:00", "Moon 01:00-02:00", "25:00-26:00", "01:00-02:00 Mars/Base", "a b c d"} {
+		if _, err := ParseMaintenanceWindow(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestMaintenanceWindow_Text(t *testing.T) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"maintenance_windows": ["Sat,Sun 01:00-05:00"]}`), &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.MaintenanceWindows) != 1 || cfg.MaintenanceWindows[0].String() != "Sat,Sun 01:00-05:00" {
+		t.Fatalf("unexpected windows: %v", cfg.MaintenanceWindows)
+	}
+}
+
+func TestMaintenanceWindow_Contains(t *testing.T) {
+	// Fri-Sat 22:00-04:00, the window on Saturday ends on Sunday morning
+	window := MaintenanceWindow{Days: []time.Weekday{time.Friday, time.Saturday}, Start: 22 * time.Hour,
+		End: 4 * time.Hour}
+	tests := map[string]bool{
+		"2026-10-16T21:59:00Z": false, // Friday
+		"2026-10-16T22:00:00Z": true,
+		"2026-10-17T03:59:00Z": true, // Saturday morning, the window of Friday
+		"2026-10-17T12:00:00Z": false,
+		"2026-10-18T03:00:00Z": true, // Sunday morning, the window of Saturday
+		"2026-10-18T22:00:00Z": false,
+		"2026-10-19T03:00:00Z": false,
+	}
+	for value, want := range tests {
+		at, _ := time.Parse(time.RFC3339, value)
+		if got := window.Contains(at); got != want {
+			t.Fatalf("unexpected result %t for %s, got: %t", want, value, got)
+		}
+	}
+
+	at, _ := time.Parse(time.RFC3339, "2026-10-17T12:00:00Z")
+	if next := window.Next(at); next.Format(time.RFC3339) != "2026-10-17T22:00:00Z" {
+		t.Fatalf("unexpected next window, got: %s", next)
+	}
+	at, _ = time.Parse(time.RFC3339, "2026-10-18T12:00:00Z")
+	if next := window.Next(at); next.Format(time.RFC3339) != "2026-10-23T22:00:00Z" {
+		t.Fatalf("unexpected next window, got: %s", next)
+	}
+}
+
+func TestMaintenanceWindow_DaylightSavingTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+
+	// the clocks are set forward from 02:00 to 03:00 on Sunday, 2026-03-29, and back on Sunday, 2026-10-25
+	window := MaintenanceWindow{Days: []time.Weekday{time.Sunday}, Start: 3 * time.Hour, End: 5 * time.Hour,
+		Location: berlin}
+	for _, day := range []int{29, 25} {
+		month := time.March
+		if day == 25 {
+			month = time.October
+		}
+		start := time.Date(2026, month, day, 3, 0, 0, 0, berlin)
+		if next := window.Next(start.Add(-12 * time.Hour)); !next.Equal(start) {
+			t.Fatalf("unexpected next window %s, got: %s", start, next)
+		}
+		if !window.Contains(start) || window.Contains(start.Add(-time.Minute)) ||
+			!window.Contains(start.Add(119*time.Minute)) || window.Contains(start.Add(2*time.Hour)) {
+			t.Fatalf("unexpected window bounds on %s", start)
+		}
+	}
+}
+
+// closedWindow returns a window that does not contain the current time.
+func closedWindow() MaintenanceWindow {
+	now := time.Now().UTC()
+	offset := time.Duration(now.Hour())*time.Hour + 2*time.Hour
+	return MaintenanceWindow{Start: offset % (24 * time.Hour), End: (offset + time.Hour) % (24 * time.Hour)}
+}
+
+func Test_driver_RunMigration_HeavyOutsideWindow(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT version") {
+			return versionHandler([]sqldriver.Value{int64(5), int64(1), int64(4), nil, int64(1)})(call)
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true,
+		MaintenanceWindows: []MaintenanceWindow{closedWindow()}}}
+
+	err := d.RunMigration(strings.NewReader("-- lightmigrate:heavy\nALTER TABLE a ADD COLUMN b int;"))
+	if !errors.Is(err, ErrOutsideMaintenanceWindow) {
+		t.Fatalf("expected error %v, got: %v", ErrOutsideMaintenanceWindow, err)
+	}
+
+	var restored bool
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "ALTER TABLE") {
+			t.Fatalf("heavy migration must not be executed outside of the window")
+		}
+		if strings.HasPrefix(call.Query, "INSERT INTO `migrations`") && call.Args[0] == int64(4) && call.Args[1] == false {
+			restored = true
+		}
+	}
+	if !restored {
+		t.Fatalf("clean version was not restored: %q", srv.Queries())
+	}
+}
+
+func Test_driver_RunMigration_HeavyOverride(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0), cfg: &config{SplitStatements: true,
+		MaintenanceWindows: []MaintenanceWindow{closedWindow()}, MaintenanceOverride: "incident 42"}}
+
+	if err := d.RunMigration(strings.NewReader("-- lightmigrate:heavy\nALTER TABLE a ADD COLUMN b int;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 1 || !strings.HasPrefix(queries[0], "ALTER TABLE") {
+		t.Fatalf("unexpected queries, got: %q", queries)
+	}
+	if !strings.Contains(logs.String(), "outside of the maintenance windows: incident 42") {
+		t.Fatalf("unexpected logs, got: %s", logs.String())
+	}
+}
+
+func Test_driver_RunMigration_HeavyWaitAfterDeadline(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, RunDeadline: time.Minute, MaintenanceWait: 24 * time.Hour,
+		MaintenanceWindows: []MaintenanceWindow{closedWindow()}}}
+	d.startRun()
+
+	err := d.RunMigration(strings.NewReader("-- lightmigrate:heavy\nALTER TABLE a ADD COLUMN b int;"))
+	if !errors.Is(err, ErrOutsideMaintenanceWindow) {
+		t.Fatalf("expected error %v, got: %v", ErrOutsideMaintenanceWindow, err)
+	}
+}
+
+func Test_driver_RunMigration_HeavyWaitTooLong(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, MaintenanceWait: time.Hour,
+		MaintenanceWindows: []MaintenanceWindow{closedWindow()}}}
+
+	err := d.RunMigration(strings.NewReader("-- lightmigrate:heavy\nALTER TABLE a ADD COLUMN b int;"))
+	if !errors.Is(err, ErrOutsideMaintenanceWindow) {
+		t.Fatalf("expected error %v, got: %v", ErrOutsideMaintenanceWindow, err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "ALTER TABLE") {
+			t.Fatalf("heavy migration must not be executed outside of the window")
+		}
+	}
+}
+
+func Test_driver_RunMigration_HeavyMisplaced(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+
+	err := d.RunMigration(strings.NewReader("SELECT 1;\n-- lightmigrate:heavy\nSELECT 2;"))
+	if !errors.Is(err, ErrMisplacedDirective) {
+		t.Fatalf("expected error %v, got: %v", ErrMisplacedDirective, err)
+	}
+}
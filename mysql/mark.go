@@ -0,0 +1,39 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// MarkApplied records the version as the current, clean version without executing any migration, e.g. to reconcile
+// an environment after a hotfix was applied manually. It is the programmatic counterpart of the
+// "-- lightmigrate:skip reason=..." directive. If the history is enabled, the version is recorded as skipped
+// migration with the note. Like down migrations, versions below the rollback floor must be confirmed, see
+// WithRollbackFloor. The migration lock is acquired, unless it is already held by this driver.
+func (d *driver) MarkApplied(version uint64, note string) (err error) {
+	unlock, err := d.lockOperation()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	if err := d.checkRollbackFloor(version); err != nil {
+		return err
+	}
+
+	started := time.Now()
+	if err := d.SetVersion(version, false); err != nil {
+		return err
+	}
+	d.logf("version %d was marked as applied without executing it: %s", version, note)
+
+	if !d.cfg.History {
+		return nil
+	}
+	return d.insertHistory(version, lightmigrate.Up, &migrationState{Skipped: true, Note: note}, started, nil)
+}
@@ -0,0 +1,36 @@
+package mysql
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func Test_driver_MarkApplied(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, logger: log.New(io.Discard, "", 0),
+		cfg: &config{DatabaseName: "db", MigrationsTable: "migrations", Locking: true, History: true}}
+
+	if err := d.MarkApplied(5, "hotfix applied manually"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var inserted, recorded bool
+	for _, call := range srv.Calls() {
+		switch {
+		case strings.HasPrefix(call.Query, "INSERT INTO `migrations`"):
+			inserted = call.Args[0] == int64(5) && call.Args[1] == false
+		case strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_history`"):
+			recorded = call.Args[0] == int64(5) && call.Args[13] == true && call.Args[14] == "hotfix applied manually"
+		}
+	}
+	if !inserted || !recorded {
+		t.Fatalf("unexpected queries: %q", srv.Queries())
+	}
+
+	queries := srv.Queries()
+	if !strings.HasPrefix(queries[0], "SELECT GET_LOCK") || queries[len(queries)-1] != "SELECT RELEASE_LOCK(?)" {
+		t.Fatalf("expected version to be marked within the migration lock, got: %q", queries)
+	}
+}
@@ -4,33 +4,183 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"hash/crc32"
 	"io"
-	"io/ioutil"
+	"io/fs"
+	"log"
 	"sync/atomic"
+	"time"
 
+	gomysql "github.com/go-sql-driver/mysql"
 	"github.com/h44z/lightmigrate"
 )
 
-const advisoryLockIDSalt uint = 1486364155
-
 type driver struct {
 	client            *sql.DB
 	cfg               *config
-	reentrantLockFlag int32 // must be accessed by atomic.XXX functions!
+	reentrantLockFlag int32     // must be accessed by atomic.XXX functions!
+	lockConn          *sql.Conn // the session that holds the advisory lock, nil if the pool has a single connection
+	lastLockWait      int64     // time.Duration, must be accessed by atomic.XXX functions!
+	runStarted        int64     // start of the current run in unix nanoseconds, must be accessed by atomic.XXX functions!
 
 	logger  lightmigrate.Logger
 	verbose bool
+
+	decryptor Decryptor
+	includeFS fs.FS
+
+	infileFS      fs.FS
+	infileReaders map[string]func() io.Reader
+
+	lockWaitObserver LockWaitObserver
+	progressObserver ProgressObserver
+
+	features *featureSet // optional features that were disabled due to missing privileges
+	stats    *statsRecorder
+	server   serverInfo
+
+	owner     string     // identifies the driver instance within the lock table
+	heartbeat *heartbeat // keeps the lock row alive, if the table lock strategy is used
+
+	faults *FaultInjector
+
+	runningVersion   uint64                 // the version that was marked dirty by the last SetVersion call
+	runningDirection lightmigrate.Direction // whether the migration to runningVersion is an up or down migration
+	knownVersion     uint64                 // the clean version that was last read or set, see runningDirection
+	versionKnown     bool                   // knownVersion was read or set
+	runActive        bool                   // a migration was started within the current run, see WithPreRunSQL
+	runVersion       uint64                 // the last version that was set within the current run
+	runDirty         bool                   // the last version that was set within the current run is dirty
+
+	notifiers     []Notifier
+	dirtyHandlers []DirtyHandler
+	runFailure    *MigrationFailure // the diagnostics of the failed migration of the current run
+	runReports    []MigrationReport // the migrations of the current run, see WithReportFile
+	runReport     bool              // the lock was acquired by a migration run, whose report is written by Unlock
+
+	ownsClient bool            // the client was opened by the driver and is closed by Close
+	statements *statementCache // prepared statements of the version updates, nil if not created by NewDriver
+	charsets   *pinnedCharsets // original charsets of the migration sessions, nil if not created by NewDriver
+
+	store VersionStore // a custom version store, nil for the migrations table
+
+	guardedSource     lightmigrate.MigrationSource // the source of WithSourceGuard
+	sourceFingerprint *SourceFingerprint           // the fingerprint of the guarded source, computed by NewDriver
 }
 
+// Driver is the MySQL/MariaDB migration driver. Besides the lightmigrate.MigrationDriver interface, it provides
+// helpers to inspect the migration state of the database.
+type Driver interface {
+	lightmigrate.MigrationDriver
+
+	// Healthy pings the server and verifies that the migration table is reachable and not dirty. If an
+	// expected version was configured (see WithExpectedVersion), the current version must match it.
+	Healthy(ctx context.Context) error
+
+	// AssertVersion verifies that the schema is at the expected version and not dirty, e.g. at application startup.
+	// A mismatch is reported as *VersionAssertionError, see ErrSchemaBehind, ErrSchemaAhead and
+	// lightmigrate.ErrDatabaseDirty.
+	AssertVersion(expected uint64) error
+
+	// Status reports the current migration state of the database.
+	Status(ctx context.Context) (*Status, error)
+
+	// RunMigrationWithResult applies a single migration, like RunMigration, and reports what was executed.
+	RunMigrationWithResult(migration io.Reader) (*MigrationResult, error)
+
+	// Namespace returns a driver for another, independently versioned migration namespace of the same database.
+	Namespace(namespace string) (Driver, error)
+
+	// AppliedVersions returns the migration history, see WithHistory.
+	AppliedVersions(ctx context.Context) ([]AppliedVersion, error)
+
+	// VersionAt returns the schema version that was live at the given time, see WithHistory.
+	VersionAt(t time.Time) (uint64, error)
+
+	// VerifyChecksum compares the migration with the checksum that was recorded when the version was applied.
+	VerifyChecksum(ctx context.Context, version uint64, migration io.Reader) error
+
+	// MarkApplied records the version as applied without executing anything, see also the skip directive.
+	MarkApplied(version uint64, note string) error
+
+	// ImportState records the version change of a migration script that was executed manually, see NewScriptDriver.
+	ImportState(state ScriptState) error
+
+	// Repair reconciles a migration table with more than one row to the highest clean version.
+	Repair(ctx context.Context) error
+
+	// Config returns the effective configuration of the driver, after all options were applied.
+	Config() EffectiveConfig
+
+	// QuarantinedMigrations returns the migrations that failed in best-effort mode, see WithQuarantine.
+	QuarantinedMigrations(ctx context.Context) ([]QuarantinedMigration, error)
+
+	// Stats returns cumulative runtime statistics, e.g. the number of applied migrations and the lock wait time.
+	Stats() Stats
+}
+
+// Decryptor transforms the raw migration content before it is executed, e.g. to decrypt migration files.
+type Decryptor func(migration io.Reader) (io.Reader, error)
+
 // DriverOption is a function that can be used within the driver constructor to
 // modify the driver object.
 type DriverOption func(svc *driver)
 
-// NewDriver instantiates a new MongoDB driver. A MongoDB client and the database name are required arguments.
-// If you have migration file that contain multiple statements, ensure that the sql.DB was opened with
-// the multiStatements=true parameter!
-func NewDriver(client *sql.DB, database string, opts ...DriverOption) (lightmigrate.MigrationDriver, error) {
+// NewDriver instantiates a new MySQL driver. A MySQL client and the database name are required arguments.
+// If statement splitting is disabled and you have migration files that contain multiple statements, ensure that
+// the sql.DB was opened with the multiStatements=true parameter!
+func NewDriver(client *sql.DB, database string, opts ...DriverOption) (Driver, error) {
+	d, err := newDriver(client, database, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.fingerprintSource(); err != nil {
+		return nil, err
+	}
+	cfg := d.cfg
+
+	ctx, cancel := d.internalContext()
+	d.detectServer(ctx)
+	d.detectCluster(ctx)
+	d.detectReadOnly(ctx)
+	if cfg.ShardLockKey || cfg.PrimaryGuard {
+		d.detectServerIdentity(ctx)
+	}
+	cancel()
+
+	if err := d.checkReadOnly(); err != nil {
+		return nil, err
+	}
+	if err := d.checkPrimaryGuard(); err != nil {
+		return nil, err
+	}
+	if err := d.checkShardLockKey(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case cfg.LockStrategy == LockStrategyAuto && cfg.PrimaryGuard:
+		cfg.LockStrategy = LockStrategyTable
+	case cfg.LockStrategy == LockStrategyAuto:
+		cfg.LockStrategy = LockStrategyAdvisory
+	}
+	d.owner = newLockOwner()
+	if err := d.prepareLockTable(); err != nil {
+		return nil, err
+	}
+
+	if err := d.prepareMigrationTable(); err != nil {
+		return nil, err
+	}
+	if err := d.prepareNotificationTable(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// newDriver instantiates the driver with the default configuration and applies the options. The database is not
+// accessed.
+func newDriver(client *sql.DB, database string, opts []DriverOption) (*driver, error) {
 	if database == "" {
 		return nil, ErrNoDatabaseName
 	}
@@ -39,25 +189,64 @@ func NewDriver(client *sql.DB, database string, opts ...DriverOption) (lightmigr
 		return nil, ErrNoDatabaseClient
 	}
 
+	return configureDriver(client, database, opts)
+}
+
+// configureDriver creates the driver with the default configuration and applies the options. The client is nil
+// for drivers that never access a database, see NewScriptDriver.
+func configureDriver(client *sql.DB, database string, opts []DriverOption) (*driver, error) {
 	cfg := &config{
 		DatabaseName:    database,
 		MigrationsTable: DefaultMigrationsTable,
 		Locking:         true,
+		SplitStatements: true,
+		SessionCharset:  DefaultSessionCharset,
+
+		MaxParallelStatements: DefaultMaxParallelStatements,
+		LockTimeout:           DefaultLockTimeout,
+		RollbackFloor:         DefaultRollbackFloor,
 	}
 
 	d := &driver{
-		client: client,
-		cfg:    cfg,
+		client:     client,
+		cfg:        cfg,
+		logger:     log.Default(),
+		features:   newFeatureSet(),
+		stats:      newStatsRecorder(),
+		statements: newStatementCache(),
+		charsets:   newPinnedCharsets(),
 	}
 
 	for _, opt := range opts {
 		opt(d)
 	}
 
-	err := d.prepareMigrationTable()
+	if err := d.validate(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// NewDriverFromDSN opens a database client for the DSN (see github.com/go-sql-driver/mysql) and instantiates a new
+// MySQL driver for the database of the DSN. The client is owned by the driver and is closed by Close.
+func NewDriverFromDSN(dsn string, opts ...DriverOption) (Driver, error) {
+	dsnCfg, err := gomysql.ParseDSN(dsn)
 	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+
+	client, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database client: %w", err)
+	}
+
+	d, err := NewDriver(client, dsnCfg.DBName, opts...)
+	if err != nil {
+		_ = client.Close()
 		return nil, err
 	}
+	d.(*driver).ownsClient = true
 
 	return d, nil
 }
@@ -83,6 +272,29 @@ func WithMigrationTable(migrationTable string) DriverOption {
 	}
 }
 
+// WithLockStrategy selects how concurrent migration processes are coordinated. Defaults to LockStrategyAuto.
+func WithLockStrategy(strategy LockStrategy) DriverOption {
+	return func(d *driver) {
+		d.cfg.LockStrategy = strategy
+	}
+}
+
+// WithLockTTL sets the time after which a lock of LockStrategyTable expires, if the process that holds the lock
+// stopped its heartbeat (e.g. because it crashed). Defaults to DefaultLockTTL.
+func WithLockTTL(ttl time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.LockTTL = ttl
+	}
+}
+
+// WithTablePrefix sets a prefix that is prepended to the names of all tables of the driver (e.g. "myapp_"),
+// so that multiple applications can share one database. The prefix is also part of the lock key.
+func WithTablePrefix(prefix string) DriverOption {
+	return func(d *driver) {
+		d.cfg.TablePrefix = prefix
+	}
+}
+
 // WithLocking can be used to configure the locking behaviour of the MongoDB migration driver.
 func WithLocking(lockingEnabled bool) DriverOption {
 	return func(d *driver) {
@@ -90,145 +302,368 @@ func WithLocking(lockingEnabled bool) DriverOption {
 	}
 }
 
-func (d *driver) Close() error {
-	return nil // nothing to clean up
+// WithStatementSplitting configures if the migration files are split into single statements by the driver.
+// If disabled, each migration file is sent to the server as a whole. In this case, migrations with multiple
+// statements require the sql.DB to be opened with the multiStatements=true parameter.
+func WithStatementSplitting(splitStatements bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.SplitStatements = splitStatements
+	}
 }
 
-func (d *driver) Lock() error {
-	if !d.cfg.Locking {
-		return nil
+// WithDecryptor sets a function that is applied to the content of each migration before it gets executed.
+// This allows to store migration files with sensitive content (e.g. seed data) in an encrypted form.
+func WithDecryptor(decryptor Decryptor) DriverOption {
+	return func(d *driver) {
+		d.decryptor = decryptor
 	}
+}
 
-	// check if already locked, if not, lock
-	if !atomic.CompareAndSwapInt32(&d.reentrantLockFlag, 0, 1) {
-		return nil // no swap happened, already locked
+// WithIncludeFS sets the filesystem that is used to resolve include directives within migration files.
+// Migrations can include other files with the mysql-CLI style "source other_file.sql" command or with
+// a "-- lightmigrate:include other_file.sql" directive. Paths are resolved relative to the root of fsys.
+func WithIncludeFS(fsys fs.FS) DriverOption {
+	return func(d *driver) {
+		d.includeFS = fsys
 	}
+}
 
-	lockKey := d.getLockingKey()
-	query := "SELECT GET_LOCK(?, 5)" // 5 second timeout
-	var success bool
-	if err := d.client.QueryRowContext(context.Background(), query, lockKey).Scan(&success); err != nil {
-		atomic.StoreInt32(&d.reentrantLockFlag, 0) // restore unlock flag
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "try lock failed", Query: []byte(query)}
+// WithMaxParallelStatements sets the maximum number of statements that are executed concurrently within
+// a "-- lightmigrate:parallel" block. Each concurrently executed statement uses its own connection.
+func WithMaxParallelStatements(maxParallel int) DriverOption {
+	return func(d *driver) {
+		d.cfg.MaxParallelStatements = maxParallel
 	}
+}
 
-	if !success {
-		atomic.StoreInt32(&d.reentrantLockFlag, 0) // restore unlock flag
-		return ErrDatabaseLocked
+// WithDirtyRetry configures the automatic retry of dirty migrations. If a previous run left the database dirty
+// and the failed migration was marked with the "-- lightmigrate:idempotent" directive, the driver reports the
+// last clean version instead of the dirty state, so that the migration gets re-applied.
+func WithDirtyRetry(policy DirtyRetryPolicy) DriverOption {
+	return func(d *driver) {
+		d.cfg.DirtyRetry = policy
 	}
+}
 
-	return nil
+// WithExpectedVersion sets the schema version that is expected by the application. It is used by the
+// health check. A value of 0 disables the version check.
+func WithExpectedVersion(version uint64) DriverOption {
+	return func(d *driver) {
+		d.cfg.ExpectedVersion = version
+	}
 }
 
-func (d *driver) Unlock() error {
-	if !d.cfg.Locking {
-		return nil
+// WithDefaultQueryTimeout sets a timeout that applies to all driver-internal bookkeeping queries (version reads
+// and writes, table creation, lock release). Migration statements are not affected, see WithStatementTimeout.
+// The lock query may additionally wait for the lock timeout, see WithLockTimeout.
+// A value of 0 disables the timeout.
+func WithDefaultQueryTimeout(timeout time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.QueryTimeout = timeout
 	}
+}
 
-	// check if already unlocked, if not, unlock
-	if !atomic.CompareAndSwapInt32(&d.reentrantLockFlag, 1, 0) {
-		return nil // no swap happened, already unlocked
+// WithLockTimeout sets the time the driver waits for the migration lock, before ErrDatabaseLocked is returned.
+// The timeout is rounded up to whole seconds. A value of 0 tries to get the lock only once, a negative value
+// waits forever. Defaults to DefaultLockTimeout.
+func WithLockTimeout(timeout time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.LockTimeout = timeout
 	}
+}
 
-	lockKey := d.getLockingKey()
-	query := "SELECT RELEASE_LOCK(?)" // 5 second timeout
-	if _, err := d.client.ExecContext(context.Background(), query, lockKey); err != nil {
-		atomic.StoreInt32(&d.reentrantLockFlag, 1) // restore lock flag
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "release lock failed", Query: []byte(query)}
+// WithStatementTimeout sets a timeout for each single statement of a migration. If a statement exceeds the
+// timeout, it is stopped on the server with KILL QUERY and the migration fails. A value of 0 disables the timeout.
+func WithStatementTimeout(timeout time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.StatementTimeout = timeout
 	}
+}
 
-	// NOTE: RELEASE_LOCK could return NULL or (or 0 if the code is changed),
-	// in which case isLocked should be true until the timeout expires -- synchronizing
-	// these states is likely not worth trying to do; reconsider the necessity of isLocked.
+// WithLockWaitObserver sets a function that is called with the time spent waiting for the migration lock.
+// This can be used to feed metrics systems, e.g. to monitor contention between concurrently deploying replicas.
+func WithLockWaitObserver(observer LockWaitObserver) DriverOption {
+	return func(d *driver) {
+		d.lockWaitObserver = observer
+	}
+}
 
-	return nil
+// WithLockWaitTimeouts sets the session variables innodb_lock_wait_timeout (row locks) and lock_wait_timeout
+// (metadata locks) on the connections that execute the migrations. This lets a migration that is blocked by
+// application queries fail fast instead of hanging. The timeouts are rounded up to whole seconds,
+// zero values keep the server defaults.
+func WithLockWaitTimeouts(innodb, metadata time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.InnoDBLockWaitTimeout = innodb
+		d.cfg.MetadataLockWaitTimeout = metadata
+	}
 }
 
-func (d *driver) GetVersion() (version uint64, dirty bool, err error) {
-	query := "SELECT version, dirty FROM `" + d.cfg.MigrationsTable + "` LIMIT 1"
-	err = d.client.QueryRowContext(context.Background(), query).Scan(&version, &dirty)
-	switch {
-	case err == sql.ErrNoRows:
-		return lightmigrate.NoMigrationVersion, false, nil
+// WithMaxAffectedRows sets the maximum number of rows a single migration statement may change. Statements that
+// exceed the limit are logged, in strict mode they fail the migration (see WithStrictMode). Only DML statements
+// (INSERT, UPDATE, DELETE, REPLACE) are checked. A value of 0 disables the check.
+func WithMaxAffectedRows(limit int64) DriverOption {
+	return func(d *driver) {
+		d.cfg.MaxAffectedRows = limit
+	}
+}
 
-	case err != nil:
-		return 0, false, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to select version", Query: []byte(query)}
-	default:
-		return version, dirty, nil
+// WithStrictMode turns the warnings of the safety checks (e.g. WithMaxAffectedRows) into errors. Note that the
+// offending statement was already executed, unless it is part of a transaction within the migration.
+func WithStrictMode(strict bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.Strict = strict
 	}
 }
 
-func (d *driver) SetVersion(version uint64, dirty bool) error {
-	tx, err := d.client.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
-	if err != nil {
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "transaction start failed"}
+// WithWarningsCapture enables the collection of server warnings (SHOW WARNINGS) after each migration statement.
+// The warnings are reported by RunMigrationWithResult and logged if verbose logging is enabled.
+func WithWarningsCapture(capture bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.CaptureWarnings = capture
 	}
+}
 
-	// Delete all entries in the migrations table.
-	query := "DELETE FROM `" + d.cfg.MigrationsTable + "`"
-	if _, err := tx.ExecContext(context.Background(), query); err != nil {
-		if errRollback := tx.Rollback(); errRollback != nil {
-			origMsg := fmt.Sprintf("failed rollback for previous error: %v", err)
-			return &lightmigrate.DriverError{OrigErr: err, Msg: origMsg, Query: []byte(query)}
+// WithAtomicDDLRecovery enables the recovery of migrations that failed with an atomically rolled back DDL
+// statement (MySQL 8.0, MariaDB 10.6). If the first statement of a migration fails and the server rolled it back
+// atomically, the database is unchanged, so the last clean version is restored instead of marking it dirty.
+func WithAtomicDDLRecovery(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.AtomicDDLRecovery = enabled
+	}
+}
+
+// Close releases the migration lock if it is still held, stops the lock heartbeat and closes the database client,
+// if it was opened by the driver (see NewDriverFromDSN). The driver may not be used afterwards.
+func (d *driver) Close() error {
+	var err error
+	if atomic.LoadInt32(&d.reentrantLockFlag) == 1 {
+		err = d.Unlock()
+	}
+	d.stopHeartbeat()
+	if d.statements != nil {
+		if closeErr := d.statements.close(); closeErr != nil && err == nil {
+			err = &lightmigrate.DriverError{OrigErr: closeErr, Msg: "failed to close prepared statements"}
 		}
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to clean migration table", Query: []byte(query)}
 	}
 
-	query = "INSERT INTO `" + d.cfg.MigrationsTable + "` (version, dirty) VALUES (?, ?)"
-	if _, err := tx.ExecContext(context.Background(), query, version, dirty); err != nil {
-		if errRollback := tx.Rollback(); errRollback != nil {
-			origMsg := fmt.Sprintf("failed rollback for previous error: %v", err)
-			return &lightmigrate.DriverError{OrigErr: err, Msg: origMsg, Query: []byte(query)}
+	if d.ownsClient {
+		if closeErr := d.client.Close(); closeErr != nil && err == nil {
+			err = &lightmigrate.DriverError{OrigErr: closeErr, Msg: "failed to close database client"}
+		}
+	}
+
+	return err
+}
+
+func (d *driver) GetVersion() (version uint64, dirty bool, err error) {
+	defer d.recoverVersion(d.runningVersion, &err)
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	if d.store == nil {
+		// only the migration run repairs the migration table, read-only callers fail on a corrupt table
+		version, dirty, err = tableVersionStore{d: d, repair: true}.Get(ctx)
+	} else {
+		version, dirty, err = d.store.Get(ctx)
+	}
+	if err == nil && !dirty {
+		d.knownVersion, d.versionKnown = version, true
+	}
+	return version, dirty, err
+}
+
+func (d *driver) SetVersion(version uint64, dirty bool) (err error) {
+	defer d.recordRunError(&err)
+	defer d.recoverVersion(version, &err)
+
+	d.faults.beforeSetVersion()
+
+	if err := d.checkLockSkipped(); err != nil {
+		return err
+	}
+	if err := d.checkLockLost(); err != nil {
+		return err
+	}
+	if dirty {
+		if err := d.checkRollbackFloor(version); err != nil {
+			return err
+		}
+		if err := d.checkRunDeadlineBeforeMigration(version); err != nil {
+			return err
+		}
+		if err := d.checkSource(); err != nil {
+			return err
+		}
+		if err := d.startRunHooks(); err != nil {
+			return err
 		}
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to update migration table", Query: []byte(query)}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "transaction commit failed"}
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	if err := d.versionStore().Set(ctx, version, dirty); err != nil {
+		return err
+	}
+	if dirty {
+		// the migrator only passes the target version, a target below the current version is a down migration
+		d.runningVersion, d.runningDirection = version, lightmigrate.Up
+		if d.versionKnown && version < d.knownVersion {
+			d.runningDirection = lightmigrate.Down
+		}
+	} else {
+		d.knownVersion, d.versionKnown = version, true
+	}
+	if d.runActive {
+		d.runVersion, d.runDirty = version, dirty
 	}
 
 	return nil
 }
 
 func (d *driver) RunMigration(migration io.Reader) error {
-	migr, err := ioutil.ReadAll(migration)
+	return d.runMigration(migration, &migrationState{})
+}
+
+// runMigration decrypts and executes the migration, the progress is stored in the state.
+func (d *driver) runMigration(migration io.Reader, state *migrationState) (err error) {
+	if err := d.checkLockSkipped(); err != nil {
+		return err
+	}
+
+	begin := time.Now()
+	defer func() {
+		failure := err
+		if failure == nil {
+			failure = state.Quarantined
+		}
+		d.stats.recordMigration(state.Result.statements(), time.Since(begin), failure)
+		d.reportMigration(state, time.Since(begin), failure)
+	}()
+	defer d.recoverMigration(state, &err)
+
+	if d.decryptor != nil {
+		decrypted, err := d.decryptor(migration)
+		if err != nil {
+			d.recordFailure(newMigrationFailure(state, err))
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to decrypt migration"}
+		}
+		migration = decrypted
+	}
+	if d.cfg.History || d.cfg.ReportFile != "" {
+		// the checksum covers the migration as stored, before its encoding is normalized
+		state.Checksum = newChecksum(d.cfg.ChecksumAlgorithm, d.cfg.ChecksumNormalization)
+		migration = io.TeeReader(migration, state.Checksum)
+	}
+	migration = normalizeEncoding(migration)
+	var release func()
+	migration, release, err = d.spool(migration)
+	if err != nil {
+		d.recordFailure(newMigrationFailure(state, err))
+		return err
+	}
+	defer release()
+	if d.cfg.History || d.cfg.ReportFile != "" {
+		state.Description, migration = parseDescription(migration)
+	}
+
+	started := time.Now()
+	session, err := d.openSession(context.Background())
 	if err != nil {
+		d.recordFailure(newMigrationFailure(state, err))
+		d.recordHistory(state, started, err)
 		return err
 	}
 
-	query := string(migr[:]) // each line is a query
-	if _, err := d.client.ExecContext(context.Background(), query); err != nil {
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "migration failed", Query: migr}
+	state.Session = session
+	if d.cfg.SplitStatements {
+		stream := newStatementStream(migration, d.includeFS)
+		err = d.runStatements(stream, state)
+		stream.Close()
+	} else {
+		err = d.runUnsplitMigration(session, migration, state)
+	}
+	if state.Session != nil {
+		d.releaseTableLocks(state)
+		d.closeSession(state.Session)
+		state.Session = nil
+	}
+
+	if state.ResumeLock != nil {
+		if lockErr := state.ResumeLock(); lockErr != nil && err == nil {
+			err = lockErr
+		}
+		state.ResumeLock = nil
+	}
+
+	d.recordHistory(state, started, err)
+	d.recordObjects(state)
+	if err != nil {
+		if !d.recoverAtomicDDL(state) && !d.recoverRefusal(state) {
+			d.recordFailure(newMigrationFailure(state, err))
+		}
+		return d.quarantine(state, err)
 	}
 
 	return nil
 }
 
-func (d *driver) Reset() error {
-	// Delete all entries in the migrations table.
-	query := "DROP TABLE IF EXISTS `" + d.cfg.MigrationsTable + "`"
-	if _, err := d.client.ExecContext(context.Background(), query); err != nil {
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed drop migration table", Query: []byte(query)}
+// runUnsplitMigration sends the whole migration to the server within one query.
+// Directives are not supported in this mode, except for the skip directive.
+func (d *driver) runUnsplitMigration(session *sql.Conn, migration io.Reader, state *migrationState) error {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if _, err := buf.ReadFrom(migration); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read migration"}
 	}
+	if arg, line, leading, ok := unsplitSkipDirective(buf.String()); ok {
+		index := 0
+		if !leading {
+			index = 1 // rejected by skipMigration, the migration must not be executed partially
+		}
+		return d.skipMigration(state, index, arg, line)
+	}
+
+	stmt := statement{Query: buf.String(), Index: 1, Line: 1}
+	if err := d.execStatement(context.Background(), session, state, stmt); err != nil {
+		state.Failed = &stmt
+		return err
+	}
+
 	return nil
 }
 
-// Generate a unique locking key for the given database.
-// The key will be derived from the database name only.
-func (d *driver) getLockingKey() string {
-	sum := crc32.ChecksumIEEE([]byte(d.cfg.DatabaseName))
-	sum = sum * uint32(advisoryLockIDSalt)
+func (d *driver) Reset() error {
+	ctx, cancel := d.internalContext()
+	defer cancel()
 
-	return fmt.Sprint(sum)
+	if d.store != nil {
+		return d.store.Set(ctx, lightmigrate.NoMigrationVersion, false)
+	}
+
+	// Delete all entries in the migrations table.
+	query := "DROP TABLE IF EXISTS `" + d.migrationsTable() + "`"
+	if _, err := d.client.ExecContext(ctx, query); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed drop migration table", Query: []byte(query)}
+	}
+	return nil
 }
 
-// prepareMigrationTable will create the migration table if it does not exist.
+// prepareMigrationTable will create the migration table if it does not exist. Nothing is created, if a custom
+// version store is used.
 func (d *driver) prepareMigrationTable() (err error) {
-	if err = d.Lock(); err != nil {
+	if d.store != nil {
+		return nil
+	}
+	unlock, err := d.lockOperation()
+	if err != nil {
 		return err
 	}
 	defer func() {
-		if e := d.Unlock(); e != nil {
+		if e := unlock(); e != nil {
 			if err == nil {
 				err = e
 			} else {
@@ -237,9 +672,78 @@ func (d *driver) prepareMigrationTable() (err error) {
 		}
 	}()
 
-	query := "CREATE TABLE IF NOT EXISTS `" + d.cfg.MigrationsTable + "` (version bigint not null primary key, dirty boolean not null)"
-	if _, err := d.client.ExecContext(context.Background(), query); err != nil {
-		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed create migration table", Query: []byte(query)}
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	columns := d.withExtraColumns(versionTableColumns)
+	if err := d.createTable(ctx, d.migrationsTable(), columns, "failed create migration table"); err != nil {
+		return err
+	}
+
+	// tables created by older releases might miss some columns
+	if err := d.ensureColumns(ctx, d.migrationsTable(), columns); err != nil {
+		return err
+	}
+
+	if err := d.prepareHistoryTable(ctx); err != nil {
+		return err
+	}
+	if err := d.checkTableDefinitions(ctx); err != nil {
+		return err
+	}
+
+	if err := d.prepareQuarantineTable(ctx); err != nil {
+		return err
+	}
+	if err := d.prepareObjectTable(ctx); err != nil {
+		return err
+	}
+	return d.prepareSourceTable(ctx)
+}
+
+// tableName returns the name of a driver table, including the configured table prefix.
+func (d *driver) tableName(name string) string {
+	return d.cfg.TablePrefix + name
+}
+
+// migrationsTable returns the name of the migrations table, including the configured table prefix and the
+// migration namespace.
+func (d *driver) migrationsTable() string {
+	if d.cfg.Namespace != "" {
+		return d.tableName(d.cfg.MigrationsTable + "_" + d.cfg.Namespace)
+	}
+	return d.tableName(d.cfg.MigrationsTable)
+}
+
+// sideContext returns the context for the queries that run next to a migration statement (e.g. KILL QUERY), they
+// may have to wait for a free connection of the pool. Without a query timeout, sideQueryTimeout is used.
+func (d *driver) sideContext() (context.Context, context.CancelFunc) {
+	if d.cfg.QueryTimeout <= 0 {
+		return context.WithTimeout(context.Background(), sideQueryTimeout)
+	}
+	return context.WithTimeout(context.Background(), d.cfg.QueryTimeout)
+}
+
+// internalContext returns the context for driver-internal queries. If a default query timeout was configured,
+// the context is bounded by this timeout.
+func (d *driver) internalContext() (context.Context, context.CancelFunc) {
+	if d.cfg.QueryTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), d.cfg.QueryTimeout)
+}
+
+// statementContext returns the context for a single migration statement, bounded by the statement timeout.
+func (d *driver) statementContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if d.cfg.StatementTimeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, d.cfg.StatementTimeout)
+}
+
+// logf prints a log message, if a logger is configured.
+func (d *driver) logf(format string, v ...interface{}) {
+	if d.logger != nil {
+		d.logger.Printf(format, v...)
 	}
-	return nil
 }
@@ -1,8 +1,18 @@
 package mysql
 
 import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/h44z/lightmigrate"
 )
 
 func TestNewDriver(t *testing.T) {
@@ -23,6 +33,93 @@ func TestNewDriver_NoClient(t *testing.T) {
 	}
 }
 
+func TestWithDecryptor(t *testing.T) {
+	d := &driver{}
+
+	WithDecryptor(func(r io.Reader) (io.Reader, error) { return r, nil })(d)
+	if d.decryptor == nil {
+		t.Fatalf("failed to set decryptor")
+	}
+}
+
+func TestWithDefaultQueryTimeout(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithDefaultQueryTimeout(time.Minute)(d)
+	if d.cfg.QueryTimeout != time.Minute {
+		t.Fatalf("failed to set query timeout")
+	}
+}
+
+func Test_driver_internalContext(t *testing.T) {
+	d := &driver{cfg: &config{}}
+	ctx, cancel := d.internalContext()
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatalf("unexpected deadline for disabled timeout")
+	}
+	cancel()
+
+	d.cfg.QueryTimeout = time.Minute
+	ctx, cancel = d.internalContext()
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Minute {
+		t.Fatalf("unexpected deadline: %v", deadline)
+	}
+}
+
+func TestWithLockTimeout(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithLockTimeout(time.Minute)(d)
+	if d.cfg.LockTimeout != time.Minute {
+		t.Fatalf("failed to set lock timeout")
+	}
+}
+
+func TestWithStatementTimeout(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithStatementTimeout(time.Hour)(d)
+	if d.cfg.StatementTimeout != time.Hour {
+		t.Fatalf("failed to set statement timeout")
+	}
+}
+
+func Test_driver_statementContext(t *testing.T) {
+	d := &driver{cfg: &config{QueryTimeout: time.Second}}
+	ctx, cancel := d.statementContext(context.Background())
+	if _, ok := ctx.Deadline(); ok {
+		t.Fatalf("unexpected deadline for disabled timeout")
+	}
+	cancel()
+
+	d.cfg.StatementTimeout = time.Hour
+	ctx, cancel = d.statementContext(context.Background())
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) < time.Minute {
+		t.Fatalf("unexpected deadline: %v", deadline)
+	}
+}
+
+func TestWithDirtyRetry(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithDirtyRetry(DirtyRetryPolicy{MaxAttempts: 3})(d)
+	if d.cfg.DirtyRetry.MaxAttempts != 3 {
+		t.Fatalf("failed to set dirty retry policy")
+	}
+}
+
+func TestWithIncludeFS(t *testing.T) {
+	d := &driver{}
+
+	fsys := fstest.MapFS{}
+	WithIncludeFS(fsys)(d)
+	if d.includeFS == nil {
+		t.Fatalf("failed to set include filesystem")
+	}
+}
+
 func TestWithLocking(t *testing.T) {
 	d := &driver{cfg: &config{}}
 
@@ -41,6 +138,32 @@ func TestWithLogger(t *testing.T) {
 	}
 }
 
+func TestWithTablePrefix(t *testing.T) {
+	d := &driver{cfg: &config{MigrationsTable: DefaultMigrationsTable}}
+
+	WithTablePrefix("myapp_")(d)
+	if d.cfg.TablePrefix != "myapp_" || d.migrationsTable() != "myapp_schema_migrations" {
+		t.Fatalf("failed to set table prefix")
+	}
+}
+
+func Test_driver_RunMigration_TablePrefix(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", TablePrefix: "app_"}}
+
+	if err := d.SetVersion(2, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.Contains(query, "`migrations`") {
+			t.Fatalf("table prefix was not applied: %s", query)
+		}
+	}
+	if queries := srv.Queries(); !strings.Contains(strings.Join(queries, "\n"), "`app_migrations`") {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
 func TestWithMigrationTable(t *testing.T) {
 	d := &driver{cfg: &config{}}
 
@@ -50,6 +173,15 @@ func TestWithMigrationTable(t *testing.T) {
 	}
 }
 
+func TestWithStatementSplitting(t *testing.T) {
+	d := &driver{cfg: &config{SplitStatements: true}}
+
+	WithStatementSplitting(false)(d)
+	if d.cfg.SplitStatements != false {
+		t.Fatalf("failed to set statement splitting flag")
+	}
+}
+
 func TestWithVerboseLogging(t *testing.T) {
 	d := &driver{}
 
@@ -67,12 +199,99 @@ func Test_driver_Close(t *testing.T) {
 	}
 }
 
+func Test_driver_Close_ReleasesLock(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "db", Locking: true}, ownsClient: true}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	released := false
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "SELECT RELEASE_LOCK") {
+			released = true
+		}
+	}
+	if !released {
+		t.Fatalf("expected lock to be released, got queries: %v", srv.Queries())
+	}
+	if err := db.Ping(); err == nil {
+		t.Fatalf("expected owned client to be closed")
+	}
+	if err := d.Close(); err != nil {
+		t.Fatalf("unexpected error on second close: %v", err)
+	}
+}
+
+func TestNewDriverFromDSN_NoDatabase(t *testing.T) {
+	if _, err := NewDriverFromDSN("user:pass@tcp(localhost:3306)/"); !errors.Is(err, ErrNoDatabaseName) {
+		t.Fatalf("unexpected error %v, got: %v", ErrNoDatabaseName, err)
+	}
+	if _, err := NewDriverFromDSN("not a dsn"); err == nil {
+		t.Fatalf("expected error for invalid DSN")
+	}
+}
+
+func versionHandler(row []sqldriver.Value) fakeHandler {
+	return func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT version, dirty") {
+			resp := fakeResponse{Columns: []string{"version", "dirty", "previous_version", "idempotent", "attempts"}}
+			if row != nil {
+				resp.Rows = [][]sqldriver.Value{row}
+			}
+			return resp
+		}
+		return defaultFakeHandler(call)
+	}
+}
+
 func Test_driver_GetVersion(t *testing.T) {
+	db, _ := newFakeDB(t, versionHandler(nil))
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations"}}
+
+	version, dirty, err := d.GetVersion()
+	if err != nil || version != lightmigrate.NoMigrationVersion || dirty {
+		t.Fatalf("unexpected version 0 (clean), got: %d (dirty: %t), %v", version, dirty, err)
+	}
 
+	db, _ = newFakeDB(t, versionHandler([]sqldriver.Value{int64(3), int64(1), int64(2), int64(1), int64(1)}))
+	d = &driver{client: db, cfg: &config{MigrationsTable: "migrations"}}
+
+	version, dirty, err = d.GetVersion()
+	if err != nil || version != 3 || !dirty {
+		t.Fatalf("unexpected version 3 (dirty), got: %d (dirty: %t), %v", version, dirty, err)
+	}
 }
 
-func Test_driver_Lock(t *testing.T) {
+func Test_driver_GetVersion_DirtyRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		row         []sqldriver.Value
+		wantVersion uint64
+		wantDirty   bool
+	}{
+		{"idempotent", []sqldriver.Value{int64(3), int64(1), int64(2), int64(1), int64(1)}, 2, false},
+		{"not idempotent", []sqldriver.Value{int64(3), int64(1), int64(2), int64(0), int64(1)}, 3, true},
+		{"unknown", []sqldriver.Value{int64(3), int64(1), int64(2), nil, int64(1)}, 3, true},
+		{"attempts exceeded", []sqldriver.Value{int64(3), int64(1), int64(2), int64(1), int64(2)}, 3, true},
+		{"clean", []sqldriver.Value{int64(3), int64(0), nil, nil, nil}, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, versionHandler(tt.row))
+			d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", DirtyRetry: DirtyRetryPolicy{MaxAttempts: 2}}}
 
+			version, dirty, err := d.GetVersion()
+			if err != nil || version != tt.wantVersion || dirty != tt.wantDirty {
+				t.Fatalf("unexpected version %d (dirty: %t), got: %d (dirty: %t), %v", tt.wantVersion, tt.wantDirty,
+					version, dirty, err)
+			}
+		})
+	}
 }
 
 func Test_driver_Reset(t *testing.T) {
@@ -80,28 +299,119 @@ func Test_driver_Reset(t *testing.T) {
 }
 
 func Test_driver_RunMigration(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+
+	err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int);\n-- comment\nINSERT INTO a VALUES (1);"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
+	want := []string{"CREATE TABLE a (id int)", "INSERT INTO a VALUES (1)"}
+	if got := srv.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected queries %q, got: %q", want, got)
+	}
 }
 
-func Test_driver_SetVersion(t *testing.T) {
+func Test_driver_RunMigration_NoSplit(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: false}}
+
+	migration := "CREATE TABLE a (id int);\nINSERT INTO a VALUES (1);"
+	if err := d.RunMigration(strings.NewReader(migration)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := srv.Queries(); !reflect.DeepEqual(got, []string{migration}) {
+		t.Fatalf("unexpected queries %q, got: %q", migration, got)
+	}
+}
+
+func Test_driver_RunMigration_Decryptor(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+	d.decryptor = func(r io.Reader) (io.Reader, error) {
+		encrypted, _ := io.ReadAll(r)
+		return strings.NewReader(strings.ToUpper(string(encrypted))), nil
+	}
+
+	if err := d.RunMigration(strings.NewReader("select 1;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := srv.Queries(); !reflect.DeepEqual(got, []string{"SELECT 1"}) {
+		t.Fatalf("unexpected queries [SELECT 1], got: %q", got)
+	}
+
+	errDecrypt := errors.New("bad key")
+	d.decryptor = func(r io.Reader) (io.Reader, error) { return nil, errDecrypt }
+	if err := d.RunMigration(strings.NewReader("select 1;")); !errors.Is(err, errDecrypt) {
+		t.Fatalf("unexpected error %v, got: %v", errDecrypt, err)
+	}
+}
 
+func Test_driver_RunMigration_Include(t *testing.T) {
+	errFailed := errors.New("syntax error")
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "BROKEN") {
+			return fakeResponse{Err: errFailed}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+	d.includeFS = fstest.MapFS{"part.sql": {Data: []byte("SELECT 1;\nBROKEN;")}}
+
+	err := d.RunMigration(strings.NewReader("SELECT 0;\nsource part.sql"))
+	var driverErr *lightmigrate.DriverError
+	if !errors.As(err, &driverErr) {
+		t.Fatalf("expected driver error, got: %v", err)
+	}
+	if driverErr.Line != 2 || !strings.Contains(driverErr.Msg, "part.sql") {
+		t.Fatalf("unexpected driver error: %v", driverErr)
+	}
 }
 
-func Test_driver_Unlock(t *testing.T) {
+func Test_driver_RunMigration_Error(t *testing.T) {
+	errFailed := errors.New("syntax error")
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "BROKEN") {
+			return fakeResponse{Err: errFailed}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
 
+	err := d.RunMigration(strings.NewReader("SELECT 1;\nBROKEN;"))
+	var driverErr *lightmigrate.DriverError
+	if !errors.As(err, &driverErr) {
+		t.Fatalf("expected driver error, got: %v", err)
+	}
+	if driverErr.Line != 2 || string(driverErr.Query) != "BROKEN" || !errors.Is(err, errFailed) {
+		t.Fatalf("unexpected driver error: %v", driverErr)
+	}
 }
 
-func Test_driver_getLockingKey(t *testing.T) {
-	d := &driver{cfg: &config{DatabaseName: "testdb"}}
-	key := d.getLockingKey()
-	if key != "2584668960" {
-		t.Fatalf("unexpected key 2584668960, got: %s", key)
+func Test_driver_SetVersion(t *testing.T) {
+	db, srv := newFakeDB(t, versionHandler([]sqldriver.Value{int64(1), int64(0), nil, nil, nil}))
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations"}}
+
+	if err := d.SetVersion(2, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
 
-	d = &driver{cfg: &config{DatabaseName: "testdb2"}}
-	key = d.getLockingKey()
-	if key != "2083671126" {
-		t.Fatalf("unexpected key 2083671126, got: %s", key)
+	calls := srv.Calls()
+	queries := srv.Queries()
+	want := []string{
+		"BEGIN",
+		"SELECT version, dirty, previous_version, idempotent, attempts FROM `migrations` FOR UPDATE",
+		"DELETE FROM `migrations`",
+		"INSERT INTO `migrations` (version, dirty, previous_version, attempts) VALUES (?, ?, ?, ?)",
+		"COMMIT",
+	}
+	if !reflect.DeepEqual(queries, want) {
+		t.Fatalf("unexpected queries %q, got: %q", want, queries)
+	}
+	if args := calls[3].Args; !reflect.DeepEqual(args, []sqldriver.Value{int64(2), true, int64(1), int64(1)}) {
+		t.Fatalf("unexpected insert arguments: %v", args)
 	}
 }
 
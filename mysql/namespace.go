@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var namespacePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// WithNamespace sets the migration namespace of the driver (e.g. "core" or "analytics"). Each namespace has its
+// own migrations table (the namespace is appended to the table name) and its own lock, so different parts of
+// the schema can be versioned independently. An empty namespace uses the default migrations table.
+func WithNamespace(namespace string) DriverOption {
+	return func(d *driver) {
+		d.cfg.Namespace = namespace
+	}
+}
+
+// Namespace returns a driver for another migration namespace of the same database, see WithNamespace.
+// The namespace driver is created by NewDriver on the same client, with the configuration, the logger, the
+// observers and the notifiers of this driver. The source guard is not inherited, as the namespace has its own
+// migrations. The internal tables of the namespace are created if needed. Closing the namespace driver does not
+// close the database client.
+func (d *driver) Namespace(namespace string) (Driver, error) {
+	if err := validateNamespace(namespace); err != nil {
+		return nil, err
+	}
+	if d.store != nil {
+		return nil, fmt.Errorf("%w: namespaces require the default version store", ErrInvalidNamespace)
+	}
+
+	return NewDriver(d.client, d.cfg.DatabaseName, d.namespaceOption(namespace))
+}
+
+// namespaceOption applies the configuration of the driver with the given namespace to a new driver.
+func (d *driver) namespaceOption(namespace string) DriverOption {
+	return func(ns *driver) {
+		cfg := *d.cfg
+		cfg.Namespace = namespace
+		ns.cfg = &cfg
+
+		ns.logger, ns.verbose = d.logger, d.verbose
+		ns.decryptor, ns.includeFS = d.decryptor, d.includeFS
+		ns.infileFS, ns.infileReaders = d.infileFS, d.infileReaders
+		ns.lockWaitObserver, ns.progressObserver = d.lockWaitObserver, d.progressObserver
+		ns.faults = d.faults
+		ns.notifiers, ns.dirtyHandlers = d.notifiers, d.dirtyHandlers
+	}
+}
+
+// validateNamespace verifies that the namespace can be used as part of a table name.
+func validateNamespace(namespace string) error {
+	if namespace != "" && !namespacePattern.MatchString(namespace) {
+		return fmt.Errorf("%w: %q, only lower case letters, digits and underscores are allowed", ErrInvalidNamespace,
+			namespace)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestWithNamespace(t *testing.T) {
+	d := &driver{cfg: &config{MigrationsTable: DefaultMigrationsTable}}
+
+	WithNamespace("core")(d)
+	if d.cfg.Namespace != "core" || d.migrationsTable() != "schema_migrations_core" {
+		t.Fatalf("failed to set namespace")
+	}
+}
+
+func TestNewDriver_InvalidNamespace(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+
+	if _, err := NewDriver(db, "testdb", WithNamespace("Core; DROP")); !errors.Is(err, ErrInvalidNamespace) {
+		t.Fatalf("unexpected error %v, got: %v", ErrInvalidNamespace, err)
+	}
+}
+
+func Test_driver_Namespace(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	logger := log.New(io.Discard, "", 0)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations", Locking: true,
+		MaxParallelStatements: DefaultMaxParallelStatements}, logger: logger, charsets: newPinnedCharsets(),
+		runningVersion: 4}
+	d.reentrantLockFlag = 1
+
+	nsDriver, err := d.Namespace("analytics")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ns := nsDriver.(*driver)
+
+	if ns.migrationsTable() != "migrations_analytics" || d.migrationsTable() != "migrations" {
+		t.Fatalf("unexpected migrations tables: %s, %s", ns.migrationsTable(), d.migrationsTable())
+	}
+	if ns.getLockingKey() == d.getLockingKey() {
+		t.Fatalf("namespaces must not share the lock")
+	}
+	if ns.reentrantLockFlag != 0 || ns.runningVersion != 0 || ns.charsets == d.charsets {
+		t.Fatalf("lock and migration state must not be shared")
+	}
+	if ns.logger != logger || ns.cfg == d.cfg || d.cfg.Namespace != "" {
+		t.Fatalf("unexpected configuration of the namespace driver")
+	}
+	if queries := srv.Queries(); !strings.Contains(strings.Join(queries, "\n"), "CREATE TABLE IF NOT EXISTS `migrations_analytics`") {
+		t.Fatalf("migrations table of namespace was not created: %q", queries)
+	}
+
+	if _, err := d.Namespace("a-b"); !errors.Is(err, ErrInvalidNamespace) {
+		t.Fatalf("unexpected error %v, got: %v", ErrInvalidNamespace, err)
+	}
+}
+
+func Test_driver_Namespace_Close(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations",
+		MaxParallelStatements: DefaultMaxParallelStatements}, ownsClient: true, statements: newStatementCache()}
+
+	nsDriver, err := d.Namespace("analytics")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ns := nsDriver.(*driver)
+	if ns.ownsClient || ns.statements == d.statements {
+		t.Fatalf("namespace driver shares the client or the statements of its parent")
+	}
+
+	if err := ns.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("client was closed by the namespace driver: %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// defaultNotifyTimeout limits the time of each notification, unless a query timeout is configured.
+const defaultNotifyTimeout = 10 * time.Second
+
+// notificationTableColumns are the columns of the notification table, see WithNotificationTable. Columns that are
+// added in later releases must be nullable, so that they can be added to existing tables.
+var notificationTableColumns = []columnDefinition{
+	{Name: "id", Definition: "bigint not null auto_increment primary key"},
+	{Name: "db_name", Definition: "varchar(64) not null"},
+	{Name: "namespace", Definition: "varchar(64) null"},
+	{Name: "version", Definition: "bigint not null"},
+	{Name: "app_id", Definition: "varchar(255) null"},
+	{Name: "notified_at", Definition: "datetime not null"},
+}
+
+// Notification describes a successful migration run, see WithNotifier.
+type Notification struct {
+	Database  string `json:"database"`
+	Namespace string `json:"namespace,omitempty"`
+	// Version is the schema version after the run.
+	Version uint64 `json:"version"`
+	// AppID identifies the application that applied the migrations, see WithNotificationAppID.
+	AppID     string    `json:"app_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Notifier is called after a successful migration run that applied at least one migration, once the migration
+// lock was released. Runs with a failure are not notified, also if the database stayed clean (e.g. the run deadline
+// passed before the next migration, or a migration was quarantined). Errors are logged, they do not fail the run.
+type Notifier func(ctx context.Context, notification Notification) error
+
+// WithNotifier adds a notifier that is called after each successful migration run, e.g. to invalidate caches.
+func WithNotifier(notifier Notifier) DriverOption {
+	return func(d *driver) {
+		d.notifiers = append(d.notifiers, notifier)
+	}
+}
+
+// WithNotificationTable inserts a row (database, namespace, version, app id and timestamp) into the given table
+// after each successful migration run, so that downstream services can react to schema changes without polling
+// the migrations table. The table is created if it does not exist, the table prefix is applied like for the other
+// tables of the driver (see WithTablePrefix).
+func WithNotificationTable(table string) DriverOption {
+	return func(d *driver) {
+		d.cfg.NotificationTable = table
+	}
+}
+
+// WithWebhook posts the Notification as JSON to the URL after each successful migration run.
+func WithWebhook(url string) DriverOption {
+	return func(d *driver) {
+		d.cfg.WebhookURL = url
+	}
+}
+
+// WithNotificationAppID sets the application identifier of the notifications.
+func WithNotificationAppID(appID string) DriverOption {
+	return func(d *driver) {
+		d.cfg.NotificationAppID = appID
+	}
+}
+
+// notificationTable returns the name of the notification table, including the configured table prefix.
+func (d *driver) notificationTable() string {
+	return d.tableName(d.cfg.NotificationTable)
+}
+
+// prepareNotificationTable creates the notification table, if it was configured.
+func (d *driver) prepareNotificationTable() error {
+	if d.cfg.NotificationTable == "" {
+		return nil
+	}
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	table := d.notificationTable()
+	if err := d.createTable(ctx, table, notificationTableColumns, "failed create notification table"); err != nil {
+		return err
+	}
+
+	return d.ensureColumns(ctx, table, notificationTableColumns)
+}
+
+// notify sends the notifications of the finished run. Failed notifications are logged.
+func (d *driver) notify() {
+	notifiers := d.notifiers
+	if d.cfg.NotificationTable != "" {
+		notifiers = append([]Notifier{d.notifyTable}, notifiers...)
+	}
+	if d.cfg.WebhookURL != "" {
+		notifiers = append([]Notifier{d.notifyWebhook}, notifiers...)
+	}
+	if len(notifiers) == 0 {
+		return
+	}
+
+	notification := Notification{
+		Database:  d.cfg.DatabaseName,
+		Namespace: d.cfg.Namespace,
+		Version:   d.runVersion,
+		AppID:     d.cfg.NotificationAppID,
+		Timestamp: time.Now(),
+	}
+	timeout := d.cfg.QueryTimeout
+	if timeout <= 0 {
+		timeout = defaultNotifyTimeout
+	}
+	for _, notifier := range notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := notifier(ctx, notification); err != nil {
+			d.logf("failed to send migration notification for version %d: %v", notification.Version, err)
+		}
+		cancel()
+	}
+}
+
+// notifyTable inserts the notification into the notification table.
+func (d *driver) notifyTable(ctx context.Context, notification Notification) error {
+	query := "INSERT INTO `" + d.notificationTable() + "` (db_name, namespace, version, app_id, notified_at) " +
+		"VALUES (?, ?, ?, ?, FROM_UNIXTIME(?))"
+	if _, err := d.client.ExecContext(ctx, query, notification.Database, nullString(notification.Namespace),
+		notification.Version, nullString(notification.AppID), notification.Timestamp.Unix()); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to insert notification", Query: []byte(query)}
+	}
+	return nil
+}
+
+// notifyWebhook posts the notification to the webhook URL.
+func (d *driver) notifyWebhook(ctx context.Context, notification Notification) error {
+	return postJSON(ctx, d.cfg.WebhookURL, notification)
+}
+
+// postJSON posts the payload as JSON to the URL, any status other than 2xx is an error.
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_driver_Unlock_Notify(t *testing.T) {
+	var received []Notification
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		received = append(received, notification)
+	}))
+	defer webhook.Close()
+
+	db, srv := newFakeDB(t, nil)
+	var notified []Notification
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations",
+		NotificationTable: "schema_changes", WebhookURL: webhook.URL, NotificationAppID: "billing"}}
+	WithNotifier(func(_ context.Context, notification Notification) error {
+		notified = append(notified, notification)
+		return nil
+	})(d)
+
+	for _, dirty := range []bool{true, false} {
+		if err := d.SetVersion(4, dirty); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(notified) != 1 || notified[0].Version != 4 || notified[0].AppID != "billing" || notified[0].Database != "testdb" {
+		t.Fatalf("unexpected notifications: %+v", notified)
+	}
+	if len(received) != 1 || received[0].Version != 4 {
+		t.Fatalf("unexpected webhook notifications: %+v", received)
+	}
+	inserted := 0
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `schema_changes`") {
+			inserted++
+		}
+	}
+	if inserted != 1 {
+		t.Fatalf("unexpected notification rows, got: %d", inserted)
+	}
+
+	// a run without migrations and a failed run are not notified
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.SetVersion(5, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notified) != 1 {
+		t.Fatalf("unexpected notifications: %+v", notified)
+	}
+
+	// the run deadline passed before the second migration, the database is clean but the run failed
+	d.cfg.RunDeadline = time.Hour
+	for _, dirty := range []bool{true, false} {
+		if err := d.SetVersion(5, dirty); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	d.runStarted = time.Now().Add(-2 * time.Hour).UnixNano()
+	if err := d.SetVersion(6, true); !errors.Is(err, ErrRunDeadlineExceeded) {
+		t.Fatalf("unexpected error %v, got: %v", ErrRunDeadlineExceeded, err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notified) != 1 {
+		t.Fatalf("unexpected notifications: %+v", notified)
+	}
+}
+
+func Test_driver_notificationTable_Prefix(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", TablePrefix: "billing_",
+		NotificationTable: "schema_changes"}}
+
+	if err := d.prepareNotificationTable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.notifyTable(context.Background(), Notification{Database: "testdb", Version: 4}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	queries := strings.Join(srv.Queries(), "\n")
+	if !strings.Contains(queries, "CREATE TABLE IF NOT EXISTS `billing_schema_changes`") ||
+		!strings.Contains(queries, "INSERT INTO `billing_schema_changes`") {
+		t.Fatalf("table prefix was not applied: %q", srv.Queries())
+	}
+}
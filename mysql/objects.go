@@ -0,0 +1,465 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultObjectTable is the name of the schema object registry, see WithObjectTracking.
+const DefaultObjectTable = "schema_migrations_objects"
+
+// objectTableColumns are the columns of the object registry. Columns that are added in later releases
+// must be nullable, so that they can be added to existing tables.
+var objectTableColumns = []columnDefinition{
+	{Name: "id", Definition: "bigint not null auto_increment primary key"},
+	{Name: "version", Definition: "bigint not null"},
+	{Name: "statement_index", Definition: "int not null"},
+	{Name: "action", Definition: "varchar(16) not null"},
+	{Name: "object_type", Definition: "varchar(16) not null"},
+	{Name: "table_name", Definition: "varchar(255) null"},
+	{Name: "object_name", Definition: "varchar(255) not null"},
+	{Name: "recorded_at", Definition: "datetime not null"},
+}
+
+// SchemaObject is an object that was created, changed, renamed or dropped by a DDL statement, see
+// WithObjectTracking.
+type SchemaObject struct {
+	// StatementIndex is the index of the statement within the migration.
+	StatementIndex int `json:"statement_index"`
+	// Action is create, alter, rename or drop.
+	Action string `json:"action"`
+	// Type is table, column, index, constraint, view, trigger, procedure, function or event.
+	Type string `json:"type"`
+	// Table is the table of columns, indexes, constraints and triggers.
+	Table string `json:"table,omitempty"`
+	// Name is the name of the object, renamed objects are recorded with their new name.
+	Name string `json:"name"`
+}
+
+// WithObjectTracking records the objects that are created, changed, renamed or dropped by the DDL statements of
+// each migration in the object registry (schema_migrations_objects), e.g. to find the migration that introduced a
+// column. The objects are parsed from CREATE, ALTER, DROP and RENAME statements, statements that cannot be parsed
+// are not recorded. The objects are also reported by RunMigrationWithResult. Object tracking requires the default
+// version store, see WithVersionStore.
+func WithObjectTracking(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.ObjectTracking = enabled
+	}
+}
+
+// objectTable returns the name of the object registry, including the configured table prefix.
+func (d *driver) objectTable() string {
+	if d.cfg.Namespace != "" {
+		return d.tableName(DefaultObjectTable + "_" + d.cfg.Namespace)
+	}
+	return d.tableName(DefaultObjectTable)
+}
+
+// prepareObjectTable creates the object registry, if the object tracking is enabled.
+func (d *driver) prepareObjectTable(ctx context.Context) error {
+	if !d.cfg.ObjectTracking {
+		return nil
+	}
+
+	if err := d.createTable(ctx, d.objectTable(), objectTableColumns, "failed create object table"); err != nil {
+		return err
+	}
+
+	return d.ensureColumns(ctx, d.objectTable(), objectTableColumns)
+}
+
+// recordObjects inserts the objects of the executed statements into the object registry. Errors are only logged,
+// as the migration itself was already applied (or failed).
+func (d *driver) recordObjects(state *migrationState) {
+	objects := state.Result.objects()
+	if !d.cfg.ObjectTracking || len(objects) == 0 {
+		return
+	}
+
+	now := time.Now().Unix()
+	values := make([]string, len(objects))
+	args := make([]interface{}, 0, 6*len(objects))
+	for i, object := range objects {
+		values[i] = "(?, ?, ?, ?, ?, ?, FROM_UNIXTIME(?))"
+		args = append(args, d.runningVersion, object.StatementIndex, object.Action, object.Type,
+			nullString(object.Table), object.Name, now)
+	}
+	query := "INSERT INTO `" + d.objectTable() + "` (version, statement_index, action, object_type, table_name, " +
+		"object_name, recorded_at) VALUES " + strings.Join(values, ", ")
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	if _, err := d.client.ExecContext(ctx, query, args...); err != nil {
+		err = &lightmigrate.DriverError{OrigErr: err, Msg: "failed to record schema objects", Query: []byte(query)}
+		d.logf("failed to record schema objects of migration %d: %v", d.runningVersion, err)
+	}
+}
+
+// ddlToken is a word, a quoted identifier or a punctuation character of a DDL statement. String literals are
+// kept as a single token, so that their content is never mistaken for keywords.
+type ddlToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeDDL splits the statement into tokens. Comments were already removed by the statement scanner.
+func tokenizeDDL(query string) []ddlToken {
+	var tokens []ddlToken
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, ddlToken{text: word.String()})
+			word.Reset()
+		}
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '`' || c == '\'' || c == '"':
+			flush()
+			var text strings.Builder
+			for i++; i < len(query); i++ {
+				if query[i] == c && i+1 < len(query) && query[i+1] == c {
+					text.WriteByte(c)
+					i++
+					continue
+				}
+				if query[i] == c {
+					break
+				}
+				if query[i] == '\\' && c != '`' && i+1 < len(query) {
+					i++
+				}
+				text.WriteByte(query[i])
+			}
+			if c == '`' {
+				tokens = append(tokens, ddlToken{text: text.String(), quoted: true})
+			} else {
+				tokens = append(tokens, ddlToken{text: string(c)}) // string literal
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		case strings.IndexByte("(),.;=@", c) >= 0:
+			flush()
+			tokens = append(tokens, ddlToken{text: string(c)})
+		default:
+			word.WriteByte(c)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// ddlParser walks the tokens of a DDL statement.
+type ddlParser struct {
+	tokens []ddlToken
+	pos    int
+}
+
+// done checks if all tokens were consumed.
+func (p *ddlParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+// keyword returns the next token in upper case, or an empty string for quoted identifiers.
+func (p *ddlParser) keyword() string {
+	if p.done() || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return strings.ToUpper(p.tokens[p.pos].text)
+}
+
+// accept consumes the keywords, if the next tokens match all of them.
+func (p *ddlParser) accept(keywords ...string) bool {
+	for i, keyword := range keywords {
+		pos := p.pos + i
+		if pos >= len(p.tokens) || p.tokens[pos].quoted || !strings.EqualFold(p.tokens[pos].text, keyword) {
+			return false
+		}
+	}
+	p.pos += len(keywords)
+	return true
+}
+
+// name consumes an optionally schema qualified identifier.
+func (p *ddlParser) name() string {
+	if p.done() {
+		return ""
+	}
+	name := p.tokens[p.pos].text
+	p.pos++
+	for !p.done() && p.tokens[p.pos].text == "." && p.pos+1 < len(p.tokens) {
+		name += "." + p.tokens[p.pos+1].text
+		p.pos += 2
+	}
+	return name
+}
+
+// names consumes a comma separated list of identifiers.
+func (p *ddlParser) names() []string {
+	names := []string{p.name()}
+	for p.accept(",") {
+		names = append(names, p.name())
+	}
+	return names
+}
+
+// skipTo consumes the tokens up to and including the keyword, it reports false if the keyword was not found.
+func (p *ddlParser) skipTo(keyword string) bool {
+	for !p.done() {
+		if p.accept(keyword) {
+			return true
+		}
+		p.pos++
+	}
+	return false
+}
+
+// items splits the remaining tokens (or the tokens of the following parenthesized group) at the commas that are
+// not nested within parentheses.
+func (p *ddlParser) items(group bool) [][]ddlToken {
+	if group && !p.accept("(") {
+		return nil
+	}
+
+	var items [][]ddlToken
+	var item []ddlToken
+	depth := 0
+	for ; !p.done(); p.pos++ {
+		token := p.tokens[p.pos]
+		if !token.quoted {
+			switch token.text {
+			case "(":
+				depth++
+			case ")":
+				if depth == 0 && group {
+					p.pos++
+					return append(items, item)
+				}
+				depth--
+			case ",", ";":
+				if depth == 0 {
+					items, item = append(items, item), nil
+					continue
+				}
+			}
+		}
+		item = append(item, token)
+	}
+	return append(items, item)
+}
+
+// routineTypes are the object keywords of CREATE, ALTER and DROP statements besides tables and indexes.
+var routineTypes = map[string]bool{"VIEW": true, "TRIGGER": true, "PROCEDURE": true, "FUNCTION": true, "EVENT": true}
+
+// tableConstraintKeywords start the definitions within CREATE TABLE that are no columns.
+var tableConstraintKeywords = map[string]bool{"PRIMARY": true, "KEY": true, "INDEX": true, "UNIQUE": true,
+	"CONSTRAINT": true, "FOREIGN": true, "FULLTEXT": true, "SPATIAL": true, "CHECK": true, "PERIOD": true}
+
+// constraintKinds follow the optional symbol of a constraint definition.
+var constraintKinds = map[string]bool{"PRIMARY": true, "UNIQUE": true, "FOREIGN": true, "CHECK": true}
+
+// parseSchemaObjects parses the objects that are changed by a DDL statement. Unsupported statements return nil.
+func parseSchemaObjects(index int, query string) []SchemaObject {
+	p := &ddlParser{tokens: tokenizeDDL(query)}
+	var objects []SchemaObject
+	add := func(action, objectType, table, name string) {
+		if name != "" {
+			objects = append(objects, SchemaObject{StatementIndex: index, Action: action, Type: objectType,
+				Table: table, Name: name})
+		}
+	}
+
+	switch {
+	case p.accept("CREATE"):
+		p.parseCreate(add)
+	case p.accept("ALTER"):
+		p.parseAlter(add)
+	case p.accept("DROP"):
+		p.parseDrop(add)
+	case p.accept("RENAME", "TABLE"):
+		for _, item := range p.items(false) {
+			rename := &ddlParser{tokens: item}
+			rename.name()
+			if rename.accept("TO") {
+				add("rename", "table", "", rename.name())
+			}
+		}
+	}
+	return objects
+}
+
+// parseCreate parses CREATE statements, column definitions of tables are recorded as created columns.
+func (p *ddlParser) parseCreate(add func(action, objectType, table, name string)) {
+	for !p.done() { // skip modifiers like OR REPLACE, TEMPORARY, UNIQUE, ALGORITHM=... or DEFINER=...
+		switch keyword := p.keyword(); {
+		case keyword == "TABLE":
+			p.pos++
+			p.accept("IF", "NOT", "EXISTS")
+			table := p.name()
+			add("create", "table", "", table)
+			for _, item := range p.items(true) {
+				if len(item) > 0 && (item[0].quoted || !tableConstraintKeywords[strings.ToUpper(item[0].text)]) {
+					add("create", "column", table, item[0].text)
+				}
+			}
+			return
+		case keyword == "INDEX":
+			p.pos++
+			p.accept("IF", "NOT", "EXISTS")
+			name := p.name()
+			if p.skipTo("ON") {
+				add("create", "index", p.name(), name)
+			}
+			return
+		case routineTypes[keyword]:
+			p.pos++
+			p.accept("IF", "NOT", "EXISTS")
+			name := p.name()
+			table := ""
+			if keyword == "TRIGGER" && p.skipTo("ON") {
+				table = p.name()
+			}
+			add("create", strings.ToLower(keyword), table, name)
+			return
+		default:
+			p.pos++
+		}
+	}
+}
+
+// parseAlter parses ALTER statements, the changed columns, indexes and constraints of tables are recorded.
+func (p *ddlParser) parseAlter(add func(action, objectType, table, name string)) {
+	for !p.done() { // skip modifiers like ONLINE, IGNORE, ALGORITHM=... or DEFINER=...
+		switch keyword := p.keyword(); {
+		case keyword == "TABLE":
+			p.pos++
+			p.accept("IF", "EXISTS")
+			table := p.name()
+			add("alter", "table", "", table)
+			for _, item := range p.items(false) {
+				(&ddlParser{tokens: item}).parseAlterSpecification(table, add)
+			}
+			return
+		case routineTypes[keyword]:
+			p.pos++
+			add("alter", strings.ToLower(keyword), "", p.name())
+			return
+		default:
+			p.pos++
+		}
+	}
+}
+
+// parseAlterSpecification parses a single change of ALTER TABLE, e.g. ADD COLUMN or DROP INDEX.
+func (p *ddlParser) parseAlterSpecification(table string, add func(action, objectType, table, name string)) {
+	switch {
+	case p.accept("ADD"):
+		switch {
+		case p.accept("CONSTRAINT"):
+			if !constraintKinds[p.keyword()] { // the symbol is optional
+				add("create", "constraint", table, p.name())
+			}
+		case p.accept("FOREIGN", "KEY"):
+			if p.keyword() != "(" {
+				add("create", "constraint", table, p.name())
+			}
+		case p.accept("PRIMARY"), p.accept("CHECK"):
+		case p.accept("UNIQUE"), p.accept("FULLTEXT"), p.accept("SPATIAL"), p.keyword() == "INDEX",
+			p.keyword() == "KEY":
+			_ = p.accept("INDEX") || p.accept("KEY")
+			p.accept("IF", "NOT", "EXISTS")
+			if keyword := p.keyword(); keyword != "(" && keyword != "USING" && !p.done() {
+				add("create", "index", table, p.name())
+			}
+		default:
+			p.accept("COLUMN")
+			p.accept("IF", "NOT", "EXISTS")
+			if p.keyword() != "(" {
+				add("create", "column", table, p.name())
+				return
+			}
+			for _, item := range p.items(true) {
+				if len(item) > 0 {
+					add("create", "column", table, item[0].text)
+				}
+			}
+		}
+	case p.accept("MODIFY"), p.accept("ALTER"):
+		if p.accept("INDEX") {
+			add("alter", "index", table, p.name())
+			return
+		}
+		p.accept("COLUMN")
+		p.accept("IF", "EXISTS")
+		add("alter", "column", table, p.name())
+	case p.accept("CHANGE"):
+		p.accept("COLUMN")
+		p.accept("IF", "EXISTS")
+		if old, name := p.name(), p.name(); old == name {
+			add("alter", "column", table, name)
+		} else {
+			add("rename", "column", table, name)
+		}
+	case p.accept("DROP"):
+		switch {
+		case p.accept("PRIMARY"):
+		case p.accept("INDEX"), p.accept("KEY"):
+			p.accept("IF", "EXISTS")
+			add("drop", "index", table, p.name())
+		case p.accept("FOREIGN", "KEY"), p.accept("CONSTRAINT"), p.accept("CHECK"):
+			p.accept("IF", "EXISTS")
+			add("drop", "constraint", table, p.name())
+		default:
+			p.accept("COLUMN")
+			p.accept("IF", "EXISTS")
+			add("drop", "column", table, p.name())
+		}
+	case p.accept("RENAME"):
+		switch {
+		case p.accept("COLUMN"):
+			p.name()
+			if p.accept("TO") {
+				add("rename", "column", table, p.name())
+			}
+		case p.accept("INDEX"), p.accept("KEY"):
+			p.name()
+			if p.accept("TO") {
+				add("rename", "index", table, p.name())
+			}
+		default:
+			_ = p.accept("TO") || p.accept("AS")
+			add("rename", "table", "", p.name())
+		}
+	}
+}
+
+// parseDrop parses DROP statements.
+func (p *ddlParser) parseDrop(add func(action, objectType, table, name string)) {
+	p.accept("TEMPORARY")
+	switch keyword := p.keyword(); {
+	case keyword == "TABLE", keyword == "VIEW":
+		p.pos++
+		p.accept("IF", "EXISTS")
+		for _, name := range p.names() {
+			add("drop", strings.ToLower(keyword), "", name)
+		}
+	case keyword == "INDEX":
+		p.pos++
+		p.accept("IF", "EXISTS")
+		name := p.name()
+		if p.accept("ON") {
+			add("drop", "index", p.name(), name)
+		}
+	case routineTypes[keyword]:
+		p.pos++
+		p.accept("IF", "EXISTS")
+		add("drop", strings.ToLower(keyword), "", p.name())
+	}
+}
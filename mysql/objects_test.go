@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseSchemaObjects(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []SchemaObject
+	}{
+		{"CREATE TABLE IF NOT EXISTS `users` (id int, `key` varchar(16), PRIMARY KEY (id), KEY name (`key`))",
+			[]SchemaObject{{Action: "create", Type: "table", Name: "users"},
+				{Action: "create", Type: "column", Table: "users", Name: "id"},
+				{Action: "create", Type: "column", Table: "users", Name: "key"}}},
+		{"ALTER TABLE app.users ADD COLUMN email varchar(255) DEFAULT 'a, b', ADD UNIQUE INDEX email_idx (email), " +
+			"DROP COLUMN legacy, CHANGE name full_name text, RENAME INDEX a TO b, ALGORITHM=INPLACE",
+			[]SchemaObject{{Action: "alter", Type: "table", Name: "app.users"},
+				{Action: "create", Type: "column", Table: "app.users", Name: "email"},
+				{Action: "create", Type: "index", Table: "app.users", Name: "email_idx"},
+				{Action: "drop", Type: "column", Table: "app.users", Name: "legacy"},
+				{Action: "rename", Type: "column", Table: "app.users", Name: "full_name"},
+				{Action: "rename", Type: "index", Table: "app.users", Name: "b"}}},
+		{"ALTER TABLE orders ADD CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users (id), MODIFY total bigint",
+			[]SchemaObject{{Action: "alter", Type: "table", Name: "orders"},
+				{Action: "create", Type: "constraint", Table: "orders", Name: "fk_user"},
+				{Action: "alter", Type: "column", Table: "orders", Name: "total"}}},
+		{"CREATE UNIQUE INDEX idx ON users (email)",
+			[]SchemaObject{{Action: "create", Type: "index", Table: "users", Name: "idx"}}},
+		{"CREATE OR REPLACE DEFINER=`app`@`%` VIEW active AS SELECT * FROM users",
+			[]SchemaObject{{Action: "create", Type: "view", Name: "active"}}},
+		{"CREATE TRIGGER audit BEFORE INSERT ON users FOR EACH ROW SET NEW.id = 1",
+			[]SchemaObject{{Action: "create", Type: "trigger", Table: "users", Name: "audit"}}},
+		{"DROP TABLE IF EXISTS a, `b`",
+			[]SchemaObject{{Action: "drop", Type: "table", Name: "a"}, {Action: "drop", Type: "table", Name: "b"}}},
+		{"RENAME TABLE a TO b, c TO d",
+			[]SchemaObject{{Action: "rename", Type: "table", Name: "b"}, {Action: "rename", Type: "table", Name: "d"}}},
+		{"INSERT INTO users VALUES (1)", nil},
+	}
+	for _, tt := range tests {
+		if got := parseSchemaObjects(0, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("unexpected objects of %q %+v, got: %+v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func Test_driver_RunMigration_ObjectTracking(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, ObjectTracking: true}, runningVersion: 3}
+
+	result, err := d.RunMigrationWithResult(strings.NewReader(
+		"CREATE TABLE a (id int);\nINSERT INTO a VALUES (1);\nALTER TABLE a ADD COLUMN b int;"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Objects) != 4 || result.Objects[3].StatementIndex != 3 {
+		t.Fatalf("unexpected objects, got: %+v", result.Objects)
+	}
+
+	calls := srv.Calls()
+	last := calls[len(calls)-1]
+	if !strings.HasPrefix(last.Query, "INSERT INTO `schema_migrations_objects`") || len(last.Args) != 4*7 ||
+		last.Args[0] != int64(3) || last.Args[len(last.Args)-2] != "b" {
+		t.Fatalf("unexpected object registry insert, got: %q %v", last.Query, last.Args)
+	}
+}
@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// panicError converts a recovered panic (e.g. of a misbehaving decryptor, version store or callback) into a
+// DriverError that describes the migration and the statement that were executed. The stack is logged.
+func (d *driver) panicError(recovered interface{}, version uint64, stmt *statement) *lightmigrate.DriverError {
+	msg := fmt.Sprintf("recovered from panic during migration %d", version)
+	driverErr := &lightmigrate.DriverError{OrigErr: fmt.Errorf("%w: %v", ErrPanic, recovered)}
+	if stmt != nil {
+		msg += fmt.Sprintf(" at statement %d", stmt.Index)
+		driverErr.Query, driverErr.Line = []byte(stmt.Query), uint(stmt.Line)
+	}
+	driverErr.Msg = msg
+
+	d.logf("%s: %v\n%s", msg, recovered, debug.Stack())
+	return driverErr
+}
+
+// recoverMigration converts a panic of the migration into an error. The session and the suspended lock are
+// released and the failure is recorded, like for a failed statement.
+func (d *driver) recoverMigration(state *migrationState, err *error) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+
+	*err = d.panicError(recovered, d.runningVersion, state.Executing)
+	if state.Session != nil {
+		d.releaseTableLocks(state)
+		d.closeSession(state.Session)
+		state.Session = nil
+	}
+	if state.ResumeLock != nil {
+		if lockErr := state.ResumeLock(); lockErr != nil {
+			d.logf("failed to resume migration lock after panic: %v", lockErr)
+		}
+		state.ResumeLock = nil
+	}
+	d.recordFailure(newMigrationFailure(state, *err))
+}
+
+// recoverVersion converts a panic while the version is read or written into an error.
+func (d *driver) recoverVersion(version uint64, err *error) {
+	if recovered := recover(); recovered != nil {
+		*err = d.panicError(recovered, version, nil)
+	}
+}
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/h44z/lightmigrate"
+)
+
+type panickingVersionStore struct{}
+
+func (panickingVersionStore) Get(_ context.Context) (uint64, bool, error) { panic("store is broken") }
+
+func (panickingVersionStore) Set(_ context.Context, _ uint64, _ bool) error { panic("store is broken") }
+
+func (panickingVersionStore) History(_ context.Context) ([]AppliedVersion, error) {
+	panic("store is broken")
+}
+
+func Test_driver_RunMigration_Panic(t *testing.T) {
+	panicking := func() io.Reader { panic("reader is broken") }
+	tests := []struct {
+		name      string
+		migration string
+		wantIndex string
+	}{
+		{"statement", "SELECT 1;\n-- lightmigrate:infile data\nLOAD DATA LOCAL INFILE 'Reader::data' INTO TABLE t;", "at statement 2"},
+		{"parallel", "-- lightmigrate:parallel\n-- lightmigrate:infile data\nLOAD DATA LOCAL INFILE 'Reader::data' INTO TABLE t;", "at statement 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, nil)
+			d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true,
+				MaxParallelStatements: 2}, runningVersion: 7}
+			WithInfileReader("data", panicking)(d)
+
+			err := d.RunMigration(strings.NewReader(tt.migration))
+			var driverErr *lightmigrate.DriverError
+			if !errors.Is(err, ErrPanic) || !errors.As(err, &driverErr) {
+				t.Fatalf("expected error %v, got: %v", ErrPanic, err)
+			}
+			if !strings.Contains(driverErr.Msg, "migration 7 "+tt.wantIndex) || driverErr.Line != 3 {
+				t.Fatalf("unexpected error: %v (line %d)", driverErr.Msg, driverErr.Line)
+			}
+			if db.Stats().InUse != 0 {
+				t.Fatalf("migration session was not released")
+			}
+		})
+	}
+}
+
+func Test_driver_RunMigration_PanicDecryptor(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true},
+		decryptor: func(migration io.Reader) (io.Reader, error) { panic("decryptor is broken") }}
+
+	if err := d.RunMigration(strings.NewReader("SELECT 1;")); !errors.Is(err, ErrPanic) {
+		t.Fatalf("expected error %v, got: %v", ErrPanic, err)
+	}
+}
+
+func Test_driver_SetVersion_Panic(t *testing.T) {
+	d := &driver{cfg: &config{}, store: panickingVersionStore{}}
+
+	if err := d.SetVersion(3, true); !errors.Is(err, ErrPanic) {
+		t.Fatalf("expected error %v, got: %v", ErrPanic, err)
+	}
+	if _, _, err := d.GetVersion(); !errors.Is(err, ErrPanic) {
+		t.Fatalf("expected error %v, got: %v", ErrPanic, err)
+	}
+}
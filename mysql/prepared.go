@@ -0,0 +1,68 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// statementCache keeps the prepared statements of queries that are executed for each migration, e.g. the version
+// updates. Without it, each execution with arguments prepares and closes a statement on the server.
+type statementCache struct {
+	mux   sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStatementCache() *statementCache {
+	return &statementCache{stmts: make(map[string]*sql.Stmt)}
+}
+
+// prepare returns the prepared statement of the query, it is prepared on first use.
+func (c *statementCache) prepare(ctx context.Context, client *sql.DB, query string) (*sql.Stmt, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := client.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// close closes all prepared statements, they are prepared again on the next use.
+func (c *statementCache) close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	var err error
+	for query, stmt := range c.stmts {
+		if closeErr := stmt.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(c.stmts, query)
+	}
+	return err
+}
+
+// execPrepared executes the query with a cached prepared statement, within the transaction if tx is not nil.
+func (d *driver) execPrepared(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	if d.statements == nil { // the driver was not created by NewDriver
+		if tx != nil {
+			return tx.ExecContext(ctx, query, args...)
+		}
+		return d.client.ExecContext(ctx, query, args...)
+	}
+
+	stmt, err := d.statements.prepare(ctx, d.client, query)
+	if err != nil {
+		return nil, err
+	}
+	if tx != nil {
+		return tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+	}
+	return stmt.ExecContext(ctx, args...)
+}
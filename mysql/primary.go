@@ -0,0 +1,55 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// WithPrimaryGuard refuses concurrent runs against different primaries of the same database, e.g. within
+// multi-primary replication or Aurora multi-master clusters, where each writable endpoint would grant its own
+// migration lock. The server identity (server_uuid, or hostname and port on MariaDB) is stored in the lock row and
+// the history table. If the lock is held by a run on another server, Lock fails with ErrPrimaryConflict instead of
+// waiting. The guard requires the table lock strategy, as advisory locks are not replicated.
+func WithPrimaryGuard(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.PrimaryGuard = enabled
+	}
+}
+
+// checkPrimaryGuard verifies that the server identity was detected, the guard cannot compare runs without it.
+func (d *driver) checkPrimaryGuard() error {
+	if !d.cfg.PrimaryGuard || d.server.Identity != "" {
+		return nil
+	}
+	return &lightmigrate.DriverError{OrigErr: ErrUnknownServerIdentity,
+		Msg: "the primary guard requires the server identity (server_uuid, or hostname and port)"}
+}
+
+// lockServer reads the owner of the lock row and the server it was acquired on. The server is only stored by
+// the primary guard.
+func (d *driver) lockServer(ctx context.Context) (owner string, server sql.NullString, err error) {
+	query := "SELECT owner FROM `" + d.lockTable() + "` WHERE lock_key = ?"
+	if !d.cfg.PrimaryGuard {
+		err = d.client.QueryRowContext(ctx, query, d.getLockingKey()).Scan(&owner)
+	} else {
+		query = "SELECT owner, server_uuid FROM `" + d.lockTable() + "` WHERE lock_key = ?"
+		err = d.client.QueryRowContext(ctx, query, d.getLockingKey()).Scan(&owner, &server)
+	}
+	if err != nil {
+		return "", server, &lightmigrate.DriverError{OrigErr: err, Msg: "try lock failed", Query: []byte(query)}
+	}
+	return owner, server, nil
+}
+
+// primaryConflict reports a lock of another driver that was acquired on a different server.
+func (d *driver) primaryConflict(server sql.NullString) error {
+	if !d.cfg.PrimaryGuard || !server.Valid || server.String == "" || server.String == d.server.Identity {
+		return nil
+	}
+	return &lightmigrate.DriverError{OrigErr: ErrPrimaryConflict,
+		Msg: fmt.Sprintf("the migration lock is held by a run on server %s, this driver is connected to %s",
+			server.String, d.server.Identity)}
+}
@@ -0,0 +1,51 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_driver_Lock_PrimaryGuard(t *testing.T) {
+	tests := []struct {
+		name    string
+		server  string
+		wantErr error
+	}{
+		{"other primary", "uuid-b", ErrPrimaryConflict},
+		{"same primary", "uuid-a", ErrDatabaseLocked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+				if strings.HasPrefix(call.Query, "SELECT owner, server_uuid") {
+					return fakeResponse{Columns: []string{"owner", "server_uuid"},
+						Rows: [][]sqldriver.Value{{"other", tt.server}}}
+				}
+				return fakeResponse{}
+			})
+			d := &driver{client: db, owner: "me", server: serverInfo{Identity: "uuid-a"}, cfg: &config{
+				DatabaseName: "testdb", Locking: true, LockStrategy: LockStrategyTable, PrimaryGuard: true}}
+
+			if err := d.Lock(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+			if call := srv.Calls()[0]; !strings.Contains(call.Query, "server_uuid") || call.Args[3] != "uuid-a" {
+				t.Fatalf("server identity was not stored, got: %q %v", call.Query, call.Args)
+			}
+		})
+	}
+}
+
+func Test_driver_checkPrimaryGuard(t *testing.T) {
+	d := &driver{cfg: &config{PrimaryGuard: true}}
+	if err := d.checkPrimaryGuard(); !errors.Is(err, ErrUnknownServerIdentity) {
+		t.Fatalf("expected error %v, got: %v", ErrUnknownServerIdentity, err)
+	}
+
+	d.server.Identity = "uuid-a"
+	if err := d.checkPrimaryGuard(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
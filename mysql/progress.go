@@ -0,0 +1,142 @@
+package mysql
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// DefaultProgressInterval is the default interval between two progress reports, see WithProgressObserver.
+const DefaultProgressInterval = 5 * time.Second
+
+// stageProgressQuery reads the current stage of a server thread. The work counters are only set by stages that
+// report progress, e.g. the stages of InnoDB ALTER TABLE and CREATE INDEX operations.
+const stageProgressQuery = "SELECT s.EVENT_NAME, s.WORK_COMPLETED, s.WORK_ESTIMATED " +
+	"FROM performance_schema.events_stages_current s " +
+	"JOIN performance_schema.threads t ON t.THREAD_ID = s.THREAD_ID WHERE t.PROCESSLIST_ID = ?"
+
+// StatementProgress is the progress of a running DDL statement, see WithProgressObserver.
+type StatementProgress struct {
+	Version        uint64 `json:"version"`
+	StatementIndex int    `json:"statement_index"`
+	Line           int    `json:"line"`
+	// Stage is the performance_schema stage, e.g. "stage/innodb/alter table (read PK and internal sort)".
+	Stage string `json:"stage"`
+	// WorkCompleted and WorkEstimated are the work units reported by the stage, the estimate may grow while the
+	// statement runs.
+	WorkCompleted int64 `json:"work_completed"`
+	WorkEstimated int64 `json:"work_estimated"`
+	// Percent is the completed share of the estimated work, 0 to 100.
+	Percent float64       `json:"percent"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+}
+
+// ProgressObserver is called with the progress of long-running DDL statements.
+type ProgressObserver func(progress StatementProgress)
+
+// WithProgressObserver reports the progress of DDL statements (e.g. CREATE INDEX or ALTER TABLE) on MySQL 8.0.
+// While a DDL statement runs, the performance_schema stage events of its server thread are polled every interval
+// (see WithProgressInterval) and the observer is called for each stage that reports its work. The stage
+// instruments and consumers must be enabled on the server, e.g. with
+// "UPDATE performance_schema.setup_instruments SET ENABLED = 'YES' WHERE NAME LIKE 'stage/innodb/alter%'" and
+// "UPDATE performance_schema.setup_consumers SET ENABLED = 'YES' WHERE NAME LIKE 'events_stages_%'".
+// Otherwise, or on other servers, no progress is reported.
+func WithProgressObserver(observer ProgressObserver) DriverOption {
+	return func(d *driver) {
+		d.progressObserver = observer
+	}
+}
+
+// WithProgressInterval sets the interval between two progress reports, see WithProgressObserver.
+func WithProgressInterval(interval time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.ProgressInterval = interval
+	}
+}
+
+func (d *driver) progressInterval() time.Duration {
+	if d.cfg.ProgressInterval <= 0 {
+		return DefaultProgressInterval
+	}
+	return d.cfg.ProgressInterval
+}
+
+// supportsStageProgress checks if the server reports the progress of stages. MariaDB reports the progress in the
+// processlist instead of performance_schema.
+func (s serverInfo) supportsStageProgress() bool {
+	return s.known() && !s.MariaDB && s.atLeast(8, 0, 0)
+}
+
+// reportProgress polls the progress of the DDL statement until the returned function is called. The server thread
+// id of the session is read before the statement is sent, the progress is read on a separate connection.
+func (d *driver) reportProgress(session *sql.Conn, state *migrationState, stmt statement) (stop func()) {
+	if d.progressObserver == nil || !d.server.supportsStageProgress() || !isDDL(stmt.Query) {
+		return func() {}
+	}
+
+	id, err := d.sessionID(session, state)
+	if err != nil {
+		d.logf("failed to read the connection id, the progress of statement %d is not reported: %v", stmt.Index, err)
+		return func() {}
+	}
+
+	started := time.Now()
+	interval := d.progressInterval()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				d.readStageProgress(id, stmt, time.Since(started))
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// readStageProgress reports the stages of the server thread that have a work estimate.
+func (d *driver) readStageProgress(id int64, stmt statement, elapsed time.Duration) {
+	ctx, cancel := d.sideContext()
+	defer cancel()
+
+	rows, err := d.client.QueryContext(ctx, stageProgressQuery, id)
+	if err != nil {
+		if d.verbose {
+			d.logf("failed to read the progress of statement %d: %v", stmt.Index, err)
+		}
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stage string
+		var completed, estimated sql.NullInt64
+		if err := rows.Scan(&stage, &completed, &estimated); err != nil {
+			d.logf("failed to read the progress of statement %d: %v", stmt.Index, err)
+			return
+		}
+		if !estimated.Valid || estimated.Int64 <= 0 {
+			continue
+		}
+
+		progress := StatementProgress{Version: d.runningVersion, StatementIndex: stmt.Index, Line: stmt.Line,
+			Stage: stage, WorkCompleted: completed.Int64, WorkEstimated: estimated.Int64, Elapsed: elapsed}
+		progress.Percent = 100 * float64(completed.Int64) / float64(estimated.Int64)
+		if progress.Percent > 100 {
+			progress.Percent = 100
+		}
+		d.progressObserver(progress)
+	}
+}
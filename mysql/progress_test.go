@@ -0,0 +1,60 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_driver_RunMigration_Progress(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case call.Query == "SELECT CONNECTION_ID()":
+			return fakeResponse{Columns: []string{"id"}, Rows: [][]sqldriver.Value{{int64(42)}}}
+		case strings.HasPrefix(call.Query, "SELECT s.EVENT_NAME"):
+			return fakeResponse{Columns: []string{"EVENT_NAME", "WORK_COMPLETED", "WORK_ESTIMATED"},
+				Rows: [][]sqldriver.Value{{"stage/innodb/alter table (read PK and internal sort)", int64(25), int64(100)}}}
+		case strings.HasPrefix(call.Query, "ALTER TABLE"):
+			time.Sleep(50 * time.Millisecond)
+		}
+		return fakeResponse{}
+	})
+
+	var mux sync.Mutex
+	var reports []StatementProgress
+	d := &driver{client: db, cfg: &config{SplitStatements: true, ProgressInterval: 10 * time.Millisecond},
+		server: serverInfo{Major: 8}, runningVersion: 7}
+	WithProgressObserver(func(progress StatementProgress) {
+		mux.Lock()
+		defer mux.Unlock()
+		reports = append(reports, progress)
+	})(d)
+
+	if err := d.RunMigration(strings.NewReader("INSERT INTO a VALUES (1);\nALTER TABLE a ADD INDEX b (b);")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(reports) == 0 || reports[0].Version != 7 || reports[0].StatementIndex != 2 || reports[0].Percent != 25 {
+		t.Fatalf("unexpected progress reports, got: %+v", reports)
+	}
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "SELECT s.EVENT_NAME") && call.Args[0] != int64(42) {
+			t.Fatalf("unexpected thread id, got: %v", call.Args)
+		}
+	}
+}
+
+func Test_driver_reportProgress_Unsupported(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{}, server: serverInfo{Major: 10, Minor: 6, MariaDB: true}}
+	WithProgressObserver(func(StatementProgress) {})(d)
+
+	d.reportProgress(nil, &migrationState{}, statement{Query: "ALTER TABLE a ADD INDEX b (b)"})()
+	if queries := srv.Queries(); len(queries) != 0 {
+		t.Fatalf("unexpected queries, got: %q", queries)
+	}
+}
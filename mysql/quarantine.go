@@ -0,0 +1,137 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultQuarantineTable is the name of the table of quarantined migrations, see WithQuarantine.
+const DefaultQuarantineTable = "schema_migrations_failed"
+
+// quarantineTableColumns are the columns of the quarantine table. Columns that are added in later releases
+// must be nullable, so that they can be added to existing tables.
+var quarantineTableColumns = []columnDefinition{
+	{Name: "id", Definition: "bigint not null auto_increment primary key"},
+	{Name: "version", Definition: "bigint not null"},
+	{Name: "failed_at", Definition: "datetime not null"},
+	{Name: "statement_index", Definition: "int not null"},
+	{Name: "line", Definition: "int null"},
+	{Name: "error_code", Definition: "smallint unsigned null"},
+	{Name: "error_message", Definition: "text not null"},
+	{Name: "statement", Definition: "text null"},
+}
+
+// QuarantinedMigration is a migration that failed in best-effort mode, see WithQuarantine.
+type QuarantinedMigration struct {
+	Version  uint64    `json:"version"`
+	FailedAt time.Time `json:"failed_at"`
+	// StatementIndex is the 1-based index of the failed statement, 0 if no statement failed.
+	StatementIndex int    `json:"statement_index"`
+	Line           int    `json:"line,omitempty"`
+	ErrorCode      uint16 `json:"error_code,omitempty"`
+	ErrorMessage   string `json:"error_message"`
+	Statement      string `json:"statement,omitempty"`
+}
+
+// WithQuarantine enables the best-effort mode for development and preview environments: a failing migration is
+// recorded in the quarantine table and its version is reported as applied, so that the run continues with the
+// following versions. The database may be left partially migrated, QuarantinedMigrations reports all failures.
+func WithQuarantine(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.Quarantine = enabled
+	}
+}
+
+// quarantineTable returns the name of the quarantine table of the migration namespace.
+func (d *driver) quarantineTable() string {
+	if d.cfg.Namespace != "" {
+		return d.tableName(DefaultQuarantineTable + "_" + d.cfg.Namespace)
+	}
+	return d.tableName(DefaultQuarantineTable)
+}
+
+// prepareQuarantineTable creates the quarantine table, if the best-effort mode is enabled.
+func (d *driver) prepareQuarantineTable(ctx context.Context) error {
+	if !d.cfg.Quarantine {
+		return nil
+	}
+
+	if err := d.createTable(ctx, d.quarantineTable(), quarantineTableColumns, "failed create quarantine table"); err != nil {
+		return err
+	}
+
+	return d.ensureColumns(ctx, d.quarantineTable(), quarantineTableColumns)
+}
+
+// quarantine records the failed migration in the quarantine table, so that the run can continue. Failures that
+// would also break the following migrations (lost connections, the run deadline) are not quarantined.
+func (d *driver) quarantine(state *migrationState, migrationErr error) error {
+	if !d.cfg.Quarantine || errors.Is(migrationErr, ErrRunDeadlineExceeded) || errors.Is(migrationErr, ErrConnectionLost) ||
+		errors.Is(migrationErr, ErrOutsideMaintenanceWindow) {
+		return migrationErr
+	}
+
+	failure := newMigrationFailure(state, migrationErr)
+	var line, errorCode, stmt interface{}
+	if state.Failed != nil {
+		line, stmt = state.Failed.Line, truncateMessage(state.Failed.Query, maxErrorMessageLength)
+	}
+	if failure.ErrorCode != 0 {
+		errorCode = failure.ErrorCode
+	}
+
+	query := "INSERT INTO `" + d.quarantineTable() + "` (version, failed_at, statement_index, line, error_code, " +
+		"error_message, statement) VALUES (?, FROM_UNIXTIME(?), ?, ?, ?, ?, ?)"
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	if _, err := d.client.ExecContext(ctx, query, d.runningVersion, time.Now().Unix(), failure.StatementIndex, line,
+		errorCode, failure.Message, stmt); err != nil {
+		d.logf("failed to quarantine migration %d: %v", d.runningVersion, err)
+		return migrationErr
+	}
+
+	state.Quarantined = migrationErr
+	d.logf("migration %d failed and was quarantined, continuing with the next migration: %v", d.runningVersion,
+		migrationErr)
+	return nil
+}
+
+// QuarantinedMigrations returns the migrations that failed in best-effort mode, oldest entries first.
+func (d *driver) QuarantinedMigrations(ctx context.Context) ([]QuarantinedMigration, error) {
+	if !d.cfg.Quarantine {
+		return nil, ErrNoQuarantine
+	}
+
+	query := "SELECT version, UNIX_TIMESTAMP(failed_at), statement_index, line, error_code, error_message, statement " +
+		"FROM `" + d.quarantineTable() + "` ORDER BY id"
+	rows, err := d.client.QueryContext(ctx, query)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read quarantined migrations", Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	var quarantined []QuarantinedMigration
+	for rows.Next() {
+		var entry QuarantinedMigration
+		var failed int64
+		var line, errorCode sql.NullInt64
+		var stmt sql.NullString
+		if err := rows.Scan(&entry.Version, &failed, &entry.StatementIndex, &line, &errorCode, &entry.ErrorMessage,
+			&stmt); err != nil {
+			return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read quarantined migrations", Query: []byte(query)}
+		}
+		entry.FailedAt = time.Unix(failed, 0)
+		entry.Line, entry.ErrorCode, entry.Statement = int(line.Int64), uint16(errorCode.Int64), stmt.String
+		quarantined = append(quarantined, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read quarantined migrations", Query: []byte(query)}
+	}
+
+	return quarantined, nil
+}
@@ -0,0 +1,97 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+func TestWithQuarantine(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithQuarantine(true)(d)
+	if !d.cfg.Quarantine {
+		t.Fatalf("failed to set quarantine")
+	}
+}
+
+func Test_driver_RunMigration_Quarantine(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "ALTER TABLE missing") {
+			return fakeResponse{Err: &gomysql.MySQLError{Number: 1146, Message: "Table 'missing' doesn't exist"}}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true, Quarantine: true},
+		stats: newStatsRecorder(), runningVersion: 3}
+
+	if err := d.RunMigration(strings.NewReader("SELECT 1;\nALTER TABLE missing ADD x int;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var inserted *fakeCall
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_failed`") {
+			call := call
+			inserted = &call
+		}
+	}
+	if inserted == nil {
+		t.Fatalf("migration was not quarantined: %q", srv.Queries())
+	}
+	if inserted.Args[0] != int64(3) || inserted.Args[2] != int64(2) || inserted.Args[3] != int64(2) ||
+		inserted.Args[4] != int64(1146) || inserted.Args[6] != "ALTER TABLE missing ADD x int" {
+		t.Fatalf("unexpected quarantine entry: %v", inserted.Args)
+	}
+	if stats := d.Stats(); stats.MigrationsFailed != 1 || stats.MigrationsApplied != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func Test_driver_quarantine_NotQuarantinable(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{Quarantine: true}}
+
+	for _, err := range []error{ErrConnectionLost, ErrRunDeadlineExceeded} {
+		if got := d.quarantine(&migrationState{}, err); got != err {
+			t.Fatalf("expected error %v, got: %v", err, got)
+		}
+	}
+	if queries := srv.Queries(); len(queries) != 0 {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_QuarantinedMigrations(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT version, UNIX_TIMESTAMP(failed_at)") {
+			return fakeResponse{
+				Columns: []string{"version", "failed_at", "statement_index", "line", "error_code", "error_message", "statement"},
+				Rows: [][]sqldriver.Value{
+					{int64(3), int64(1700000000), int64(2), int64(2), int64(1146), "table missing", "ALTER TABLE missing"},
+					{int64(5), int64(1700000001), int64(0), nil, nil, "invalid directive", nil},
+				},
+			}
+		}
+		return fakeResponse{}
+	})
+
+	d := &driver{client: db, cfg: &config{}}
+	if _, err := d.QuarantinedMigrations(context.Background()); !errors.Is(err, ErrNoQuarantine) {
+		t.Fatalf("expected error %v, got: %v", ErrNoQuarantine, err)
+	}
+
+	d.cfg.Quarantine = true
+	quarantined, err := d.QuarantinedMigrations(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(quarantined) != 2 || quarantined[0].ErrorCode != 1146 || quarantined[0].Statement != "ALTER TABLE missing" ||
+		quarantined[1].Version != 5 || quarantined[1].Line != 0 {
+		t.Fatalf("unexpected quarantined migrations: %+v", quarantined)
+	}
+}
@@ -0,0 +1,115 @@
+// Package readiness provides an HTTP handler that reports the migration state of a database, so that
+// operators can query the state of a running service without database access.
+package readiness
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/h44z/lightmigrate-mysql/mysql"
+)
+
+// DefaultTimeout is the default timeout for querying the migration state.
+const DefaultTimeout = 5 * time.Second
+
+// StatusProvider reports the migration state. It is implemented by mysql.Driver.
+type StatusProvider interface {
+	Status(ctx context.Context) (*mysql.Status, error)
+}
+
+// Response is the JSON body returned by the handler.
+type Response struct {
+	// Ready is true, if the database is migrated and no migration is running.
+	Ready bool `json:"ready"`
+	// Reason explains why the database is not ready.
+	Reason string `json:"reason,omitempty"`
+	// ExpectedVersion is the configured expected schema version, if any.
+	ExpectedVersion uint64 `json:"expected_version,omitempty"`
+
+	*mysql.Status
+}
+
+type handler struct {
+	provider        StatusProvider
+	expectedVersion uint64
+	timeout         time.Duration
+}
+
+// Option is a function that can be used within the handler constructor to
+// modify the handler object.
+type Option func(h *handler)
+
+// NewHandler instantiates a new readiness handler. The handler responds with 200 (OK) if the database is ready,
+// and with 503 (Service Unavailable) if the database is dirty, a migration is running, the schema version
+// differs from the expected version or the state could not be queried.
+func NewHandler(provider StatusProvider, opts ...Option) http.Handler {
+	h := &handler{
+		provider: provider,
+		timeout:  DefaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// WithExpectedVersion sets the schema version that must be reached for the database to be ready.
+// A value of 0 disables the version check.
+func WithExpectedVersion(version uint64) Option {
+	return func(h *handler) {
+		h.expectedVersion = version
+	}
+}
+
+// WithTimeout sets the timeout for querying the migration state.
+func WithTimeout(timeout time.Duration) Option {
+	return func(h *handler) {
+		h.timeout = timeout
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	resp := Response{ExpectedVersion: h.expectedVersion}
+	status, err := h.provider.Status(ctx)
+	switch {
+	case err != nil:
+		resp.Reason = fmt.Sprintf("failed to query migration state: %v", err)
+	case status.Dirty:
+		resp.Reason = "database is dirty"
+	case status.LockHeld:
+		resp.Reason = "migration in progress"
+	case h.expectedVersion != 0 && status.Version != h.expectedVersion:
+		resp.Reason = fmt.Sprintf("schema version %d does not match expected version %d", status.Version,
+			h.expectedVersion)
+	default:
+		resp.Ready = true
+	}
+	resp.Status = status
+
+	code := http.StatusOK
+	if !resp.Ready {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if r.Method == http.MethodHead {
+		return
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,83 @@
+package readiness
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/h44z/lightmigrate-mysql/mysql"
+)
+
+type staticProvider struct {
+	status *mysql.Status
+	err    error
+}
+
+func (p staticProvider) Status(_ context.Context) (*mysql.Status, error) {
+	return p.status, p.err
+}
+
+func TestWithExpectedVersion(t *testing.T) {
+	h := &handler{}
+
+	WithExpectedVersion(3)(h)
+	if h.expectedVersion != 3 {
+		t.Fatalf("failed to set expected version")
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	h := &handler{}
+
+	WithTimeout(time.Second)(h)
+	if h.timeout != time.Second {
+		t.Fatalf("failed to set timeout")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider staticProvider
+		expected uint64
+		method   string
+		wantCode int
+	}{
+		{"ready", staticProvider{status: &mysql.Status{Version: 2}}, 2, http.MethodGet, http.StatusOK},
+		{"no expectation", staticProvider{status: &mysql.Status{Version: 1}}, 0, http.MethodGet, http.StatusOK},
+		{"dirty", staticProvider{status: &mysql.Status{Version: 2, Dirty: true}}, 2, http.MethodGet, http.StatusServiceUnavailable},
+		{"locked", staticProvider{status: &mysql.Status{Version: 2, LockHeld: true}}, 2, http.MethodGet, http.StatusServiceUnavailable},
+		{"behind", staticProvider{status: &mysql.Status{Version: 1}}, 2, http.MethodGet, http.StatusServiceUnavailable},
+		{"error", staticProvider{err: errors.New("connection refused")}, 0, http.MethodGet, http.StatusServiceUnavailable},
+		{"head", staticProvider{status: &mysql.Status{Version: 2}}, 2, http.MethodHead, http.StatusOK},
+		{"post", staticProvider{status: &mysql.Status{Version: 2}}, 2, http.MethodPost, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewHandler(tt.provider, WithExpectedVersion(tt.expected)).ServeHTTP(rec, httptest.NewRequest(tt.method, "/", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("unexpected status code %d, got: %d", tt.wantCode, rec.Code)
+			}
+			if tt.method != http.MethodGet {
+				return
+			}
+
+			var resp Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+			if resp.Ready != (tt.wantCode == http.StatusOK) {
+				t.Fatalf("unexpected ready flag in response: %s", rec.Body.String())
+			}
+			if !resp.Ready && resp.Reason == "" {
+				t.Fatalf("missing reason in response: %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultReconnectBackoff is the wait time before the first reconnect attempt.
+const DefaultReconnectBackoff = time.Second
+
+// maxReconnectBackoff limits the doubled wait time of further reconnect attempts.
+const maxReconnectBackoff = time.Minute
+
+// ReconnectPolicy configures the automatic reconnect after the migration connection was lost, e.g. due to a
+// failover or a proxy restart.
+type ReconnectPolicy struct {
+	// MaxAttempts is the maximum number of reconnect attempts within a single migration. 0 disables the reconnect.
+	MaxAttempts int
+	// Backoff is the wait time before the first reconnect attempt, it doubles with each further attempt up to one
+	// minute. Defaults to DefaultReconnectBackoff.
+	Backoff time.Duration
+}
+
+// backoff returns the wait time before the given (0-based) reconnect attempt.
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = DefaultReconnectBackoff
+	}
+	delay := backoff
+	for i := 0; i < attempt && delay < maxReconnectBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxReconnectBackoff {
+		delay = maxReconnectBackoff
+	}
+	if delay < backoff {
+		delay = backoff // a configured backoff above the limit is not shortened
+	}
+	return delay
+}
+
+// WithReconnect configures the automatic reconnect if the connection is lost during a migration. After the
+// reconnect, the new session is prepared like the lost one (character set, session variables and role), the
+// session SET statements that the migration executed so far (e.g. SET sql_mode = ...) are replayed, the migration
+// lock is re-acquired and the recorded version is verified. The interrupted statement
+// is only re-executed if it did not reach the server or the migration is marked with the
+// "-- lightmigrate:idempotent" directive, otherwise the migration is aborted with ErrConnectionLost. Migrations are
+// also aborted if the connection is lost within a transaction (START TRANSACTION ... COMMIT) of the migration.
+// Statements of parallel blocks are not retried.
+func WithReconnect(policy ReconnectPolicy) DriverOption {
+	return func(d *driver) {
+		d.cfg.Reconnect = policy
+	}
+}
+
+// isConnectionLoss checks if the error was caused by a broken connection.
+func isConnectionLoss(err error) bool {
+	return errors.Is(err, sqldriver.ErrBadConn) || errors.Is(err, gomysql.ErrInvalidConn) ||
+		errors.Is(err, sql.ErrConnDone)
+}
+
+// execStatementWithReconnect executes the statement within the migration session, see execStatement.
+// If the connection is lost and the reconnect is enabled, the migration is resumed within a new session.
+func (d *driver) execStatementWithReconnect(state *migrationState, stmt statement) error {
+	err := d.execStatement(context.Background(), state.Session, state, stmt)
+	if err == nil && d.cfg.Reconnect.MaxAttempts > 0 {
+		if isSessionStatement(stmt.Query) {
+			state.SessionStatements = append(state.SessionStatements, stmt.Query)
+		}
+		state.Transaction = transactionState(stmt.Query, state.Transaction)
+	}
+	if err == nil || d.cfg.Reconnect.MaxAttempts < 1 || !isConnectionLoss(err) {
+		return err
+	}
+
+	// the driver reports a bad connection only if the statement was not sent to the server
+	sent := !errors.Is(err, sqldriver.ErrBadConn)
+	d.logf("connection lost during statement %d (line %d): %v", stmt.Index, stmt.Line, err)
+	if state.Transaction {
+		// the server rolled back the open transaction, resuming would commit the remaining statements only
+		return d.connectionLostError(state, stmt, sent, err)
+	}
+
+	if reconnectErr := d.reconnect(state); reconnectErr != nil {
+		return d.connectionLostError(state, stmt, sent, reconnectErr)
+	}
+	if sent && !state.Idempotent {
+		return d.connectionLostError(state, stmt, sent, err)
+	}
+
+	d.logf("migration resumed with statement %d (line %d)", stmt.Index, stmt.Line)
+	return d.execStatementWithReconnect(state, stmt)
+}
+
+// isSessionStatement checks if the statement changes the state of the session, e.g. SET sql_mode = ... or
+// SET @var = ..., but not the global or persisted server configuration.
+func isSessionStatement(query string) bool {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) < 2 || fields[0] != "SET" {
+		return false
+	}
+	switch fields[1] {
+	case "GLOBAL", "PERSIST", "PERSIST_ONLY", "PASSWORD", "DEFAULT":
+		return false
+	}
+	return !strings.HasPrefix(fields[1], "@@GLOBAL.") && !strings.HasPrefix(fields[1], "@@PERSIST")
+}
+
+// transactionState reports if a transaction is open after the statement. Transactions are opened by START
+// TRANSACTION and BEGIN, and closed by COMMIT, ROLLBACK and the statements that commit implicitly (DDL).
+func transactionState(query string, open bool) bool {
+	fields := strings.Fields(strings.ToUpper(strings.TrimRight(query, "; \t\n")))
+	if len(fields) == 0 {
+		return open
+	}
+	switch fields[0] {
+	case "START":
+		return len(fields) > 1 && fields[1] == "TRANSACTION" || open
+	case "BEGIN":
+		return len(fields) == 1 || fields[1] == "WORK" || open
+	case "CREATE", "DROP":
+		return len(fields) > 1 && fields[1] == "TEMPORARY" && open // temporary tables do not commit
+	case "COMMIT", "ALTER", "RENAME", "TRUNCATE":
+		return false
+	case "ROLLBACK":
+		for _, field := range fields[1:] {
+			if field == "TO" {
+				return open // ROLLBACK TO SAVEPOINT keeps the transaction
+			}
+		}
+		return false
+	}
+	return open
+}
+
+// reconnect replaces the lost migration session, re-acquires the migration lock and verifies that the version
+// table still records the running migration.
+func (d *driver) reconnect(state *migrationState) error {
+	_ = state.Session.Close()
+	state.Session, state.SessionID = nil, 0
+
+	err := errors.New("no reconnect attempts left")
+	for state.Reconnects < d.cfg.Reconnect.MaxAttempts {
+		time.Sleep(d.cfg.Reconnect.backoff(state.Reconnects))
+		state.Reconnects++
+
+		var session *sql.Conn
+		session, err = d.openSession(context.Background())
+		if err == nil {
+			state.Session = session
+			break
+		}
+		d.logf("reconnect attempt %d failed: %v", state.Reconnects, err)
+	}
+	if state.Session == nil {
+		return fmt.Errorf("reconnect failed: %w", err)
+	}
+	for _, query := range state.SessionStatements { // the session state of the migration was lost
+		ctx, cancel := d.internalContext()
+		_, err := state.Session.ExecContext(ctx, query)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to restore the session state (%s): %w", query, err)
+		}
+	}
+
+	if len(state.TableLocks) > 0 { // the table locks were held by the lost connection
+		if err := d.acquireTableLocks(state.Session, state.TableLocks); err != nil {
+			return fmt.Errorf("failed to re-acquire the table locks: %w", err)
+		}
+	}
+	if state.ResumeLock == nil { // the lock is not suspended by the no-lock directive
+		if err := d.relock(); err != nil {
+			return fmt.Errorf("failed to re-acquire the migration lock: %w", err)
+		}
+	}
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	row, err := d.currentVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to verify the version after reconnect: %w", err)
+	}
+	if row == nil || !row.Dirty || row.Version != d.runningVersion {
+		return fmt.Errorf("unexpected version after reconnect, expected dirty version %d", d.runningVersion)
+	}
+
+	return nil
+}
+
+// connectionLostError describes how far the migration got before the connection was lost.
+func (d *driver) connectionLostError(state *migrationState, stmt statement, sent bool, cause error) error {
+	state.Failed = &stmt
+
+	var driverErr *lightmigrate.DriverError
+	if errors.As(cause, &driverErr) {
+		cause = driverErr.OrigErr // the statement is already part of the message
+	}
+
+	msg := fmt.Sprintf("connection lost during statement %d, %d statements of the migration were applied",
+		stmt.Index, state.Result.result.Statements)
+	if sent {
+		msg += ", the interrupted statement may have been applied"
+	} else {
+		msg += ", the interrupted statement was not applied"
+	}
+	if state.Transaction {
+		msg += ", the open transaction of the migration was rolled back by the server"
+	}
+	msg += fmt.Sprintf(", version %d is dirty", d.runningVersion)
+
+	return stmt.error(msg, fmt.Errorf("%w: %v", ErrConnectionLost, cause))
+}
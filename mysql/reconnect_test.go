@@ -0,0 +1,210 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// lostConnectionHandler fails the given statement once with err.
+func lostConnectionHandler(failing string, err error) fakeHandler {
+	failed := false
+	return func(call fakeCall) fakeResponse {
+		switch {
+		case call.Query == failing && !failed:
+			failed = true
+			return fakeResponse{Err: err}
+		case strings.HasPrefix(call.Query, "SELECT version, dirty"):
+			return versionHandler([]sqldriver.Value{int64(3), int64(1), int64(2), nil, int64(1)})(call)
+		}
+		return defaultFakeHandler(call)
+	}
+}
+
+func TestReconnectPolicy_backoff(t *testing.T) {
+	if got := (ReconnectPolicy{}).backoff(0); got != DefaultReconnectBackoff {
+		t.Fatalf("unexpected backoff %v, got: %v", DefaultReconnectBackoff, got)
+	}
+	if got := (ReconnectPolicy{Backoff: time.Second}).backoff(2); got != 4*time.Second {
+		t.Fatalf("unexpected backoff %v, got: %v", 4*time.Second, got)
+	}
+	if got := (ReconnectPolicy{Backoff: time.Second}).backoff(70); got != maxReconnectBackoff {
+		t.Fatalf("unexpected backoff %v, got: %v", maxReconnectBackoff, got)
+	}
+	if got := (ReconnectPolicy{Backoff: 2 * time.Minute}).backoff(3); got != 2*time.Minute {
+		t.Fatalf("unexpected backoff %v, got: %v", 2*time.Minute, got)
+	}
+}
+
+func Test_isSessionStatement(t *testing.T) {
+	tests := map[string]bool{
+		"SET sql_mode = 'ANSI_QUOTES'":       true,
+		"set session foreign_key_checks = 0": true,
+		"SET @batch = 1000":                  true,
+		"SET NAMES latin1":                   true,
+		"SET GLOBAL read_only = 1":           false,
+		"SET @@GLOBAL.max_connections = 100": false,
+		"SET PERSIST max_connections = 100":  false,
+		"SET PASSWORD FOR app = 'secret'":    false,
+		"SET DEFAULT ROLE ddl_admin TO app":  false,
+		"SELECT 1":                           false,
+		"UPDATE t SET a = 1":                 false,
+	}
+	for query, want := range tests {
+		if got := isSessionStatement(query); got != want {
+			t.Fatalf("unexpected result %t for %s, got: %t", want, query, got)
+		}
+	}
+}
+
+func Test_transactionState(t *testing.T) {
+	tests := []struct {
+		query string
+		open  bool
+		want  bool
+	}{
+		{"START TRANSACTION", false, true},
+		{"begin", false, true},
+		{"BEGIN WORK", false, true},
+		{"INSERT INTO a VALUES (1)", true, true},
+		{"ROLLBACK TO SAVEPOINT s1", true, true},
+		{"CREATE TEMPORARY TABLE t (id int)", true, true},
+		{"COMMIT", true, false},
+		{"ROLLBACK", true, false},
+		{"ALTER TABLE a ADD b int", true, false},
+		{"CREATE TABLE b (id int)", true, false},
+		{"INSERT INTO a VALUES (1)", false, false},
+	}
+	for _, tt := range tests {
+		if got := transactionState(tt.query, tt.open); got != tt.want {
+			t.Fatalf("unexpected result %t for %s, got: %t", tt.want, tt.query, got)
+		}
+	}
+}
+
+func Test_driver_RunMigration_ReconnectTransaction(t *testing.T) {
+	db, srv := newFakeDB(t, lostConnectionHandler("INSERT INTO b VALUES (1)", sqldriver.ErrBadConn))
+	d := &driver{client: db, runningVersion: 3, cfg: &config{DatabaseName: "db", SplitStatements: true,
+		Reconnect: ReconnectPolicy{MaxAttempts: 1, Backoff: time.Millisecond}}}
+
+	migration := "-- lightmigrate:idempotent\nSTART TRANSACTION; INSERT INTO a VALUES (1); INSERT INTO b VALUES (1); COMMIT;"
+	err := d.RunMigration(strings.NewReader(migration))
+	if !errors.Is(err, ErrConnectionLost) || !strings.Contains(err.Error(), "open transaction") {
+		t.Fatalf("expected error %v, got: %v", ErrConnectionLost, err)
+	}
+	for _, query := range srv.Queries() {
+		if query == "COMMIT" {
+			t.Fatalf("the rest of the transaction must not be committed: %q", srv.Queries())
+		}
+	}
+}
+
+func Test_driver_RunMigration_ReconnectSessionState(t *testing.T) {
+	db, srv := newFakeDB(t, lostConnectionHandler("SELECT 2", sqldriver.ErrBadConn))
+	d := &driver{client: db, runningVersion: 3, cfg: &config{DatabaseName: "db", SplitStatements: true,
+		InnoDBLockWaitTimeout: time.Second, Reconnect: ReconnectPolicy{MaxAttempts: 1, Backoff: time.Millisecond}}}
+
+	if err := d.RunMigration(strings.NewReader("SET sql_mode = 'ANSI_QUOTES'; SELECT 2;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the session of the reconnect is prepared again before the statement is retried
+	var statements []string
+	conns := make(map[int]bool)
+	for _, call := range srv.Calls() {
+		switch {
+		case call.Query == "SET SESSION innodb_lock_wait_timeout = 1":
+			statements = append(statements, "variables")
+		case call.Query == "SET sql_mode = 'ANSI_QUOTES'":
+			statements = append(statements, "sql_mode")
+		case call.Query == "SELECT 2":
+			statements = append(statements, "statement")
+			conns[call.ConnID] = true
+		}
+	}
+	want := "variables,sql_mode,statement,variables,sql_mode,statement"
+	if strings.Join(statements, ",") != want || len(conns) != 2 {
+		t.Fatalf("unexpected session statements %s, got: %s (%d sessions)", want, strings.Join(statements, ","),
+			len(conns))
+	}
+}
+
+func Test_driver_RunMigration_Reconnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		lockLost  bool
+		wantLocks int
+	}{
+		{"lock session alive", false, 1},
+		{"lock session lost", true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := lostConnectionHandler("SELECT 2", sqldriver.ErrBadConn)
+			db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+				if call.Query == "PING" && tt.lockLost {
+					return fakeResponse{Err: sqldriver.ErrBadConn}
+				}
+				return handler(call)
+			})
+			d := &driver{client: db, runningVersion: 3, cfg: &config{DatabaseName: "db", Locking: true,
+				SplitStatements: true, Reconnect: ReconnectPolicy{MaxAttempts: 1, Backoff: time.Millisecond}}}
+
+			if err := d.Lock(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := d.RunMigration(strings.NewReader("SELECT 1; SELECT 2; SELECT 3;")); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var executed []string
+			locks := 0
+			for _, query := range srv.Queries() {
+				switch {
+				case strings.HasPrefix(query, "SELECT GET_LOCK"):
+					locks++
+				case strings.HasPrefix(query, "SELECT ") && len(query) == len("SELECT 1"):
+					executed = append(executed, query)
+				}
+			}
+			if strings.Join(executed, ",") != "SELECT 1,SELECT 2,SELECT 2,SELECT 3" {
+				t.Fatalf("unexpected statements, got: %q", executed)
+			}
+			if locks != tt.wantLocks {
+				t.Fatalf("unexpected lock queries %d, got: %d", tt.wantLocks, locks)
+			}
+		})
+	}
+}
+
+func Test_driver_RunMigration_ReconnectAbort(t *testing.T) {
+	tests := []struct {
+		name           string
+		migration      string
+		runningVersion uint64
+		want           string
+	}{
+		{"statement may be applied", "SELECT 1; SELECT 2;", 3, "may have been applied"},
+		{"unexpected version", "-- lightmigrate:idempotent\nSELECT 1; SELECT 2;", 4, "unexpected version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t, lostConnectionHandler("SELECT 2", gomysql.ErrInvalidConn))
+			d := &driver{client: db, runningVersion: tt.runningVersion, cfg: &config{SplitStatements: true,
+				Reconnect: ReconnectPolicy{MaxAttempts: 1, Backoff: time.Millisecond}}}
+
+			err := d.RunMigration(strings.NewReader(tt.migration))
+			if !errors.Is(err, ErrConnectionLost) {
+				t.Fatalf("unexpected error %v, got: %v", ErrConnectionLost, err)
+			}
+			if !strings.Contains(err.Error(), "1 statements of the migration were applied") ||
+				!strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("unexpected error details, got: %v", err)
+			}
+		})
+	}
+}
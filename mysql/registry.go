@@ -0,0 +1,110 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegistryTable is the name of the central version registry table, see WithVersionRegistry.
+const DefaultRegistryTable = "schema_migrations_registry"
+
+// registryTablePattern matches valid names of the registry table.
+var registryTablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// registryTableColumns are the columns of the version registry. The rows are identified by the server and the
+// database (see registryPrimaryKey), the server identity is limited to 191 characters, so that the primary key can
+// be indexed on all supported servers.
+var registryTableColumns = []columnDefinition{
+	{Name: "server_identity", Definition: "varchar(191) not null"},
+	{Name: "db_name", Definition: "varchar(64) not null"},
+	{Name: "target", Definition: "varchar(191) not null"},
+	{Name: "version", Definition: "bigint not null"},
+	{Name: "dirty", Definition: "boolean not null"},
+	{Name: "success", Definition: "boolean not null"},
+	{Name: "error_message", Definition: "text null"},
+	{Name: "updated_at", Definition: "datetime not null"},
+}
+
+// registryPrimaryKey is the primary key of the version registry. Targets of different servers may use the same
+// database name, e.g. the shards of a horizontally sharded database.
+const registryPrimaryKey = "PRIMARY KEY (server_identity, db_name)"
+
+// versionRegistry records the versions of all targets in a central table.
+type versionRegistry struct {
+	client *sql.DB
+	table  string
+}
+
+// WithVersionRegistry records the version of each migrated target (or shard) in a central table of the given
+// database, e.g. an admin database. After a partial rollout, the targets that are behind can be found with a single
+// query. The table is created if needed, an empty table name uses DefaultRegistryTable. Each target is recorded by
+// the identity of its server (server_uuid, or hostname and port, or the cluster state UUID on Galera clusters) and
+// its database. Skipped targets and targets whose server could not be identified are not recorded, failed targets
+// keep their last known version. Failed registry updates are logged (see WithCoordinatorLogger), they do not fail
+// the migration of the target.
+func WithVersionRegistry(client *sql.DB, table string) CoordinatorOption {
+	return func(c *Coordinator) {
+		if table == "" {
+			table = DefaultRegistryTable
+		}
+		c.registry = &versionRegistry{client: client, table: table}
+	}
+}
+
+// prepare validates the table name and creates the registry table.
+func (r *versionRegistry) prepare(ctx context.Context) error {
+	if !registryTablePattern.MatchString(r.table) {
+		return &ConfigError{Problems: []error{errors.New("invalid version registry table name")}}
+	}
+
+	query := strings.TrimSuffix(createTableQuery(r.table, registryTableColumns), ")") + ", " + registryPrimaryKey +
+		") ENGINE=InnoDB"
+	if _, err := r.client.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create version registry table: %w", err)
+	}
+	return nil
+}
+
+// record stores the result of a single target. The version is only updated if it could be read after the
+// migration.
+func (r *versionRegistry) record(ctx context.Context, result TargetResult) error {
+	if result.server == "" {
+		return fmt.Errorf("failed to record version in registry: %w", ErrUnknownServerIdentity)
+	}
+
+	var errorMessage interface{}
+	if result.Err != nil {
+		errorMessage = truncateMessage(result.Err.Error(), maxErrorMessageLength)
+	}
+
+	query := "INSERT INTO `" + r.table + "` (server_identity, db_name, target, version, dirty, success, " +
+		"error_message, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, NOW()) ON DUPLICATE KEY UPDATE " +
+		"target = VALUES(target), version = IF(?, VALUES(version), version), dirty = IF(?, VALUES(dirty), dirty), " +
+		"success = VALUES(success), error_message = VALUES(error_message), updated_at = VALUES(updated_at)"
+	_, err := r.client.ExecContext(ctx, query, result.server, result.database, result.Target, result.ToVersion,
+		result.Dirty, result.Err == nil, errorMessage, result.versionKnown, result.versionKnown)
+	if err != nil {
+		return fmt.Errorf("failed to record version in registry: %w", err)
+	}
+	return nil
+}
+
+// registryKey returns the server identity and the database of the driver, which identify the target within the
+// version registry. The server identity is detected, unless the driver already detected it.
+func registryKey(migrationDriver Driver) (server, database string) {
+	d, ok := migrationDriver.(*driver)
+	if !ok {
+		return "", ""
+	}
+
+	if d.server.Identity == "" {
+		ctx, cancel := d.internalContext()
+		d.detectServerIdentity(ctx)
+		cancel()
+	}
+	return d.lockIdentity(), d.cfg.DatabaseName
+}
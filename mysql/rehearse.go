@@ -0,0 +1,247 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// definerClause is the DEFINER clause of SHOW CREATE VIEW, TRIGGER or PROCEDURE, it is dropped so that the clone
+// does not require the SET_USER_ID (or SUPER) privilege.
+var definerClause = regexp.MustCompile("DEFINER=(`[^`]*`|[^ ]*)@(`[^`]*`|[^ ]*) ")
+
+// RehearsalError is returned by Rehearse if the migrations failed within the scratch database.
+// The real database was not changed.
+type RehearsalError struct {
+	Err error
+}
+
+// Error implements error interface.
+func (e *RehearsalError) Error() string {
+	return "rehearsal failed: " + e.Err.Error()
+}
+
+// Unwrap returns the error of the rehearsal.
+func (e *RehearsalError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrRehearsalFailed.
+func (e *RehearsalError) Is(target error) bool {
+	return target == ErrRehearsalFailed
+}
+
+// Rehearse migrates a scratch copy of the database of the DSN before the database itself. The structure of all
+// tables (including foreign keys), views, routines and triggers is cloned into a throwaway database (SHOW CREATE),
+// the pending migrations of the source are applied there, and only if they succeed, the real database is migrated
+// to the version. The clone contains no data, so data migrations are only checked against the schema.
+//
+// A failed rehearsal is reported as *RehearsalError, see ErrRehearsalFailed. The scratch database is dropped in
+// any case.
+func Rehearse(dsn string, source lightmigrate.MigrationSource, version uint64, opts ...DriverOption) error {
+	drv, err := NewDriverFromDSN(dsn, opts...)
+	if err != nil {
+		return err
+	}
+	defer drv.Close()
+	d := drv.(*driver)
+
+	current, dirty, err := d.GetVersion()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return lightmigrate.ErrDatabaseDirty
+	}
+
+	if current != version {
+		ctx := context.Background()
+		scratch, err := createScratchDatabase(ctx, dsn, "rehearsal")
+		if err != nil {
+			return err
+		}
+		err = d.rehearse(ctx, scratch, source, current, version, opts)
+		if dropErr := scratch.Drop(ctx); dropErr != nil {
+			d.logf("failed to drop the rehearsal database %s: %v", scratch.Name, dropErr)
+		}
+		if err != nil {
+			return err
+		}
+		d.logf("rehearsal of version %d succeeded, migrating %s", version, d.cfg.DatabaseName)
+	}
+
+	migrator, err := lightmigrate.NewMigrator(source, d)
+	if err != nil {
+		return err
+	}
+	return migrator.Migrate(version)
+}
+
+// rehearse clones the schema into the scratch database and migrates it from the current to the target version.
+func (d *driver) rehearse(ctx context.Context, scratch *scratchDatabase, source lightmigrate.MigrationSource,
+	current, version uint64, opts []DriverOption) error {
+	if err := cloneSchema(ctx, scratch.admin, scratch.Client, d.cfg.DatabaseName, scratch.Name,
+		d.internalTables()); err != nil {
+		return err
+	}
+
+	drv, err := NewDriver(scratch.Client, scratch.Name, append(opts, withoutSideEffects())...)
+	if err != nil {
+		return err
+	}
+	defer drv.Close()
+
+	if current != lightmigrate.NoMigrationVersion {
+		if err := drv.SetVersion(current, false); err != nil {
+			return err
+		}
+	}
+
+	migrator, err := lightmigrate.NewMigrator(source, drv)
+	if err != nil {
+		return err
+	}
+	if err := migrator.Migrate(version); err != nil {
+		return &RehearsalError{Err: err}
+	}
+	return nil
+}
+
+// withoutSideEffects keeps a driver within its own database: custom version stores, notifications, dirty alerts,
+// the run report and the best-effort mode are disabled.
+func withoutSideEffects() DriverOption {
+	return func(d *driver) {
+		d.store = nil
+		d.notifiers = nil
+		d.dirtyHandlers = nil
+		d.cfg.NotificationTable = ""
+		d.cfg.WebhookURL = ""
+		d.cfg.DirtyWebhookURL = ""
+		d.cfg.ReportFile = ""
+		d.cfg.Quarantine = false
+	}
+}
+
+// cloneSchema copies the structure of all tables, views, routines and triggers of the database from to the database
+// to, both on the server of admin. client must be connected to the database to. The tables in exclude (and their
+// triggers) are skipped. The tables are created with disabled foreign key checks, so that their foreign keys can
+// reference tables that are created later.
+func cloneSchema(ctx context.Context, admin, client *sql.DB, from, to string, exclude map[string]bool) error {
+	query := "SELECT TABLE_NAME, TABLE_TYPE FROM information_schema.tables WHERE TABLE_SCHEMA = ?"
+	objects, err := queryPairs(ctx, admin, query, from)
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to list tables", Query: []byte(query)}
+	}
+
+	conn, err := client.Conn(ctx)
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to open a connection to the clone"}
+	}
+	defer conn.Close()
+	query = "SET SESSION FOREIGN_KEY_CHECKS = 0"
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to disable foreign key checks", Query: []byte(query)}
+	}
+	defer func() {
+		_, _ = conn.ExecContext(ctx, "SET SESSION FOREIGN_KEY_CHECKS = 1") // the connection is returned to the pool
+	}()
+
+	var views []string
+	for _, object := range objects {
+		name, tableType := object[0], object[1]
+		switch {
+		case exclude[name]:
+			continue
+		case tableType == "VIEW":
+			views = append(views, name)
+			continue
+		}
+
+		definition, err := showCreate(ctx, admin, "SHOW CREATE TABLE `"+from+"`.`"+name+"`")
+		if err != nil {
+			return err
+		}
+		if err := execClone(ctx, conn, "failed to clone table", from, to, definition); err != nil {
+			return err
+		}
+	}
+
+	query = "SELECT ROUTINE_NAME, ROUTINE_TYPE FROM information_schema.routines WHERE ROUTINE_SCHEMA = ?"
+	routines, err := queryPairs(ctx, admin, query, from)
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to list routines", Query: []byte(query)}
+	}
+	for _, routine := range routines {
+		definition, err := showCreateColumn(ctx, admin, "SHOW CREATE "+routine[1]+" `"+from+"`.`"+routine[0]+"`", 2)
+		if err != nil {
+			return err
+		}
+		if err := execClone(ctx, conn, "failed to clone routine", from, to, definition); err != nil {
+			return err
+		}
+	}
+
+	// views may depend on other views, failed views are retried as long as the previous pass made progress
+	definitions := make(map[string]string, len(views))
+	for _, view := range views {
+		definition, err := showCreate(ctx, admin, "SHOW CREATE VIEW `"+from+"`.`"+view+"`")
+		if err != nil {
+			return err
+		}
+		definitions[view] = cloneDefinition(from, to, definition)
+	}
+	for len(views) > 0 {
+		var failed []string
+		var lastErr error
+		for _, view := range views {
+			if _, err := conn.ExecContext(ctx, definitions[view]); err != nil {
+				failed, lastErr = append(failed, view), err
+			}
+		}
+		if len(failed) == len(views) {
+			return &lightmigrate.DriverError{OrigErr: lastErr, Msg: "failed to clone view",
+				Query: []byte(definitions[failed[len(failed)-1]])}
+		}
+		views = failed
+	}
+
+	// triggers are created in their order of execution, so that the clone keeps the order of each table
+	query = "SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE FROM information_schema.triggers WHERE TRIGGER_SCHEMA = ? " +
+		"ORDER BY EVENT_OBJECT_TABLE, EVENT_MANIPULATION, ACTION_TIMING, ACTION_ORDER"
+	triggers, err := queryPairs(ctx, admin, query, from)
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to list triggers", Query: []byte(query)}
+	}
+	for _, trigger := range triggers {
+		if exclude[trigger[1]] {
+			continue
+		}
+		definition, err := showCreateColumn(ctx, admin, "SHOW CREATE TRIGGER `"+from+"`.`"+trigger[0]+"`", 2)
+		if err != nil {
+			return err
+		}
+		if err := execClone(ctx, conn, "failed to clone trigger", from, to, definition); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cloneDefinition adapts the definition of an object of the database from to the database to.
+func cloneDefinition(from, to, definition string) string {
+	definition = definerClause.ReplaceAllString(definition, "")
+	return strings.ReplaceAll(definition, "`"+from+"`.", "`"+to+"`.")
+}
+
+// execClone creates an object of the clone from its definition.
+func execClone(ctx context.Context, conn *sql.Conn, msg, from, to, definition string) error {
+	query := cloneDefinition(from, to, definition)
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: msg, Query: []byte(query)}
+	}
+	return nil
+}
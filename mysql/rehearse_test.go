@@ -0,0 +1,157 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func Test_cloneSchema(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case strings.HasPrefix(call.Query, "SELECT TABLE_NAME, TABLE_TYPE"):
+			return fakeResponse{Columns: []string{"TABLE_NAME", "TABLE_TYPE"}, Rows: [][]sqldriver.Value{
+				{"schema_migrations", "BASE TABLE"}, {"users", "BASE TABLE"}, {"active_users", "VIEW"},
+			}}
+		case strings.HasPrefix(call.Query, "SHOW CREATE TABLE"):
+			return fakeResponse{Columns: []string{"Table", "Create Table"}, Rows: [][]sqldriver.Value{{
+				"users", "CREATE TABLE `users` (`id` int, `team_id` int, CONSTRAINT `fk_team` FOREIGN KEY " +
+					"(`team_id`) REFERENCES `teams` (`id`))",
+			}}}
+		case strings.HasPrefix(call.Query, "SELECT ROUTINE_NAME"):
+			return fakeResponse{Columns: []string{"ROUTINE_NAME", "ROUTINE_TYPE"},
+				Rows: [][]sqldriver.Value{{"user_count", "FUNCTION"}}}
+		case strings.HasPrefix(call.Query, "SHOW CREATE FUNCTION"):
+			return fakeResponse{Columns: []string{"Function", "sql_mode", "Create Function"}, Rows: [][]sqldriver.Value{{
+				"user_count", "", "CREATE DEFINER=`app`@`%` FUNCTION `user_count`() RETURNS int READS SQL DATA " +
+					"RETURN (SELECT COUNT(*) FROM `app`.`users`)",
+			}}}
+		case strings.HasPrefix(call.Query, "SELECT TRIGGER_NAME"):
+			return fakeResponse{Columns: []string{"TRIGGER_NAME", "EVENT_OBJECT_TABLE"},
+				Rows: [][]sqldriver.Value{{"users_bi", "users"}, {"migrations_bi", "schema_migrations"}}}
+		case strings.HasPrefix(call.Query, "SHOW CREATE TRIGGER"):
+			return fakeResponse{Columns: []string{"Trigger", "sql_mode", "SQL Original Statement"},
+				Rows: [][]sqldriver.Value{{"users_bi", "", "CREATE DEFINER=`app`@`%` TRIGGER `users_bi` BEFORE " +
+					"INSERT ON `users` FOR EACH ROW SET NEW.id = NEW.id"}}}
+		case strings.HasPrefix(call.Query, "SHOW CREATE VIEW"):
+			return fakeResponse{Columns: []string{"View", "Create View"}, Rows: [][]sqldriver.Value{{
+				"active_users",
+				"CREATE ALGORITHM=UNDEFINED DEFINER=`app`@`%` SQL SECURITY DEFINER VIEW `active_users` AS " +
+					"select `app`.`users`.`id` AS `id` from `app`.`users`",
+			}}}
+		}
+		return fakeResponse{}
+	})
+
+	err := cloneSchema(context.Background(), db, db, "app", "scratch", map[string]bool{"schema_migrations": true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queries := srv.Queries()
+	want := []string{
+		"SELECT TABLE_NAME, TABLE_TYPE FROM information_schema.tables WHERE TABLE_SCHEMA = ?",
+		"SET SESSION FOREIGN_KEY_CHECKS = 0",
+		"SHOW CREATE TABLE `app`.`users`",
+		"CREATE TABLE `users` (`id` int, `team_id` int, CONSTRAINT `fk_team` FOREIGN KEY (`team_id`) " +
+			"REFERENCES `teams` (`id`))",
+		"SELECT ROUTINE_NAME, ROUTINE_TYPE FROM information_schema.routines WHERE ROUTINE_SCHEMA = ?",
+		"SHOW CREATE FUNCTION `app`.`user_count`",
+		"CREATE FUNCTION `user_count`() RETURNS int READS SQL DATA RETURN (SELECT COUNT(*) FROM `scratch`.`users`)",
+		"SHOW CREATE VIEW `app`.`active_users`",
+		"CREATE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `active_users` AS " +
+			"select `scratch`.`users`.`id` AS `id` from `scratch`.`users`",
+		"SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE FROM information_schema.triggers WHERE TRIGGER_SCHEMA = ? " +
+			"ORDER BY EVENT_OBJECT_TABLE, EVENT_MANIPULATION, ACTION_TIMING, ACTION_ORDER",
+		"SHOW CREATE TRIGGER `app`.`users_bi`",
+		"CREATE TRIGGER `users_bi` BEFORE INSERT ON `users` FOR EACH ROW SET NEW.id = NEW.id",
+		"SET SESSION FOREIGN_KEY_CHECKS = 1",
+	}
+	if strings.Join(queries, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected queries %q, got: %q", want, queries)
+	}
+}
+
+func Test_cloneSchema_ViewDependencies(t *testing.T) {
+	created := map[string]bool{}
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case strings.HasPrefix(call.Query, "SELECT TABLE_NAME, TABLE_TYPE"):
+			return fakeResponse{Columns: []string{"TABLE_NAME", "TABLE_TYPE"}, Rows: [][]sqldriver.Value{
+				{"a", "VIEW"}, {"b", "VIEW"},
+			}}
+		case strings.HasPrefix(call.Query, "SHOW CREATE VIEW `app`.`a`"):
+			return fakeResponse{Columns: []string{"View", "Create View"},
+				Rows: [][]sqldriver.Value{{"a", "CREATE VIEW `a` AS select * from `b`"}}}
+		case strings.HasPrefix(call.Query, "SHOW CREATE VIEW `app`.`b`"):
+			return fakeResponse{Columns: []string{"View", "Create View"},
+				Rows: [][]sqldriver.Value{{"b", "CREATE VIEW `b` AS select 1"}}}
+		case call.Query == "CREATE VIEW `a` AS select * from `b`" && !created["b"]:
+			return fakeResponse{Err: errors.New("table b doesn't exist")}
+		case strings.HasPrefix(call.Query, "CREATE VIEW"):
+			created[call.Query[len("CREATE VIEW `"):len("CREATE VIEW `")+1]] = true
+		}
+		return fakeResponse{}
+	})
+
+	if err := cloneSchema(context.Background(), db, db, "app", "scratch", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !created["a"] || !created["b"] {
+		t.Fatalf("unexpected views, got: %v", created)
+	}
+}
+
+func Test_driver_rehearse(t *testing.T) {
+	errSyntax := errors.New("syntax error")
+	tests := []struct {
+		name    string
+		fail    bool
+		wantErr error
+	}{
+		{"success", false, nil},
+		{"failure", true, ErrRehearsalFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+				if tt.fail && strings.HasPrefix(call.Query, "CREATE TABLE a") {
+					return fakeResponse{Err: errSyntax}
+				}
+				return defaultFakeHandler(call)
+			})
+			d := &driver{client: db, cfg: &config{DatabaseName: "app", MigrationsTable: "schema_migrations"}}
+			scratch := &scratchDatabase{Name: "scratch", Client: db, admin: db}
+
+			err := d.rehearse(context.Background(), scratch, testSource(t), 0, 1, nil)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && (!errors.Is(err, tt.wantErr) || !errors.Is(err, errSyntax)) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+
+			found := false
+			for _, query := range srv.Queries() {
+				found = found || query == "CREATE TABLE a (id int)"
+			}
+			if !found {
+				t.Fatalf("migration was not rehearsed, got: %q", srv.Queries())
+			}
+		})
+	}
+}
+
+func Test_withoutSideEffects(t *testing.T) {
+	d := &driver{cfg: &config{NotificationTable: "deployments", WebhookURL: "http://localhost", Quarantine: true,
+		DirtyWebhookURL: "http://localhost"}, store: &memoryVersionStore{}, notifiers: []Notifier{nil},
+		dirtyHandlers: []DirtyHandler{nil}}
+
+	withoutSideEffects()(d)
+	if d.store != nil || d.notifiers != nil || d.dirtyHandlers != nil || d.cfg.NotificationTable != "" ||
+		d.cfg.WebhookURL != "" || d.cfg.DirtyWebhookURL != "" || d.cfg.Quarantine {
+		t.Fatalf("unexpected side effects: %+v", d.cfg)
+	}
+}
@@ -0,0 +1,57 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// WithAllowReplica allows migrations on servers with read_only (or super_read_only) enabled, e.g. replica-only
+// maintenance with sql_log_bin=0. By default, the driver refuses to migrate read-only servers, as they are
+// usually replicas that were selected by mistake.
+func WithAllowReplica(allow bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.AllowReplica = allow
+	}
+}
+
+// detectReadOnly checks if read_only or super_read_only is enabled on the server. Detection failures are logged,
+// the server is then treated as writable.
+func (d *driver) detectReadOnly(ctx context.Context) {
+	rows, err := d.client.QueryContext(ctx, "SHOW GLOBAL VARIABLES WHERE Variable_name IN ('read_only', 'super_read_only')")
+	if err != nil {
+		d.logf("failed to detect read-only server: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, value sql.NullString
+		if err := rows.Scan(&name, &value); err != nil {
+			d.logf("failed to detect read-only server: %v", err)
+			return
+		}
+		if strings.EqualFold(value.String, "ON") || value.String == "1" {
+			d.server.ReadOnly = true
+		}
+	}
+}
+
+// checkReadOnly refuses read-only servers, unless they were allowed using WithAllowReplica.
+func (d *driver) checkReadOnly() error {
+	if !d.server.ReadOnly {
+		return nil
+	}
+
+	if d.cfg.AllowReplica {
+		d.logf("server of database %s is read-only, migrating it anyway as replicas are allowed", d.cfg.DatabaseName)
+		return nil
+	}
+
+	return &lightmigrate.DriverError{
+		OrigErr: ErrReadOnlyServer,
+		Msg:     "refusing to migrate a read-only server (replica), use WithAllowReplica to migrate it anyway",
+	}
+}
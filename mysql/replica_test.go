@@ -0,0 +1,43 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func replicaHandler(call fakeCall) fakeResponse {
+	if strings.HasPrefix(call.Query, "SHOW GLOBAL VARIABLES WHERE Variable_name IN ('read_only'") {
+		return fakeResponse{
+			Columns: []string{"Variable_name", "Value"},
+			Rows:    [][]sqldriver.Value{{"read_only", "ON"}, {"super_read_only", "OFF"}},
+		}
+	}
+	return defaultFakeHandler(call)
+}
+
+func TestNewDriver_ReadOnly(t *testing.T) {
+	db, srv := newFakeDB(t, replicaHandler)
+
+	if _, err := NewDriver(db, "testdb"); !errors.Is(err, ErrReadOnlyServer) {
+		t.Fatalf("expected error %v, got: %v", ErrReadOnlyServer, err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "CREATE TABLE") {
+			t.Fatalf("no tables must be created on a read-only server: %s", query)
+		}
+	}
+}
+
+func TestNewDriver_AllowReplica(t *testing.T) {
+	db, _ := newFakeDB(t, replicaHandler)
+
+	d, err := NewDriver(db, "testdb", WithAllowReplica(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !d.Config().ReadOnly {
+		t.Fatalf("read-only server was not detected")
+	}
+}
@@ -0,0 +1,144 @@
+package mysql
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// RunReport summarizes a migration run, see WithReportFile.
+type RunReport struct {
+	Database  string `json:"database"`
+	Namespace string `json:"namespace,omitempty"`
+	// StartedAt is the time the migration lock was acquired, FinishedAt the time it was released.
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Duration   time.Duration `json:"duration_ns"`
+	// Success is false if the run left the database dirty or a post-run statement failed.
+	Success bool `json:"success"`
+	// Version and Dirty are the final state of the database.
+	Version uint64 `json:"version"`
+	Dirty   bool   `json:"dirty"`
+	// Failure describes the failed migration of a dirty run.
+	Failure *MigrationFailure `json:"failure,omitempty"`
+	// ChecksumAlgorithm is the algorithm of the migration checksums, see WithChecksumAlgorithm.
+	ChecksumAlgorithm ChecksumAlgorithm `json:"checksum_algorithm"`
+	// Migrations are the migrations that were executed within the run, in the order of execution.
+	Migrations []MigrationReport `json:"migrations"`
+}
+
+// MigrationReport describes a single migration of a RunReport.
+type MigrationReport struct {
+	Version     uint64 `json:"version"`
+	Description string `json:"description,omitempty"`
+	// Checksum is the checksum of the migration as stored, empty if the migration failed.
+	Checksum string `json:"checksum,omitempty"`
+	// Error is the error of a failed or quarantined migration.
+	Error string `json:"error,omitempty"`
+	MigrationResult
+}
+
+// WithReportFile writes a RunReport (JSON) to the file after each migration run, once the migration lock was
+// released. Driver operations that take the lock outside of a run (e.g. NewDriver, MarkApplied or Repair) do not
+// write a report. The report lists the applied migrations with their durations, warnings and checksums, and the final
+// state of the database, e.g. to attach it to CI job artifacts. An existing file is replaced. Errors are logged,
+// they do not fail the run.
+func WithReportFile(path string) DriverOption {
+	return func(d *driver) {
+		d.cfg.ReportFile = path
+	}
+}
+
+// reportMigration adds the migration to the report of the current run, see WithReportFile.
+func (d *driver) reportMigration(state *migrationState, duration time.Duration, migrationErr error) {
+	if d.cfg.ReportFile == "" || !d.runActive {
+		return
+	}
+
+	migration := MigrationReport{
+		Version:         d.runningVersion,
+		Description:     state.Description,
+		MigrationResult: state.Result.result,
+	}
+	migration.Duration = duration
+	if migrationErr != nil {
+		migration.Error = truncateMessage(migrationErr.Error(), maxErrorMessageLength)
+	}
+	if state.Checksum != nil && migrationErr == nil {
+		migration.Checksum = state.Checksum.Sum()
+	}
+	d.runReports = append(d.runReports, migration)
+}
+
+// writeReport writes the report of the finished run. If no migration was applied, the version is read from the
+// version store. Failed reports are logged.
+func (d *driver) writeReport(applied, dirty bool, hookErr error, failure *MigrationFailure) {
+	if d.cfg.ReportFile == "" {
+		return
+	}
+
+	migrations := d.runReports
+	d.runReports = nil
+	if migrations == nil {
+		migrations = []MigrationReport{}
+	}
+
+	report := RunReport{
+		Database:          d.cfg.DatabaseName,
+		Namespace:         d.cfg.Namespace,
+		FinishedAt:        time.Now(),
+		Success:           !dirty && hookErr == nil,
+		Version:           d.runVersion,
+		Dirty:             dirty,
+		ChecksumAlgorithm: d.cfg.ChecksumAlgorithm,
+		Migrations:        migrations,
+	}
+	if dirty {
+		report.Failure = failure // quarantined migrations fail without leaving the database dirty
+	}
+	report.StartedAt = report.FinishedAt
+	if started := atomic.LoadInt64(&d.runStarted); started != 0 {
+		report.StartedAt = time.Unix(0, started)
+	}
+	report.Duration = report.FinishedAt.Sub(report.StartedAt)
+	if !applied {
+		ctx, cancel := d.internalContext()
+		version, dirty, err := d.versionStore().Get(ctx)
+		cancel()
+		if err != nil {
+			d.logf("failed to read the version for the migration report: %v", err)
+		}
+		report.Version, report.Dirty = version, dirty
+		report.Success = err == nil && !dirty && hookErr == nil
+	}
+
+	if err := writeReportFile(d.cfg.ReportFile, &report); err != nil {
+		d.logf("failed to write migration report %s: %v", d.cfg.ReportFile, err)
+	}
+}
+
+// writeReportFile writes the report to a temporary file, which replaces the file once it is complete, so that
+// readers never see a partial report.
+func writeReportFile(path string, report *RunReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // no-op after a successful rename
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
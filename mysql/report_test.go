@@ -0,0 +1,150 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readReport decodes the report file.
+func readReport(t *testing.T, path string) RunReport {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var report RunReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return report
+}
+
+func Test_driver_Unlock_Report(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations",
+		SplitStatements: true, ChecksumAlgorithm: ChecksumSHA256, ReportFile: path}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, version := range []uint64{4, 5} {
+		if err := d.SetVersion(version, true); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := d.RunMigration(strings.NewReader("-- description: add users\nCREATE TABLE users (id int);")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := d.SetVersion(version, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := readReport(t, path)
+	if !report.Success || report.Dirty || report.Version != 5 || report.Database != "testdb" ||
+		report.ChecksumAlgorithm != ChecksumSHA256 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(report.Migrations) != 2 || report.Migrations[0].Version != 4 || report.Migrations[1].Version != 5 {
+		t.Fatalf("unexpected migrations: %+v", report.Migrations)
+	}
+	migration := report.Migrations[0]
+	if migration.Statements != 1 || migration.Description != "add users" || len(migration.Checksum) != 64 ||
+		migration.Error != "" {
+		t.Fatalf("unexpected migration: %+v", migration)
+	}
+}
+
+func Test_driver_Unlock_ReportDirty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	errSyntax := errors.New("syntax error")
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "ALTER TABLE") {
+			return fakeResponse{Err: errSyntax}
+		}
+		return defaultFakeHandler(call)
+	})
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations",
+		SplitStatements: true, ReportFile: path}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.SetVersion(7, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.RunMigration(strings.NewReader("ALTER TABLE users ADD name text;")); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := readReport(t, path)
+	if report.Success || !report.Dirty || report.Version != 7 || report.Failure == nil ||
+		report.Failure.StatementIndex != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(report.Migrations) != 1 || report.Migrations[0].Error == "" || report.Migrations[0].Checksum != "" {
+		t.Fatalf("unexpected migrations: %+v", report.Migrations)
+	}
+}
+
+func Test_driver_Unlock_ReportWithoutMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	db, _ := newFakeDB(t, versionHandler([]sqldriver.Value{int64(3), int64(0), nil, nil, int64(1)}))
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", ReportFile: path}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := readReport(t, path)
+	if !report.Success || report.Version != 3 || report.Migrations == nil || len(report.Migrations) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Fatalf("unexpected files, got: %v", entries)
+	}
+}
+
+func Test_driver_Unlock_ReportOperations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	db, _ := newFakeDB(t, nil)
+	d := &driver{client: db, logger: log.New(io.Discard, "", 0),
+		cfg: &config{DatabaseName: "testdb", MigrationsTable: "migrations", Locking: true, ReportFile: path}}
+
+	// operations outside of a run, and an Unlock without a preceding Lock do not write a report
+	if err := d.prepareMigrationTable(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.MarkApplied(5, "hotfix"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.Unlock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("unexpected report file, got: %v", err)
+	}
+
+	rehearsal := &driver{cfg: &config{ReportFile: path}}
+	withoutSideEffects()(rehearsal)
+	if rehearsal.cfg.ReportFile != "" {
+		t.Fatalf("unexpected report file in rehearsal, got: %s", rehearsal.cfg.ReportFile)
+	}
+}
@@ -0,0 +1,146 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"sync"
+	"time"
+)
+
+// MigrationResult describes what was executed by a single migration, see Driver.RunMigrationWithResult.
+type MigrationResult struct {
+	// Statements is the number of executed statements.
+	Statements int `json:"statements"`
+	// RowsAffected is the sum of the rows that were changed by the statements.
+	RowsAffected int64 `json:"rows_affected"`
+	// Warnings contains the warnings of the driver safety checks and, if enabled by WithWarningsCapture,
+	// the warnings reported by the server.
+	Warnings []Warning `json:"warnings,omitempty"`
+	// Estimates contains the estimated impact of the UPDATE and DELETE statements, see WithImpactEstimation.
+	Estimates []ImpactEstimate `json:"estimates,omitempty"`
+	// Duration is the execution time of the migration.
+	Duration time.Duration `json:"duration_ns"`
+	// Skipped is true, if the migration was recorded as applied without executing it (skip directive).
+	Skipped bool `json:"skipped,omitempty"`
+	// Objects are the schema objects changed by the DDL statements, see WithObjectTracking.
+	Objects []SchemaObject `json:"objects,omitempty"`
+}
+
+// Warning is a single warning that was raised by a migration statement.
+type Warning struct {
+	StatementIndex int    `json:"statement_index"`
+	Line           int    `json:"line"`
+	Level          string `json:"level"`
+	Code           uint16 `json:"code,omitempty"` // the MySQL warning code, 0 for driver warnings
+	Message        string `json:"message"`
+}
+
+// resultRecorder collects the result of a migration. It is safe for concurrent use by parallel statements.
+type resultRecorder struct {
+	mux    sync.Mutex
+	result MigrationResult
+}
+
+// record adds an executed statement to the result.
+func (r *resultRecorder) record(affected int64, warnings []Warning) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.result.Statements++
+	r.result.RowsAffected += affected
+	r.result.Warnings = append(r.result.Warnings, warnings...)
+}
+
+// statements returns the number of statements that were executed so far.
+func (r *resultRecorder) statements() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.result.Statements
+}
+
+// skip marks the migration as skipped.
+func (r *resultRecorder) skip() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.result.Skipped = true
+}
+
+// estimate adds the impact estimate of a statement to the result.
+func (r *resultRecorder) estimate(estimate ImpactEstimate) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.result.Estimates = append(r.result.Estimates, estimate)
+}
+
+// track adds the schema objects of a statement to the result.
+func (r *resultRecorder) track(objects []SchemaObject) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.result.Objects = append(r.result.Objects, objects...)
+}
+
+// objects returns the schema objects that were changed so far.
+func (r *resultRecorder) objects() []SchemaObject {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	return r.result.Objects
+}
+
+// warn adds a driver warning to the result.
+func (r *resultRecorder) warn(warning Warning) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.result.Warnings = append(r.result.Warnings, warning)
+}
+
+// RunMigrationWithResult applies a single migration, like RunMigration, and reports what was executed.
+// The result is also returned if the migration failed, it then covers all statements up to the failure.
+func (d *driver) RunMigrationWithResult(migration io.Reader) (*MigrationResult, error) {
+	state := &migrationState{}
+	start := time.Now()
+
+	err := d.runMigration(migration, state)
+
+	result := state.Result.result
+	result.Duration = time.Since(start)
+	return &result, err
+}
+
+// captureWarnings fetches the warnings of the last statement that was executed within the session.
+func (d *driver) captureWarnings(ctx context.Context, session *sql.Conn, stmt statement) []Warning {
+	if d.featureDisabled(featureWarnings) {
+		return nil
+	}
+
+	rows, err := session.QueryContext(ctx, "SHOW WARNINGS")
+	if err != nil {
+		if d.degrade(featureWarnings, err) {
+			return nil
+		}
+		d.logf("failed to fetch warnings of statement %d: %v", stmt.Index, err)
+		return nil
+	}
+	defer rows.Close()
+
+	var warnings []Warning
+	for rows.Next() {
+		warning := Warning{StatementIndex: stmt.Index, Line: stmt.Line}
+		if err := rows.Scan(&warning.Level, &warning.Code, &warning.Message); err != nil {
+			d.logf("failed to read warnings of statement %d: %v", stmt.Index, err)
+			return warnings
+		}
+		warnings = append(warnings, warning)
+	}
+	if err := rows.Err(); err != nil {
+		d.logf("failed to read warnings of statement %d: %v", stmt.Index, err)
+	}
+
+	return warnings
+}
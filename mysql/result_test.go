@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithWarningsCapture(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithWarningsCapture(true)(d)
+	if !d.cfg.CaptureWarnings {
+		t.Fatalf("failed to enable warnings capture")
+	}
+}
+
+func Test_driver_RunMigrationWithResult(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case call.Query == "SHOW WARNINGS":
+			return fakeResponse{
+				Columns: []string{"Level", "Code", "Message"},
+				Rows:    [][]sqldriver.Value{{"Warning", int64(1265), "Data truncated for column 'a' at row 1"}},
+			}
+		case strings.HasPrefix(call.Query, "UPDATE"):
+			return fakeResponse{RowsAffected: 3}
+		}
+		return fakeResponse{RowsAffected: 1}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true, CaptureWarnings: true, MaxAffectedRows: 2}}
+
+	result, err := d.RunMigrationWithResult(strings.NewReader("INSERT INTO t VALUES (1);\nUPDATE t SET a = 2;"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Statements != 2 || result.RowsAffected != 4 || result.Duration <= 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.Warnings) != 3 || result.Warnings[0].Code != 1265 || result.Warnings[1].StatementIndex != 2 ||
+		result.Warnings[2].Code != 0 {
+		t.Fatalf("unexpected warnings: %+v", result.Warnings)
+	}
+}
+
+func Test_driver_RunMigrationWithResult_Error(t *testing.T) {
+	errFailed := errors.New("syntax error")
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT 2") {
+			return fakeResponse{Err: errFailed}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true, MigrationsTable: "migrations"}}
+
+	result, err := d.RunMigrationWithResult(strings.NewReader("SELECT 1;\nSELECT 2;\nSELECT 3;"))
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("unexpected error %v, got: %v", errFailed, err)
+	}
+	if result == nil || result.Statements != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
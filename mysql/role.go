@@ -0,0 +1,92 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// WithRole activates the role (e.g. "ddl_admin" or "ddl_admin@%") on all migration sessions before statements are
+// executed, for servers that grant the DDL privileges via MySQL 8 (or MariaDB) roles. The role is deactivated
+// before the connection is returned to the pool, connections on which it cannot be deactivated are closed.
+func WithRole(role string) DriverOption {
+	return func(d *driver) {
+		d.cfg.Role = role
+	}
+}
+
+// splitRole splits the configured role into the name and the (optional) host.
+func splitRole(role string) (name, host string) {
+	if idx := strings.LastIndex(role, "@"); idx > 0 {
+		return role[:idx], role[idx+1:]
+	}
+	return role, ""
+}
+
+// quoteAccountPart quotes a part of a role or account name as string literal.
+func quoteAccountPart(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// activateRole executes SET ROLE on the session and verifies that the role is active.
+func (d *driver) activateRole(ctx context.Context, conn *sql.Conn) error {
+	if d.cfg.Role == "" {
+		return nil
+	}
+
+	name, host := splitRole(d.cfg.Role)
+	query := "SET ROLE " + quoteAccountPart(name)
+	if host != "" {
+		query += "@" + quoteAccountPart(host)
+	}
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to activate role " + d.cfg.Role, Query: []byte(query)}
+	}
+
+	var current sql.NullString
+	if err := conn.QueryRowContext(ctx, "SELECT CURRENT_ROLE()").Scan(&current); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to verify role " + d.cfg.Role, Query: []byte("SELECT CURRENT_ROLE()")}
+	}
+	if !roleActive(current.String, name) {
+		return &lightmigrate.DriverError{
+			OrigErr: ErrRoleNotActive,
+			Msg:     fmt.Sprintf("role %s is not active after SET ROLE, active roles: %s", d.cfg.Role, current.String),
+		}
+	}
+
+	return nil
+}
+
+// roleActive checks if the role is contained in the result of CURRENT_ROLE(), e.g. "`ddl_admin`@`%`,`reader`@`%`"
+// on MySQL or "ddl_admin" on MariaDB.
+func roleActive(current, name string) bool {
+	for _, role := range strings.Split(current, ",") {
+		active, _ := splitRole(strings.TrimSpace(role))
+		if strings.Trim(active, "`'") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// deactivateRole restores the default roles of the account, so that the role does not leak into application queries.
+// MariaDB has no SET ROLE DEFAULT, the role is deactivated with SET ROLE NONE. It reports false if the role could
+// not be deactivated.
+func (d *driver) deactivateRole(ctx context.Context, conn *sql.Conn) bool {
+	if d.cfg.Role == "" {
+		return true
+	}
+
+	query := "SET ROLE DEFAULT"
+	if d.server.MariaDB {
+		query = "SET ROLE NONE"
+	}
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		d.logf("failed to reset role %s, the connection is closed: %v", d.cfg.Role, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func roleHandler(current string) fakeHandler {
+	return func(call fakeCall) fakeResponse {
+		if call.Query == "SELECT CURRENT_ROLE()" {
+			return fakeResponse{Columns: []string{"role"}, Rows: [][]sqldriver.Value{{current}}}
+		}
+		return fakeResponse{}
+	}
+}
+
+func Test_roleActive(t *testing.T) {
+	tests := []struct {
+		current string
+		want    bool
+	}{
+		{"`ddl_admin`@`%`", true},
+		{"`reader`@`%`,`ddl_admin`@`localhost`", true},
+		{"ddl_admin", true},
+		{"NONE", false},
+		{"`ddl_admin_old`@`%`", false},
+	}
+	for _, tt := range tests {
+		if got := roleActive(tt.current, "ddl_admin"); got != tt.want {
+			t.Fatalf("unexpected result %t for %s, got: %t", tt.want, tt.current, got)
+		}
+	}
+}
+
+func Test_driver_RunMigration_Role(t *testing.T) {
+	db, srv := newFakeDB(t, roleHandler("`ddl_admin`@`%`"))
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true, Role: "ddl_admin@%"}}
+
+	if err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int);")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"SET ROLE 'ddl_admin'@'%'", "SELECT CURRENT_ROLE()", "CREATE TABLE a (id int)", "SET ROLE DEFAULT"}
+	if queries := srv.Queries(); strings.Join(queries, ";") != strings.Join(want, ";") {
+		t.Fatalf("unexpected queries %q, got: %q", want, queries)
+	}
+}
+
+func Test_driver_RunMigration_RoleNotActive(t *testing.T) {
+	db, srv := newFakeDB(t, roleHandler("NONE"))
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true, Role: "ddl_admin"}}
+
+	if err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int);")); !errors.Is(err, ErrRoleNotActive) {
+		t.Fatalf("expected error %v, got: %v", ErrRoleNotActive, err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "CREATE TABLE a") {
+			t.Fatalf("statement must not be executed without the role")
+		}
+	}
+}
+
+func Test_driver_RunMigration_RoleMariaDB(t *testing.T) {
+	db, srv := newFakeDB(t, roleHandler("ddl_admin"))
+	d := &driver{client: db, server: serverInfo{MariaDB: true},
+		cfg: &config{MigrationsTable: "migrations", SplitStatements: true, Role: "ddl_admin"}}
+
+	if err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int);")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if queries := srv.Queries(); queries[len(queries)-1] != "SET ROLE NONE" {
+		t.Fatalf("unexpected queries, got: %q", queries)
+	}
+}
+
+func Test_driver_RunMigration_RoleResetFailed(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if call.Query == "SET ROLE DEFAULT" {
+			return fakeResponse{Err: errors.New("connection lost")}
+		}
+		return roleHandler("`ddl_admin`@`%`")(call)
+	})
+	d := &driver{client: db, logger: log.New(io.Discard, "", 0),
+		cfg: &config{MigrationsTable: "migrations", SplitStatements: true, Role: "ddl_admin"}}
+
+	if err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int);")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the connection that may still have the role active is not returned to the pool
+	if stats := db.Stats(); stats.OpenConnections != 0 {
+		t.Fatalf("unexpected open connections, got: %d", stats.OpenConnections)
+	}
+}
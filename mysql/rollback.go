@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultRollbackFloor is the lowest version that can be reached by down migrations without confirmation, see
+// WithRollbackFloor. By default, rolling back the first migration (to version 0) must be confirmed.
+const DefaultRollbackFloor = 1
+
+// WithRollbackFloor sets the lowest version that can be reached by down migrations. Rollbacks below the floor are
+// rejected with ErrRollbackNotAllowed, unless they are confirmed by WithAllowFullRollback. Zero allows all rollbacks.
+func WithRollbackFloor(version uint64) DriverOption {
+	return func(d *driver) {
+		d.cfg.RollbackFloor = version
+	}
+}
+
+// WithAllowFullRollback confirms a rollback below the rollback floor (see WithRollbackFloor). The token must be
+// created by RollbackToken for the current and the target version, so that a confirmation cannot be copied to
+// another environment or reused after the database was migrated further by accident.
+func WithAllowFullRollback(token string) DriverOption {
+	return func(d *driver) {
+		d.cfg.FullRollbackToken = token
+	}
+}
+
+// RollbackToken returns the confirmation of a rollback of the database from the current to the target version,
+// see WithAllowFullRollback. The token is also reported by the ErrRollbackNotAllowed error.
+func RollbackToken(database string, current, target uint64) string {
+	return fmt.Sprintf("%s:%d:%d", database, current, target)
+}
+
+// checkRollbackFloor rejects down migrations (and versions marked as applied) below the rollback floor, unless they
+// were confirmed. The current version is only read if the target version is below the floor.
+func (d *driver) checkRollbackFloor(version uint64) error {
+	if version >= d.cfg.RollbackFloor {
+		return nil
+	}
+
+	current, _, err := d.GetVersion()
+	if err != nil {
+		return err
+	}
+	if current <= version {
+		return nil // no rollback
+	}
+	token := RollbackToken(d.cfg.DatabaseName, current, version)
+	if d.cfg.FullRollbackToken == token {
+		return nil
+	}
+
+	return &lightmigrate.DriverError{OrigErr: ErrRollbackNotAllowed, Msg: fmt.Sprintf(
+		"rollback from version %d to %d is below the rollback floor %d, confirm it using WithAllowFullRollback(%q)",
+		current, version, d.cfg.RollbackFloor, token)}
+}
@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestWithAllowFullRollback(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithRollbackFloor(3)(d)
+	WithAllowFullRollback(RollbackToken("app", 5, 0))(d)
+	if d.cfg.RollbackFloor != 3 || d.cfg.FullRollbackToken != "app:5:0" {
+		t.Fatalf("failed to set rollback options")
+	}
+}
+
+func Test_driver_SetVersion_RollbackFloor(t *testing.T) {
+	tests := []struct {
+		name    string
+		version uint64
+		token   string
+		wantErr error
+	}{
+		{"above floor", 2, "", nil},
+		{"up migration", 4, "", nil},
+		{"below floor", 0, "", ErrRollbackNotAllowed},
+		{"confirmed", 0, "app:3:0", nil},
+		{"database name", 0, "app", ErrRollbackNotAllowed},
+		{"other version", 0, "app:4:0", ErrRollbackNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, srv := newFakeDB(t, versionHandler([]sqldriver.Value{int64(3), int64(0), nil, nil, nil}))
+			d := &driver{client: db, cfg: &config{DatabaseName: "app", MigrationsTable: "migrations",
+				RollbackFloor: 2, FullRollbackToken: tt.token}}
+
+			err := d.SetVersion(tt.version, true)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+				}
+				for _, query := range srv.Queries() {
+					if strings.HasPrefix(query, "INSERT") {
+						t.Fatalf("unexpected version update: %s", query)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_driver_MarkApplied_RollbackFloor(t *testing.T) {
+	db, srv := newFakeDB(t, versionHandler([]sqldriver.Value{int64(3), int64(0), nil, nil, nil}))
+	d := &driver{client: db, logger: log.New(io.Discard, "", 0), cfg: &config{DatabaseName: "app",
+		MigrationsTable: "migrations", RollbackFloor: 2}}
+
+	if err := d.MarkApplied(1, "restored backup"); !errors.Is(err, ErrRollbackNotAllowed) ||
+		!strings.Contains(err.Error(), `WithAllowFullRollback("app:3:1")`) {
+		t.Fatalf("expected error %v, got: %v", ErrRollbackNotAllowed, err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "INSERT") {
+			t.Fatalf("unexpected version update: %s", query)
+		}
+	}
+
+	d.cfg.FullRollbackToken = RollbackToken("app", 3, 1)
+	if err := d.MarkApplied(1, "restored backup"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
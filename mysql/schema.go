@@ -0,0 +1,198 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+// schemaRaceAttempts is the number of attempts of an internal table change that failed due to a concurrent
+// process, see isSchemaRace.
+const schemaRaceAttempts = 5
+
+// schemaRaceBackoff is the delay before the first retry of an internal table change, it doubles with each attempt.
+const schemaRaceBackoff = 100 * time.Millisecond
+
+// columnDefinition describes a single column of an internal table.
+type columnDefinition struct {
+	Name       string
+	Definition string
+}
+
+// versionTableColumns are the columns of the migration table. Columns that were added in later releases
+// must be nullable, so that they can be added to existing tables.
+var versionTableColumns = []columnDefinition{
+	{Name: "version", Definition: "bigint not null primary key"},
+	{Name: "dirty", Definition: "boolean not null"},
+	{Name: "error_statement", Definition: "int null"},
+	{Name: "error_code", Definition: "int null"},
+	{Name: "error_message", Definition: "text null"},
+	{Name: "previous_version", Definition: "bigint null"},
+	{Name: "idempotent", Definition: "boolean null"},
+	{Name: "attempts", Definition: "int null"},
+}
+
+// createTableQuery builds the CREATE TABLE IF NOT EXISTS query for an internal table.
+func createTableQuery(table string, columns []columnDefinition) string {
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = "`" + column.Name + "` " + column.Definition
+	}
+
+	return "CREATE TABLE IF NOT EXISTS `" + table + "` (" + strings.Join(definitions, ", ") + ")"
+}
+
+// createTable creates an internal table, if it does not exist. Races with concurrent processes are retried.
+func (d *driver) createTable(ctx context.Context, table string, columns []columnDefinition, msg string) error {
+	return d.createTableWithOptions(ctx, table, columns, d.tableOptions(), msg)
+}
+
+// createTableWithOptions creates an internal table with the table options, e.g. " ENGINE=InnoDB".
+func (d *driver) createTableWithOptions(ctx context.Context, table string, columns []columnDefinition, options,
+	msg string) error {
+	query := createTableQuery(table, columns) + options
+	return d.retrySchemaRace(ctx, table, func() error {
+		if _, err := d.client.ExecContext(ctx, query); err != nil {
+			return &lightmigrate.DriverError{OrigErr: err, Msg: msg, Query: []byte(query)}
+		}
+		return nil
+	})
+}
+
+// isSchemaRace checks if an internal table change failed because many processes (e.g. replicas that start at the
+// same time) change the same table concurrently. CREATE TABLE IF NOT EXISTS is not safe against these races,
+// especially within Galera clusters.
+func isSchemaRace(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+
+	switch mysqlErr.Number {
+	case 1050, // ER_TABLE_EXISTS_ERROR
+		1060, // ER_DUP_FIELDNAME, the column was added by another process
+		1205, // ER_LOCK_WAIT_TIMEOUT, e.g. the metadata lock is held by another process
+		1213, // ER_LOCK_DEADLOCK, also reported for Galera certification conflicts
+		1412, // ER_TABLE_DEF_CHANGED
+		1047: // ER_UNKNOWN_COM_ERROR, the Galera node is not ready (yet)
+		return true
+	}
+	return false
+}
+
+// retrySchemaRace executes the internal table change until it succeeds, fails with an error that is no race
+// (see isSchemaRace), or the maximum number of attempts is reached.
+func (d *driver) retrySchemaRace(ctx context.Context, table string, change func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := change()
+		if err == nil || attempt >= schemaRaceAttempts || !isSchemaRace(err) {
+			return err
+		}
+
+		d.logf("concurrent change of table %s detected, retrying (attempt %d/%d): %v", table, attempt,
+			schemaRaceAttempts, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(schemaRaceBackoff << (attempt - 1)):
+		}
+	}
+}
+
+// ensureColumns adds all columns that are missing in an existing internal table. If the table is changed
+// concurrently, the missing columns are detected again.
+func (d *driver) ensureColumns(ctx context.Context, table string, columns []columnDefinition) error {
+	return d.retrySchemaRace(ctx, table, func() error {
+		return d.addMissingColumns(ctx, table, columns)
+	})
+}
+
+// addMissingColumns adds all columns that are missing in the table.
+func (d *driver) addMissingColumns(ctx context.Context, table string, columns []columnDefinition) error {
+	if d.featureDisabled(featureColumnCheck) {
+		return nil
+	}
+
+	existing, err := d.tableColumns(ctx, table)
+	if err != nil {
+		if d.degrade(featureColumnCheck, err) {
+			return nil // the table was created by this release or was already upgraded
+		}
+		return err
+	}
+
+	var missing []string
+	for _, column := range columns {
+		if _, ok := existing[strings.ToLower(column.Name)]; !ok {
+			missing = append(missing, "ADD COLUMN `"+column.Name+"` "+column.Definition)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	query := "ALTER TABLE `" + table + "` " + strings.Join(missing, ", ")
+	if d.server.supports(featureInstantAddColumn) {
+		instant := query + ", ALGORITHM=INSTANT" // columns are appended, no table rebuild required
+		_, err := d.client.ExecContext(ctx, instant)
+		switch {
+		case err == nil:
+			return nil
+		case !isInstantUnsupported(err):
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to upgrade table " + table, Query: []byte(instant)}
+		}
+		d.logf("table %s cannot be upgraded instantly, falling back to the default algorithm: %v", table, err)
+	}
+
+	if _, err := d.client.ExecContext(ctx, query); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to upgrade table " + table, Query: []byte(query)}
+	}
+
+	return nil
+}
+
+// isInstantUnsupported checks if the server refused ALGORITHM=INSTANT for the table, e.g. for tables with a
+// full-text index or tables that were created in an old row format.
+func isInstantUnsupported(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	return errors.As(err, &mysqlErr) &&
+		(mysqlErr.Number == 1845 || mysqlErr.Number == 1846) // ER_ALTER_OPERATION_NOT_SUPPORTED(_REASON)
+}
+
+// tableColumns returns the lower-cased column names of the given table.
+func (d *driver) tableColumns(ctx context.Context, table string) (map[string]struct{}, error) {
+	query := "SHOW COLUMNS FROM `" + table + "`"
+	rows, err := d.client.QueryContext(ctx, query)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to list columns of table " + table, Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	fields, err := rows.Columns()
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to list columns of table " + table, Query: []byte(query)}
+	}
+
+	columns := make(map[string]struct{})
+	values := make([]sql.RawBytes, len(fields))
+	dest := make([]interface{}, len(fields))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read columns of table " + table, Query: []byte(query)}
+		}
+		columns[strings.ToLower(string(values[0]))] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read columns of table " + table, Query: []byte(query)}
+	}
+
+	return columns, nil
+}
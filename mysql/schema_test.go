@@ -0,0 +1,122 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+func Test_createTableQuery(t *testing.T) {
+	query := createTableQuery("tbl", []columnDefinition{{"a", "int not null"}, {"b", "text null"}})
+	if query != "CREATE TABLE IF NOT EXISTS `tbl` (`a` int not null, `b` text null)" {
+		t.Fatalf("unexpected query: %s", query)
+	}
+}
+
+func Test_driver_ensureColumns(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SHOW COLUMNS") {
+			return fakeResponse{
+				Columns: []string{"Field", "Type", "Null", "Key", "Default", "Extra"},
+				Rows: [][]sqldriver.Value{
+					{"version", "bigint", "NO", "PRI", nil, ""},
+					{"DIRTY", "tinyint(1)", "NO", "", nil, ""},
+				},
+			}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{}}
+
+	columns := []columnDefinition{{"version", "bigint"}, {"dirty", "boolean"}, {"a", "int null"}, {"b", "text null"}}
+	if err := d.ensureColumns(context.Background(), "tbl", columns); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	queries := srv.Queries()
+	want := "ALTER TABLE `tbl` ADD COLUMN `a` int null, ADD COLUMN `b` text null"
+	if len(queries) != 2 || queries[1] != want {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_ensureColumns_Instant(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SHOW COLUMNS") {
+			return fakeResponse{Columns: []string{"Field"}, Rows: [][]sqldriver.Value{{"version"}}}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{}, server: parseServerVersion("8.0.36")}
+
+	if err := d.ensureColumns(context.Background(), "tbl", []columnDefinition{{"version", "bigint"}, {"a", "int null"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "ALTER TABLE `tbl` ADD COLUMN `a` int null, ALGORITHM=INSTANT"
+	if queries := srv.Queries(); len(queries) != 2 || queries[1] != want {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_ensureColumns_InstantUnsupported(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SHOW COLUMNS") {
+			return fakeResponse{Columns: []string{"Field"}, Rows: [][]sqldriver.Value{{"version"}}}
+		}
+		if strings.HasSuffix(call.Query, "ALGORITHM=INSTANT") {
+			return fakeResponse{Err: &gomysql.MySQLError{Number: 1846, Message: "ALGORITHM=INSTANT is not supported. " +
+				"Reason: InnoDB presently supports one FULLTEXT index creation at a time. Try ALGORITHM=COPY/INPLACE."}}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{}, server: parseServerVersion("8.0.36"), logger: log.New(io.Discard, "", 0)}
+
+	if err := d.ensureColumns(context.Background(), "tbl", []columnDefinition{{"version", "bigint"}, {"a", "int null"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "ALTER TABLE `tbl` ADD COLUMN `a` int null"
+	if queries := srv.Queries(); len(queries) != 3 || queries[2] != want {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_createTable_Race(t *testing.T) {
+	attempts := 0
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "CREATE TABLE") {
+			if attempts++; attempts == 1 {
+				return fakeResponse{Err: &gomysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}}
+			}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{}}
+
+	if err := d.createTable(context.Background(), "tbl", versionTableColumns, "failed"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("unexpected attempts 2, got: %d", attempts)
+	}
+}
+
+func Test_driver_createTable_NoRace(t *testing.T) {
+	errDenied := &gomysql.MySQLError{Number: 1142, Message: "CREATE command denied"}
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse { return fakeResponse{Err: errDenied} })
+	d := &driver{client: db, cfg: &config{}}
+
+	if err := d.createTable(context.Background(), "tbl", versionTableColumns, "failed"); !errors.Is(err, errDenied) {
+		t.Fatalf("unexpected error %v, got: %v", errDenied, err)
+	}
+	if len(srv.Queries()) != 1 {
+		t.Fatalf("unexpected retry: %q", srv.Queries())
+	}
+}
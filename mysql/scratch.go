@@ -0,0 +1,76 @@
+package mysql
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+// scratchDatabase is a throwaway database on the server of a DSN, e.g. for verifications and rehearsals.
+type scratchDatabase struct {
+	Name string
+	// Client is connected to the scratch database.
+	Client *sql.DB
+	// DSNConfig is the configuration of the original DSN.
+	DSNConfig *gomysql.Config
+
+	admin *sql.DB
+}
+
+// createScratchDatabase creates an empty database with a random name on the server of the DSN. The database must be
+// dropped after use.
+func createScratchDatabase(ctx context.Context, dsn, purpose string) (*scratchDatabase, error) {
+	dsnCfg, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+
+	adminCfg := dsnCfg.Clone()
+	adminCfg.DBName = ""
+	admin, err := sql.Open("mysql", adminCfg.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database client: %w", err)
+	}
+
+	random := make([]byte, 6)
+	_, _ = rand.Read(random)
+	scratch := &scratchDatabase{
+		Name:      "lightmigrate_" + purpose + "_" + hex.EncodeToString(random),
+		DSNConfig: dsnCfg,
+		admin:     admin,
+	}
+
+	query := "CREATE DATABASE `" + scratch.Name + "`"
+	if _, err := admin.ExecContext(ctx, query); err != nil {
+		_ = admin.Close()
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to create scratch database", Query: []byte(query)}
+	}
+
+	scratchCfg := dsnCfg.Clone()
+	scratchCfg.DBName = scratch.Name
+	if scratch.Client, err = sql.Open("mysql", scratchCfg.FormatDSN()); err != nil {
+		_ = scratch.Drop(ctx)
+		return nil, fmt.Errorf("failed to open database client: %w", err)
+	}
+
+	return scratch, nil
+}
+
+// Drop closes the client and drops the scratch database.
+func (s *scratchDatabase) Drop(ctx context.Context) error {
+	if s.Client != nil {
+		_ = s.Client.Close()
+	}
+	defer s.admin.Close()
+
+	query := "DROP DATABASE IF EXISTS `" + s.Name + "`"
+	if _, err := s.admin.ExecContext(ctx, query); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to drop scratch database", Query: []byte(query)}
+	}
+	return nil
+}
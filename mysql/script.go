@@ -0,0 +1,274 @@
+package mysql
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// scriptDelimiter terminates statements of the script that contain semicolons, e.g. stored procedures.
+const scriptDelimiter = "$$"
+
+// ScriptState describes the version change of a script that was rendered by a ScriptDriver. It can be stored next
+// to the script and is recorded with ImportState once the script was executed.
+type ScriptState struct {
+	Database    string    `json:"database"`
+	FromVersion uint64    `json:"from_version"`
+	ToVersion   uint64    `json:"to_version"`
+	Versions    []uint64  `json:"versions"` // the target versions of the rendered migrations, in order
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// ScriptDriver renders migrations into an SQL script instead of executing them, see NewScriptDriver.
+type ScriptDriver interface {
+	lightmigrate.MigrationDriver
+
+	// State returns the version change of the script that was rendered so far.
+	State() ScriptState
+}
+
+// scriptDriver writes the statements of the migrations and the version updates to the writer.
+type scriptDriver struct {
+	d     *driver
+	w     io.Writer
+	state ScriptState
+	prior *versionRow // the row of the migration table after the script rendered so far, nil if the table is empty
+	err   error       // the first write error, all later writes are skipped
+}
+
+// NewScriptDriver creates a driver that does not need a database connection: the migrations and the updates of
+// the migration table are written to w, e.g. for a DBA who applies the script manually. The database is assumed to
+// be at the given version. Nothing is recorded, once the script was executed, the version change must be recorded
+// with Driver.ImportState, using the State of the script driver.
+//
+// The options of the regular driver apply as far as they affect the generated SQL, e.g. the migration table, the
+// table prefix, statement splitting, WithIncludeFS and WithDecryptor. Directives are rendered as comments, migrations
+// with the skip directive are omitted.
+func NewScriptDriver(w io.Writer, database string, version uint64, opts ...DriverOption) (ScriptDriver, error) {
+	if database == "" {
+		return nil, ErrNoDatabaseName
+	}
+
+	d, err := configureDriver(nil, database, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &scriptDriver{d: d, w: w, state: ScriptState{Database: database, FromVersion: version, ToVersion: version,
+		GeneratedAt: time.Now().UTC()}}
+	if version != lightmigrate.NoMigrationVersion {
+		s.prior = &versionRow{Version: version}
+	}
+	s.printf("-- migration script for database %s, generated at %s\n", database,
+		s.state.GeneratedAt.Format(time.RFC3339))
+	s.printf("-- the database must be at version %d\n", version)
+	s.printf("%s;\n", createTableQuery(d.migrationsTable(), d.withExtraColumns(versionTableColumns)))
+	return s, s.err
+}
+
+// printf writes to the script, unless a previous write failed.
+func (s *scriptDriver) printf(format string, v ...interface{}) {
+	if s.err == nil {
+		_, s.err = fmt.Fprintf(s.w, format, v...)
+	}
+}
+
+// State returns the version change of the script that was rendered so far.
+func (s *scriptDriver) State() ScriptState {
+	state := s.state
+	state.Versions = append([]uint64(nil), s.state.Versions...)
+	return state
+}
+
+// Close reports the first error that occurred while writing the script.
+func (s *scriptDriver) Close() error {
+	if s.err != nil {
+		return &lightmigrate.DriverError{OrigErr: s.err, Msg: "failed to write migration script"}
+	}
+	return nil
+}
+
+// Lock is a no-op, the script is executed by a single session.
+func (s *scriptDriver) Lock() error {
+	return nil
+}
+
+// Unlock is a no-op, see Lock.
+func (s *scriptDriver) Unlock() error {
+	return nil
+}
+
+// GetVersion returns the version that the database has after the script rendered so far.
+func (s *scriptDriver) GetVersion() (version uint64, dirty bool, err error) {
+	return s.state.ToVersion, false, nil
+}
+
+// SetVersion writes the update of the migration table, the same row that the migrations table store writes.
+func (s *scriptDriver) SetVersion(version uint64, dirty bool) error {
+	if dirty {
+		s.printf("\n-- migration %d\n", version)
+	}
+
+	dirtyValue := 0
+	if dirty {
+		dirtyValue = 1
+	}
+	previousVersion, attempts := nextAttempt(s.prior, version, dirty)
+	s.prior = &versionRow{Version: version, Dirty: dirty, PreviousVersion: scriptInt(previousVersion),
+		Attempts: scriptInt(attempts)}
+
+	table := s.d.migrationsTable()
+	s.printf("DELETE FROM `%s`;\n", table)
+	s.printf("INSERT INTO `%s` (version, dirty, previous_version, attempts) VALUES (%d, %d, %s, %s);\n", table,
+		version, dirtyValue, scriptValue(s.prior.PreviousVersion), scriptValue(s.prior.Attempts))
+
+	if dirty {
+		s.state.Versions = append(s.state.Versions, version)
+	} else {
+		s.state.ToVersion = version
+	}
+	return s.err
+}
+
+// scriptInt converts a value of nextAttempt.
+func scriptInt(value interface{}) sql.NullInt64 {
+	switch v := value.(type) {
+	case int:
+		return sql.NullInt64{Int64: int64(v), Valid: true}
+	case int64:
+		return sql.NullInt64{Int64: v, Valid: true}
+	}
+	return sql.NullInt64{}
+}
+
+// scriptValue renders the value as SQL literal.
+func scriptValue(value sql.NullInt64) string {
+	if !value.Valid {
+		return "NULL"
+	}
+	return strconv.FormatInt(value.Int64, 10)
+}
+
+// RunMigration writes the statements of the migration.
+func (s *scriptDriver) RunMigration(migration io.Reader) error {
+	if s.d.decryptor != nil {
+		decrypted, err := s.d.decryptor(migration)
+		if err != nil {
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to decrypt migration"}
+		}
+		migration = decrypted
+	}
+	migration = normalizeEncoding(migration)
+
+	if !s.d.cfg.SplitStatements {
+		buf := getBuffer()
+		defer putBuffer(buf)
+		if _, err := buf.ReadFrom(migration); err != nil {
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read migration"}
+		}
+		s.printf("%s\n", bytes.TrimSpace(buf.Bytes()))
+		return s.err
+	}
+
+	// the statements are buffered, so that skipped migrations are omitted completely
+	var script strings.Builder
+	stream := newStatementStream(migration, s.d.includeFS)
+	defer stream.Close()
+	for {
+		kind, text, ok := stream.Next()
+		if !ok {
+			break
+		}
+
+		if kind == tokenDirective {
+			name, arg := splitDirective(text)
+			if name == directiveSkip {
+				s.printf("-- skipped: %s\n", arg)
+				return s.err
+			}
+			script.WriteString("-- " + DirectivePrefix + string(text) + "\n")
+			continue
+		}
+
+		if bytes.Contains(text, []byte(DefaultDelimiter)) {
+			script.WriteString("DELIMITER " + scriptDelimiter + "\n")
+			script.Write(text)
+			script.WriteString("\n" + scriptDelimiter + "\nDELIMITER " + DefaultDelimiter + "\n")
+			continue
+		}
+		script.Write(text)
+		script.WriteString(DefaultDelimiter + "\n")
+	}
+	if err := stream.Err(); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read migration"}
+	}
+
+	s.printf("%s", script.String())
+	return s.err
+}
+
+// Reset writes the removal of the migration table.
+func (s *scriptDriver) Reset() error {
+	s.printf("DROP TABLE IF EXISTS `%s`;\n", s.d.migrationsTable())
+	s.state.ToVersion = lightmigrate.NoMigrationVersion
+	return s.err
+}
+
+// ImportState records the version change of a script that was rendered by a ScriptDriver and executed manually.
+// If the database is still at the start version of the script, the target version is set; if it is already at the
+// target version (e.g. the script updated the migration table), only the history is recorded. In any other case,
+// ErrScriptStateMismatch is returned. If the history is enabled, each migration of the script is recorded with a
+// note. The migration lock is acquired, unless it is already held by this driver.
+func (d *driver) ImportState(state ScriptState) (err error) {
+	if state.Database != "" && state.Database != d.cfg.DatabaseName {
+		return &lightmigrate.DriverError{OrigErr: ErrScriptStateMismatch,
+			Msg: fmt.Sprintf("the script was generated for database %s", state.Database)}
+	}
+
+	unlock, err := d.lockOperation()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	version, dirty, err := d.GetVersion()
+	if err != nil {
+		return err
+	}
+	switch {
+	case version == state.ToVersion && !dirty:
+		// the script already updated the migration table
+	case version == state.FromVersion && !dirty:
+		if err := d.SetVersion(state.ToVersion, false); err != nil {
+			return err
+		}
+	default:
+		return &lightmigrate.DriverError{OrigErr: ErrScriptStateMismatch,
+			Msg: fmt.Sprintf("the script migrates from version %d to %d, the database is at version %d (dirty: %t)",
+				state.FromVersion, state.ToVersion, version, dirty)}
+	}
+	d.logf("version %d was recorded from the migration script generated at %s", state.ToVersion,
+		state.GeneratedAt.Format(time.RFC3339))
+
+	if !d.cfg.History {
+		return nil
+	}
+	note := "applied by the migration script generated at " + state.GeneratedAt.Format(time.RFC3339)
+	for _, applied := range state.Versions {
+		started := time.Now()
+		if err := d.insertHistory(applied, lightmigrate.Up, &migrationState{Note: note}, started, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
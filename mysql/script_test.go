@@ -0,0 +1,122 @@
+package mysql
+
+import (
+	"bytes"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/h44z/lightmigrate"
+)
+
+func TestNewScriptDriver(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/1_init.up.sql": {Data: []byte("CREATE TABLE a (id int);")},
+		"migrations/2_proc.up.sql": {Data: []byte("-- lightmigrate:idempotent\nDELIMITER //\n" +
+			"CREATE PROCEDURE p() BEGIN SELECT 1; END//\nDELIMITER ;\nCALL p();")},
+		"migrations/3_manual.up.sql": {Data: []byte("-- lightmigrate:skip reason=applied manually\nDROP TABLE a;")},
+	}
+	source, err := lightmigrate.NewFsSource(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	script := &bytes.Buffer{}
+	drv, err := NewScriptDriver(script, "app", 1, WithTablePrefix("app_"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	migrator, err := lightmigrate.NewMigrator(source, drv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := migrator.Migrate(3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := drv.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"CREATE TABLE IF NOT EXISTS `app_schema_migrations` (`version` bigint not null primary key",
+		"-- migration 2\nDELETE FROM `app_schema_migrations`;\n" +
+			"INSERT INTO `app_schema_migrations` (version, dirty, previous_version, attempts) VALUES (2, 1, 1, 1);\n" +
+			"-- lightmigrate:idempotent\n" +
+			"DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END\n$$\nDELIMITER ;\nCALL p();\n",
+		"-- migration 3\nDELETE FROM `app_schema_migrations`;\n" +
+			"INSERT INTO `app_schema_migrations` (version, dirty, previous_version, attempts) VALUES (3, 1, 2, 1);\n",
+		"-- skipped: reason=applied manually\n",
+		"INSERT INTO `app_schema_migrations` (version, dirty, previous_version, attempts) VALUES (3, 0, NULL, NULL);\n",
+	} {
+		if !strings.Contains(script.String(), want) {
+			t.Fatalf("expected script to contain %q, got: %s", want, script.String())
+		}
+	}
+	if strings.Contains(script.String(), "CREATE TABLE a") || strings.Contains(script.String(), "DROP TABLE a") {
+		t.Fatalf("unexpected migrations in script: %s", script.String())
+	}
+
+	state := drv.State()
+	if state.Database != "app" || state.FromVersion != 1 || state.ToVersion != 3 ||
+		!reflect.DeepEqual(state.Versions, []uint64{2, 3}) {
+		t.Fatalf("unexpected state, got: %+v", state)
+	}
+}
+
+func TestNewScriptDriver_NoDatabase(t *testing.T) {
+	if _, err := NewScriptDriver(io.Discard, "", 0); !errors.Is(err, ErrNoDatabaseName) {
+		t.Fatalf("expected error %v, got: %v", ErrNoDatabaseName, err)
+	}
+}
+
+func Test_driver_ImportState(t *testing.T) {
+	tests := []struct {
+		name    string
+		current int64
+		wantSet bool
+		wantErr error
+	}{
+		{"from version", 1, true, nil},
+		{"to version", 3, false, nil},
+		{"other version", 2, false, ErrScriptStateMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, srv := newFakeDB(t, versionHandler([]sqldriver.Value{tt.current, int64(0), nil, nil, nil}))
+			d := &driver{client: db, logger: log.New(io.Discard, "", 0),
+				cfg: &config{DatabaseName: "app", MigrationsTable: "migrations", Locking: true, History: true}}
+
+			err := d.ImportState(ScriptState{Database: "app", FromVersion: 1, ToVersion: 3, Versions: []uint64{2, 3}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+
+			var set bool
+			var recorded []interface{}
+			for _, call := range srv.Calls() {
+				switch {
+				case strings.HasPrefix(call.Query, "INSERT INTO `migrations`"):
+					set = call.Args[0] == int64(3) && call.Args[1] == false
+				case strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_history`"):
+					recorded = append(recorded, call.Args[0])
+				}
+			}
+			if set != tt.wantSet {
+				t.Fatalf("unexpected version update %t, got: %q", tt.wantSet, srv.Queries())
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(recorded, []interface{}{int64(2), int64(3)}) {
+				t.Fatalf("unexpected history, got: %v", recorded)
+			}
+		})
+	}
+
+	d := &driver{cfg: &config{DatabaseName: "app"}}
+	if err := d.ImportState(ScriptState{Database: "other"}); !errors.Is(err, ErrScriptStateMismatch) {
+		t.Fatalf("expected error %v, got: %v", ErrScriptStateMismatch, err)
+	}
+}
@@ -0,0 +1,149 @@
+package mysql
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// serverInfo describes the connected database server.
+type serverInfo struct {
+	Version string // the raw version string, empty if the version could not be detected
+	Major   int
+	Minor   int
+	Patch   int
+	MariaDB bool
+	Cluster bool // the server is a node of a Galera cluster
+	// ReadOnly is true if read_only or super_read_only is enabled, usually on replicas
+	ReadOnly bool
+	// Identity identifies the server instance (server_uuid, or host and port), only detected for shard lock keys
+	Identity string
+	// ClusterIdentity identifies the Galera cluster (wsrep_cluster_state_uuid), it is the same on all nodes
+	ClusterIdentity string
+}
+
+// parseServerVersion parses the result of SELECT VERSION(), e.g. "8.0.32-0ubuntu0.22.04.2" or "10.6.12-MariaDB-log".
+func parseServerVersion(version string) serverInfo {
+	info := serverInfo{Version: version, MariaDB: strings.Contains(strings.ToLower(version), "mariadb")}
+
+	// MariaDB might prefix the version for replication compatibility, e.g. "5.5.5-10.6.12-MariaDB"
+	number := strings.TrimPrefix(version, "5.5.5-")
+	if idx := strings.IndexFunc(number, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); idx >= 0 {
+		number = number[:idx]
+	}
+
+	parts := strings.SplitN(number, ".", 3)
+	for i, target := range []*int{&info.Major, &info.Minor, &info.Patch} {
+		if i < len(parts) {
+			*target, _ = strconv.Atoi(parts[i])
+		}
+	}
+
+	return info
+}
+
+// known returns true if the server version was detected.
+func (s serverInfo) known() bool {
+	return s.Major > 0
+}
+
+// atLeast compares the server version with the given version.
+func (s serverInfo) atLeast(major, minor, patch int) bool {
+	if s.Major != major {
+		return s.Major > major
+	}
+	if s.Minor != minor {
+		return s.Minor > minor
+	}
+	return s.Patch >= patch
+}
+
+// serverFeature is a server feature that is only available in newer server versions.
+type serverFeature int
+
+const (
+	// featureAtomicDDL means that DDL statements are executed atomically.
+	// A failed DDL statement leaves no partially created or changed objects.
+	featureAtomicDDL serverFeature = iota
+	// featureInstantAddColumn means that columns can be appended using ALTER TABLE ... ALGORITHM=INSTANT.
+	featureInstantAddColumn
+	// featureLargeIndexPrefix means that index keys can be up to 3072 bytes. Older servers are limited to
+	// 767 bytes, which are only 191 characters of an utf8mb4 column.
+	featureLargeIndexPrefix
+	// featureMultipleLocks means that a session can hold more than one advisory lock (GET_LOCK). Older servers
+	// release the held lock if another one is acquired.
+	featureMultipleLocks
+)
+
+// serverVersion is a version of MySQL or MariaDB.
+type serverVersion struct {
+	Major, Minor, Patch int
+}
+
+// compatibilityMatrix contains the first MySQL and MariaDB version that supports a feature.
+var compatibilityMatrix = map[serverFeature]struct{ MySQL, MariaDB serverVersion }{
+	featureAtomicDDL:        {MySQL: serverVersion{8, 0, 0}, MariaDB: serverVersion{10, 6, 0}},
+	featureInstantAddColumn: {MySQL: serverVersion{8, 0, 12}, MariaDB: serverVersion{10, 3, 2}},
+	featureLargeIndexPrefix: {MySQL: serverVersion{5, 7, 7}, MariaDB: serverVersion{10, 2, 2}},
+	featureMultipleLocks:    {MySQL: serverVersion{5, 7, 5}, MariaDB: serverVersion{10, 0, 2}},
+}
+
+// Minimum supported server versions. Older servers might work, but are not tested.
+var (
+	minimumMySQLVersion   = serverVersion{5, 6, 0}
+	minimumMariaDBVersion = serverVersion{10, 0, 0}
+)
+
+// supports checks if the server supports the feature. If the server version is unknown, all features are
+// treated as unsupported.
+func (s serverInfo) supports(feature serverFeature) bool {
+	if !s.known() {
+		return false
+	}
+
+	since := compatibilityMatrix[feature]
+	if s.MariaDB {
+		return s.atLeast(since.MariaDB.Major, since.MariaDB.Minor, since.MariaDB.Patch)
+	}
+	return s.atLeast(since.MySQL.Major, since.MySQL.Minor, since.MySQL.Patch)
+}
+
+// supportsAtomicDDL checks if the server executes DDL statements atomically (MySQL 8.0, MariaDB 10.6).
+func (s serverInfo) supportsAtomicDDL() bool {
+	return s.supports(featureAtomicDDL)
+}
+
+// legacy checks if the server is older than the minimum supported version.
+func (s serverInfo) legacy() bool {
+	minimum := minimumMySQLVersion
+	if s.MariaDB {
+		minimum = minimumMariaDBVersion
+	}
+	return s.known() && !s.atLeast(minimum.Major, minimum.Minor, minimum.Patch)
+}
+
+// indexedVarcharLength returns the maximum length of an indexed utf8mb4 varchar column of internal tables.
+func (s serverInfo) indexedVarcharLength() int {
+	if s.known() && !s.supports(featureLargeIndexPrefix) {
+		return 191
+	}
+	return 255
+}
+
+// detectServer queries the version of the connected server. If the version cannot be detected, all version
+// dependent features stay disabled.
+func (d *driver) detectServer(ctx context.Context) {
+	var version string
+	if err := d.client.QueryRowContext(ctx, "SELECT VERSION()").Scan(&version); err != nil {
+		d.logf("failed to detect server version, version specific features are disabled: %v", err)
+		return
+	}
+
+	d.server = parseServerVersion(version)
+	if d.verbose {
+		d.logf("detected server version %s", version)
+	}
+	if d.server.legacy() {
+		d.logf("server version %s is older than the minimum supported versions MySQL 5.6 and MariaDB 10.0", version)
+	}
+}
@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"testing"
+)
+
+func Test_parseServerVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    serverInfo
+		atomic  bool
+	}{
+		{"8.0.32-0ubuntu0.22.04.2", serverInfo{Major: 8, Minor: 0, Patch: 32}, true},
+		{"5.7.41-log", serverInfo{Major: 5, Minor: 7, Patch: 41}, false},
+		{"10.6.12-MariaDB-log", serverInfo{Major: 10, Minor: 6, Patch: 12, MariaDB: true}, true},
+		{"5.5.5-10.5.19-MariaDB", serverInfo{Major: 10, Minor: 5, Patch: 19, MariaDB: true}, false},
+		{"8.1", serverInfo{Major: 8, Minor: 1}, true},
+		{"unknown", serverInfo{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got := parseServerVersion(tt.version)
+			tt.want.Version = tt.version
+			if got != tt.want {
+				t.Fatalf("unexpected server info %+v, got: %+v", tt.want, got)
+			}
+			if got.supportsAtomicDDL() != tt.atomic {
+				t.Fatalf("unexpected atomic DDL support %t", tt.atomic)
+			}
+		})
+	}
+}
+
+func Test_driver_detectServer(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if call.Query == "SELECT VERSION()" {
+			return fakeResponse{Columns: []string{"VERSION()"}, Rows: [][]sqldriver.Value{{"8.0.35"}}}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{}}
+
+	d.detectServer(context.Background())
+	if !d.server.known() || !d.server.atLeast(8, 0, 35) || d.server.atLeast(8, 0, 36) {
+		t.Fatalf("unexpected server info: %+v", d.server)
+	}
+}
+
+// Test_serverInfo_compatibilityMatrix documents the supported server baselines and the available features.
+func Test_serverInfo_compatibilityMatrix(t *testing.T) {
+	tests := []struct {
+		version      string
+		legacy       bool
+		atomicDDL    bool
+		instantAdd   bool
+		varcharIndex int
+		locks        bool
+	}{
+		{"5.5.62", true, false, false, 191, false},
+		{"5.6.51", false, false, false, 191, false},
+		{"5.7.6", false, false, false, 191, true},
+		{"5.7.44", false, false, false, 255, true},
+		{"8.0.11", false, true, false, 255, true},
+		{"8.0.36", false, true, true, 255, true},
+		{"8.4.0", false, true, true, 255, true},
+		{"5.5.5-10.1.48-MariaDB", false, false, false, 191, true},
+		{"10.3.39-MariaDB", false, false, true, 255, true},
+		{"10.6.16-MariaDB", false, true, true, 255, true},
+		{"11.2.2-MariaDB", false, true, true, 255, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			s := parseServerVersion(tt.version)
+			if s.legacy() != tt.legacy || s.supports(featureAtomicDDL) != tt.atomicDDL ||
+				s.supports(featureInstantAddColumn) != tt.instantAdd || s.indexedVarcharLength() != tt.varcharIndex ||
+				s.supports(featureMultipleLocks) != tt.locks {
+				t.Fatalf("unexpected compatibility of %s: %+v", tt.version, tt)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	sqldriver "database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// sessionVariable is a session variable that is set on all connections that execute migration statements.
+type sessionVariable struct {
+	Name  string
+	Value int64
+}
+
+// sessionVariables returns the configured session variables of the migration connections.
+func (d *driver) sessionVariables() []sessionVariable {
+	var vars []sessionVariable
+	if d.cfg.InnoDBLockWaitTimeout > 0 {
+		vars = append(vars, sessionVariable{"innodb_lock_wait_timeout", timeoutSeconds(d.cfg.InnoDBLockWaitTimeout)})
+	}
+	if d.cfg.MetadataLockWaitTimeout > 0 {
+		vars = append(vars, sessionVariable{"lock_wait_timeout", timeoutSeconds(d.cfg.MetadataLockWaitTimeout)})
+	}
+	return vars
+}
+
+// openSession reserves a connection of the pool for the execution of migration statements and prepares
+// its session variables. The session must be released using closeSession.
+func (d *driver) openSession(ctx context.Context) (*sql.Conn, error) {
+	conn, err := d.client.Conn(ctx)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to open migration connection"}
+	}
+
+	if err := d.prepareSession(ctx, conn); err != nil {
+		d.closeSession(conn)
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// prepareSession sets the character set, the session variables and the role of the migration connection.
+func (d *driver) prepareSession(ctx context.Context, conn *sql.Conn) error {
+	if err := d.pinCharset(ctx, conn); err != nil {
+		return err
+	}
+	for _, v := range d.sessionVariables() {
+		query := fmt.Sprintf("SET SESSION %s = %d", v.Name, v.Value)
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to prepare migration session", Query: []byte(query)}
+		}
+	}
+	return d.activateRole(ctx, conn)
+}
+
+// closeSession restores the session variables, the role and the character set and returns the connection to the pool, so that the
+// settings of the migration do not leak into application queries.
+func (d *driver) closeSession(conn *sql.Conn) {
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	for _, v := range d.sessionVariables() {
+		if _, err := conn.ExecContext(ctx, "SET SESSION "+v.Name+" = DEFAULT"); err != nil {
+			d.logf("failed to reset session variable %s: %v", v.Name, err)
+		}
+	}
+	deactivated := d.deactivateRole(ctx, conn)
+	d.restoreCharset(ctx, conn)
+
+	if !deactivated {
+		// the connection is discarded instead of returning it to the pool with the active role
+		_ = conn.Raw(func(interface{}) error { return sqldriver.ErrBadConn })
+	}
+	_ = conn.Close()
+}
+
+// timeoutSeconds converts the timeout to whole seconds (rounded up), as expected by the MySQL timeout variables.
+func timeoutSeconds(timeout time.Duration) int64 {
+	return int64((timeout + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithLockWaitTimeouts(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithLockWaitTimeouts(3*time.Second, time.Minute)(d)
+	if d.cfg.InnoDBLockWaitTimeout != 3*time.Second || d.cfg.MetadataLockWaitTimeout != time.Minute {
+		t.Fatalf("failed to set lock wait timeouts")
+	}
+}
+
+func Test_timeoutSeconds(t *testing.T) {
+	if got := timeoutSeconds(1500 * time.Millisecond); got != 2 {
+		t.Fatalf("unexpected timeout 2, got: %d", got)
+	}
+	if got := timeoutSeconds(time.Minute); got != 60 {
+		t.Fatalf("unexpected timeout 60, got: %d", got)
+	}
+}
+
+func Test_driver_RunMigration_LockWaitTimeouts(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, InnoDBLockWaitTimeout: 2 * time.Second, MetadataLockWaitTimeout: 10 * time.Second}}
+
+	if err := d.RunMigration(bytes.NewBufferString("SELECT 1;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"SET SESSION innodb_lock_wait_timeout = 2",
+		"SET SESSION lock_wait_timeout = 10",
+		"SELECT 1",
+		"SET SESSION innodb_lock_wait_timeout = DEFAULT",
+		"SET SESSION lock_wait_timeout = DEFAULT",
+	}
+	calls := srv.Calls()
+	if got := srv.Queries(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected queries %q, got: %q", want, got)
+	}
+	for _, call := range calls {
+		if call.ConnID != calls[0].ConnID {
+			t.Fatalf("statements were not executed within the prepared session: %+v", calls)
+		}
+	}
+}
+
+func Test_driver_RunMigration_SessionError(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SET SESSION") && !strings.HasSuffix(call.Query, "DEFAULT") {
+			return fakeResponse{Err: errors.New("access denied")}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{SplitStatements: true, MigrationsTable: "migrations", InnoDBLockWaitTimeout: time.Second}}
+
+	if err := d.RunMigration(bytes.NewBufferString("SELECT 1;")); err == nil {
+		t.Fatalf("expected error, got: %v", err)
+	}
+	for _, query := range srv.Queries() {
+		if query == "SELECT 1" {
+			t.Fatalf("migration was executed without prepared session")
+		}
+	}
+}
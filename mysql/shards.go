@@ -0,0 +1,124 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// Shard is a single horizontal shard of a sharded database, see Coordinator.MigrateShards.
+type Shard struct {
+	// Name identifies the shard within the report. Defaults to the database name, or the address and the database
+	// of the DSN.
+	Name string
+	// Client is the database client of the shard.
+	Client *sql.DB
+	// DSN is used to open a database client, if no client is set.
+	DSN string
+	// Database is the name of the database, if a client is set.
+	Database string
+}
+
+// WithShardLockKey derives the migration lock key from the identity of the server (server_uuid, or hostname and port
+// on MariaDB), the database, the table prefix and the namespace. Many shards hosted by the same server then never
+// share a lock, and the key of a shard does not change if the database names of the shards are reused on other
+// servers. On Galera clusters, the cluster state UUID is used instead, so that all nodes (also after a failover)
+// derive the same key. NewDriver fails with ErrUnknownServerIdentity if the identity cannot be detected, a key
+// without it would be shared by the shards of different servers. It is enabled by Coordinator.MigrateShards.
+func WithShardLockKey(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.ShardLockKey = enabled
+	}
+}
+
+// detectServerIdentity queries the identity of the server instance for the shard lock key and the primary guard.
+func (d *driver) detectServerIdentity(ctx context.Context) {
+	var identity string
+	err := d.client.QueryRowContext(ctx, "SELECT @@server_uuid").Scan(&identity)
+	if err != nil || identity == "" { // MariaDB has no server_uuid
+		err = d.client.QueryRowContext(ctx, "SELECT CONCAT(@@hostname, ':', @@port)").Scan(&identity)
+	}
+	if err != nil {
+		d.logf("failed to detect server identity: %v", err)
+		return
+	}
+
+	d.server.Identity = identity
+	if d.verbose {
+		d.logf("detected server identity %s", identity)
+	}
+
+	if d.server.Cluster {
+		d.detectClusterIdentity(ctx)
+	}
+}
+
+// detectClusterIdentity queries the state UUID of the Galera cluster. Unlike the server_uuid of the node, it is the
+// same on all nodes and does not change if the clients fail over to another node.
+func (d *driver) detectClusterIdentity(ctx context.Context) {
+	var name, identity string
+	err := d.client.QueryRowContext(ctx, "SHOW GLOBAL STATUS LIKE 'wsrep_cluster_state_uuid'").Scan(&name, &identity)
+	if err != nil {
+		d.logf("failed to detect cluster identity: %v", err)
+		return
+	}
+
+	d.server.ClusterIdentity = identity
+	if d.verbose {
+		d.logf("detected cluster identity %s", identity)
+	}
+}
+
+// lockIdentity returns the identity of the shard lock key: the cluster identity on Galera clusters, as the server
+// identity differs between the nodes, otherwise the server identity.
+func (d *driver) lockIdentity() string {
+	if d.server.Cluster {
+		return d.server.ClusterIdentity
+	}
+	return d.server.Identity
+}
+
+// checkShardLockKey verifies that the server identity was detected, see WithShardLockKey.
+func (d *driver) checkShardLockKey() error {
+	if !d.cfg.ShardLockKey || d.lockIdentity() != "" {
+		return nil
+	}
+	return &lightmigrate.DriverError{OrigErr: ErrUnknownServerIdentity,
+		Msg: "the shard lock key requires the server identity (server_uuid, or hostname and port, or " +
+			"wsrep_cluster_state_uuid on Galera clusters)"}
+}
+
+// shardLockKey returns the lock key of a shard, see WithShardLockKey. The hex encoded SHA-256 hash fits the maximum
+// length of advisory lock names (64 characters).
+func (d *driver) shardLockKey() string {
+	sum := sha256.Sum256([]byte(d.lockIdentity() + "/" + d.cfg.DatabaseName + "/" + d.cfg.TablePrefix + "/" +
+		d.cfg.Namespace))
+	return hex.EncodeToString(sum[:])
+}
+
+// MigrateShards applies the same migrations to all shards. Each shard is migrated by its own driver, with its own
+// lock and version table. The lock key of each shard is derived from the server and the database, see
+// WithShardLockKey. The parallelism and the failure semantics are configured by the coordinator options
+// (WithParallelism, WithStopOnFailure). The report contains the result of each shard, also if shards failed.
+func (c *Coordinator) MigrateShards(ctx context.Context, shards []Shard, source lightmigrate.MigrationSource,
+	version uint64, opts ...DriverOption) (*Report, error) {
+	opts = append([]DriverOption{WithShardLockKey(true)}, opts...)
+
+	targets := make([]Target, len(shards))
+	for i, shard := range shards {
+		targets[i] = Target{
+			Name:          shard.Name,
+			Client:        shard.Client,
+			DSN:           shard.DSN,
+			Database:      shard.Database,
+			Source:        source,
+			Version:       version,
+			DriverOptions: opts,
+		}
+	}
+
+	return c.MigrateWithReport(ctx, targets)
+}
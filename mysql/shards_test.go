@@ -0,0 +1,276 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestCoordinator_MigrateShards(t *testing.T) {
+	errCreate := errors.New("access denied")
+	okDB, _ := newFakeDB(t, nil)
+	failDB, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "CREATE TABLE a") {
+			return fakeResponse{Err: errCreate}
+		}
+		return defaultFakeHandler(call)
+	})
+
+	shards := []Shard{
+		{Client: okDB, Database: "shard1"},
+		{Client: failDB, Database: "shard2"},
+	}
+	report, err := NewCoordinator().MigrateShards(context.Background(), shards, testSource(t), 1)
+	if err == nil || !strings.Contains(err.Error(), errCreate.Error()) {
+		t.Fatalf("unexpected error %v, got: %v", errCreate, err)
+	}
+	if report == nil || len(report.Results) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if ok := report.Results[0]; ok.Target != "shard1" || ok.Err != nil || ok.Skipped {
+		t.Fatalf("unexpected result of shard1: %+v", ok)
+	}
+	if failed := report.Failed(); len(failed) != 1 || failed[0].Target != "shard2" || !errors.Is(failed[0].Err, errCreate) {
+		t.Fatalf("unexpected failed shards: %+v", failed)
+	}
+}
+
+func TestCoordinator_MigrateShards_StopOnFailure(t *testing.T) {
+	failDB, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "CREATE TABLE IF NOT EXISTS") {
+			return fakeResponse{Err: errors.New("access denied")}
+		}
+		return defaultFakeHandler(call)
+	})
+	okDB, okSrv := newFakeDB(t, nil)
+
+	shards := []Shard{{Name: "a", Client: failDB, Database: "shard1"}, {Name: "b", Client: okDB, Database: "shard2"}}
+	report, err := NewCoordinator(WithParallelism(1), WithStopOnFailure(true)).
+		MigrateShards(context.Background(), shards, testSource(t), 1)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	skipped := report.Results[1]
+	if !skipped.Skipped || !errors.Is(skipped.Err, ErrTargetSkipped) {
+		t.Fatalf("expected shard b to be skipped, got: %+v", skipped)
+	}
+	if len(okSrv.Queries()) != 0 {
+		t.Fatalf("unexpected queries on skipped shard: %q", okSrv.Queries())
+	}
+}
+
+func TestTarget_name(t *testing.T) {
+	target := Target{DSN: "user:pass@tcp(shard-1:3306)/app"}
+	if name := target.name(); name != "shard-1:3306/app" {
+		t.Fatalf("unexpected name shard-1:3306/app, got: %s", name)
+	}
+}
+
+func Test_driver_shardLockKey(t *testing.T) {
+	d := &driver{cfg: &config{DatabaseName: "shard1", ShardLockKey: true}, server: serverInfo{Identity: "uuid-1"}}
+	key := d.getLockingKey()
+	if len(key) != 64 {
+		t.Fatalf("unexpected key length 64, got: %d", len(key))
+	}
+
+	other := &driver{cfg: &config{DatabaseName: "shard1", ShardLockKey: true}, server: serverInfo{Identity: "uuid-2"}}
+	if other.getLockingKey() == key {
+		t.Fatalf("server identity is not part of the locking key")
+	}
+	other = &driver{cfg: &config{DatabaseName: "shard1", Namespace: "ns", ShardLockKey: true}, server: d.server}
+	if other.getLockingKey() == key {
+		t.Fatalf("namespace is not part of the locking key")
+	}
+}
+
+func Test_driver_detectServerIdentity(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT CONCAT(@@hostname") {
+			return fakeResponse{Columns: []string{"identity"}, Rows: [][]sqldriver.Value{{"db-1:3306"}}}
+		}
+		return fakeResponse{Err: errors.New("unknown system variable 'server_uuid'")}
+	})
+	d := &driver{client: db, cfg: &config{}, logger: log.New(io.Discard, "", 0)}
+
+	d.detectServerIdentity(context.Background())
+	if d.server.Identity != "db-1:3306" {
+		t.Fatalf("unexpected identity db-1:3306, got: %s (queries: %q)", d.server.Identity, srv.Queries())
+	}
+}
+
+func Test_driver_shardLockKey_Cluster(t *testing.T) {
+	d := &driver{cfg: &config{DatabaseName: "shard1", ShardLockKey: true},
+		server: serverInfo{Cluster: true, Identity: "uuid-1", ClusterIdentity: "cluster-1"}}
+	other := &driver{cfg: &config{DatabaseName: "shard1", ShardLockKey: true},
+		server: serverInfo{Cluster: true, Identity: "uuid-2", ClusterIdentity: "cluster-1"}}
+	if other.getLockingKey() != d.getLockingKey() {
+		t.Fatalf("nodes of the same cluster use different locking keys")
+	}
+}
+
+func Test_driver_detectServerIdentity_Cluster(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if call.Query == "SHOW GLOBAL STATUS LIKE 'wsrep_cluster_state_uuid'" {
+			return fakeResponse{Columns: []string{"Variable_name", "Value"},
+				Rows: [][]sqldriver.Value{{"wsrep_cluster_state_uuid", "b8a4f3c4-35de-11ee-8e5a-6b7c2f1d9a10"}}}
+		}
+		return defaultFakeHandler(call)
+	})
+	d := &driver{client: db, cfg: &config{ShardLockKey: true}, server: serverInfo{Cluster: true},
+		logger: log.New(io.Discard, "", 0)}
+
+	d.detectServerIdentity(context.Background())
+	if d.lockIdentity() != "b8a4f3c4-35de-11ee-8e5a-6b7c2f1d9a10" || d.checkShardLockKey() != nil {
+		t.Fatalf("unexpected lock identity, got: %s", d.lockIdentity())
+	}
+
+	d.server = serverInfo{Cluster: true}
+	db, _ = newFakeDB(t, nil)
+	d.client = db
+	d.detectServerIdentity(context.Background())
+	if err := d.checkShardLockKey(); !errors.Is(err, ErrUnknownServerIdentity) {
+		t.Fatalf("expected error %v, got: %v", ErrUnknownServerIdentity, err)
+	}
+}
+
+func TestNewDriver_ShardLockKeyWithoutIdentity(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT @@server_uuid") || strings.HasPrefix(call.Query, "SELECT CONCAT(@@hostname") {
+			return fakeResponse{Err: errors.New("access denied")}
+		}
+		return defaultFakeHandler(call)
+	})
+
+	_, err := NewDriver(db, "shard1", WithShardLockKey(true), WithLogger(log.New(io.Discard, "", 0)))
+	if !errors.Is(err, ErrUnknownServerIdentity) {
+		t.Fatalf("expected error %v, got: %v", ErrUnknownServerIdentity, err)
+	}
+}
+
+func TestCoordinator_MigrateShards_LockKey(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+
+	shards := []Shard{{Client: db, Database: "shard1"}}
+	if _, err := NewCoordinator().MigrateShards(context.Background(), shards, testSource(t), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "SELECT GET_LOCK") {
+			if key, _ := call.Args[0].(string); len(key) != 64 {
+				t.Fatalf("expected shard lock key, got: %v", call.Args[0])
+			}
+			return
+		}
+	}
+	t.Fatalf("lock was not acquired: %q", srv.Queries())
+}
+
+func TestCoordinator_MigrateShards_RegistryFailure(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	adminDB, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_registry`") {
+			return fakeResponse{Err: errors.New("access denied")}
+		}
+		return defaultFakeHandler(call)
+	})
+	logs := &bytes.Buffer{}
+	coordinator := NewCoordinator(WithVersionRegistry(adminDB, ""), WithCoordinatorLogger(log.New(logs, "", 0)))
+
+	shards := []Shard{{Name: "a", Client: db, Database: "shard1"}}
+	if _, err := coordinator.MigrateShards(context.Background(), shards, testSource(t), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(logs.String(), "target a: failed to record version in registry: access denied") {
+		t.Fatalf("registry failure was not logged, got: %s", logs.String())
+	}
+}
+
+func TestCoordinator_MigrateShards_RegistryTable(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+
+	_, err := NewCoordinator(WithVersionRegistry(db, "registry`; DROP TABLE users; --")).
+		MigrateShards(context.Background(), []Shard{{Client: db, Database: "shard1"}}, testSource(t), 1)
+	if !errors.Is(err, ErrInvalidConfig) || len(srv.Queries()) != 0 {
+		t.Fatalf("expected error %v, got: %v (queries: %q)", ErrInvalidConfig, err, srv.Queries())
+	}
+}
+
+func TestCoordinator_MigrateShards_Registry(t *testing.T) {
+	okDB, _ := newFakeDB(t, nil)
+	failDB, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "CREATE TABLE a") {
+			return fakeResponse{Err: errors.New("access denied")}
+		}
+		return defaultFakeHandler(call)
+	})
+	adminDB, adminSrv := newFakeDB(t, nil)
+
+	shards := []Shard{{Name: "a", Client: okDB, Database: "shard1"}, {Name: "b", Client: failDB, Database: "shard2"}}
+	_, err := NewCoordinator(WithParallelism(1), WithVersionRegistry(adminDB, "")).
+		MigrateShards(context.Background(), shards, testSource(t), 1)
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	calls := adminSrv.Calls()
+	if len(calls) != 3 || !strings.HasPrefix(calls[0].Query, "CREATE TABLE IF NOT EXISTS `schema_migrations_registry`") {
+		t.Fatalf("unexpected registry queries: %q", adminSrv.Queries())
+	}
+	if args := calls[1].Args; args[0] != "3e11fa47-71ca-11e1-9e33-c80aa9429562" || args[1] != "shard1" ||
+		args[2] != "a" || args[5] != true || args[6] != nil {
+		t.Fatalf("unexpected registry entry of shard a: %v", args)
+	}
+	if args := calls[2].Args; args[1] != "shard2" || args[2] != "b" || args[5] != false || args[6] == nil {
+		t.Fatalf("unexpected registry entry of shard b: %v", args)
+	}
+}
+
+func TestCoordinator_Migrate_RegistryServerIdentity(t *testing.T) {
+	euDB, _ := newFakeDB(t, nil)
+	usDB, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if call.Query == "SELECT @@server_uuid" {
+			return fakeResponse{Columns: []string{"uuid"}, Rows: [][]sqldriver.Value{{"9b2c4d1e-71ca-11e1-9e33-c80aa9429562"}}}
+		}
+		return defaultFakeHandler(call)
+	})
+	unknownDB, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if call.Query == "SELECT @@server_uuid" || strings.HasPrefix(call.Query, "SELECT CONCAT(@@hostname") {
+			return fakeResponse{Err: errors.New("access denied")}
+		}
+		return defaultFakeHandler(call)
+	})
+	adminDB, adminSrv := newFakeDB(t, nil)
+	logs := &bytes.Buffer{}
+
+	targets := []Target{
+		{Name: "eu", Client: euDB, Database: "app", Source: testSource(t), Version: 1},
+		{Name: "us", Client: usDB, Database: "app", Source: testSource(t), Version: 1},
+		{Name: "unknown", Client: unknownDB, Database: "app", Source: testSource(t), Version: 1},
+	}
+	err := NewCoordinator(WithParallelism(1), WithVersionRegistry(adminDB, ""),
+		WithCoordinatorLogger(log.New(logs, "", 0))).Migrate(context.Background(), targets)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	servers := map[interface{}]interface{}{}
+	for _, call := range adminSrv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_registry`") {
+			servers[call.Args[0]] = call.Args[2]
+		}
+	}
+	if len(servers) != 2 || servers["3e11fa47-71ca-11e1-9e33-c80aa9429562"] != "eu" ||
+		servers["9b2c4d1e-71ca-11e1-9e33-c80aa9429562"] != "us" {
+		t.Fatalf("unexpected registry entries: %v", servers)
+	}
+	if !strings.Contains(logs.String(), "target unknown: failed to record version in registry: unknown server identity") {
+		t.Fatalf("unidentified target was not logged, got: %s", logs.String())
+	}
+}
@@ -0,0 +1,163 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// autoIncrementOption is the AUTO_INCREMENT table option of SHOW CREATE TABLE, it depends on the data.
+var autoIncrementOption = regexp.MustCompile(` AUTO_INCREMENT=\d+`)
+
+// schemaSnapshot maps each schema object (e.g. "table users") to its normalized definition.
+type schemaSnapshot map[string]string
+
+// snapshotSchema reads the definitions of all tables, views, triggers and routines of the database.
+// The tables in exclude (e.g. the internal tables of the driver) are skipped.
+func snapshotSchema(ctx context.Context, client *sql.DB, database string,
+	exclude map[string]bool) (schemaSnapshot, error) {
+	snapshot := schemaSnapshot{}
+
+	query := "SELECT TABLE_NAME, TABLE_TYPE FROM information_schema.tables WHERE TABLE_SCHEMA = ?"
+	objects, err := queryPairs(ctx, client, query, database)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to list tables", Query: []byte(query)}
+	}
+	for _, object := range objects {
+		name, tableType := object[0], object[1]
+		if exclude[name] {
+			continue
+		}
+		kind := "table"
+		if tableType == "VIEW" {
+			kind = "view"
+		}
+
+		definition, err := showCreate(ctx, client, "SHOW CREATE TABLE `"+database+"`.`"+name+"`")
+		if err != nil {
+			return nil, err
+		}
+		snapshot[kind+" "+name] = autoIncrementOption.ReplaceAllString(definition, "")
+	}
+
+	query = "SELECT TRIGGER_NAME, CONCAT_WS(' ', ACTION_TIMING, EVENT_MANIPULATION, EVENT_OBJECT_TABLE, " +
+		"ACTION_STATEMENT) FROM information_schema.triggers WHERE TRIGGER_SCHEMA = ?"
+	triggers, err := queryPairs(ctx, client, query, database)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to list triggers", Query: []byte(query)}
+	}
+	for _, trigger := range triggers {
+		snapshot["trigger "+trigger[0]] = trigger[1]
+	}
+
+	query = "SELECT CONCAT(LOWER(ROUTINE_TYPE), ' ', ROUTINE_NAME), IFNULL(ROUTINE_DEFINITION, '') " +
+		"FROM information_schema.routines WHERE ROUTINE_SCHEMA = ?"
+	routines, err := queryPairs(ctx, client, query, database)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to list routines", Query: []byte(query)}
+	}
+	for _, routine := range routines {
+		snapshot[routine[0]] = routine[1]
+	}
+
+	return snapshot, nil
+}
+
+// diff describes the differences between the snapshot and other, sorted by object name.
+func (s schemaSnapshot) diff(other schemaSnapshot) []string {
+	var diffs []string
+	for name, definition := range s {
+		if otherDefinition, ok := other[name]; !ok {
+			diffs = append(diffs, "removed "+name)
+		} else if otherDefinition != definition {
+			diffs = append(diffs, "changed "+name)
+		}
+	}
+	for name := range other {
+		if _, ok := s[name]; !ok {
+			diffs = append(diffs, "added "+name)
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// queryPairs returns the first two columns of all rows of the query.
+func queryPairs(ctx context.Context, client *sql.DB, query string, args ...interface{}) ([][2]string, error) {
+	rows, err := client.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pairs [][2]string
+	for rows.Next() {
+		var pair [2]string
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, rows.Err()
+}
+
+// showCreate returns the definition (the second column) of a SHOW CREATE statement.
+func showCreate(ctx context.Context, client *sql.DB, query string) (string, error) {
+	return showCreateColumn(ctx, client, query, 1)
+}
+
+// showCreateColumn returns the column (0-based) of a SHOW CREATE statement, e.g. the statement of SHOW CREATE
+// TRIGGER is the third column.
+func showCreateColumn(ctx context.Context, client *sql.DB, query string, column int) (string, error) {
+	rows, err := client.QueryContext(ctx, query)
+	if err != nil {
+		return "", &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read definition", Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	if len(columns) <= column {
+		return "", &lightmigrate.DriverError{OrigErr: fmt.Errorf("unexpected result with %d columns", len(columns)),
+			Msg: "failed to read definition", Query: []byte(query)}
+	}
+
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", &lightmigrate.DriverError{OrigErr: sql.ErrNoRows, Msg: "failed to read definition",
+			Query: []byte(query)}
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return "", err
+	}
+	return string(values[column]), rows.Err()
+}
+
+// internalTables returns the names of all tables that are managed by the driver.
+func (d *driver) internalTables() map[string]bool {
+	tables := map[string]bool{
+		d.migrationsTable(): true,
+		d.historyTable():    true,
+		d.lockTable():       true,
+		d.quarantineTable(): true,
+		d.objectTable():     true,
+		d.sourceTable():     true,
+	}
+	if d.cfg.NotificationTable != "" {
+		tables[d.notificationTable()] = true
+	}
+	return tables
+}
@@ -0,0 +1,241 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// DefaultSourceTable is the name of the source registry, see WithSourceGuard.
+const DefaultSourceTable = "schema_migrations_sources"
+
+// sourceTableColumns are the columns of the source registry. Columns that are added in later releases
+// must be nullable, so that they can be added to existing tables.
+var sourceTableColumns = []columnDefinition{
+	{Name: "fingerprint", Definition: "char(64) not null primary key"},
+	{Name: "migrations", Definition: "int not null"},
+	{Name: "highest_version", Definition: "bigint not null"},
+	{Name: "manifest", Definition: "mediumtext not null"},
+	{Name: "first_run_at", Definition: "datetime not null"},
+	{Name: "last_run_at", Definition: "datetime not null"},
+	{Name: "runs", Definition: "int not null"},
+}
+
+// SourceFingerprint identifies the migrations of a migration source, see WithSourceGuard.
+type SourceFingerprint struct {
+	// Migrations is the number of up migrations.
+	Migrations int
+	// HighestVersion is the version of the last up migration.
+	HighestVersion uint64
+	// Checksums are the SHA-256 checksums (hex encoded) of the up migrations by version.
+	Checksums map[uint64]string
+}
+
+// NewSourceFingerprint reads all up migrations of the source and computes their checksums.
+func NewSourceFingerprint(source lightmigrate.MigrationSource) (*SourceFingerprint, error) {
+	f := &SourceFingerprint{Checksums: map[uint64]string{}}
+
+	version, err := source.First()
+	for err == nil {
+		var r io.ReadCloser
+		if r, _, err = source.ReadUp(version); err == nil {
+			hash := sha256.New()
+			_, err = io.Copy(hash, r)
+			_ = r.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read migration %d: %w", version, err)
+			}
+			f.Checksums[version] = hex.EncodeToString(hash.Sum(nil))
+			f.Migrations++
+			f.HighestVersion = version
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read migration %d: %w", version, err)
+		}
+		version, err = source.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	return f, nil
+}
+
+// parseSourceManifest parses a manifest that was created by SourceFingerprint.Manifest.
+func parseSourceManifest(manifest string) (*SourceFingerprint, error) {
+	f := &SourceFingerprint{Checksums: map[uint64]string{}}
+	for _, line := range strings.Split(manifest, "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid manifest line %q", line)
+		}
+		version, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest line %q", line)
+		}
+		f.Checksums[version] = fields[1]
+		f.Migrations++
+		if version > f.HighestVersion {
+			f.HighestVersion = version
+		}
+	}
+	return f, nil
+}
+
+// Manifest lists the checksums of the migrations, one "<version> <checksum>" line per migration, ordered by version.
+func (f *SourceFingerprint) Manifest() string {
+	versions := make([]uint64, 0, len(f.Checksums))
+	for version := range f.Checksums {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	var manifest strings.Builder
+	for _, version := range versions {
+		manifest.WriteString(strconv.FormatUint(version, 10) + " " + f.Checksums[version] + "\n")
+	}
+	return manifest.String()
+}
+
+// String returns the SHA-256 checksum of the manifest, which identifies the source.
+func (f *SourceFingerprint) String() string {
+	sum := sha256.Sum256([]byte(f.Manifest()))
+	return hex.EncodeToString(sum[:])
+}
+
+// conflict returns the lowest version at which the sources differ. Up to the highest version of the smaller
+// source, both sources must contain the same migrations, a source with additional, later migrations (e.g. a newer
+// release) is compatible.
+func (f *SourceFingerprint) conflict(other *SourceFingerprint) (uint64, bool) {
+	var conflicts []uint64
+	for version, checksum := range f.Checksums {
+		if version <= other.HighestVersion && other.Checksums[version] != checksum {
+			conflicts = append(conflicts, version)
+		}
+	}
+	for version := range other.Checksums {
+		if _, ok := f.Checksums[version]; !ok && version <= f.HighestVersion {
+			conflicts = append(conflicts, version)
+		}
+	}
+	if len(conflicts) == 0 {
+		return 0, false
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i] < conflicts[j] })
+	return conflicts[0], true
+}
+
+// WithSourceGuard protects the migration table from instances that embed different migration sets, e.g. two
+// releases that both added a different migration 42. The fingerprint of the source (see NewSourceFingerprint) is
+// recorded in the source registry (schema_migrations_sources) before the first migration of each run. If the
+// migrations of a recorded source differ from the source up to the highest version of either of them, the
+// migration is refused with ErrIncompatibleSource. Sources can be removed from the registry manually, e.g. after a
+// migration was changed deliberately. The source guard requires the default version store, see WithVersionStore.
+func WithSourceGuard(source lightmigrate.MigrationSource) DriverOption {
+	return func(d *driver) {
+		d.guardedSource = source
+	}
+}
+
+// sourceTable returns the name of the source registry, including the configured table prefix.
+func (d *driver) sourceTable() string {
+	if d.cfg.Namespace != "" {
+		return d.tableName(DefaultSourceTable + "_" + d.cfg.Namespace)
+	}
+	return d.tableName(DefaultSourceTable)
+}
+
+// fingerprintSource computes the fingerprint of the guarded source, see WithSourceGuard.
+func (d *driver) fingerprintSource() error {
+	if d.guardedSource == nil {
+		return nil
+	}
+
+	fingerprint, err := NewSourceFingerprint(d.guardedSource)
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to fingerprint the migration source"}
+	}
+	d.sourceFingerprint = fingerprint
+	return nil
+}
+
+// prepareSourceTable creates the source registry, if the source guard is enabled.
+func (d *driver) prepareSourceTable(ctx context.Context) error {
+	if d.sourceFingerprint == nil {
+		return nil
+	}
+
+	if err := d.createTable(ctx, d.sourceTable(), sourceTableColumns, "failed create source table"); err != nil {
+		return err
+	}
+
+	return d.ensureColumns(ctx, d.sourceTable(), sourceTableColumns)
+}
+
+// checkSource compares the source with the recorded sources and records it, before the first migration of a run
+// is applied, see WithSourceGuard.
+func (d *driver) checkSource() error {
+	if d.sourceFingerprint == nil || d.runActive {
+		return nil
+	}
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	own := d.sourceFingerprint.String()
+	query := "SELECT fingerprint, manifest, UNIX_TIMESTAMP(last_run_at) FROM `" + d.sourceTable() + "`"
+	rows, err := d.client.QueryContext(ctx, query)
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read source registry", Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var fingerprint, manifest string
+		var lastRun int64
+		if err := rows.Scan(&fingerprint, &manifest, &lastRun); err != nil {
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read source registry", Query: []byte(query)}
+		}
+		if fingerprint == own {
+			continue
+		}
+
+		recorded, err := parseSourceManifest(manifest)
+		if err != nil {
+			return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to parse manifest of source " + fingerprint}
+		}
+		if version, ok := d.sourceFingerprint.conflict(recorded); ok {
+			return &lightmigrate.DriverError{
+				OrigErr: ErrIncompatibleSource,
+				Msg: fmt.Sprintf("migration %d differs from source %s (%d migrations up to version %d, last run "+
+					"at %s)", version, fingerprint, recorded.Migrations, recorded.HighestVersion,
+					time.Unix(lastRun, 0).UTC().Format(time.RFC3339)),
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to read source registry", Query: []byte(query)}
+	}
+	_ = rows.Close() // release the connection before the source is recorded
+
+	now := time.Now().Unix()
+	query = "INSERT INTO `" + d.sourceTable() + "` (fingerprint, migrations, highest_version, manifest, " +
+		"first_run_at, last_run_at, runs) VALUES (?, ?, ?, ?, FROM_UNIXTIME(?), FROM_UNIXTIME(?), 1) " +
+		"ON DUPLICATE KEY UPDATE last_run_at = VALUES(last_run_at), runs = runs + 1"
+	if _, err := d.client.ExecContext(ctx, query, own, d.sourceFingerprint.Migrations,
+		d.sourceFingerprint.HighestVersion, d.sourceFingerprint.Manifest(), now, now); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to record migration source", Query: []byte(query)}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/h44z/lightmigrate"
+)
+
+func fingerprintOf(t *testing.T, fsys fstest.MapFS) *SourceFingerprint {
+	source, err := lightmigrate.NewFsSource(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	fingerprint, err := NewSourceFingerprint(source)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return fingerprint
+}
+
+func TestNewSourceFingerprint(t *testing.T) {
+	fingerprint := fingerprintOf(t, fstest.MapFS{
+		"migrations/1_init.up.sql":      {Data: []byte("CREATE TABLE a (id int);")},
+		"migrations/1_init.down.sql":    {Data: []byte("DROP TABLE a;")},
+		"migrations/3_users.up.sql":     {Data: []byte("CREATE TABLE b (id int);")},
+		"migrations/4_cleanup.down.sql": {Data: []byte("SELECT 1;")},
+	})
+	if fingerprint.Migrations != 2 || fingerprint.HighestVersion != 3 {
+		t.Fatalf("unexpected fingerprint, got: %+v", fingerprint)
+	}
+
+	parsed, err := parseSourceManifest(fingerprint.Manifest())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if parsed.String() != fingerprint.String() || parsed.Migrations != 2 || parsed.HighestVersion != 3 {
+		t.Fatalf("unexpected parsed manifest %+v, got: %+v", fingerprint, parsed)
+	}
+}
+
+func TestSourceFingerprint_conflict(t *testing.T) {
+	base := &SourceFingerprint{HighestVersion: 2, Checksums: map[uint64]string{1: "a", 2: "b"}}
+	tests := []struct {
+		name         string
+		other        *SourceFingerprint
+		wantVersion  uint64
+		wantConflict bool
+	}{
+		{"same", &SourceFingerprint{HighestVersion: 2, Checksums: map[uint64]string{1: "a", 2: "b"}}, 0, false},
+		{"newer release", &SourceFingerprint{HighestVersion: 3, Checksums: map[uint64]string{1: "a", 2: "b", 3: "c"}}, 0, false},
+		{"older release", &SourceFingerprint{HighestVersion: 1, Checksums: map[uint64]string{1: "a"}}, 0, false},
+		{"changed migration", &SourceFingerprint{HighestVersion: 3, Checksums: map[uint64]string{1: "a", 2: "x", 3: "c"}}, 2, true},
+		{"missing migration", &SourceFingerprint{HighestVersion: 3, Checksums: map[uint64]string{1: "a", 3: "c"}}, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, conflict := base.conflict(tt.other)
+			if conflict != tt.wantConflict || version != tt.wantVersion {
+				t.Fatalf("unexpected conflict %t at %d, got: %t at %d", tt.wantConflict, tt.wantVersion, conflict,
+					version)
+			}
+		})
+	}
+}
+
+func Test_driver_checkSource(t *testing.T) {
+	own := &SourceFingerprint{Migrations: 2, HighestVersion: 2, Checksums: map[uint64]string{1: "a", 2: "b"}}
+	tests := []struct {
+		name     string
+		recorded *SourceFingerprint
+		wantErr  error
+	}{
+		{"compatible", &SourceFingerprint{Checksums: map[uint64]string{1: "a"}}, nil},
+		{"incompatible", &SourceFingerprint{Checksums: map[uint64]string{1: "a", 2: "x"}}, ErrIncompatibleSource},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+				if strings.HasPrefix(call.Query, "SELECT fingerprint, manifest") {
+					return fakeResponse{Columns: []string{"fingerprint", "manifest", "last_run_at"},
+						Rows: [][]sqldriver.Value{{"other", tt.recorded.Manifest(), int64(1760000000)}}}
+				}
+				return defaultFakeHandler(call)
+			})
+			d := &driver{client: db, cfg: &config{}, sourceFingerprint: own}
+
+			err := d.checkSource()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+
+			var recorded bool
+			for _, call := range srv.Calls() {
+				if strings.HasPrefix(call.Query, "INSERT INTO `schema_migrations_sources`") {
+					recorded = call.Args[0] == own.String() && call.Args[3] == own.Manifest()
+				}
+			}
+			if recorded != (tt.wantErr == nil) {
+				t.Fatalf("unexpected recording %t, got: %q", tt.wantErr == nil, srv.Queries())
+			}
+		})
+	}
+}
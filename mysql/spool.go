@@ -0,0 +1,113 @@
+package mysql
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// WithMaxInMemoryMigrationSize reads each migration completely before it is executed, so that a failing source
+// does not leave a partially applied migration. Migrations up to size bytes are buffered in memory, larger
+// migrations are buffered in a temporary file, which protects small migration pods from running out of memory on
+// huge data migrations. Without statement splitting, migrations above the size are rejected with
+// ErrMigrationTooLarge, as they would have to be sent to the server within one query. Zero disables the buffering.
+func WithMaxInMemoryMigrationSize(size int64) DriverOption {
+	return func(d *driver) {
+		d.cfg.MaxInMemoryMigrationSize = size
+	}
+}
+
+// spooledMigration is a migration that was read completely, see spoolMigration.
+type spooledMigration struct {
+	io.Reader
+	buf  *bytes.Buffer
+	file *os.File
+}
+
+// spoolMigration reads the migration completely. Up to max bytes are kept in a pooled buffer, larger migrations
+// are written to a temporary file. The spooled migration must be closed after use.
+func spoolMigration(migration io.Reader, max int64) (*spooledMigration, error) {
+	limited := migration
+	if max < math.MaxInt64 { // max+1 would overflow, the migration is read completely then
+		limited = io.LimitReader(migration, max+1)
+	}
+
+	buf := getBuffer()
+	n, err := buf.ReadFrom(limited)
+	if err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	if n <= max {
+		return &spooledMigration{Reader: bytes.NewReader(buf.Bytes()), buf: buf}, nil
+	}
+
+	file, err := os.CreateTemp("", "lightmigrate-*.sql")
+	if err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	spooled := &spooledMigration{Reader: file, file: file}
+
+	_, err = buf.WriteTo(file)
+	putBuffer(buf)
+	if err == nil {
+		_, err = io.Copy(file, migration)
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
+		return nil, err
+	}
+
+	return spooled, nil
+}
+
+// Spilled returns true, if the migration was buffered in a temporary file.
+func (m *spooledMigration) Spilled() bool {
+	return m.file != nil
+}
+
+// Close releases the buffer or removes the temporary file.
+func (m *spooledMigration) Close() {
+	if m.buf != nil {
+		putBuffer(m.buf)
+		m.buf = nil
+	}
+	if m.file != nil {
+		_ = m.file.Close()
+		_ = os.Remove(m.file.Name())
+		m.file = nil
+	}
+}
+
+// spool reads the migration completely, if WithMaxInMemoryMigrationSize is set. The returned function releases
+// the spooled migration.
+func (d *driver) spool(migration io.Reader) (io.Reader, func(), error) {
+	if d.cfg.MaxInMemoryMigrationSize <= 0 {
+		return migration, func() {}, nil
+	}
+
+	spooled, err := spoolMigration(migration, d.cfg.MaxInMemoryMigrationSize)
+	if err != nil {
+		return nil, nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to buffer migration"}
+	}
+	if spooled.Spilled() && !d.cfg.SplitStatements {
+		spooled.Close()
+		return nil, nil, &lightmigrate.DriverError{OrigErr: ErrMigrationTooLarge, Msg: fmt.Sprintf(
+			"migration exceeds the maximum in-memory size of %d bytes, statement splitting is required",
+			d.cfg.MaxInMemoryMigrationSize)}
+	}
+	if spooled.Spilled() && d.verbose {
+		d.logf("migration exceeds %d bytes, buffered in temporary file %s", d.cfg.MaxInMemoryMigrationSize,
+			spooled.file.Name())
+	}
+
+	return spooled, spooled.Close, nil
+}
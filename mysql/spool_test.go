@@ -0,0 +1,84 @@
+package mysql
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWithMaxInMemoryMigrationSize(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithMaxInMemoryMigrationSize(1024)(d)
+	if d.cfg.MaxInMemoryMigrationSize != 1024 {
+		t.Fatalf("failed to set max in-memory migration size")
+	}
+}
+
+func Test_spoolMigration(t *testing.T) {
+	migration := "CREATE TABLE a (id int);\nCREATE TABLE b (id int);\n"
+	tests := []struct {
+		name    string
+		max     int64
+		spilled bool
+	}{
+		{"in memory", int64(len(migration)), false},
+		{"temporary file", 10, true},
+		{"unlimited", math.MaxInt64, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spooled, err := spoolMigration(strings.NewReader(migration), tt.max)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if spooled.Spilled() != tt.spilled {
+				t.Fatalf("unexpected spilled %t, got: %t", tt.spilled, spooled.Spilled())
+			}
+
+			var name string
+			if spooled.file != nil {
+				name = spooled.file.Name()
+			}
+			content, _ := io.ReadAll(spooled)
+			if string(content) != migration {
+				t.Fatalf("unexpected content %q, got: %q", migration, content)
+			}
+
+			spooled.Close()
+			if _, err := os.Stat(name); name != "" && !os.IsNotExist(err) {
+				t.Fatalf("temporary file was not removed: %v", err)
+			}
+		})
+	}
+}
+
+func Test_driver_RunMigration_Spooled(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{SplitStatements: true, MaxInMemoryMigrationSize: 10}}
+
+	if err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int); CREATE TABLE b (id int);")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries := srv.Queries(); len(queries) != 2 || queries[1] != "CREATE TABLE b (id int)" {
+		t.Fatalf("unexpected queries: %q", queries)
+	}
+}
+
+func Test_driver_RunMigration_TooLarge(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	d := &driver{client: db, cfg: &config{MaxInMemoryMigrationSize: 10}}
+
+	err := d.RunMigration(strings.NewReader("CREATE TABLE a (id int);"))
+	if !errors.Is(err, ErrMigrationTooLarge) {
+		t.Fatalf("expected error %v, got: %v", ErrMigrationTooLarge, err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "CREATE TABLE") {
+			t.Fatalf("unexpected query: %s", query)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package mysql
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DefaultDelimiter is the statement delimiter that is active at the beginning of each migration.
+const DefaultDelimiter = ";"
+
+const scannerBufferSize = 32 * 1024
+
+var (
+	readerPool = sync.Pool{New: func() interface{} { return bufio.NewReaderSize(nil, scannerBufferSize) }}
+	bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+)
+
+// getBuffer fetches an empty buffer from the shared buffer pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns the buffer to the shared buffer pool. Very large buffers are dropped so that a single
+// huge migration does not pin its memory for the lifetime of the process.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > 4*1024*1024 {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// DirectivePrefix starts a driver directive comment, e.g. "-- lightmigrate:include other.sql".
+const DirectivePrefix = "lightmigrate:"
+
+type tokenKind int
+
+const (
+	tokenStatement tokenKind = iota
+	tokenDirective
+)
+
+type scanState int
+
+const (
+	stateNormal scanState = iota
+	stateSingleQuote
+	stateDoubleQuote
+	stateBacktick
+	stateLineComment
+	stateBlockComment
+	stateKeptBlockComment
+)
+
+// statementScanner splits a migration into single statements while streaming the input.
+// Only the statement that is currently scanned is held in memory, the buffers are pooled and reused.
+//
+// Quoted strings, backticked identifiers and comments are respected, and the mysql-CLI style
+// DELIMITER command can be used to switch the statement delimiter (e.g. for stored procedures).
+// Comments are stripped from the statements, except for executable (/*! ... */) comments and
+// optimizer hints (/*+ ... */).
+//
+// Directive comments ("-- lightmigrate:<directive>") between statements and the mysql-CLI commands
+// "source <file>" and "\. <file>" are returned as directive tokens.
+type statementScanner struct {
+	r         *bufio.Reader
+	buf       *bytes.Buffer
+	delimiter []byte
+
+	state     scanState
+	line      int // current line of the input (1-based)
+	stmtLine  int // line where the current statement started
+	stmtCount int // number of statements returned so far
+	err       error
+}
+
+// newStatementScanner creates a new scanner for the given input. The scanner must be closed after use to
+// return its buffers to the pool.
+func newStatementScanner(input io.Reader) *statementScanner {
+	r := readerPool.Get().(*bufio.Reader)
+	r.Reset(input)
+
+	return &statementScanner{
+		r:         r,
+		buf:       getBuffer(),
+		delimiter: []byte(DefaultDelimiter),
+		line:      1,
+	}
+}
+
+// Close releases the buffers of the scanner. The scanner may not be used afterwards.
+func (s *statementScanner) Close() {
+	if s.r != nil {
+		s.r.Reset(nil)
+		readerPool.Put(s.r)
+		s.r = nil
+	}
+	if s.buf != nil {
+		putBuffer(s.buf)
+		s.buf = nil
+	}
+}
+
+// Next advances the scanner to the next token. For statement tokens, text contains the statement without its
+// delimiter, for directive tokens it contains the directive without the prefix (e.g. "include other.sql").
+// The returned slice is only valid until the next call to Next.
+// At the end of the input or on read errors, ok will be false. Use Err to distinguish both states.
+func (s *statementScanner) Next() (kind tokenKind, text []byte, ok bool) {
+	s.buf.Reset()
+	s.state = stateNormal
+
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+				return tokenStatement, nil, false
+			}
+			text, ok = s.finish()
+			return tokenStatement, text, ok
+		}
+
+		if c == '\n' {
+			s.line++
+		}
+
+		switch s.state {
+		case stateNormal:
+			if s.buf.Len() == 0 {
+				if directive, consumed, err := s.scanCommand(c); err != nil {
+					s.err = err
+					return tokenStatement, nil, false
+				} else if directive != nil {
+					return tokenDirective, directive, true
+				} else if consumed {
+					continue
+				}
+			}
+			if done := s.scanNormal(c); done && !s.withinEventBody() {
+				text = bytes.TrimSpace(s.buf.Bytes()[:s.buf.Len()-len(s.delimiter)])
+				if len(text) == 0 {
+					s.buf.Reset()
+					continue // empty statement, e.g. ";;"
+				}
+				s.stmtCount++
+				return tokenStatement, text, true
+			}
+		case stateSingleQuote:
+			s.scanQuoted(c, '\'')
+		case stateDoubleQuote:
+			s.scanQuoted(c, '"')
+		case stateBacktick:
+			s.buf.WriteByte(c)
+			if c == '`' {
+				s.state = stateNormal
+			}
+		case stateLineComment:
+			if c == '\n' {
+				s.state = stateNormal
+				s.writeSeparator('\n')
+			}
+		case stateBlockComment:
+			if c == '*' && s.peekIs('/') {
+				_, _ = s.r.ReadByte()
+				s.state = stateNormal
+				s.writeSeparator(' ')
+			}
+		case stateKeptBlockComment:
+			s.buf.WriteByte(c)
+			if c == '*' && s.peekIs('/') {
+				_, _ = s.r.ReadByte()
+				s.buf.WriteByte('/')
+				s.state = stateNormal
+			}
+		}
+	}
+}
+
+// Err returns the first non-EOF error that was encountered by the scanner.
+func (s *statementScanner) Err() error {
+	return s.err
+}
+
+// Line returns the line number where the last statement returned by Next started.
+func (s *statementScanner) Line() int {
+	return s.stmtLine
+}
+
+// Count returns the number of statements returned so far.
+func (s *statementScanner) Count() int {
+	return s.stmtCount
+}
+
+// finish returns the trailing statement which was not terminated by a delimiter.
+func (s *statementScanner) finish() ([]byte, bool) {
+	stmt := bytes.TrimSpace(s.buf.Bytes())
+	if len(stmt) == 0 {
+		return nil, false
+	}
+	s.buf.Reset() // further calls to Next will report the end of input
+	s.stmtCount++
+	return stmt, true
+}
+
+// scanCommand checks if a client command or a directive starts at the beginning of a statement.
+// DELIMITER commands are handled by the scanner, all other commands are returned as directives.
+// If consumed is true, the current line was consumed by the command.
+func (s *statementScanner) scanCommand(c byte) (directive []byte, consumed bool, err error) {
+	s.markStart()
+
+	switch {
+	case (c == 'd' || c == 'D') && s.peekKeyword("ELIMITER"):
+		line, err := s.readLine(len("ELIMITER"))
+		if fields := bytes.Fields(line); len(fields) > 0 {
+			s.delimiter = append([]byte(nil), fields[0]...)
+		}
+		return nil, true, err
+	case (c == 's' || c == 'S') && s.peekKeyword("OURCE"):
+		line, err := s.readLine(len("OURCE"))
+		return s.includeDirective(line), true, err
+	case c == '\\' && s.peekIs('.'):
+		line, err := s.readLine(1)
+		return s.includeDirective(line), true, err
+	case c == '-' && s.peekDirective():
+		line, err := s.readLine(len("- ")) // the second dash and the whitespace
+		return bytes.TrimSpace(bytes.TrimSpace(line)[len(DirectivePrefix):]), true, err
+	}
+	return nil, false, nil
+}
+
+// includeDirective converts the argument of a source command to an include directive.
+func (s *statementScanner) includeDirective(arg []byte) []byte {
+	arg = bytes.TrimSpace(arg)
+	arg = bytes.TrimSpace(bytes.TrimSuffix(arg, s.delimiter))
+
+	s.buf.WriteString("include ")
+	s.buf.Write(arg)
+	return s.buf.Bytes()
+}
+
+// scanNormal handles a byte outside of quotes and comments. It returns true if the statement delimiter was found.
+func (s *statementScanner) scanNormal(c byte) bool {
+	if s.buf.Len() == 0 && isSpace(c) {
+		return false // skip leading whitespace
+	}
+
+	switch c {
+	case '\'':
+		s.state = stateSingleQuote
+	case '"':
+		s.state = stateDoubleQuote
+	case '`':
+		s.state = stateBacktick
+	case '#':
+		s.state = stateLineComment
+		return false
+	case '-':
+		if s.peekLineComment() {
+			s.state = stateLineComment
+			return false
+		}
+	case '/':
+		if s.peekIs('*') {
+			_, _ = s.r.ReadByte()
+			if s.peekIs('!') || s.peekIs('+') {
+				s.state = stateKeptBlockComment
+				s.markStart()
+				s.buf.WriteString("/*")
+			} else {
+				s.state = stateBlockComment
+			}
+			return false
+		}
+	}
+
+	s.markStart()
+	s.buf.WriteByte(c)
+
+	return c == s.delimiter[len(s.delimiter)-1] && bytes.HasSuffix(s.buf.Bytes(), s.delimiter)
+}
+
+// withinEventBody checks if the default delimiter was found within the BEGIN ... END body of an event definition.
+func (s *statementScanner) withinEventBody() bool {
+	return bytes.Equal(s.delimiter, []byte(DefaultDelimiter)) && openEventBody(s.buf.Bytes())
+}
+
+// scanQuoted handles a byte within a quoted string. Backslash escapes and doubled quotes are supported.
+func (s *statementScanner) scanQuoted(c, quote byte) {
+	s.buf.WriteByte(c)
+	switch c {
+	case '\\':
+		if next, err := s.r.ReadByte(); err == nil {
+			if next == '\n' {
+				s.line++
+			}
+			s.buf.WriteByte(next)
+		}
+	case quote:
+		s.state = stateNormal // a doubled quote simply re-enters the quoted state
+	}
+}
+
+// markStart remembers the line of the statement, if the statement is about to begin.
+func (s *statementScanner) markStart() {
+	if s.buf.Len() == 0 {
+		s.stmtLine = s.line
+	}
+}
+
+// writeSeparator replaces a stripped comment, so that the surrounding tokens do not get merged.
+func (s *statementScanner) writeSeparator(c byte) {
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte(c)
+	}
+}
+
+// readLine consumes the rest of the current line. The first skip bytes of the line are dropped.
+func (s *statementScanner) readLine(skip int) ([]byte, error) {
+	line, err := s.r.ReadSlice('\n')
+	for err == bufio.ErrBufferFull { // very long line, collect it in the statement buffer
+		s.buf.Write(line)
+		line, err = s.r.ReadSlice('\n')
+	}
+	if s.buf.Len() > 0 {
+		s.buf.Write(line)
+		line = append([]byte(nil), s.buf.Bytes()...)
+		s.buf.Reset()
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if err == nil {
+		s.line++
+	}
+	if len(line) < skip {
+		return nil, nil
+	}
+
+	return line[skip:], nil
+}
+
+// peekIs checks if the next byte of the input equals c, without consuming it.
+func (s *statementScanner) peekIs(c byte) bool {
+	next, err := s.r.Peek(1)
+	return err == nil && next[0] == c
+}
+
+// peekLineComment checks if a "-- " comment starts at the current position. The first dash was already consumed.
+// MySQL requires the second dash to be followed by whitespace or the end of the input.
+func (s *statementScanner) peekLineComment() bool {
+	next, _ := s.r.Peek(2)
+	switch {
+	case len(next) == 0 || next[0] != '-':
+		return false
+	case len(next) == 1:
+		return true
+	default:
+		return isSpace(next[1]) || next[1] < 0x20
+	}
+}
+
+// peekDirective checks if a directive comment starts at the current position. The first dash was already consumed.
+func (s *statementScanner) peekDirective() bool {
+	next, _ := s.r.Peek(len("- ") + len(DirectivePrefix))
+	return len(next) == len("- ")+len(DirectivePrefix) && next[0] == '-' && isSpace(next[1]) &&
+		string(next[2:]) == DirectivePrefix
+}
+
+// peekKeyword checks (case-insensitive) if the next bytes of the input equal keyword, followed by whitespace.
+func (s *statementScanner) peekKeyword(keyword string) bool {
+	next, _ := s.r.Peek(len(keyword) + 1)
+	if len(next) < len(keyword)+1 {
+		return false
+	}
+	return bytes.EqualFold(next[:len(keyword)], []byte(keyword)) && isSpace(next[len(keyword)])
+}
+
+// splitDirective splits a directive token into its lower-cased name and the (trimmed) argument. The argument is
+// separated by whitespace or an equals sign, e.g. "max-duration=10m".
+func splitDirective(directive []byte) (name, arg string) {
+	text := strings.TrimSpace(string(directive))
+	if idx := strings.IndexFunc(text, func(r rune) bool { return r == ' ' || r == '\t' || r == '=' }); idx >= 0 {
+		return strings.ToLower(text[:idx]), strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text[idx:]), "="))
+	}
+	return strings.ToLower(text), ""
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
@@ -0,0 +1,74 @@
+//go:build go1.18
+// +build go1.18
+
+package mysql
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func FuzzStatementScanner(f *testing.F) {
+	f.Add("SELECT 1;\nSELECT 2;")
+	f.Add("INSERT INTO t VALUES ('a;b', \"c;d\", `e;f`); -- comment\n# comment\nSELECT /* x; */ 1;")
+	f.Add("DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; END$$\nDELIMITER ;\nSELECT 2;")
+	f.Add("-- lightmigrate:parallel\nsource a.sql\n\\. b.sql\nSELECT 'it\\'s';")
+	f.Add("/*!40101 SET NAMES utf8 */;SELECT 5--3;")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		s := newStatementScanner(strings.NewReader(input))
+		defer s.Close()
+
+		lines := strings.Count(input, "\n") + 1
+		lastLine, statements := 0, 0
+		for {
+			kind, text, ok := s.Next()
+			if !ok {
+				break
+			}
+			if kind == tokenStatement {
+				statements++
+				if len(bytes.TrimSpace(text)) != len(text) || len(text) == 0 {
+					t.Fatalf("statement is not trimmed: %q", text)
+				}
+			}
+			if s.Line() < lastLine || s.Line() > lines {
+				t.Fatalf("unexpected line %d (previous %d, total %d)", s.Line(), lastLine, lines)
+			}
+			lastLine = s.Line()
+		}
+
+		if err := s.Err(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if s.Count() != statements {
+			t.Fatalf("unexpected count %d, got: %d", statements, s.Count())
+		}
+	})
+}
+
+func FuzzStatementScanner_Literals(f *testing.F) {
+	f.Add("a;b", "c;d", "BEGIN SELECT 1; END")
+	f.Add("it's -- not a comment", "/* no comment */", "SELECT '$$'")
+	f.Add("\\", "#", "")
+
+	f.Fuzz(func(t *testing.T, literal, identifier, body string) {
+		identifier = strings.ReplaceAll(identifier, "`", "``")
+		if strings.Contains(body, "$") || strings.TrimSpace(body) != body || body == "" ||
+			strings.ContainsAny(body, "'\"`#-/\\") {
+			t.Skip() // the body must not contain the delimiter, quotes or comments
+		}
+
+		want := []string{
+			"SELECT " + quoteLiteral(literal) + " AS `" + identifier + "`",
+			"CREATE PROCEDURE p() " + body,
+		}
+		input := want[0] + ";\nDELIMITER $$\n" + want[1] + "$$\nDELIMITER ;\n"
+
+		got := scanAll(t, input)
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Fatalf("unexpected statements %q, got: %q", want, got)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+package mysql
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func scanAll(t testing.TB, input string) []string {
+	s := newStatementScanner(strings.NewReader(input))
+	defer s.Close()
+
+	var stmts []string
+	for {
+		kind, text, ok := s.Next()
+		if !ok {
+			break
+		}
+		if kind == tokenDirective {
+			stmts = append(stmts, "directive: "+string(text))
+		} else {
+			stmts = append(stmts, string(text))
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return stmts
+}
+
+func Test_statementScanner_Next(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"whitespace only", " \n\t ", nil},
+		{"single", "SELECT 1;", []string{"SELECT 1"}},
+		{"no trailing delimiter", "SELECT 1;\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"empty statements", ";;SELECT 1;;", []string{"SELECT 1"}},
+		{"quoted delimiter", "INSERT INTO t VALUES ('a;b', \"c;d\");", []string{"INSERT INTO t VALUES ('a;b', \"c;d\")"}},
+		{"escaped quotes", `SELECT 'it\'s;', 'it''s;';`, []string{`SELECT 'it\'s;', 'it''s;'`}},
+		{"backticks", "CREATE TABLE `a;b` (id int);", []string{"CREATE TABLE `a;b` (id int)"}},
+		{"line comments", "-- first; comment\nSELECT 1; # second; comment\nSELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"double dash without space", "SELECT 5--3;", []string{"SELECT 5--3"}},
+		{"block comment", "SELECT /* a; b */ 1;", []string{"SELECT   1"}},
+		{"executable comment", "/*!40101 SET NAMES utf8 */;", []string{"/*!40101 SET NAMES utf8 */"}},
+		{"optimizer hint", "SELECT /*+ NO_RANGE_OPTIMIZATION(t3) */ 1;", []string{"SELECT /*+ NO_RANGE_OPTIMIZATION(t3) */ 1"}},
+		{
+			"delimiter",
+			"DELIMITER $$\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END$$\nDELIMITER ;\nSELECT 3;",
+			[]string{"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END", "SELECT 3"},
+		},
+		{
+			"event body",
+			"CREATE DEFINER=`app`@`%` EVENT e ON SCHEDULE EVERY 1 DAY DO BEGIN\n" +
+				"IF 1 THEN DELETE FROM t; END IF; SELECT CASE WHEN 1 THEN 2 END; END;\nSELECT 3;",
+			[]string{"CREATE DEFINER=`app`@`%` EVENT e ON SCHEDULE EVERY 1 DAY DO BEGIN\n" +
+				"IF 1 THEN DELETE FROM t; END IF; SELECT CASE WHEN 1 THEN 2 END; END", "SELECT 3"},
+		},
+		{
+			"event without body",
+			"ALTER EVENT e DO DELETE FROM t; SELECT 1;",
+			[]string{"ALTER EVENT e DO DELETE FROM t", "SELECT 1"},
+		},
+		{"delete is no delimiter command", "DELETE FROM t;", []string{"DELETE FROM t"}},
+		{"crlf", "SELECT 1;\r\nSELECT 2;\r\n", []string{"SELECT 1", "SELECT 2"}},
+		{
+			"directives",
+			"-- lightmigrate:include a.sql\nSELECT 1; -- lightmigrate:no-lock\nSELECT 2 -- lightmigrate:ignored\n;",
+			[]string{"directive: include a.sql", "SELECT 1", "directive: no-lock", "SELECT 2"},
+		},
+		{"source", "source a.sql\nSOURCE b.sql;\n\\. c.sql\nSELECT 1;", []string{"directive: include a.sql", "directive: include b.sql", "directive: include c.sql", "SELECT 1"}},
+		{"source is no statement prefix", "SELECT 1; sources;", []string{"SELECT 1", "sources"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanAll(t, tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("unexpected statements %q, got: %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_statementScanner_Line(t *testing.T) {
+	s := newStatementScanner(strings.NewReader("SELECT 1;\n\n-- comment\nSELECT\n2;\nSELECT 'a\nb'; SELECT 4;"))
+	defer s.Close()
+
+	var lines []int
+	for {
+		if _, _, ok := s.Next(); !ok {
+			break
+		}
+		lines = append(lines, s.Line())
+	}
+
+	if want := []int{1, 4, 6, 7}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("unexpected lines %v, got: %v", want, lines)
+	}
+	if s.Count() != 4 {
+		t.Fatalf("unexpected count 4, got: %d", s.Count())
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(_ []byte) (int, error) {
+	return 0, io.ErrUnexpectedEOF
+}
+
+func Test_statementScanner_Err(t *testing.T) {
+	s := newStatementScanner(io.MultiReader(strings.NewReader("SELECT 1;"), failingReader{}))
+	defer s.Close()
+
+	if _, stmt, ok := s.Next(); !ok || string(stmt) != "SELECT 1" {
+		t.Fatalf("unexpected statement SELECT 1, got: %s", stmt)
+	}
+	if _, _, ok := s.Next(); ok {
+		t.Fatalf("expected scanner to stop")
+	}
+	if s.Err() != io.ErrUnexpectedEOF {
+		t.Fatalf("unexpected error %v, got: %v", io.ErrUnexpectedEOF, s.Err())
+	}
+}
+
+func Test_splitDirective(t *testing.T) {
+	name, arg := splitDirective([]byte(" Include  dir/a.sql "))
+	if name != "include" || arg != "dir/a.sql" {
+		t.Fatalf("unexpected directive include dir/a.sql, got: %s %s", name, arg)
+	}
+
+	name, arg = splitDirective([]byte("no-lock"))
+	if name != "no-lock" || arg != "" {
+		t.Fatalf("unexpected directive no-lock, got: %s %s", name, arg)
+	}
+
+	name, arg = splitDirective([]byte("max-duration=10m"))
+	if name != "max-duration" || arg != "10m" {
+		t.Fatalf("unexpected directive max-duration=10m, got: %s %s", name, arg)
+	}
+}
+
+// quoteLiteral quotes the string as MySQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// Test_statementScanner_Properties verifies that the scanner returns randomly generated statements unchanged,
+// regardless of delimiters, quotes or comment markers within string literals and identifiers.
+func Test_statementScanner_Properties(t *testing.T) {
+	roundTrip := func(literals []string, identifier string) bool {
+		identifier = strings.ReplaceAll(identifier, "`", "``")
+
+		var want []string
+		var input strings.Builder
+		for _, literal := range literals {
+			stmt := "INSERT INTO `" + identifier + "` VALUES (" + quoteLiteral(literal) + ", \"x;y\")"
+			want = append(want, stmt)
+			input.WriteString(stmt + "; -- trailing comment\n")
+		}
+
+		got := scanAll(t, input.String())
+		return reflect.DeepEqual(got, want)
+	}
+
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatalf("statements were mangled: %v", err)
+	}
+}
+
+func benchmarkMigration(statements int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("-- benchmark migration\n")
+	for i := 0; i < statements; i++ {
+		buf.WriteString("INSERT INTO `users` (`id`, `name`, `note`) VALUES (1, 'user; with delimiter', \"quoted\"); -- comment\n")
+	}
+	return buf.Bytes()
+}
+
+func Benchmark_statementScanner(b *testing.B) {
+	migration := benchmarkMigration(1000)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(migration)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := newStatementScanner(bytes.NewReader(migration))
+		for {
+			if _, _, ok := s.Next(); !ok {
+				break
+			}
+		}
+		s.Close()
+	}
+}
+
+func Benchmark_driver_RunMigration(b *testing.B) {
+	db, srv := newFakeDB(b, func(call fakeCall) fakeResponse { return fakeResponse{} })
+	d := &driver{client: db, cfg: &config{SplitStatements: true}}
+	migration := benchmarkMigration(1000)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(migration)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := d.RunMigration(bytes.NewReader(migration)); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+		srv.Reset()
+	}
+}
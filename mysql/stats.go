@@ -0,0 +1,87 @@
+package mysql
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats are the cumulative runtime statistics of a driver instance, see Driver.Stats. They can be
+// published using expvar, e.g. expvar.Publish("migrations", expvar.Func(func() interface{} { return d.Stats() })).
+type Stats struct {
+	// MigrationsApplied is the number of migrations that were applied successfully by this driver.
+	MigrationsApplied int64 `json:"migrations_applied"`
+	// MigrationsFailed is the number of migrations that failed.
+	MigrationsFailed int64 `json:"migrations_failed"`
+	// Statements is the total number of executed migration statements.
+	Statements int64 `json:"statements"`
+	// ExecutionTime is the total execution time of all migrations.
+	ExecutionTime time.Duration `json:"execution_time_ns"`
+	// LockWaitTime is the total time that was spent waiting for the migration lock.
+	LockWaitTime time.Duration `json:"lock_wait_time_ns"`
+	// LastError is the message of the last migration or locking error, empty if no error occurred.
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+}
+
+// statsRecorder collects the runtime statistics. It is safe for concurrent use, a nil recorder discards
+// all statistics.
+type statsRecorder struct {
+	mux   sync.Mutex
+	stats Stats
+}
+
+func newStatsRecorder() *statsRecorder {
+	return &statsRecorder{}
+}
+
+// recordMigration adds a finished (or failed) migration to the statistics.
+func (r *statsRecorder) recordMigration(statements int, duration time.Duration, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if err == nil {
+		r.stats.MigrationsApplied++
+	} else {
+		r.stats.MigrationsFailed++
+		r.setError(err)
+	}
+	r.stats.Statements += int64(statements)
+	r.stats.ExecutionTime += duration
+}
+
+// recordLockWait adds the time that was spent waiting for the migration lock to the statistics.
+func (r *statsRecorder) recordLockWait(wait time.Duration, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.stats.LockWaitTime += wait
+	if err != nil {
+		r.setError(err)
+	}
+}
+
+// setError remembers the last error, the mutex must be held by the caller.
+func (r *statsRecorder) setError(err error) {
+	r.stats.LastError = err.Error()
+	r.stats.LastErrorAt = time.Now()
+}
+
+// Stats returns the cumulative runtime statistics of the driver.
+func (d *driver) Stats() Stats {
+	if d.stats == nil {
+		return Stats{}
+	}
+
+	d.stats.mux.Lock()
+	defer d.stats.mux.Unlock()
+
+	return d.stats.stats
+}
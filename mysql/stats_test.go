@@ -0,0 +1,36 @@
+package mysql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_driver_Stats(t *testing.T) {
+	errSyntax := errors.New("syntax error")
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "BROKEN") {
+			return fakeResponse{Err: errSyntax}
+		}
+		return fakeResponse{}
+	})
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", SplitStatements: true}, stats: newStatsRecorder()}
+
+	if err := d.RunMigration(strings.NewReader("SELECT 1; SELECT 2;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.RunMigration(strings.NewReader("SELECT 3; BROKEN;")); err == nil {
+		t.Fatalf("expected error, got: %v", err)
+	}
+	d.observeLockWait(2*time.Second, nil)
+
+	stats := d.Stats()
+	if stats.MigrationsApplied != 1 || stats.MigrationsFailed != 1 || stats.Statements != 3 ||
+		stats.LockWaitTime != 2*time.Second || stats.ExecutionTime <= 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if !strings.Contains(stats.LastError, errSyntax.Error()) || stats.LastErrorAt.IsZero() {
+		t.Fatalf("unexpected last error: %s", stats.LastError)
+	}
+}
@@ -0,0 +1,77 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+// StatusReader reads the migration state of a database without changing it, see NewStatusReader.
+type StatusReader interface {
+	// GetVersion returns the current version and the dirty flag, like lightmigrate.MigrationDriver.
+	GetVersion() (version uint64, dirty bool, err error)
+
+	// Status reports the current migration state of the database.
+	Status(ctx context.Context) (*Status, error)
+
+	// AppliedVersions returns the migration history, see WithHistory.
+	AppliedVersions(ctx context.Context) ([]AppliedVersion, error)
+}
+
+// statusReader restricts a driver to its reading methods.
+type statusReader struct {
+	d *driver
+}
+
+// NewStatusReader instantiates a read-only view of the migration state, e.g. for dashboards and health checks.
+// Unlike NewDriver, no tables are created and no locks are taken, so SELECT privileges are sufficient. A database
+// without migrations table reports lightmigrate.NoMigrationVersion. The options configure the tables to read,
+// e.g. WithMigrationTable, WithNamespace or WithHistory.
+func NewStatusReader(client *sql.DB, database string, opts ...DriverOption) (StatusReader, error) {
+	d, err := newDriver(client, database, opts)
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.LockStrategy == LockStrategyAuto {
+		d.cfg.LockStrategy = LockStrategyAdvisory
+	}
+	return &statusReader{d: d}, nil
+}
+
+func (r *statusReader) GetVersion() (version uint64, dirty bool, err error) {
+	version, dirty, err = r.d.GetVersion()
+	if isMissingTable(err) {
+		return lightmigrate.NoMigrationVersion, false, nil
+	}
+	return version, dirty, err
+}
+
+func (r *statusReader) Status(ctx context.Context) (*Status, error) {
+	status, err := r.d.Status(ctx)
+	if !isMissingTable(err) {
+		return status, err
+	}
+
+	status = &Status{Version: lightmigrate.NoMigrationVersion}
+	if status.LockHeld, err = r.d.isLockHeld(ctx); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (r *statusReader) AppliedVersions(ctx context.Context) ([]AppliedVersion, error) {
+	history, err := r.d.AppliedVersions(ctx)
+	if isMissingTable(err) {
+		return nil, nil
+	}
+	return history, err
+}
+
+// isMissingTable checks if the error was caused by a table that does not exist.
+func isMissingTable(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1146 // ER_NO_SUCH_TABLE
+}
@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"strings"
+	"testing"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/h44z/lightmigrate"
+)
+
+func TestNewStatusReader(t *testing.T) {
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT version") {
+			return versionHandler([]sqldriver.Value{int64(3), int64(0), nil, nil, nil})(call)
+		}
+		return unusedLockHandler(call)
+	})
+
+	reader, err := NewStatusReader(db, "testdb")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	version, dirty, err := reader.GetVersion()
+	if err != nil || version != 3 || dirty {
+		t.Fatalf("unexpected version 3, got: %d %t %v", version, dirty, err)
+	}
+	status, err := reader.Status(context.Background())
+	if err != nil || status.Version != 3 {
+		t.Fatalf("unexpected status: %+v, %v", status, err)
+	}
+
+	for _, query := range srv.Queries() {
+		if !strings.HasPrefix(query, "SELECT") {
+			t.Fatalf("status reader must only read, got: %q", srv.Queries())
+		}
+	}
+}
+
+func TestNewStatusReader_MissingTable(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT version") || strings.HasPrefix(call.Query, "SELECT id") {
+			return fakeResponse{Err: &gomysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}}
+		}
+		return unusedLockHandler(call)
+	})
+
+	reader, err := NewStatusReader(db, "testdb", WithHistory(true))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version, dirty, err := reader.GetVersion(); err != nil || version != lightmigrate.NoMigrationVersion || dirty {
+		t.Fatalf("unexpected version, got: %d %t %v", version, dirty, err)
+	}
+	if status, err := reader.Status(context.Background()); err != nil || status.Version != lightmigrate.NoMigrationVersion {
+		t.Fatalf("unexpected status: %+v, %v", status, err)
+	}
+	if history, err := reader.AppliedVersions(context.Background()); err != nil || len(history) != 0 {
+		t.Fatalf("unexpected history: %+v, %v", history, err)
+	}
+}
+
+// unusedLockHandler reports that the migration lock is not held.
+func unusedLockHandler(call fakeCall) fakeResponse {
+	if strings.HasPrefix(call.Query, "SELECT IS_USED_LOCK") {
+		return fakeResponse{Columns: []string{"owner"}, Rows: [][]sqldriver.Value{{nil}}}
+	}
+	return fakeResponse{}
+}
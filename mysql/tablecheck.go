@@ -0,0 +1,198 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// TableExpectation describes the expected definition of the migrations and history table, see WithTableCheck.
+// Empty fields are not checked.
+type TableExpectation struct {
+	// Engine is the storage engine, e.g. "InnoDB".
+	Engine string `json:"engine,omitempty" yaml:"engine,omitempty"`
+	// Charset is the default character set of the table, e.g. "utf8mb4".
+	Charset string `json:"charset,omitempty" yaml:"charset,omitempty"`
+	// Collation is the default collation of the table, e.g. "utf8mb4_0900_ai_ci".
+	Collation string `json:"collation,omitempty" yaml:"collation,omitempty"`
+}
+
+// TableDefinitionWarning describes a difference between a driver table and its expected definition.
+type TableDefinitionWarning struct {
+	Table string
+	// Property is "engine", "charset", "collation" or "column <name>".
+	Property string
+	Expected string
+	Actual   string
+}
+
+// String formats the warning as key=value pairs.
+func (w TableDefinitionWarning) String() string {
+	return fmt.Sprintf("table=%s property=%q expected=%q actual=%q", w.Table, w.Property, w.Expected, w.Actual)
+}
+
+// TableDefinitionError is returned by NewDriver in strict mode, if a driver table differs from its expected
+// definition, see WithTableCheck.
+type TableDefinitionError struct {
+	Warnings []TableDefinitionWarning
+}
+
+// Error implements error interface.
+func (e *TableDefinitionError) Error() string {
+	details := make([]string, len(e.Warnings))
+	for i, warning := range e.Warnings {
+		details[i] = warning.String()
+	}
+	return "unexpected table definition: " + strings.Join(details, "; ")
+}
+
+// Is reports whether target is ErrUnexpectedTableDefinition.
+func (e *TableDefinitionError) Is(target error) bool {
+	return target == ErrUnexpectedTableDefinition
+}
+
+// WithTableCheck compares the existing migrations and history table with the expected engine, charset and
+// collation, and with the column definitions of the driver (without the extra version columns, see
+// WithExtraVersionColumns), when the driver is instantiated. This detects tables that were altered by hand or
+// created with different server defaults. Each difference is logged as TableDefinitionWarning, in strict mode (see
+// WithStrictMode) NewDriver fails with a *TableDefinitionError.
+func WithTableCheck(expect TableExpectation) DriverOption {
+	return func(d *driver) {
+		d.cfg.TableCheck = &expect
+	}
+}
+
+// checkTableDefinitions compares the driver tables with the expected definitions, see WithTableCheck.
+func (d *driver) checkTableDefinitions(ctx context.Context) error {
+	if d.cfg.TableCheck == nil {
+		return nil
+	}
+
+	type table struct {
+		Name    string
+		Columns []columnDefinition
+	}
+	// the extra columns (see WithExtraVersionColumns) are not checked, their type may be changed by the application
+	tables := []table{{d.migrationsTable(), versionTableColumns}}
+	if d.cfg.History {
+		tables = append(tables, table{d.historyTable(), historyTableColumns})
+	}
+	if d.usesLockTable() {
+		tables = append(tables, table{d.lockTable(), d.lockTableColumns()})
+	}
+
+	var warnings []TableDefinitionWarning
+	for _, table := range tables {
+		tableWarnings, err := d.checkTableDefinition(ctx, table.Name, table.Columns)
+		if err != nil {
+			return err
+		}
+		warnings = append(warnings, tableWarnings...)
+	}
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	for _, warning := range warnings {
+		d.logf("table check: %s", warning)
+	}
+	if d.cfg.Strict {
+		return &TableDefinitionError{Warnings: warnings}
+	}
+	return nil
+}
+
+// checkTableDefinition compares a single table with the expected options and columns.
+func (d *driver) checkTableDefinition(ctx context.Context, table string, columns []columnDefinition) (
+	[]TableDefinitionWarning, error) {
+	var warnings []TableDefinitionWarning
+	warn := func(property, expected, actual string) {
+		warnings = append(warnings, TableDefinitionWarning{Table: table, Property: property, Expected: expected,
+			Actual: actual})
+	}
+
+	var engine, collation sql.NullString
+	query := "SELECT ENGINE, TABLE_COLLATION FROM information_schema.tables WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	if err := d.client.QueryRowContext(ctx, query, d.cfg.DatabaseName, table).Scan(&engine, &collation); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to check table " + table, Query: []byte(query)}
+	}
+
+	expect := d.cfg.TableCheck
+	charset := collation.String
+	if idx := strings.IndexByte(charset, '_'); idx >= 0 {
+		charset = charset[:idx]
+	}
+	if expect.Engine != "" && !strings.EqualFold(expect.Engine, engine.String) {
+		warn("engine", expect.Engine, engine.String)
+	}
+	if expect.Charset != "" && !strings.EqualFold(expect.Charset, charset) {
+		warn("charset", expect.Charset, charset)
+	}
+	if expect.Collation != "" && !strings.EqualFold(expect.Collation, collation.String) {
+		warn("collation", expect.Collation, collation.String)
+	}
+
+	query = "SELECT COLUMN_NAME, DATA_TYPE, IS_NULLABLE FROM information_schema.columns " +
+		"WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+	rows, err := d.client.QueryContext(ctx, query, d.cfg.DatabaseName, table)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to check table " + table, Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	existing := make(map[string][2]string)
+	for rows.Next() {
+		var name, dataType, nullable string
+		if err := rows.Scan(&name, &dataType, &nullable); err != nil {
+			return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to check table " + table, Query: []byte(query)}
+		}
+		existing[strings.ToLower(name)] = [2]string{strings.ToLower(dataType), nullability(nullable == "YES")}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to check table " + table, Query: []byte(query)}
+	}
+
+	for _, column := range columns {
+		expectedType, expectedNull := columnType(column.Definition)
+		actual, ok := existing[strings.ToLower(column.Name)]
+		switch {
+		case !ok:
+			warn("column "+column.Name, expectedType, "missing")
+		case actual[0] != expectedType:
+			warn("column "+column.Name, expectedType, actual[0])
+		case actual[1] != expectedNull:
+			warn("column "+column.Name, expectedNull, actual[1])
+		}
+	}
+
+	return warnings, nil
+}
+
+// columnType returns the data type (as reported by information_schema.columns) and the nullability of a column
+// definition, e.g. "tinyint" and "not null" for "boolean not null".
+func columnType(definition string) (dataType, null string) {
+	fields := strings.Fields(strings.ToLower(definition))
+	dataType = fields[0]
+	if idx := strings.IndexByte(dataType, '('); idx >= 0 {
+		dataType = dataType[:idx]
+	}
+	switch dataType {
+	case "boolean", "bool":
+		dataType = "tinyint"
+	case "integer":
+		dataType = "int"
+	}
+
+	nullable := !strings.Contains(definition, "not null") && !strings.Contains(definition, "primary key")
+	return dataType, nullability(nullable)
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "null"
+	}
+	return "not null"
+}
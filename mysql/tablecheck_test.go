@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tableCheckHandler reports a MyISAM migrations table whose dirty column was changed to a nullable int.
+func tableCheckHandler(call fakeCall) fakeResponse {
+	switch {
+	case strings.HasPrefix(call.Query, "SELECT ENGINE, TABLE_COLLATION"):
+		return fakeResponse{Columns: []string{"ENGINE", "TABLE_COLLATION"},
+			Rows: [][]sqldriver.Value{{"MyISAM", "latin1_swedish_ci"}}}
+	case strings.HasPrefix(call.Query, "SELECT COLUMN_NAME"):
+		response := fakeResponse{Columns: []string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE"}}
+		for _, column := range versionTableColumns {
+			dataType, null := columnType(column.Definition)
+			nullable := "NO"
+			if null == "null" {
+				nullable = "YES"
+			}
+			if column.Name == "dirty" {
+				dataType, nullable = "int", "YES"
+			}
+			if column.Name != "attempts" {
+				response.Rows = append(response.Rows, []sqldriver.Value{column.Name, dataType, nullable})
+			}
+		}
+		return response
+	}
+	return defaultFakeHandler(call)
+}
+
+func Test_driver_checkTableDefinitions(t *testing.T) {
+	db, _ := newFakeDB(t, tableCheckHandler)
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0), cfg: &config{DatabaseName: "app",
+		MigrationsTable: "schema_migrations", TableCheck: &TableExpectation{Engine: "InnoDB", Charset: "utf8mb4"}}}
+
+	if err := d.checkTableDefinitions(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		`table check: table=schema_migrations property="engine" expected="InnoDB" actual="MyISAM"`,
+		`table check: table=schema_migrations property="charset" expected="utf8mb4" actual="latin1"`,
+		`table check: table=schema_migrations property="column dirty" expected="tinyint" actual="int"`,
+		`table check: table=schema_migrations property="column attempts" expected="int" actual="missing"`,
+	}
+	if got := strings.Split(strings.TrimSpace(logs.String()), "\n"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected warnings %q, got: %q", want, got)
+	}
+
+	d.cfg.Strict = true
+	err := d.checkTableDefinitions(context.Background())
+	var definitionErr *TableDefinitionError
+	if !errors.Is(err, ErrUnexpectedTableDefinition) || !errors.As(err, &definitionErr) ||
+		len(definitionErr.Warnings) != 4 {
+		t.Fatalf("expected error %v, got: %v", ErrUnexpectedTableDefinition, err)
+	}
+}
+
+func Test_driver_checkTableDefinitions_ExtraColumns(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT COLUMN_NAME") {
+			response := fakeResponse{Columns: []string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE"},
+				Rows: [][]sqldriver.Value{{"ticket", "int", "NO"}}}
+			for _, column := range versionTableColumns {
+				dataType, _ := columnType(column.Definition)
+				response.Rows = append(response.Rows, []sqldriver.Value{column.Name, dataType, "NO"})
+			}
+			return response
+		}
+		return tableCheckHandler(call)
+	})
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0), cfg: &config{DatabaseName: "app",
+		MigrationsTable: "schema_migrations", TableCheck: &TableExpectation{},
+		ExtraVersionColumns: map[string]func() interface{}{"ticket": func() interface{} { return nil }}}}
+
+	if err := d.checkTableDefinitions(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(logs.String(), "ticket") {
+		t.Fatalf("unexpected warning for extra column, got: %s", logs.String())
+	}
+}
+
+func Test_driver_checkTableDefinitions_Disabled(t *testing.T) {
+	db, srv := newFakeDB(t, tableCheckHandler)
+	d := &driver{client: db, cfg: &config{DatabaseName: "app", MigrationsTable: "schema_migrations"}}
+
+	if err := d.checkTableDefinitions(context.Background()); err != nil || len(srv.Queries()) != 0 {
+		t.Fatalf("unexpected check: %v, %q", err, srv.Queries())
+	}
+}
+
+func Test_columnType(t *testing.T) {
+	tests := map[string][2]string{
+		"bigint not null primary key": {"bigint", "not null"},
+		"boolean null":                {"tinyint", "null"},
+		"varchar(255) null":           {"varchar", "null"},
+	}
+	for definition, want := range tests {
+		if dataType, null := columnType(definition); dataType != want[0] || null != want[1] {
+			t.Fatalf("unexpected type %v for %s, got: %s %s", want, definition, dataType, null)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package mysql
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// maxIdentifierLength is the maximum length of table names in MySQL.
+const maxIdentifierLength = 64
+
+// ConfigError is returned by NewDriver if the options contain invalid or conflicting settings.
+// All problems are reported at once.
+type ConfigError struct {
+	Problems []error
+}
+
+// Error implements error interface.
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		msgs[i] = problem.Error()
+	}
+	return "invalid driver configuration: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is ErrInvalidConfig or matches one of the problems.
+func (e *ConfigError) Is(target error) bool {
+	if target == ErrInvalidConfig {
+		return true
+	}
+	for _, problem := range e.Problems {
+		if errors.Is(problem, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// engineNamePattern matches the names of storage engines, e.g. InnoDB or NDBCLUSTER.
+var engineNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+
+// validate checks the configuration of the driver after all options were applied.
+func (d *driver) validate() error {
+	var problems []error
+	check := func(problem string, invalid bool) {
+		if invalid {
+			problems = append(problems, errors.New(problem))
+		}
+	}
+
+	cfg := d.cfg
+	check("migrations table name must not be empty", cfg.MigrationsTable == "")
+	check("table names must not contain backticks", strings.Contains(cfg.MigrationsTable+cfg.TablePrefix+
+		cfg.NotificationTable+cfg.LockTable, "`"))
+	check("lock table name exceeds 64 characters", d.usesLockTable() && len(d.lockTable()) > maxIdentifierLength)
+	check("invalid session charset", cfg.SessionCharset != "" && !charsetNamePattern.MatchString(cfg.SessionCharset))
+	check("invalid lock table engine", cfg.LockTableEngine != "" && !engineNamePattern.MatchString(cfg.LockTableEngine))
+	check("notification table name exceeds 64 characters", cfg.NotificationTable != "" &&
+		len(d.notificationTable()) > maxIdentifierLength)
+	check("migrations table name exceeds 64 characters", len(d.migrationsTable()) > maxIdentifierLength)
+	check("history table name exceeds 64 characters", cfg.History && len(d.historyTable()) > maxIdentifierLength)
+	check("quarantine table name exceeds 64 characters", cfg.Quarantine && len(d.quarantineTable()) > maxIdentifierLength)
+	check("object table name exceeds 64 characters", cfg.ObjectTracking && len(d.objectTable()) > maxIdentifierLength)
+	check("source table name exceeds 64 characters", d.guardedSource != nil &&
+		len(d.sourceTable()) > maxIdentifierLength)
+	if err := validateNamespace(cfg.Namespace); err != nil {
+		problems = append(problems, err)
+	}
+	for _, problem := range validateExtraColumns(cfg.ExtraVersionColumns) {
+		check(problem, true)
+	}
+
+	check("query timeout must not be negative", cfg.QueryTimeout < 0)
+	check("statement timeout must not be negative", cfg.StatementTimeout < 0)
+	check("run deadline must not be negative", cfg.RunDeadline < 0)
+	check("maintenance wait must not be negative", cfg.MaintenanceWait < 0)
+	check("statement watchdog threshold must not be negative", cfg.StatementWatchdog < 0)
+	check("progress interval must not be negative", cfg.ProgressInterval < 0)
+	check("lock wait timeouts must not be negative", cfg.InnoDBLockWaitTimeout < 0 || cfg.MetadataLockWaitTimeout < 0)
+	check("lock TTL must not be negative", cfg.LockTTL < 0)
+	check("unknown lock strategy", cfg.LockStrategy < LockStrategyAuto || cfg.LockStrategy > LockStrategyTable)
+	check("primary guard requires the table lock strategy", cfg.PrimaryGuard &&
+		(!cfg.Locking || cfg.LockStrategy == LockStrategyAdvisory))
+	check("unknown lock policy", cfg.LockPolicy < LockPolicyWait || cfg.LockPolicy > LockPolicySkip)
+	check("unknown version table policy", cfg.VersionTablePolicy < VersionTableFail ||
+		cfg.VersionTablePolicy > VersionTableRepair)
+	check("skip lock policy requires a target version", cfg.LockPolicy == LockPolicySkip && cfg.LockTargetVersion == 0)
+	check("max parallel statements must be at least 1", cfg.MaxParallelStatements < 1)
+	check("max affected rows must not be negative", cfg.MaxAffectedRows < 0)
+	check("max in-memory migration size must not be negative", cfg.MaxInMemoryMigrationSize < 0)
+	check("dirty retry attempts must not be negative", cfg.DirtyRetry.MaxAttempts < 0)
+	check("reconnect attempts and backoff must not be negative", cfg.Reconnect.MaxAttempts < 0 || cfg.Reconnect.Backoff < 0)
+	check("applied by identifier requires the migration history", cfg.AppliedBy != "" && !cfg.History)
+	check("full rollback token must belong to the database, see RollbackToken", cfg.FullRollbackToken != "" &&
+		!strings.HasPrefix(cfg.FullRollbackToken, cfg.DatabaseName+":"))
+	check("unknown checksum algorithm", cfg.ChecksumAlgorithm < ChecksumCRC32 || cfg.ChecksumAlgorithm > ChecksumFlyway)
+
+	// a custom version store replaces the migrations table
+	check("dirty retry requires the default version store", d.store != nil && cfg.DirtyRetry.MaxAttempts > 1)
+	check("atomic DDL recovery requires the default version store", d.store != nil && cfg.AtomicDDLRecovery)
+	check("version upserts require the default version store", d.store != nil && cfg.VersionUpsert)
+	check("migration history requires the default version store", d.store != nil && cfg.History)
+	check("quarantine requires the default version store", d.store != nil && cfg.Quarantine)
+	check("object tracking requires the default version store", d.store != nil && cfg.ObjectTracking)
+	check("source guard requires the default version store", d.store != nil && d.guardedSource != nil)
+
+	// directives are only evaluated if the driver splits the migrations
+	check("include filesystem requires statement splitting", d.includeFS != nil && !cfg.SplitStatements)
+	check("infile sources require statement splitting", (d.infileFS != nil || len(d.infileReaders) > 0) &&
+		!cfg.SplitStatements)
+	check("dirty retry requires statement splitting", cfg.DirtyRetry.MaxAttempts > 1 && !cfg.SplitStatements)
+	check("reconnect requires statement splitting", cfg.Reconnect.MaxAttempts > 0 && !cfg.SplitStatements)
+	check("atomic DDL recovery requires statement splitting", cfg.AtomicDDLRecovery && !cfg.SplitStatements)
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewDriver_InvalidConfig(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+
+	_, err := NewDriver(db, "testdb", WithMigrationTable(""), WithStatementTimeout(-time.Second),
+		WithMaxParallelStatements(0), WithStatementSplitting(false), WithDirtyRetry(DirtyRetryPolicy{MaxAttempts: 3}))
+
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfgErr.Problems) != 4 {
+		t.Fatalf("unexpected problems: %v", cfgErr.Problems)
+	}
+	if !strings.Contains(err.Error(), "statement timeout must not be negative") {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+	if len(srv.Queries()) != 0 {
+		t.Fatalf("unexpected queries for invalid configuration: %q", srv.Queries())
+	}
+}
+
+func Test_driver_validate(t *testing.T) {
+	d := &driver{cfg: &config{MigrationsTable: DefaultMigrationsTable, SplitStatements: true, MaxParallelStatements: 1}}
+	if err := d.validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d.cfg.TablePrefix = strings.Repeat("x", 60)
+	d.cfg.LockStrategy = LockStrategy(7)
+	err := d.validate()
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || len(cfgErr.Problems) != 2 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
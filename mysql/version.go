@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// rowQueryer is implemented by sql.DB, sql.Conn and sql.Tx.
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// rowsQueryer is implemented by sql.DB, sql.Conn and sql.Tx.
+type rowsQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// versionRow is the content of the migration table.
+type versionRow struct {
+	Version uint64
+	Dirty   bool
+	// PreviousVersion is the last clean version before the dirty version was written.
+	PreviousVersion sql.NullInt64
+	// Idempotent is true, if the failed migration may be re-applied. Only set for dirty versions.
+	Idempotent sql.NullBool
+	// Attempts is the number of attempts of the dirty migration.
+	Attempts sql.NullInt64
+}
+
+// readVersionRow reads the current row of the migration table. If the table is empty, nil is returned. If the
+// table contains more than one row, a *CorruptVersionTableError is returned.
+func (d *driver) readVersionRow(ctx context.Context, q rowsQueryer, forUpdate bool) (*versionRow, error) {
+	rows, err := d.readVersionRows(ctx, q, forUpdate)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(rows) == 0:
+		return nil, nil
+	case len(rows) > 1:
+		return nil, newCorruptVersionTableError(d.migrationsTable(), rows)
+	default:
+		return rows[0], nil
+	}
+}
+
+// readVersionRows reads all rows of the migration table, which should contain a single row.
+func (d *driver) readVersionRows(ctx context.Context, q rowsQueryer, forUpdate bool) ([]*versionRow, error) {
+	query := "SELECT version, dirty, previous_version, idempotent, attempts FROM `" + d.migrationsTable() + "`"
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to select version", Query: []byte(query)}
+	}
+	defer rows.Close()
+
+	var result []*versionRow
+	for rows.Next() {
+		row := &versionRow{}
+		if err := rows.Scan(&row.Version, &row.Dirty, &row.PreviousVersion, &row.Idempotent,
+			&row.Attempts); err != nil {
+			return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to select version", Query: []byte(query)}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to select version", Query: []byte(query)}
+	}
+	return result, nil
+}
+
+// shouldRetry checks if the dirty version row should be retried according to the configured DirtyRetryPolicy.
+func (d *driver) shouldRetry(row *versionRow) bool {
+	return d.cfg.DirtyRetry.MaxAttempts > 1 && row.Idempotent.Valid && row.Idempotent.Bool &&
+		row.PreviousVersion.Valid && row.Attempts.Int64 < int64(d.cfg.DirtyRetry.MaxAttempts)
+}
+
+// nextAttempt calculates the values of the previous_version and attempts columns for a new version row.
+// Both values are only set for dirty versions. Re-applying the same dirty version increments the attempts.
+func nextAttempt(prior *versionRow, version uint64, dirty bool) (previousVersion, attempts interface{}) {
+	switch {
+	case !dirty:
+		return nil, nil
+	case prior == nil:
+		return int64(lightmigrate.NoMigrationVersion), 1
+	case prior.Dirty && prior.Version == version:
+		return nullableInt(prior.PreviousVersion), prior.Attempts.Int64 + 1
+	case prior.Dirty:
+		return nullableInt(prior.PreviousVersion), 1
+	default:
+		return int64(prior.Version), 1
+	}
+}
+
+func nullableInt(n sql.NullInt64) interface{} {
+	if !n.Valid {
+		return nil
+	}
+	return n.Int64
+}
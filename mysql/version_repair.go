@@ -0,0 +1,184 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// VersionTablePolicy selects the behavior if the migration table contains more than one row.
+type VersionTablePolicy int
+
+const (
+	// VersionTableFail fails with a *CorruptVersionTableError, see ErrCorruptVersionTable.
+	VersionTableFail VersionTablePolicy = iota
+	// VersionTableRepair reconciles the migration table to the highest clean version when the version is read by a
+	// migration run, see Driver.Repair. Read-only callers (e.g. Status, Healthy or the run report) still fail with a
+	// *CorruptVersionTableError.
+	VersionTableRepair
+)
+
+// String returns the name of the version table policy.
+func (p VersionTablePolicy) String() string {
+	if p == VersionTableRepair {
+		return "repair"
+	}
+	return "fail"
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (p VersionTablePolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (p *VersionTablePolicy) UnmarshalText(text []byte) error {
+	for _, policy := range []VersionTablePolicy{VersionTableFail, VersionTableRepair} {
+		if strings.EqualFold(string(text), policy.String()) {
+			*p = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown version table policy %q", text)
+}
+
+// WithVersionTablePolicy sets the behavior if the migration table contains more than one row, e.g. after a manual
+// insert or a restore of a partial backup. By default, reading the version fails with a *CorruptVersionTableError.
+func WithVersionTablePolicy(policy VersionTablePolicy) DriverOption {
+	return func(d *driver) {
+		d.cfg.VersionTablePolicy = policy
+	}
+}
+
+// VersionTableRow is a row of the migration table, see CorruptVersionTableError.
+type VersionTableRow struct {
+	Version uint64
+	Dirty   bool
+}
+
+// CorruptVersionTableError is returned if the migration table contains more than one row. Either the rows are
+// removed manually, or the table is reconciled with Driver.Repair.
+type CorruptVersionTableError struct {
+	Table string
+	Rows  []VersionTableRow
+}
+
+// newCorruptVersionTableError creates the error for the rows of the migration table.
+func newCorruptVersionTableError(table string, rows []*versionRow) *CorruptVersionTableError {
+	err := &CorruptVersionTableError{Table: table, Rows: make([]VersionTableRow, len(rows))}
+	for i, row := range rows {
+		err.Rows[i] = VersionTableRow{Version: row.Version, Dirty: row.Dirty}
+	}
+	return err
+}
+
+// Error implements error interface.
+func (e *CorruptVersionTableError) Error() string {
+	rows := make([]string, len(e.Rows))
+	for i, row := range e.Rows {
+		rows[i] = fmt.Sprintf("%d", row.Version)
+		if row.Dirty {
+			rows[i] += " (dirty)"
+		}
+	}
+	return fmt.Sprintf("migration table %s contains %d rows: %s", e.Table, len(e.Rows), strings.Join(rows, ", "))
+}
+
+// Is reports whether target is ErrCorruptVersionTable.
+func (e *CorruptVersionTableError) Is(target error) bool {
+	return target == ErrCorruptVersionTable
+}
+
+// highestCleanVersion returns the highest version of the rows that is not dirty.
+func highestCleanVersion(rows []*versionRow) (uint64, bool) {
+	var version uint64
+	found := false
+	for _, row := range rows {
+		if !row.Dirty && (!found || row.Version > version) {
+			version, found = row.Version, true
+		}
+	}
+	return version, found
+}
+
+// Repair reconciles a migration table that contains more than one row to the highest clean version of the rows.
+// If the table contains a single row (or none), nothing is changed. If all rows are dirty, the table can not be
+// repaired and the *CorruptVersionTableError is returned. The migration lock is acquired, unless it is already held
+// by this driver.
+func (d *driver) Repair(ctx context.Context) (err error) {
+	if d.store != nil {
+		return nil // custom stores have no migrations table
+	}
+
+	unlock, err := d.lockOperation()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if e := unlock(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	_, err = d.repairVersionTable(ctx)
+	return err
+}
+
+// repairVersionTable replaces the rows of the migration table by the highest clean version within a transaction.
+// The remaining row is returned, nil if the table is empty.
+func (d *driver) repairVersionTable(ctx context.Context) (*versionRow, error) {
+	tx, err := d.client.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "transaction start failed"}
+	}
+	defer func() {
+		_ = tx.Rollback() // no-op after a successful commit
+	}()
+
+	rows, err := d.readVersionRows(ctx, tx, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) <= 1 {
+		if len(rows) == 0 {
+			return nil, tx.Commit()
+		}
+		return rows[0], tx.Commit()
+	}
+
+	version, ok := highestCleanVersion(rows)
+	if !ok {
+		return nil, newCorruptVersionTableError(d.migrationsTable(), rows)
+	}
+
+	query := "DELETE FROM `" + d.migrationsTable() + "`"
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to clean migration table", Query: []byte(query)}
+	}
+	query = "INSERT INTO `" + d.migrationsTable() + "` (version, dirty) VALUES (?, ?)"
+	if _, err := tx.ExecContext(ctx, query, version, false); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to update migration table", Query: []byte(query)}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "transaction commit failed"}
+	}
+
+	d.logf("repaired migration table: %v, kept clean version %d", newCorruptVersionTableError(d.migrationsTable(),
+		rows), version)
+	return &versionRow{Version: version}, nil
+}
+
+// readVersionRowOrRepair reads the current row of the migration table and repairs the table if it contains more
+// than one row and the repair policy is configured, see WithVersionTablePolicy. Only used by GetVersion, which
+// lightmigrate calls within the migration lock.
+func (d *driver) readVersionRowOrRepair(ctx context.Context) (*versionRow, error) {
+	row, err := d.readVersionRow(ctx, d.client, false)
+	if errors.Is(err, ErrCorruptVersionTable) && d.cfg.VersionTablePolicy == VersionTableRepair {
+		return d.repairVersionTable(ctx)
+	}
+	return row, err
+}
@@ -0,0 +1,131 @@
+package mysql
+
+import (
+	"context"
+	sqldriver "database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// corruptVersionHandler returns the rows for the version queries of the migration table.
+func corruptVersionHandler(rows ...[]sqldriver.Value) fakeHandler {
+	return func(call fakeCall) fakeResponse {
+		if strings.HasPrefix(call.Query, "SELECT version, dirty") {
+			return fakeResponse{Columns: []string{"version", "dirty", "previous_version", "idempotent", "attempts"},
+				Rows: rows}
+		}
+		return defaultFakeHandler(call)
+	}
+}
+
+func Test_driver_GetVersion_CorruptTable(t *testing.T) {
+	db, _ := newFakeDB(t, corruptVersionHandler(
+		[]sqldriver.Value{int64(4), int64(0), nil, nil, nil},
+		[]sqldriver.Value{int64(5), int64(1), int64(4), nil, int64(1)},
+	))
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations"}}
+
+	_, _, err := d.GetVersion()
+	if !errors.Is(err, ErrCorruptVersionTable) {
+		t.Fatalf("expected error %v, got: %v", ErrCorruptVersionTable, err)
+	}
+	var corruptErr *CorruptVersionTableError
+	if !errors.As(err, &corruptErr) || !reflect.DeepEqual(corruptErr.Rows, []VersionTableRow{{4, false}, {5, true}}) {
+		t.Fatalf("unexpected rows, got: %v", err)
+	}
+	if want := "migration table migrations contains 2 rows: 4, 5 (dirty)"; err.Error() != want {
+		t.Fatalf("unexpected message %q, got: %q", want, err.Error())
+	}
+}
+
+func Test_driver_GetVersion_RepairPolicy(t *testing.T) {
+	db, srv := newFakeDB(t, corruptVersionHandler(
+		[]sqldriver.Value{int64(3), int64(0), nil, nil, nil},
+		[]sqldriver.Value{int64(4), int64(0), nil, nil, nil},
+		[]sqldriver.Value{int64(5), int64(1), int64(4), nil, int64(1)},
+	))
+	d := &driver{client: db, logger: log.New(io.Discard, "", 0),
+		cfg: &config{MigrationsTable: "migrations", VersionTablePolicy: VersionTableRepair}}
+
+	version, dirty, err := d.GetVersion()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version != 4 || dirty {
+		t.Fatalf("unexpected version 4 (clean), got: %d (dirty: %t)", version, dirty)
+	}
+
+	var repaired bool
+	for _, call := range srv.Calls() {
+		if strings.HasPrefix(call.Query, "INSERT INTO `migrations`") {
+			repaired = call.Args[0] == int64(4) && call.Args[1] == false
+		}
+	}
+	if !repaired {
+		t.Fatalf("migration table was not repaired: %q", srv.Queries())
+	}
+}
+
+func Test_driver_Repair(t *testing.T) {
+	tests := []struct {
+		name     string
+		rows     [][]sqldriver.Value
+		wantErr  error
+		wantRepl bool
+	}{
+		{"single row", [][]sqldriver.Value{{int64(4), int64(0), nil, nil, nil}}, nil, false},
+		{"corrupt", [][]sqldriver.Value{{int64(2), int64(0), nil, nil, nil}, {int64(1), int64(0), nil, nil, nil}},
+			nil, true},
+		{"only dirty rows", [][]sqldriver.Value{{int64(2), int64(1), nil, nil, nil}, {int64(3), int64(1), nil, nil, nil}},
+			ErrCorruptVersionTable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, srv := newFakeDB(t, corruptVersionHandler(tt.rows...))
+			d := &driver{client: db, logger: log.New(io.Discard, "", 0),
+				cfg: &config{DatabaseName: "db", MigrationsTable: "migrations", Locking: true}}
+
+			err := d.Repair(context.Background())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got: %v", tt.wantErr, err)
+			}
+
+			var replaced bool
+			for _, query := range srv.Queries() {
+				replaced = replaced || query == "DELETE FROM `migrations`"
+			}
+			if replaced != tt.wantRepl {
+				t.Fatalf("unexpected repair %t, got: %q", tt.wantRepl, srv.Queries())
+			}
+			queries := srv.Queries()
+			if !strings.HasPrefix(queries[0], "SELECT GET_LOCK") || queries[len(queries)-1] != "SELECT RELEASE_LOCK(?)" {
+				t.Fatalf("expected repair within the migration lock, got: %q", queries)
+			}
+		})
+	}
+}
+
+func Test_driver_Status_CorruptTableNotRepaired(t *testing.T) {
+	db, srv := newFakeDB(t, corruptVersionHandler(
+		[]sqldriver.Value{int64(3), int64(0), nil, nil, nil},
+		[]sqldriver.Value{int64(4), int64(0), nil, nil, nil},
+	))
+	d := &driver{client: db, logger: log.New(io.Discard, "", 0),
+		cfg: &config{MigrationsTable: "migrations", VersionTablePolicy: VersionTableRepair}}
+
+	if _, err := d.Status(context.Background()); !errors.Is(err, ErrCorruptVersionTable) {
+		t.Fatalf("expected error %v, got: %v", ErrCorruptVersionTable, err)
+	}
+	if _, _, err := d.versionStore().Get(context.Background()); !errors.Is(err, ErrCorruptVersionTable) {
+		t.Fatalf("expected error %v, got: %v", ErrCorruptVersionTable, err)
+	}
+	for _, query := range srv.Queries() {
+		if !strings.HasPrefix(query, "SELECT") {
+			t.Fatalf("unexpected query of a read-only caller, got: %s", query)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// VersionStore persists the migration version. By default, the version is stored in the migrations table of the
+// migrated database. A custom store (see WithVersionStore) can keep the version elsewhere, e.g. in a separate
+// cluster or a configuration service, while the driver still executes and locks the migrations.
+type VersionStore interface {
+	// Get returns the current version and the dirty flag. If no version was stored yet,
+	// lightmigrate.NoMigrationVersion is returned.
+	Get(ctx context.Context) (version uint64, dirty bool, err error)
+	// Set stores the version and the dirty flag, replacing the current version.
+	Set(ctx context.Context, version uint64, dirty bool) error
+	// History returns the recorded versions, oldest entries first. Stores without a history return ErrNoHistory.
+	History(ctx context.Context) ([]AppliedVersion, error)
+}
+
+// WithVersionStore replaces the migrations table by a custom version store. The features that rely on the
+// migrations table (dirty retry, atomic DDL recovery, failure diagnostics, the history, object and source table)
+// are not available with a custom store. Refused heavy migrations (see WithMaintenanceWindow) leave the version dirty.
+func WithVersionStore(store VersionStore) DriverOption {
+	return func(d *driver) {
+		d.store = store
+	}
+}
+
+// WithVersionUpsert updates the migrations table with a single UPDATE statement instead of replacing the version row
+// within a transaction (DELETE and INSERT). The attempts and previous version of dirty versions are computed by the
+// server. The transaction is still used for the first version and if the row did not change.
+func WithVersionUpsert(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.VersionUpsert = enabled
+	}
+}
+
+// versionStore returns the configured version store, or the migrations table of the driver.
+func (d *driver) versionStore() VersionStore {
+	if d.store != nil {
+		return d.store
+	}
+	return tableVersionStore{d: d}
+}
+
+// currentVersion reads the current version from the version store. If no version was stored yet, nil is returned.
+// Only the migrations table provides the retry and recovery details of dirty versions.
+func (d *driver) currentVersion(ctx context.Context) (*versionRow, error) {
+	if d.store == nil {
+		return d.readVersionRow(ctx, d.client, false)
+	}
+
+	version, dirty, err := d.store.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version == lightmigrate.NoMigrationVersion && !dirty {
+		return nil, nil
+	}
+	return &versionRow{Version: version, Dirty: dirty}, nil
+}
+
+// tableVersionStore is the default version store, it keeps the version in the migrations table.
+type tableVersionStore struct {
+	d *driver
+	// repair enables the repair of a migration table with more than one row, see WithVersionTablePolicy.
+	repair bool
+}
+
+// Get implements the VersionStore interface. Dirty versions that should be retried (see WithDirtyRetry) are
+// reported as the previous clean version.
+func (s tableVersionStore) Get(ctx context.Context) (version uint64, dirty bool, err error) {
+	d := s.d
+	var row *versionRow
+	if s.repair {
+		row, err = d.readVersionRowOrRepair(ctx)
+	} else {
+		row, err = d.readVersionRow(ctx, d.client, false)
+	}
+	switch {
+	case err != nil:
+		return 0, false, err
+	case row == nil:
+		return lightmigrate.NoMigrationVersion, false, nil
+	case row.Dirty && d.shouldRetry(row):
+		d.logf("retrying dirty migration %d (attempt %d of %d)", row.Version, row.Attempts.Int64+1,
+			d.cfg.DirtyRetry.MaxAttempts)
+		return uint64(row.PreviousVersion.Int64), false, nil
+	default:
+		return row.Version, row.Dirty, nil
+	}
+}
+
+// Set implements the VersionStore interface. The version row is replaced within a transaction, or updated in place
+// (see WithVersionUpsert).
+func (s tableVersionStore) Set(ctx context.Context, version uint64, dirty bool) error {
+	d := s.d
+	if d.cfg.VersionUpsert {
+		if updated, err := s.update(ctx, version, dirty); err != nil || updated {
+			return err
+		}
+	}
+
+	tx, err := d.client.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "transaction start failed"}
+	}
+
+	prior, err := d.readVersionRow(ctx, tx, true)
+	if err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			origMsg := fmt.Sprintf("failed rollback for previous error: %v", err)
+			return &lightmigrate.DriverError{OrigErr: err, Msg: origMsg}
+		}
+		return err
+	}
+
+	// Delete all entries in the migrations table.
+	query := "DELETE FROM `" + d.migrationsTable() + "`"
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			origMsg := fmt.Sprintf("failed rollback for previous error: %v", err)
+			return &lightmigrate.DriverError{OrigErr: err, Msg: origMsg, Query: []byte(query)}
+		}
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to clean migration table", Query: []byte(query)}
+	}
+
+	previousVersion, attempts := nextAttempt(prior, version, dirty)
+	extraColumns, extraPlaceholders, extraValues := d.extraColumnValues()
+	query = "INSERT INTO `" + d.migrationsTable() + "` (version, dirty, previous_version, attempts" + extraColumns +
+		") VALUES (?, ?, ?, ?" + extraPlaceholders + ")"
+	args := append([]interface{}{version, dirty, previousVersion, attempts}, extraValues...)
+	if _, err := d.execPrepared(ctx, tx, query, args...); err != nil {
+		if errRollback := tx.Rollback(); errRollback != nil {
+			origMsg := fmt.Sprintf("failed rollback for previous error: %v", err)
+			return &lightmigrate.DriverError{OrigErr: err, Msg: origMsg, Query: []byte(query)}
+		}
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "failed to update migration table", Query: []byte(query)}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return &lightmigrate.DriverError{OrigErr: err, Msg: "transaction commit failed"}
+	}
+	return nil
+}
+
+// update overwrites the version row with a single statement, the result of nextAttempt is computed from the prior
+// row by the server. MySQL evaluates the assignments from left to right, so previous_version and attempts are
+// assigned before the version and the dirty flag. It reports false if no row was changed, either because the
+// table is empty or the row is unchanged.
+func (s tableVersionStore) update(ctx context.Context, version uint64, dirty bool) (bool, error) {
+	d := s.d
+	var extraAssignments string
+	for _, name := range d.extraColumnNames() {
+		extraAssignments += ", `" + name + "` = ?"
+	}
+	_, _, extraValues := d.extraColumnValues()
+
+	query := "UPDATE `" + d.migrationsTable() + "` SET " +
+		"previous_version = CASE WHEN NOT ? THEN NULL WHEN dirty THEN previous_version ELSE version END, " +
+		"attempts = CASE WHEN NOT ? THEN NULL WHEN dirty AND version = ? THEN COALESCE(attempts, 0) + 1 ELSE 1 END, " +
+		"version = ?, dirty = ?, error_statement = NULL, error_code = NULL, error_message = NULL, idempotent = NULL" +
+		extraAssignments
+	args := append([]interface{}{dirty, dirty, version, version, dirty}, extraValues...)
+	result, err := d.execPrepared(ctx, nil, query, args...)
+	if err != nil {
+		return false, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to update migration table", Query: []byte(query)}
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, &lightmigrate.DriverError{OrigErr: err, Msg: "failed to update migration table", Query: []byte(query)}
+	}
+	return affected > 0, nil
+}
+
+// History implements the VersionStore interface, it reads the history table (see WithHistory).
+func (s tableVersionStore) History(ctx context.Context) ([]AppliedVersion, error) {
+	return s.d.readHistory(ctx)
+}
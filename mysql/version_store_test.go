@@ -0,0 +1,150 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// memoryVersionStore keeps the version in memory.
+type memoryVersionStore struct {
+	version uint64
+	dirty   bool
+	sets    int
+	history []AppliedVersion
+}
+
+func (s *memoryVersionStore) Get(_ context.Context) (uint64, bool, error) {
+	return s.version, s.dirty, nil
+}
+
+func (s *memoryVersionStore) Set(_ context.Context, version uint64, dirty bool) error {
+	s.version, s.dirty = version, dirty
+	s.sets++
+	return nil
+}
+
+func (s *memoryVersionStore) History(_ context.Context) ([]AppliedVersion, error) {
+	if s.history == nil {
+		return nil, ErrNoHistory
+	}
+	return s.history, nil
+}
+
+func TestNewDriver_VersionStore(t *testing.T) {
+	db, srv := newFakeDB(t, nil)
+	store := &memoryVersionStore{}
+
+	d, err := NewDriver(db, "testdb", WithVersionStore(store))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := d.SetVersion(4, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if version, dirty, err := d.GetVersion(); err != nil || version != 4 || !dirty {
+		t.Fatalf("unexpected version 4 (dirty), got: %d %t %v", version, dirty, err)
+	}
+	if err := d.Healthy(context.Background()); !errors.Is(err, lightmigrate.ErrDatabaseDirty) {
+		t.Fatalf("expected error %v, got: %v", lightmigrate.ErrDatabaseDirty, err)
+	}
+
+	for _, query := range srv.Queries() {
+		if strings.Contains(query, DefaultMigrationsTable) {
+			t.Fatalf("unexpected query of the migrations table: %s", query)
+		}
+	}
+	if store.sets != 1 {
+		t.Fatalf("unexpected number of stored versions: %d", store.sets)
+	}
+}
+
+func TestNewDriver_VersionStoreConflicts(t *testing.T) {
+	db, _ := newFakeDB(t, nil)
+	source, err := lightmigrate.NewFsSource(fstest.MapFS{
+		"migrations/1_init.up.sql": {Data: []byte("CREATE TABLE a (id int);")},
+	}, "migrations")
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	_, err = NewDriver(db, "testdb", WithVersionStore(&memoryVersionStore{}), WithHistory(true),
+		WithDirtyRetry(DirtyRetryPolicy{MaxAttempts: 3}), WithObjectTracking(true), WithSourceGuard(source))
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) || len(cfgErr.Problems) != 4 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_tableVersionStore_Set_PreparedStatement(t *testing.T) {
+	db, srv := newFakeDB(t, versionHandler(nil))
+	d := &driver{client: db, cfg: &config{MigrationsTable: "migrations"}, statements: newStatementCache()}
+
+	for version := uint64(1); version <= 3; version++ {
+		if err := d.SetVersion(version, false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	inserts := 0
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "INSERT INTO `migrations`") {
+			inserts++
+		}
+	}
+	if inserts != 3 || len(d.statements.stmts) != 1 {
+		t.Fatalf("unexpected statements %d, got: %d inserts, %q", len(d.statements.stmts), inserts, srv.Queries())
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(d.statements.stmts) != 0 {
+		t.Fatalf("prepared statements were not closed")
+	}
+}
+
+func Test_tableVersionStore_Set_Upsert(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		want     []string
+	}{
+		{"updated", 1, []string{"UPDATE"}},
+		{"empty table", 0, []string{"UPDATE", "BEGIN", "SELECT", "DELETE", "INSERT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := versionHandler(nil)
+			db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+				if strings.HasPrefix(call.Query, "UPDATE") {
+					return fakeResponse{RowsAffected: tt.affected}
+				}
+				return handler(call)
+			})
+			d := &driver{client: db, cfg: &config{MigrationsTable: "migrations", VersionUpsert: true},
+				statements: newStatementCache()}
+
+			if err := d.SetVersion(5, true); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			queries := srv.Queries()
+			if len(queries) < len(tt.want) || (tt.affected > 0 && len(queries) != len(tt.want)) {
+				t.Fatalf("unexpected queries %q, got: %q", tt.want, queries)
+			}
+			for i, prefix := range tt.want {
+				if !strings.HasPrefix(queries[i], prefix) {
+					t.Fatalf("unexpected queries %q, got: %q", tt.want, queries)
+				}
+			}
+			if call := srv.Calls()[0]; len(call.Args) != 5 || call.Args[2] != int64(5) || call.Args[4] != true {
+				t.Fatalf("unexpected arguments, got: %v", call.Args)
+			}
+		})
+	}
+}
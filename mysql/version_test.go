@@ -0,0 +1,40 @@
+package mysql
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func Test_nextAttempt(t *testing.T) {
+	tests := []struct {
+		name         string
+		prior        *versionRow
+		version      uint64
+		dirty        bool
+		wantPrevious interface{}
+		wantAttempts interface{}
+	}{
+		{"clean", &versionRow{Version: 1}, 2, false, nil, nil},
+		{"first migration", nil, 1, true, int64(0), 1},
+		{"next migration", &versionRow{Version: 1}, 2, true, int64(1), 1},
+		{
+			"retry",
+			&versionRow{Version: 2, Dirty: true, PreviousVersion: sql.NullInt64{Int64: 1, Valid: true}, Attempts: sql.NullInt64{Int64: 1, Valid: true}},
+			2, true, int64(1), int64(2),
+		},
+		{
+			"other dirty version",
+			&versionRow{Version: 3, Dirty: true, PreviousVersion: sql.NullInt64{Int64: 1, Valid: true}},
+			2, true, int64(1), 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous, attempts := nextAttempt(tt.prior, tt.version, tt.dirty)
+			if previous != tt.wantPrevious || attempts != tt.wantAttempts {
+				t.Fatalf("unexpected previous %v / attempts %v, got: %v / %v", tt.wantPrevious, tt.wantAttempts,
+					previous, attempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/h44z/lightmigrate"
+)
+
+// warmupAttempts limits the number of stale pooled connections that are discarded while warming up a connection.
+const warmupAttempts = 3
+
+// WithConnectionWarmup verifies the connections of the pool before a migration run starts. Stale connections are
+// discarded by pinging them, and the session setup statements are executed once, so that a broken connection
+// does not fail the first statement of a migration.
+func WithConnectionWarmup(enabled bool) DriverOption {
+	return func(d *driver) {
+		d.cfg.ConnectionWarmup = enabled
+	}
+}
+
+// warmUp pings the connections that are used for locking and for the execution of the migrations.
+func (d *driver) warmUp() error {
+	if !d.cfg.ConnectionWarmup {
+		return nil
+	}
+
+	ctx, cancel := d.internalContext()
+	defer cancel()
+
+	// the lock connection is held while the execution connection is checked, so that two different
+	// connections are verified, unless the pool is limited to a single connection
+	if d.client.Stats().MaxOpenConnections != 1 {
+		lockConn, err := d.pingedConn(ctx)
+		if err != nil {
+			return err
+		}
+		defer lockConn.Close()
+	}
+
+	session, err := d.pingedConn(ctx)
+	if err != nil {
+		return err
+	}
+	if err := d.prepareSession(ctx, session); err != nil {
+		d.closeSession(session)
+		return err
+	}
+	d.closeSession(session)
+
+	return nil
+}
+
+// pingedConn reserves a connection of the pool that answered a ping. Connections that fail the ping are discarded.
+func (d *driver) pingedConn(ctx context.Context) (*sql.Conn, error) {
+	var err error
+	for attempt := 0; attempt < warmupAttempts && ctx.Err() == nil; attempt++ {
+		var conn *sql.Conn
+		if conn, err = d.client.Conn(ctx); err != nil {
+			break
+		}
+		if err = conn.PingContext(ctx); err == nil {
+			return conn, nil
+		}
+		_ = conn.Close()
+		d.logf("discarded stale connection during warm-up: %v", err)
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+
+	return nil, &lightmigrate.DriverError{OrigErr: err, Msg: "connection warm-up failed"}
+}
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	sqldriver "database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithConnectionWarmup(t *testing.T) {
+	d := &driver{cfg: &config{}}
+
+	WithConnectionWarmup(true)(d)
+	if !d.cfg.ConnectionWarmup {
+		t.Fatalf("failed to set connection warm-up")
+	}
+}
+
+func Test_driver_Lock_Warmup(t *testing.T) {
+	var mux sync.Mutex
+	stale := 1
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		mux.Lock()
+		defer mux.Unlock()
+		if call.Query == "PING" && stale > 0 {
+			stale--
+			return fakeResponse{Err: sqldriver.ErrBadConn}
+		}
+		return defaultFakeHandler(call)
+	})
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true, ConnectionWarmup: true,
+		InnoDBLockWaitTimeout: 5 * time.Second}}
+
+	if err := d.Lock(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var pings, setups int
+	sessions := make(map[int]struct{})
+	for _, call := range srv.Calls() {
+		switch {
+		case call.Query == "PING":
+			pings++
+			sessions[call.ConnID] = struct{}{}
+		case strings.HasPrefix(call.Query, "SET SESSION innodb_lock_wait_timeout = 5"):
+			setups++
+		}
+	}
+	if pings != 3 || len(sessions) != 3 || setups != 1 {
+		t.Fatalf("unexpected warm-up (pings %d, connections %d, setups %d): %q", pings, len(sessions), setups, srv.Queries())
+	}
+}
+
+func Test_driver_Lock_WarmupFailed(t *testing.T) {
+	errRefused := errors.New("connection refused")
+	db, srv := newFakeDB(t, func(call fakeCall) fakeResponse {
+		if call.Query == "PING" {
+			return fakeResponse{Err: errRefused}
+		}
+		return defaultFakeHandler(call)
+	})
+	d := &driver{client: db, cfg: &config{DatabaseName: "testdb", Locking: true, ConnectionWarmup: true}}
+
+	if err := d.Lock(); !errors.Is(err, errRefused) {
+		t.Fatalf("expected error %v, got: %v", errRefused, err)
+	}
+	for _, query := range srv.Queries() {
+		if strings.HasPrefix(query, "SELECT GET_LOCK") {
+			t.Fatalf("lock must not be acquired after a failed warm-up")
+		}
+	}
+}
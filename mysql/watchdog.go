@@ -0,0 +1,119 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// watchdogCaptureTimeout limits the diagnostic queries of the statement watchdog.
+const watchdogCaptureTimeout = 5 * time.Second
+
+// watchdogQueries are the diagnostic queries of the statement watchdog. Queries that are not supported by the
+// server (e.g. the sys schema of MariaDB) are skipped.
+var watchdogQueries = []struct {
+	Name  string
+	Query string
+}{
+	{"processlist", "SELECT id, user, host, db, command, time, state, LEFT(info, 512) FROM information_schema.processlist " +
+		"WHERE command <> 'Sleep' AND id <> CONNECTION_ID() ORDER BY time DESC LIMIT 50"},
+	{"innodb transactions", "SELECT trx_id, trx_state, trx_started, trx_mysql_thread_id, trx_rows_locked, " +
+		"LEFT(trx_query, 512) FROM information_schema.innodb_trx ORDER BY trx_started LIMIT 50"},
+	{"lock waits", "SELECT waiting_pid, LEFT(waiting_query, 512), blocking_pid, LEFT(blocking_query, 512), " +
+		"wait_age FROM sys.innodb_lock_waits LIMIT 50"},
+}
+
+// WithStatementWatchdog logs diagnostics if a single statement runs longer than the threshold: the running
+// server threads (processlist), the open InnoDB transactions and, if available, which threads block the others.
+// The diagnostics are repeated every threshold until the statement finished. Zero disables the watchdog.
+func WithStatementWatchdog(threshold time.Duration) DriverOption {
+	return func(d *driver) {
+		d.cfg.StatementWatchdog = threshold
+	}
+}
+
+// watchStatement starts the watchdog for the statement. The returned function stops the watchdog, it waits
+// for running diagnostics.
+func (d *driver) watchStatement(stmt statement) (stop func()) {
+	threshold := d.cfg.StatementWatchdog
+	if threshold <= 0 {
+		return func() {}
+	}
+
+	started := time.Now()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		timer := time.NewTimer(threshold)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-timer.C:
+				d.captureBlockers(stmt, time.Since(started))
+				timer.Reset(threshold)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// captureBlockers logs the diagnostics of a long-running statement.
+func (d *driver) captureBlockers(stmt statement, running time.Duration) {
+	d.logf("statement %d (line %d) of migration %d is running for %s: %s", stmt.Index, stmt.Line, d.runningVersion,
+		running.Round(time.Second), truncateMessage(stmt.Query, 512))
+
+	ctx, cancel := context.WithTimeout(context.Background(), watchdogCaptureTimeout)
+	defer cancel()
+
+	for _, diagnostic := range watchdogQueries {
+		rows, err := d.client.QueryContext(ctx, diagnostic.Query)
+		if err != nil {
+			if d.verbose {
+				d.logf("watchdog: failed to read %s: %v", diagnostic.Name, err)
+			}
+			continue
+		}
+		lines, err := formatRows(rows)
+		if err != nil && d.verbose {
+			d.logf("watchdog: failed to read %s: %v", diagnostic.Name, err)
+		}
+		for _, line := range lines {
+			d.logf("watchdog: %s: %s", diagnostic.Name, line)
+		}
+	}
+}
+
+// formatRows formats each row as "column=value" pairs, see formatPlanRow. The rows are closed.
+func formatRows(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	values := make([]sql.RawBytes, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return lines, err
+		}
+		lines = append(lines, formatPlanRow(columns, values))
+	}
+
+	return lines, rows.Err()
+}
@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"bytes"
+	sqldriver "database/sql/driver"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_driver_RunMigration_Watchdog(t *testing.T) {
+	db, _ := newFakeDB(t, func(call fakeCall) fakeResponse {
+		switch {
+		case strings.HasPrefix(call.Query, "ALTER TABLE users"):
+			time.Sleep(150 * time.Millisecond)
+		case strings.Contains(call.Query, "FROM information_schema.processlist"):
+			return fakeResponse{
+				Columns: []string{"id", "user", "host", "db", "command", "time", "state", "info"},
+				Rows: [][]sqldriver.Value{
+					{int64(42), "app", "10.0.0.1", "testdb", "Query", int64(3), "Waiting for table metadata lock", "ALTER TABLE users"},
+				},
+			}
+		}
+		return fakeResponse{}
+	})
+	logs := &bytes.Buffer{}
+	d := &driver{client: db, logger: log.New(logs, "", 0), runningVersion: 2,
+		cfg: &config{SplitStatements: true, StatementWatchdog: 50 * time.Millisecond}}
+
+	if err := d.RunMigration(strings.NewReader("SELECT 1;\nALTER TABLE users ADD x int;")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	output := logs.String()
+	if !strings.Contains(output, "statement 2 (line 2) of migration 2 is running for") ||
+		!strings.Contains(output, "watchdog: processlist: id=42") ||
+		!strings.Contains(output, "state=Waiting for table metadata lock") {
+		t.Fatalf("unexpected watchdog logs: %s", output)
+	}
+	if strings.Contains(output, "statement 1 ") {
+		t.Fatalf("fast statements must not be reported: %s", output)
+	}
+}